@@ -0,0 +1,320 @@
+// Package backup copies kanban databases with the SQLite online backup API,
+// keeps rotated snapshots next to the database and restores them.
+//
+// Copies are consistent while other connections, such as a running GUI,
+// read and write the database.
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"tcl-tk-kanban/store"
+)
+
+// Reasons of the automatic snapshots, part of their file names.
+const (
+	ReasonMigration = "pre-migration"
+	ReasonDelete    = "pre-delete"
+	ReasonRestore   = "pre-restore"
+	ReasonRepair    = "pre-repair"
+)
+
+// ErrNewerSchema is returned when restoring a backup written by a newer
+// version of the programs.
+var ErrNewerSchema = errors.New("backup has a newer schema version")
+
+// pagesPerStep is the number of pages copied while holding the database
+// lock; other connections can write between steps.
+const pagesPerStep = 256
+
+// busyTimeout is how long a copy waits for locks held by other
+// connections.
+const busyTimeout = time.Minute
+
+// timeLayout is the time in the snapshot file names.
+const timeLayout = "20060102-150405.000"
+
+// Copy writes a consistent copy of db to dest, replacing dest. The copy is
+// written to a temporary file first so that dest is never left partial.
+func Copy(db *sql.DB, dest string) error {
+	tmp := dest + ".tmp"
+	os.Remove(tmp)
+	destDB, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return fmt.Errorf("back up to %s: %w", dest, err)
+	}
+	err = copyDB(destDB, db)
+	if err == nil {
+		// The copy takes the WAL mode of the database; a backup is a
+		// single file
+		_, err = destDB.Exec("PRAGMA journal_mode = DELETE")
+	}
+	if cerr := destDB.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dest)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("back up to %s: %w", dest, err)
+	}
+	return nil
+}
+
+// copyDB copies the main database of src over the one of dest.
+func copyDB(dest, src *sql.DB) error {
+	ctx := context.Background()
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(d any) error {
+		return srcConn.Raw(func(s any) error {
+			destSQLite, ok1 := d.(*sqlite3.SQLiteConn)
+			srcSQLite, ok2 := s.(*sqlite3.SQLiteConn)
+			if !ok1 || !ok2 {
+				return errors.New("not a SQLite database")
+			}
+			b, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			deadline := time.Now().Add(busyTimeout)
+			for {
+				remaining := b.Remaining()
+				done, err := b.Step(pagesPerStep)
+				if err != nil {
+					b.Finish()
+					return err
+				}
+				if done {
+					return b.Finish()
+				}
+				if b.Remaining() == remaining && remaining != 0 {
+					// Busy or locked by another connection
+					if time.Now().After(deadline) {
+						b.Finish()
+						return errors.New("database is busy")
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+		})
+	})
+}
+
+// Dir returns the directory of the snapshots of a database: "backups" next
+// to it.
+func Dir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "backups")
+}
+
+// Snapshot is a backup file written by Take.
+type Snapshot struct {
+	Path   string
+	Time   time.Time
+	Reason string // "" for backups taken by hand
+}
+
+// stem returns the file name of a database without extension, which
+// starts the names of its snapshots.
+func stem(dbPath string) string {
+	base := filepath.Base(dbPath)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Take writes a snapshot of the database at dbPath, open as db, to
+// Dir(dbPath), named "<name>-<time>[-<reason>].db", and rotates the
+// snapshots.
+func Take(db *sql.DB, dbPath, reason string, now time.Time, r Rotation) (Snapshot, error) {
+	dir := Dir(dbPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Snapshot{}, fmt.Errorf("back up: %w", err)
+	}
+	name := stem(dbPath) + "-" + now.Format(timeLayout)
+	if reason != "" {
+		name += "-" + reason
+	}
+	s := Snapshot{Path: filepath.Join(dir, name+".db"), Time: now, Reason: reason}
+	if err := Copy(db, s.Path); err != nil {
+		return Snapshot{}, err
+	}
+	if _, err := Rotate(dbPath, r, now); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// List returns the snapshots of a database, newest first.
+func List(dbPath string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(Dir(dbPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+	prefix := stem(dbPath) + "-"
+	var snapshots []Snapshot
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || filepath.Ext(name) != ".db" {
+			continue
+		}
+		rest := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".db")
+		if len(rest) < len(timeLayout) {
+			continue
+		}
+		t, err := time.ParseInLocation(timeLayout, rest[:len(timeLayout)], time.Local)
+		if err != nil {
+			continue
+		}
+		s := Snapshot{Path: filepath.Join(Dir(dbPath), name), Time: t}
+		if reason := rest[len(timeLayout):]; reason != "" {
+			if reason[0] != '-' {
+				continue
+			}
+			s.Reason = reason[1:]
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Time.After(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+// Rotation says which snapshots to keep; the others are deleted. A
+// snapshot is kept if any rule keeps it.
+type Rotation struct {
+	Last   int // the newest snapshots
+	Daily  int // the newest snapshot of each of the last days
+	Weekly int // the newest snapshot of each of the last ISO weeks
+}
+
+// DefaultRotation keeps the last 10 snapshots, one a day for a week and
+// one a week for 4 weeks.
+var DefaultRotation = Rotation{Last: 10, Daily: 7, Weekly: 4}
+
+// Rotate deletes the snapshots of a database not kept by r and returns
+// their paths. Days and weeks are counted back from now.
+func Rotate(dbPath string, r Rotation, now time.Time) ([]string, error) {
+	snapshots, err := List(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	today := day(now)
+	thisYear, thisWeek := now.ISOWeek()
+	days := make(map[time.Time]bool)
+	weeks := make(map[[2]int]bool)
+	var removed []string
+	for i, s := range snapshots {
+		keep := i < r.Last
+		if d := day(s.Time); !days[d] && today.Sub(d) < time.Duration(r.Daily)*24*time.Hour {
+			days[d] = true
+			keep = true
+		}
+		year, week := s.Time.ISOWeek()
+		if w := [2]int{year, week}; !weeks[w] && weeksBetween(year, week, thisYear, thisWeek) < r.Weekly {
+			weeks[w] = true
+			keep = true
+		}
+		if keep {
+			continue
+		}
+		if err := os.Remove(s.Path); err != nil {
+			return removed, fmt.Errorf("rotate backups: %w", err)
+		}
+		removed = append(removed, s.Path)
+	}
+	return removed, nil
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// weeksBetween returns the number of weeks from one ISO week to a later
+// one.
+func weeksBetween(year1, week1, year2, week2 int) int {
+	monday := func(year, week int) time.Time {
+		// January 4 is always in week 1
+		jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)
+		offset := (int(jan4.Weekday()) + 6) % 7
+		return jan4.AddDate(0, 0, (week-1)*7-offset)
+	}
+	return int(monday(year2, week2).Sub(monday(year1, week1)).Hours()) / (24 * 7)
+}
+
+// Check verifies that a file is an intact kanban database that this
+// version of the programs can open, and returns its schema version.
+func Check(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, fmt.Errorf("check %s: %w", path, err)
+	}
+	db, err := store.OpenReadOnly(path)
+	if err != nil {
+		return 0, fmt.Errorf("check: %w", err)
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return 0, fmt.Errorf("check %s: %w", path, err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("check %s: integrity check failed: %s", path, result)
+	}
+	var tables int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'
+		AND name IN ('boards', 'swimlanes', 'lists', 'cards')`).Scan(&tables)
+	if err != nil {
+		return 0, fmt.Errorf("check %s: %w", path, err)
+	}
+	if tables != 4 {
+		return 0, fmt.Errorf("check %s: not a kanban database", path)
+	}
+	version, err := store.LoadSchemaVersion(db)
+	if err != nil {
+		return 0, fmt.Errorf("check %s: %w", path, err)
+	}
+	if version > store.SchemaVersion {
+		return version, fmt.Errorf("check %s: %w: %d, this version supports up to %d",
+			path, ErrNewerSchema, version, store.SchemaVersion)
+	}
+	return version, nil
+}
+
+// Restore replaces the content of db with the backup at src after checking
+// it. Other connections to db see the restored content. A backup of an
+// older schema is migrated when the programs open it next.
+func Restore(db *sql.DB, src string) error {
+	if _, err := Check(src); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	srcDB, err := store.OpenReadOnly(src)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	defer srcDB.Close()
+	if err := copyDB(db, srcDB); err != nil {
+		return fmt.Errorf("restore %s: %w", src, err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package backup
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"tcl-tk-kanban/store"
+)
+
+const testSchema = `
+	CREATE TABLE boards (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL);
+	CREATE TABLE swimlanes (id INTEGER PRIMARY KEY AUTOINCREMENT, board_id INTEGER NOT NULL, name TEXT NOT NULL);
+	CREATE TABLE lists (id INTEGER PRIMARY KEY AUTOINCREMENT, swimlane_id INTEGER NOT NULL, name TEXT NOT NULL);
+	CREATE TABLE cards (id INTEGER PRIMARY KEY AUTOINCREMENT, list_id INTEGER NOT NULL, title TEXT NOT NULL);
+	INSERT INTO boards (name) VALUES ('Board');
+`
+
+func openTestDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func createTestDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "wekan.db")
+	db := openTestDB(t, path)
+	if _, err := db.Exec(testSchema); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetSchemaVersion(db, store.SchemaVersion); err != nil {
+		t.Fatal(err)
+	}
+	return db, path
+}
+
+func boardNames(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT name FROM boards ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestCopyWhileWriting(t *testing.T) {
+	db, path := createTestDB(t)
+
+	// Another connection, as the GUI would be, is in a write transaction
+	other := openTestDB(t, path)
+	tx, err := other.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO boards (name) VALUES ('Uncommitted')"); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "copy.db")
+	if err := Copy(db, dest); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Check(dest); err != nil || v != store.SchemaVersion {
+		t.Errorf("Check(copy) = %d, %v", v, err)
+	}
+	// The database is in WAL mode, the copy is a single file
+	if _, err := os.Stat(dest + "-wal"); err == nil {
+		t.Error("copy in WAL mode")
+	}
+	if got := boardNames(t, openTestDB(t, dest)); !reflect.DeepEqual(got, []string{"Board"}) {
+		t.Errorf("copied boards %q", got)
+	}
+}
+
+func TestTakeAndRotate(t *testing.T) {
+	db, path := createTestDB(t)
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)
+
+	// One snapshot a day for 40 days, two on the last day
+	var times []time.Time
+	for i := 40; i >= 0; i-- {
+		times = append(times, now.AddDate(0, 0, -i))
+	}
+	times = append(times, now.Add(time.Minute))
+	none := Rotation{Last: 1000, Daily: 1000, Weekly: 1000}
+	for _, tm := range times {
+		if _, err := Take(db, path, "", tm, none); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := Take(db, path, ReasonDelete, now.Add(2*time.Minute), none)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(Dir(path), "wekan-20250310-120200.000-pre-delete.db"); s.Path != want {
+		t.Errorf("snapshot %s, want %s", s.Path, want)
+	}
+
+	snapshots, err := List(path)
+	if err != nil || len(snapshots) != 43 {
+		t.Fatalf("%d snapshots, %v", len(snapshots), err)
+	}
+	if snapshots[0].Reason != ReasonDelete || !snapshots[0].Time.Equal(s.Time) {
+		t.Errorf("newest snapshot %+v", snapshots[0])
+	}
+
+	removed, err := Rotate(path, Rotation{Last: 2, Daily: 3, Weekly: 3}, now.Add(3*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, _ = List(path)
+	var kept []string
+	for _, s := range snapshots {
+		kept = append(kept, s.Time.Format("01-02 15:04"))
+	}
+	// The last 2; the newest of March 10, 9 and 8; the newest of the weeks
+	// of March 10 (a Monday), March 3 and February 24
+	want := []string{"03-10 12:02", "03-10 12:01", "03-09 12:00", "03-08 12:00", "03-02 12:00"}
+	if !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept %q, want %q", kept, want)
+	}
+	if len(removed)+len(kept) != 43 {
+		t.Errorf("removed %d", len(removed))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	db, path := createTestDB(t)
+	store.SetSchemaVersion(db, store.SchemaVersion+1)
+	if _, err := Check(path); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("newer schema: %v, want ErrNewerSchema", err)
+	}
+
+	other := filepath.Join(t.TempDir(), "other.db")
+	openTestDB(t, other).Exec("CREATE TABLE notes (id INTEGER)")
+	if _, err := Check(other); err == nil {
+		t.Error("checked a database without boards")
+	}
+	if _, err := Check(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("checked a missing file")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	db, path := createTestDB(t)
+	s, err := Take(db, path, "", time.Now(), DefaultRotation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gui := openTestDB(t, path)
+	if _, err := db.Exec("DELETE FROM boards; INSERT INTO boards (name) VALUES ('Later')"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(db, s.Path); err != nil {
+		t.Fatal(err)
+	}
+	if got := boardNames(t, gui); !reflect.DeepEqual(got, []string{"Board"}) {
+		t.Errorf("boards after restore %q", got)
+	}
+
+	store.SetSchemaVersion(openTestDB(t, s.Path), store.SchemaVersion+1)
+	if err := Restore(db, s.Path); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("restoring a newer schema: %v", err)
+	}
+}
@@ -10,6 +10,11 @@ RED='\033[0;31m'
 YELLOW='\033[1;33m'
 NC='\033[0m' # No Color
 
+# Build tags for every Go binary: the full-text search index on cards uses
+# SQLite's FTS5 module, which go-sqlite3 only includes with this tag. A
+# binary built without it cannot write cards once the index exists.
+GO_TAGS="sqlite_fts5"
+
 # Script directory
 SCRIPT_DIR="$( cd "$( dirname "${BASH_SOURCE[0]}" )" && pwd )"
 cd "$SCRIPT_DIR"
@@ -32,16 +37,17 @@ show_menu() {
     echo "2) Build TclKit"
     echo "3) Build TclKit .kit file (Simple build)"
     echo "4) Build Go XLSX exporter (binary)"
-    echo "5) Build Go XLSX exporter as .so and embed in .kit"
+    echo "5) Build kanbango Tcl package (Go .so) and embed in .kit"
     echo "6) Build executable (macOS app bundle)"
     echo "7) Run Kanban application (Tcl interpreter)"
     echo "8) Run .kit file (if built)"
     echo "9) Clean build artifacts"
     echo "10) Build Go GUI executable"
     echo "11) Run Go GUI executable"
-    echo "12) Exit"
+    echo "12) Build and run C API and Tcl package tests"
+    echo "13) Exit"
     echo ""
-    echo -n "Enter your choice [1-12]: "
+    echo -n "Enter your choice [1-13]: "
 }
 
 # Check if tclsh is available
@@ -210,7 +216,8 @@ build_kit() {
 clean_build() {
     echo -e "${BLUE}Cleaning build artifacts...${NC}"
     
-    rm -rf kanban.kit kanban.vfs xlsx_exporter xlsx_exporter_embed.so
+    rm -rf kanban.kit kanban.vfs xlsx_exporter xlsx_exporter_embed.so xlsx_exporter_embed.h kanban_api_test
+    rm -f kanbango/kanbango.so kanbango/kanbango.h
     
     echo -e "${GREEN}✓ Build artifacts cleaned${NC}"
 }
@@ -229,7 +236,7 @@ build_go_binary() {
         return 1
     fi
     
-    go build -o xlsx_exporter xlsx.go
+    go build -tags "$GO_TAGS" -o xlsx_exporter xlsx.go
     
     if [ $? -eq 0 ]; then
         echo -e "${GREEN}✓ Go binary built: xlsx_exporter${NC}"
@@ -240,31 +247,32 @@ build_go_binary() {
     fi
 }
 
-# Build Go XLSX exporter as .so and embed in .kit
+# Build the kanbango Tcl package (Go .so) and embed it in .kit
 build_go_so_embed() {
-    echo -e "${BLUE}Building Go XLSX exporter as .so and embedding in .kit...${NC}"
+    echo -e "${BLUE}Building kanbango Tcl package and embedding in .kit...${NC}"
     
     if ! command -v go &> /dev/null; then
         echo -e "${RED}Error: Go is not installed or not in PATH${NC}"
         return 1
     fi
     
-    if [ ! -f "xlsx_exporter_embed.go" ]; then
-        echo -e "${RED}Error: xlsx_exporter_embed.go not found!${NC}"
+    if [ ! -f "kanbango/kanbango.go" ]; then
+        echo -e "${RED}Error: kanbango/kanbango.go not found!${NC}"
         return 1
     fi
     
-    go build -buildmode=c-shared -o xlsx_exporter_embed.so xlsx_exporter_embed.go
+    go build -tags "$GO_TAGS" -buildmode=c-shared -o kanbango/kanbango.so ./kanbango
     
     if [ $? -ne 0 ]; then
-        echo -e "${RED}Error building Go .so${NC}"
+        echo -e "${RED}Error building kanbango .so (Tcl 8.6 headers and libtclstub8.6 are required)${NC}"
         return 1
     fi
+    echo -e "${GREEN}✓ Tcl package built: kanbango/kanbango.so${NC}"
     
-    # Create VFS with .so embedded
-    mkdir -p kanban.vfs/lib/app-kanban
+    # Create VFS with the package embedded; starkit::startup puts lib/ on auto_path
+    mkdir -p kanban.vfs/lib/app-kanban kanban.vfs/lib/kanbango
     cp kanban.tcl kanban.vfs/lib/app-kanban/
-    cp xlsx_exporter_embed.so kanban.vfs/lib/app-kanban/
+    cp kanbango/kanbango.so kanbango/pkgIndex.tcl kanban.vfs/lib/kanbango/
     
     # Create main.tcl wrapper
     cat > kanban.vfs/main.tcl << 'EOF'
@@ -382,7 +390,7 @@ build_go_gui() {
         return 1
     fi
     
-    go build -o kanban_go kanban.go
+    go build -tags "$GO_TAGS" -o kanban_go kanban.go
     
     if [ $? -eq 0 ]; then
         echo -e "${GREEN}✓ Go GUI executable built: kanban_go${NC}"
@@ -407,6 +415,40 @@ run_go_gui() {
     ./kanban_go
 }
 
+# Build the Go shared libraries and run the C API and Tcl package tests
+run_c_api_tests() {
+    echo -e "${BLUE}Building and running C API and Tcl package tests...${NC}"
+    
+    if ! command -v go &> /dev/null; then
+        echo -e "${RED}Error: Go is not installed or not in PATH${NC}"
+        return 1
+    fi
+    
+    if ! command -v cc &> /dev/null; then
+        echo -e "${RED}Error: C compiler (cc) is not installed or not in PATH${NC}"
+        return 1
+    fi
+    
+    go build -tags "$GO_TAGS" -buildmode=c-shared -o xlsx_exporter_embed.so xlsx_exporter_embed.go || return 1
+    cc -I. -o kanban_api_test ctest/kanban_api_test.c ./xlsx_exporter_embed.so -Wl,-rpath,"$SCRIPT_DIR" || return 1
+    
+    if ./kanban_api_test; then
+        echo -e "${GREEN}✓ C API tests passed${NC}"
+    else
+        echo -e "${RED}C API tests failed${NC}"
+        return 1
+    fi
+    
+    go build -tags "$GO_TAGS" -buildmode=c-shared -o kanbango/kanbango.so ./kanbango || return 1
+    if tclsh kanbango/kanbango_test.tcl | tee /dev/stderr | grep -q "Failed	0"; then
+        echo -e "${GREEN}✓ Tcl package tests passed${NC}"
+        return 0
+    else
+        echo -e "${RED}Tcl package tests failed${NC}"
+        return 1
+    fi
+}
+
 # --- Main Script ---
 
 show_banner
@@ -449,11 +491,14 @@ while true; do
             run_go_gui
             ;;
         12)
+            run_c_api_tests
+            ;;
+        13)
             echo -e "${GREEN}Exiting...${NC}"
             exit 0
             ;;
         *)
-            echo -e "${RED}Invalid option. Please choose 1-12.${NC}"
+            echo -e "${RED}Invalid option. Please choose 1-13.${NC}"
             ;;
     esac
     echo ""
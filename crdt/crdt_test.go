@@ -0,0 +1,362 @@
+package crdt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+	"testing/quick"
+)
+
+var replicas = []string{"a", "b", "c"}
+
+func TestTextEdit(t *testing.T) {
+	var text Text
+	text.Insert("a", 0, "Hello")
+	text.Insert("a", 5, " world")
+	text.Delete(0, 1)
+	text.Insert("a", 0, "J")
+	if got := text.String(); got != "Jello world" {
+		t.Errorf("%q", got)
+	}
+	text.Edit("a", "Jelly world!")
+	if got := text.String(); got != "Jelly world!" {
+		t.Errorf("after Edit: %q", got)
+	}
+	if ops := text.Edit("a", "Jelly world!"); len(ops) != 0 {
+		t.Errorf("unchanged text: %d operations", len(ops))
+	}
+}
+
+func TestTextConcurrentEdits(t *testing.T) {
+	var base Text
+	base.Insert("a", 0, "Buy milk")
+	a, b := copyText(t, &base), copyText(t, &base)
+
+	// Edited offline on two laptops
+	a.Edit("a", "Buy milk and eggs")
+	opsB := b.Edit("b", "Todo: Buy oat milk")
+	a.Apply(opsB...)
+	b.Merge(a)
+
+	const want = "Todo: Buy oat milk and eggs"
+	if a.String() != want || b.String() != want {
+		t.Errorf("%q and %q, want %q", a, b, want)
+	}
+}
+
+func copyText(t *testing.T, text *Text) *Text {
+	t.Helper()
+	data, err := json.Marshal(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Text
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return &out
+}
+
+// randomText makes random edits to the replicas of a text, each on its
+// own as if offline, and returns the operations of each replica.
+func randomText(rng *rand.Rand, texts []*Text) [][]TextOp {
+	ops := make([][]TextOp, len(texts))
+	for i, text := range texts {
+		for n := rng.Intn(30); n > 0; n-- {
+			length := len([]rune(text.String()))
+			switch {
+			case length > 0 && rng.Intn(3) == 0:
+				ops[i] = append(ops[i], text.Delete(rng.Intn(length), 1+rng.Intn(3))...)
+			default:
+				s := string(rune('a'+rng.Intn(26))) + string(rune('A'+i))
+				ops[i] = append(ops[i], text.Insert(replicas[i], rng.Intn(length+1), s)...)
+			}
+		}
+	}
+	return ops
+}
+
+// deliver returns the operations of the other replicas, shuffled and
+// some twice, as an unreliable network would deliver them.
+func deliver[Op any](rng *rand.Rand, ops [][]Op, to int) []Op {
+	var out []Op
+	for i, list := range ops {
+		if i == to {
+			continue
+		}
+		for _, op := range list {
+			out = append(out, op)
+			if rng.Intn(5) == 0 {
+				out = append(out, op)
+			}
+		}
+	}
+	rng.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	return out
+}
+
+// order returns the characters of ids that text shows, in order.
+func order(text *Text, ids map[ID]bool) []ID {
+	var out []ID
+	for _, id := range text.visible() {
+		if ids[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func TestTextConverges(t *testing.T) {
+	property := func(seed int64) bool {
+		rng := rand.New(rand.NewSource(seed))
+		var base Text
+		base.Insert("base", 0, "shared text")
+		texts := make([]*Text, len(replicas))
+		for i := range texts {
+			texts[i] = copyText(t, &base)
+		}
+		ops := randomText(rng, texts)
+
+		// What each replica shows of its own characters before syncing
+		deleted := make(map[ID]bool)
+		own := make([]map[ID]bool, len(texts))
+		for i := range ops {
+			own[i] = make(map[ID]bool)
+			for _, op := range ops[i] {
+				if op.Delete {
+					deleted[op.ID] = true
+				} else {
+					own[i][op.ID] = true
+				}
+			}
+		}
+		local := make([][]ID, len(texts))
+		for i, text := range texts {
+			local[i] = order(text, own[i])
+		}
+		// The state of a replica before syncing, for merging
+		states := make([]*Text, len(texts))
+		for i, text := range texts {
+			states[i] = copyText(t, text)
+		}
+
+		for i, text := range texts {
+			text.Apply(deliver(rng, ops, i)...)
+		}
+		want := texts[0].String()
+		for i, text := range texts {
+			if text.String() != want {
+				t.Logf("seed %d: replica %d %q, replica 0 %q", seed, i, text.String(), want)
+				return false
+			}
+			if len(text.pending) != 0 {
+				t.Logf("seed %d: replica %d has %d pending operations", seed, i, len(text.pending))
+				return false
+			}
+			// Merging converges to the same text, in any order
+			merged := copyText(t, states[(i+1)%len(states)])
+			for _, j := range rng.Perm(len(states)) {
+				merged.Merge(states[j])
+			}
+			if merged.String() != want {
+				t.Logf("seed %d: merged %q, want %q", seed, merged.String(), want)
+				return false
+			}
+		}
+		for i := range texts {
+			// No text is lost and the order typed is kept
+			var survived []ID
+			for _, id := range local[i] {
+				if !deleted[id] {
+					survived = append(survived, id)
+				}
+			}
+			if got := order(texts[0], own[i]); !slices.Equal(got, survived) {
+				t.Logf("seed %d: characters of replica %d %v, want %v", seed, i, got, survived)
+				return false
+			}
+		}
+		// Equal states encode equally
+		a, _ := json.Marshal(texts[0])
+		b, _ := json.Marshal(texts[1])
+		return string(a) == string(b)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListSetOrder(t *testing.T) {
+	property := func(seed int64) bool {
+		rng := rand.New(rand.NewSource(seed))
+		var list List
+		var items []string
+		for i := rng.Intn(12); i >= 0; i-- {
+			items = append(items, fmt.Sprint("card", i))
+		}
+		list.SetOrder("a", items)
+		for round := 0; round < 5; round++ {
+			target := slices.Clone(items)
+			rng.Shuffle(len(target), func(i, j int) { target[i], target[j] = target[j], target[i] })
+			target = target[:rng.Intn(len(target)+1)]
+			before := list.Items()
+			list.SetOrder("a", target)
+			if got := list.Items(); !slices.Equal(got, target) && !(len(got) == 0 && len(target) == 0) {
+				t.Logf("seed %d: %v, want %v", seed, got, target)
+				return false
+			}
+			// Moving one item is one operation
+			if len(target) == len(before) && len(target) > 1 {
+				moved := slices.Clone(target)
+				item := moved[0]
+				moved = append(moved[1:], item)
+				if ops := list.SetOrder("a", moved); len(ops) != 1 {
+					t.Logf("seed %d: %d operations to move one item", seed, len(ops))
+					return false
+				}
+				list.SetOrder("a", target)
+			}
+			list.SetOrder("a", items)
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListConcurrentMoves(t *testing.T) {
+	var base List
+	base.SetOrder("base", []string{"1", "2", "3", "4"})
+	a, b := copyList(t, &base), copyList(t, &base)
+
+	opA := a.Move("a", "4", 0) // a moves card 4 to the top
+	opB := b.Move("b", "1", 3) // b moves card 1 to the bottom
+	a.Apply(opB)
+	b.Apply(opA)
+	want := []string{"4", "2", "3", "1"}
+	if !reflect.DeepEqual(a.Items(), want) || !reflect.DeepEqual(b.Items(), want) {
+		t.Errorf("%v and %v, want %v", a.Items(), b.Items(), want)
+	}
+
+	// Both move card 2; the later move wins and the card is in one place
+	opA = a.Move("a", "2", 0)
+	opB = b.Move("b", "2", 3)
+	a.Apply(opB)
+	b.Apply(opA)
+	want = []string{"4", "3", "1", "2"}
+	if !reflect.DeepEqual(a.Items(), want) || !reflect.DeepEqual(b.Items(), want) {
+		t.Errorf("%v and %v, want %v", a.Items(), b.Items(), want)
+	}
+}
+
+func copyList(t *testing.T, list *List) *List {
+	t.Helper()
+	data, err := json.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out List
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return &out
+}
+
+func TestListConverges(t *testing.T) {
+	property := func(seed int64) bool {
+		rng := rand.New(rand.NewSource(seed))
+		var base List
+		var items []string
+		for i := 0; i < 8; i++ {
+			items = append(items, fmt.Sprint("card", i))
+		}
+		base.SetOrder("base", items)
+		lists := make([]*List, len(replicas))
+		for i := range lists {
+			lists[i] = copyList(t, &base)
+		}
+		ops := make([][]ListOp, len(lists))
+		for i, list := range lists {
+			for n := rng.Intn(15); n > 0; n-- {
+				item := fmt.Sprint("card", rng.Intn(10))
+				if rng.Intn(5) == 0 {
+					ops[i] = append(ops[i], list.Remove(replicas[i], item))
+				} else {
+					ops[i] = append(ops[i], list.Move(replicas[i], item, rng.Intn(len(list.Items())+1)))
+				}
+			}
+		}
+		states := make([]*List, len(lists))
+		for i, list := range lists {
+			states[i] = copyList(t, list)
+		}
+
+		// The newest operation of an item decides whether it is listed
+		newest := make(map[string]ListOp)
+		for _, list := range ops {
+			for _, op := range list {
+				if old, ok := newest[op.Item]; !ok || old.ID.Less(op.ID) {
+					newest[op.Item] = op
+				}
+			}
+		}
+		listed := make(map[string]bool)
+		for _, item := range base.Items() {
+			listed[item] = true
+		}
+		for item, op := range newest {
+			listed[item] = !op.Remove
+		}
+
+		for i, list := range lists {
+			list.Apply(deliver(rng, ops, i)...)
+		}
+		want := lists[0].Items()
+		seen := make(map[string]bool)
+		for _, item := range want {
+			if seen[item] || !listed[item] {
+				t.Logf("seed %d: %s listed wrongly in %v", seed, item, want)
+				return false
+			}
+			seen[item] = true
+		}
+		if len(seen) != countTrue(listed) {
+			t.Logf("seed %d: %v misses items of %v", seed, want, listed)
+			return false
+		}
+		for i, list := range lists {
+			if !slices.Equal(list.Items(), want) {
+				t.Logf("seed %d: replica %d %v, replica 0 %v", seed, i, list.Items(), want)
+				return false
+			}
+			merged := copyList(t, states[(i+1)%len(states)])
+			for _, j := range rng.Perm(len(states)) {
+				merged.Merge(states[j])
+			}
+			if !slices.Equal(merged.Items(), want) {
+				t.Logf("seed %d: merged %v, want %v", seed, merged.Items(), want)
+				return false
+			}
+		}
+		a, _ := json.Marshal(lists[0])
+		b, _ := json.Marshal(lists[2])
+		return string(a) == string(b)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
+func countTrue(m map[string]bool) int {
+	n := 0
+	for _, v := range m {
+		if v {
+			n++
+		}
+	}
+	return n
+}
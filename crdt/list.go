@@ -0,0 +1,227 @@
+package crdt
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// List is a replicated order of distinct items, such as the cards of a
+// list. The zero value is an empty list.
+//
+// Moving an item inserts a new slot for it in the sequence and points the
+// item's register at it; the register with the newest stamp wins, so an
+// item moved on two replicas at once ends up in one place, not two.
+type List struct {
+	slots     sequence
+	registers map[string]register
+	// pending are the operations waiting for their slot
+	pending []ListOp
+}
+
+// register is the slot of an item; a zero slot if it was removed.
+type register struct {
+	Slot  ID `json:"slot"`
+	Stamp ID `json:"stamp"`
+}
+
+// ListOp moves an item to a new slot after another slot, or removes it.
+type ListOp struct {
+	// ID stamps the operation and is the new slot of a move.
+	ID ID `json:"id"`
+	// After is the slot inserted after, zero for the start.
+	After  ID     `json:"after"`
+	Item   string `json:"item"`
+	Remove bool   `json:"remove,omitempty"`
+}
+
+// Items returns the items in order.
+func (l *List) Items() []string {
+	var items []string
+	l.slots.walk(func(n *node) {
+		if l.registers[n.Value].Slot == n.ID {
+			items = append(items, n.Value)
+		}
+	})
+	return items
+}
+
+// Move moves item, or adds it, so that it is at index among the other
+// items, and returns the operation for the other replicas.
+func (l *List) Move(replica, item string, index int) ListOp {
+	var others []string
+	for _, it := range l.Items() {
+		if it != item {
+			others = append(others, it)
+		}
+	}
+	after := ""
+	if index > 0 && len(others) > 0 {
+		after = others[min(index, len(others))-1]
+	}
+	return l.moveAfter(replica, item, after)
+}
+
+// moveAfter moves item right after the item after, or to the start.
+func (l *List) moveAfter(replica, item, after string) ListOp {
+	var slot ID
+	if after != "" {
+		slot = l.registers[after].Slot
+	}
+	op := ListOp{ID: l.slots.next(replica), After: slot, Item: item}
+	l.apply(op)
+	return op
+}
+
+// Remove removes item and returns the operation for the other replicas.
+func (l *List) Remove(replica, item string) ListOp {
+	op := ListOp{ID: l.slots.next(replica), Item: item, Remove: true}
+	l.apply(op)
+	return op
+}
+
+// SetOrder changes the list to items, such as after cards were reordered
+// without the list: it removes the items not in items and moves the
+// fewest items needed.
+func (l *List) SetOrder(replica string, items []string) []ListOp {
+	target := make(map[string]int, len(items))
+	for i, item := range items {
+		target[item] = i
+	}
+	var ops []ListOp
+	var current []int
+	for _, item := range l.Items() {
+		if i, ok := target[item]; ok {
+			current = append(current, i)
+		} else {
+			ops = append(ops, l.Remove(replica, item))
+		}
+	}
+	// The items in order already stay where they are
+	keep := make(map[int]bool)
+	for _, i := range increasing(current) {
+		keep[i] = true
+	}
+	after := ""
+	for i, item := range items {
+		if !keep[i] {
+			ops = append(ops, l.moveAfter(replica, item, after))
+		}
+		after = item
+	}
+	return ops
+}
+
+// increasing returns a longest increasing subsequence of a.
+func increasing(a []int) []int {
+	// tails[k] is the index in a of the smallest tail of an increasing
+	// subsequence of length k+1
+	var tails []int
+	prev := make([]int, len(a))
+	for i, v := range a {
+		k := sort.Search(len(tails), func(k int) bool { return a[tails[k]] >= v })
+		if k > 0 {
+			prev[i] = tails[k-1]
+		} else {
+			prev[i] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	out := make([]int, len(tails))
+	for k, i := len(tails)-1, -1; k >= 0; k-- {
+		if i == -1 {
+			i = tails[len(tails)-1]
+		} else {
+			i = prev[i]
+		}
+		out[k] = a[i]
+	}
+	return out
+}
+
+// Apply applies the operations of another replica, in any order and any
+// number of times.
+func (l *List) Apply(ops ...ListOp) {
+	l.pending = append(l.pending, ops...)
+	for applied := true; applied; {
+		applied = false
+		rest := l.pending[:0]
+		for _, op := range l.pending {
+			if l.apply(op) {
+				applied = true
+			} else {
+				rest = append(rest, op)
+			}
+		}
+		l.pending = rest
+	}
+	if len(l.pending) == 0 {
+		l.pending = nil
+	}
+}
+
+// apply applies op if the slot it refers to is known.
+func (l *List) apply(op ListOp) bool {
+	var slot ID
+	if !op.Remove {
+		if !l.slots.has(op.After) {
+			return false
+		}
+		l.slots.insert(node{ID: op.ID, After: op.After, Value: op.Item})
+		slot = op.ID
+	}
+	l.slots.see(op.ID)
+	l.set(op.Item, register{Slot: slot, Stamp: op.ID})
+	return true
+}
+
+// set keeps the newer of the item's register and r.
+func (l *List) set(item string, r register) {
+	if l.registers == nil {
+		l.registers = make(map[string]register)
+	}
+	if old, ok := l.registers[item]; !ok || old.Stamp.Less(r.Stamp) {
+		l.registers[item] = r
+	}
+}
+
+// Merge adds the slots and moves of other.
+func (l *List) Merge(other *List) {
+	l.slots.merge(&other.slots)
+	for item, r := range other.registers {
+		l.slots.see(r.Stamp)
+		l.set(item, r)
+	}
+	l.Apply(other.pending...)
+}
+
+type listState struct {
+	Slots     []node              `json:"slots"`
+	Registers map[string]register `json:"registers,omitempty"`
+	Pending   []ListOp            `json:"pending,omitempty"`
+}
+
+// MarshalJSON encodes the state of the list. Equal states have equal
+// encodings.
+func (l *List) MarshalJSON() ([]byte, error) {
+	return json.Marshal(listState{Slots: l.slots.list(), Registers: l.registers, Pending: l.pending})
+}
+
+// UnmarshalJSON decodes a state encoded by MarshalJSON.
+func (l *List) UnmarshalJSON(data []byte) error {
+	var state listState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	*l = List{}
+	l.slots.load(state.Slots)
+	for item, r := range state.Registers {
+		l.slots.see(r.Stamp)
+		l.set(item, r)
+	}
+	l.Apply(state.Pending...)
+	return nil
+}
@@ -0,0 +1,150 @@
+// Package crdt implements the conflict-free replicated data types that
+// the sync uses for card descriptions and for the order of the cards in a
+// list, so that edits made offline on two desktops merge without losing
+// any of them.
+//
+// Text is a sequence of characters and List an order of items. Both are
+// built on a replicated growable array: every element gets a unique ID
+// when it is inserted and refers to the element it was inserted after;
+// deleted elements are kept as tombstones. Replicas that received the
+// same elements, in any order, show the same sequence.
+//
+// Changes can be exchanged as operations (Apply) or as whole states
+// (Merge, and the JSON encoding). Operations whose element has not
+// arrived yet wait until it does.
+package crdt
+
+import "sort"
+
+// ID identifies an element: a Lamport timestamp and the replica that made
+// the element. The zero ID is the start of the sequence.
+type ID struct {
+	Counter uint64 `json:"c"`
+	Replica string `json:"r"`
+}
+
+// Less orders IDs by counter, then by replica.
+func (a ID) Less(b ID) bool {
+	if a.Counter != b.Counter {
+		return a.Counter < b.Counter
+	}
+	return a.Replica < b.Replica
+}
+
+// IsZero reports whether a is the start of the sequence.
+func (a ID) IsZero() bool {
+	return a == ID{}
+}
+
+// node is an element of a sequence.
+type node struct {
+	ID      ID     `json:"id"`
+	After   ID     `json:"after"`
+	Value   string `json:"v"`
+	Deleted bool   `json:"d,omitempty"`
+}
+
+// sequence is a replicated growable array. The elements form a tree: the
+// children of an element are the elements inserted after it, newest
+// first, and the sequence is the depth-first order of the tree. A new
+// element thus comes right after the element it was inserted after, and
+// concurrent inserts at the same place are ordered by their IDs.
+type sequence struct {
+	nodes    map[ID]*node
+	children map[ID][]*node
+	// clock is the highest counter seen
+	clock uint64
+}
+
+func (s *sequence) init() {
+	if s.nodes == nil {
+		s.nodes = make(map[ID]*node)
+		s.children = make(map[ID][]*node)
+	}
+}
+
+// has reports whether the element id is known; the start always is.
+func (s *sequence) has(id ID) bool {
+	if id.IsZero() {
+		return true
+	}
+	_, ok := s.nodes[id]
+	return ok
+}
+
+// next returns a new ID of replica, after every ID seen.
+func (s *sequence) next(replica string) ID {
+	s.clock++
+	return ID{s.clock, replica}
+}
+
+func (s *sequence) see(id ID) {
+	if id.Counter > s.clock {
+		s.clock = id.Counter
+	}
+}
+
+// insert adds an element whose predecessor is known. Inserting a known
+// element does nothing.
+func (s *sequence) insert(n node) {
+	s.init()
+	if _, ok := s.nodes[n.ID]; ok {
+		return
+	}
+	s.see(n.ID)
+	added := &n
+	s.nodes[n.ID] = added
+	siblings := s.children[n.After]
+	i := sort.Search(len(siblings), func(i int) bool { return siblings[i].ID.Less(n.ID) })
+	siblings = append(siblings, nil)
+	copy(siblings[i+1:], siblings[i:])
+	siblings[i] = added
+	s.children[n.After] = siblings
+}
+
+// walk calls f for the elements in sequence order, tombstones included.
+// Every element comes after its predecessor.
+func (s *sequence) walk(f func(*node)) {
+	stack := [][]*node{s.children[ID{}]}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if len(top) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		n := top[0]
+		stack[len(stack)-1] = top[1:]
+		f(n)
+		if children := s.children[n.ID]; len(children) > 0 {
+			stack = append(stack, children)
+		}
+	}
+}
+
+// merge adds the elements of other and its deletions.
+func (s *sequence) merge(other *sequence) {
+	other.walk(func(n *node) {
+		s.insert(*n)
+		if n.Deleted {
+			s.nodes[n.ID].Deleted = true
+		}
+	})
+}
+
+// list returns the elements in sequence order, for encoding.
+func (s *sequence) list() []node {
+	nodes := []node{}
+	s.walk(func(n *node) { nodes = append(nodes, *n) })
+	return nodes
+}
+
+// load adds encoded elements, which come after their predecessors.
+func (s *sequence) load(nodes []node) {
+	s.init()
+	for _, n := range nodes {
+		s.insert(n)
+		if n.Deleted {
+			s.nodes[n.ID].Deleted = true
+		}
+	}
+}
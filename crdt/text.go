@@ -0,0 +1,161 @@
+package crdt
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Text is a replicated text, such as a card description. The zero value
+// is an empty text.
+type Text struct {
+	seq sequence
+	// pending are the operations waiting for their character
+	pending []TextOp
+}
+
+// TextOp inserts a character after another, or deletes one.
+type TextOp struct {
+	// ID is the inserted or deleted character.
+	ID ID `json:"id"`
+	// After is the character inserted after, zero for the start.
+	After  ID     `json:"after"`
+	Char   string `json:"char,omitempty"`
+	Delete bool   `json:"delete,omitempty"`
+}
+
+// String returns the text.
+func (t *Text) String() string {
+	var b strings.Builder
+	t.seq.walk(func(n *node) {
+		if !n.Deleted {
+			b.WriteString(n.Value)
+		}
+	})
+	return b.String()
+}
+
+// visible returns the IDs of the characters of the text.
+func (t *Text) visible() []ID {
+	var ids []ID
+	t.seq.walk(func(n *node) {
+		if !n.Deleted {
+			ids = append(ids, n.ID)
+		}
+	})
+	return ids
+}
+
+// Insert inserts s before the character at index (counted in runes) and
+// returns the operations for the other replicas.
+func (t *Text) Insert(replica string, index int, s string) []TextOp {
+	var after ID
+	if index > 0 {
+		ids := t.visible()
+		after = ids[min(index, len(ids))-1]
+	}
+	var ops []TextOp
+	for _, r := range s {
+		op := TextOp{ID: t.seq.next(replica), After: after, Char: string(r)}
+		t.apply(op)
+		ops = append(ops, op)
+		after = op.ID
+	}
+	return ops
+}
+
+// Delete deletes n characters from index and returns the operations for
+// the other replicas.
+func (t *Text) Delete(index, n int) []TextOp {
+	ids := t.visible()
+	var ops []TextOp
+	for i := index; i < index+n && i < len(ids); i++ {
+		op := TextOp{ID: ids[i], Delete: true}
+		t.apply(op)
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// Edit changes the text to s, such as after editing it in a text field,
+// by deleting and inserting the characters between the common prefix and
+// suffix.
+func (t *Text) Edit(replica, s string) []TextOp {
+	old, changed := []rune(t.String()), []rune(s)
+	prefix := 0
+	for prefix < len(old) && prefix < len(changed) && old[prefix] == changed[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(changed)-prefix &&
+		old[len(old)-1-suffix] == changed[len(changed)-1-suffix] {
+		suffix++
+	}
+	ops := t.Delete(prefix, len(old)-prefix-suffix)
+	return append(ops, t.Insert(replica, prefix, string(changed[prefix:len(changed)-suffix]))...)
+}
+
+// Apply applies the operations of another replica, in any order and any
+// number of times.
+func (t *Text) Apply(ops ...TextOp) {
+	t.pending = append(t.pending, ops...)
+	for applied := true; applied; {
+		applied = false
+		rest := t.pending[:0]
+		for _, op := range t.pending {
+			if t.apply(op) {
+				applied = true
+			} else {
+				rest = append(rest, op)
+			}
+		}
+		t.pending = rest
+	}
+	if len(t.pending) == 0 {
+		t.pending = nil
+	}
+}
+
+// apply applies op if the characters it refers to are known.
+func (t *Text) apply(op TextOp) bool {
+	if op.Delete {
+		n, ok := t.seq.nodes[op.ID]
+		if ok {
+			n.Deleted = true
+		}
+		return ok
+	}
+	if !t.seq.has(op.After) {
+		return false
+	}
+	t.seq.insert(node{ID: op.ID, After: op.After, Value: op.Char})
+	return true
+}
+
+// Merge adds the characters and deletions of other.
+func (t *Text) Merge(other *Text) {
+	t.seq.merge(&other.seq)
+	t.Apply(other.pending...)
+}
+
+type textState struct {
+	Nodes   []node   `json:"nodes"`
+	Pending []TextOp `json:"pending,omitempty"`
+}
+
+// MarshalJSON encodes the state of the text, tombstones included. Equal
+// states have equal encodings.
+func (t *Text) MarshalJSON() ([]byte, error) {
+	return json.Marshal(textState{Nodes: t.seq.list(), Pending: t.pending})
+}
+
+// UnmarshalJSON decodes a state encoded by MarshalJSON.
+func (t *Text) UnmarshalJSON(data []byte) error {
+	var state textState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	*t = Text{}
+	t.seq.load(state.Nodes)
+	t.Apply(state.Pending...)
+	return nil
+}
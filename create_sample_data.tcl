@@ -3,8 +3,21 @@
 
 package require sqlite3
 
-# Initialize database
-sqlite3 db wekan.db
+# Initialize database: the file given as argument, else $KANBAN_DB, else
+# wekan.db in the current directory
+if {[llength $argv] > 0} {
+    set dbPath [lindex $argv 0]
+} elseif {[info exists env(KANBAN_DB)] && $env(KANBAN_DB) ne ""} {
+    set dbPath $env(KANBAN_DB)
+} else {
+    set dbPath wekan.db
+}
+sqlite3 db $dbPath
+db timeout 5000
+db eval {
+    PRAGMA foreign_keys = ON;
+    PRAGMA journal_mode = WAL;
+}
 
 # Create tables if they don't exist
 # Copied from kanban.tcl
@@ -0,0 +1,157 @@
+/*
+ * kanban_api_test.c
+ * Test harness for the C API of xlsx_exporter_embed.so.
+ *
+ * Build and run from the repository root:
+ *
+ *   go build -buildmode=c-shared -o xlsx_exporter_embed.so xlsx_exporter_embed.go
+ *   cc -I. -o kanban_api_test ctest/kanban_api_test.c ./xlsx_exporter_embed.so
+ *   ./kanban_api_test
+ *
+ * or select "Build and run C API tests" in build.sh.
+ */
+
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <unistd.h>
+
+#include "kanban_api.h"
+
+static int failures = 0;
+
+#define CHECK(cond, ...)                                         \
+    do {                                                         \
+        if (!(cond)) {                                           \
+            fprintf(stderr, "FAIL %s:%d: ", __FILE__, __LINE__); \
+            fprintf(stderr, __VA_ARGS__);                        \
+            fprintf(stderr, "\n");                               \
+            failures++;                                          \
+        }                                                        \
+    } while (0)
+
+/* last_error returns a copy of KanbanLastError() that the caller frees. */
+static char *last_error(void)
+{
+    char *err = KanbanLastError();
+    char *copy = strdup(err ? err : "");
+    KanbanFree(err);
+    return copy;
+}
+
+static int file_exists(const char *path)
+{
+    return access(path, F_OK) == 0;
+}
+
+static void test_crud(char *db)
+{
+    int board = KanbanCreateBoard(db, "C Board", "created from C");
+    CHECK(board > 0, "create board returned %d", board);
+    CHECK(KanbanLastError() == NULL, "error set after success");
+
+    int swimlane = KanbanCreateSwimlane(db, board, "Team");
+    int todo = KanbanCreateList(db, swimlane, "Todo");
+    int done = KanbanCreateList(db, swimlane, "Done");
+    CHECK(swimlane > 0 && todo > 0 && done > 0, "create swimlane/lists: %d %d %d", swimlane, todo, done);
+
+    int card = KanbanCreateCard(db, todo, "Write harness", "exercise every function");
+    CHECK(card > 0, "create card returned %d", card);
+    CHECK(KanbanUpdateCard(db, card, "Harness written", "done") == 0, "update card");
+    CHECK(KanbanUpdateList(db, done, "Finished") == 0, "update list");
+    CHECK(KanbanUpdateSwimlane(db, swimlane, "Core team") == 0, "update swimlane");
+    CHECK(KanbanUpdateBoard(db, board, "C Board", "updated from C") == 0, "update board");
+
+    char *json = KanbanGetBoard(db, board);
+    CHECK(json != NULL, "get board returned NULL");
+    if (json) {
+        CHECK(strstr(json, "\"Harness written\"") != NULL, "card title missing: %s", json);
+        CHECK(strstr(json, "\"Finished\"") != NULL, "list name missing: %s", json);
+        CHECK(strstr(json, "\"updated from C\"") != NULL, "description missing: %s", json);
+        KanbanFree(json);
+    }
+
+    json = KanbanListBoards(db);
+    CHECK(json != NULL && strstr(json, "\"C Board\"") != NULL, "list boards: %s", json ? json : "NULL");
+    KanbanFree(json);
+
+    CHECK(KanbanDeleteCard(db, card) == 0, "delete card");
+    CHECK(KanbanDeleteList(db, done) == 0, "delete list");
+    CHECK(KanbanDeleteSwimlane(db, swimlane) == 0, "delete swimlane");
+    CHECK(KanbanUpdateList(db, todo, "Gone") == -1, "list survived deleting its swimlane");
+    CHECK(KanbanDeleteBoard(db, board) == 0, "delete board");
+}
+
+static void test_errors(char *db)
+{
+    CHECK(KanbanDeleteCard(db, 999999) == -1, "deleting a missing card succeeded");
+    char *err = last_error();
+    CHECK(strstr(err, "card 999999 not found") != NULL, "unexpected error %s", err);
+    free(err);
+
+    CHECK(KanbanCreateList(db, 999999, "Orphan") == -1, "list without swimlane was created");
+    err = last_error();
+    CHECK(strstr(err, "swimlane 999999 not found") != NULL, "unexpected error %s", err);
+    free(err);
+
+    CHECK(KanbanGetBoard(db, 999999) == NULL, "missing board returned JSON");
+    err = last_error();
+    CHECK(err[0] != '\0', "no error for missing board");
+    free(err);
+
+    CHECK(KanbanExportBoard(NULL, 1, "x.xlsx", NULL) == -1, "NULL database path accepted");
+    err = last_error();
+    CHECK(strstr(err, "NULL") != NULL, "unexpected error %s", err);
+    free(err);
+}
+
+static void test_export_import(char *db, const char *dir)
+{
+    char path[4096];
+    int board = KanbanCreateBoard(db, "Export Board", "");
+    int swimlane = KanbanCreateSwimlane(db, board, "Lane");
+    int list = KanbanCreateList(db, swimlane, "List");
+    KanbanCreateCard(db, list, "Card", "text");
+
+    const char *formats[] = {"xlsx", "ods", "pdf", "json"};
+    for (size_t i = 0; i < sizeof formats / sizeof formats[0]; i++) {
+        snprintf(path, sizeof path, "%s/board.%s", dir, formats[i]);
+        int rc = KanbanExportBoard(db, board, path, "board");
+        char *err = last_error();
+        CHECK(rc == 0, "export %s: %s", formats[i], err);
+        free(err);
+        CHECK(file_exists(path), "export %s: no file at %s", formats[i], path);
+    }
+
+    snprintf(path, sizeof path, "%s/board.csv", dir);
+    CHECK(KanbanExportBoard(db, board, path, NULL) == -1, "csv export succeeded");
+
+    snprintf(path, sizeof path, "%s/board.json", dir);
+    int copy = KanbanImportBoard(db, path);
+    CHECK(copy > 0 && copy != board, "import returned %d", copy);
+    char *json = KanbanGetBoard(db, copy);
+    CHECK(json != NULL && strstr(json, "\"Card\"") != NULL, "imported board: %s", json ? json : "NULL");
+    KanbanFree(json);
+}
+
+int main(void)
+{
+    char dir[] = "/tmp/kanban_api_testXXXXXX";
+    if (mkdtemp(dir) == NULL) {
+        perror("mkdtemp");
+        return 2;
+    }
+    char db[4096];
+    snprintf(db, sizeof db, "%s/wekan.db", dir);
+
+    test_crud(db);
+    test_errors(db);
+    test_export_import(db, dir);
+
+    if (failures > 0) {
+        fprintf(stderr, "%d check(s) failed\n", failures);
+        return 1;
+    }
+    printf("PASS (database in %s)\n", dir);
+    return 0;
+}
@@ -0,0 +1,557 @@
+// Package doctor checks kanban databases for damage and for the
+// inconsistencies left by older versions and other programs, and repairs
+// what it can.
+package doctor
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"regexp"
+	"strings"
+
+	"tcl-tk-kanban/store"
+)
+
+// Kind is the kind of a problem.
+type Kind string
+
+const (
+	// Integrity is a problem reported by PRAGMA integrity_check.
+	Integrity Kind = "integrity"
+	// ForeignKey is a row reported by PRAGMA foreign_key_check that is not
+	// an orphan.
+	ForeignKey Kind = "foreign-key"
+	// Orphan is a row whose parent row is missing, see store.FindOrphans.
+	Orphan Kind = "orphan"
+	// Position is a swimlane, list or card with duplicate or gapped
+	// positions among its parent's children.
+	Position Kind = "position"
+	// Color is a color that is not a "#RRGGBB" hex color.
+	Color Kind = "color"
+	// Attachment is an attachment that cannot be read as a file.
+	Attachment Kind = "attachment"
+)
+
+// ErrDamaged is returned by Run when fixing a database that fails the
+// integrity check; nothing is changed then.
+var ErrDamaged = errors.New("the database is damaged, restore a backup")
+
+// RecoveredBoard is the name of the board that orphaned swimlanes, lists
+// and cards are moved to.
+const RecoveredBoard = "Recovered items"
+
+// Problem is a problem found in a database.
+type Problem struct {
+	Kind Kind
+	// Table and ID are the row with the problem; for positions, the
+	// parent whose children are misnumbered. Table is "" for the whole
+	// database.
+	Table   string
+	ID      int
+	Message string
+	// Fixed tells what Run changed, "" if the problem was not fixed.
+	Fixed string
+}
+
+func (p Problem) String() string {
+	if p.Table == "" {
+		return fmt.Sprintf("%s: %s", p.Kind, p.Message)
+	}
+	return fmt.Sprintf("%s: %s %d: %s", p.Kind, singular(p.Table), p.ID, p.Message)
+}
+
+// Report lists the problems found by Run.
+type Report struct {
+	Problems []Problem
+}
+
+// Unfixed returns the number of problems that were not fixed.
+func (r *Report) Unfixed() int {
+	n := 0
+	for _, p := range r.Problems {
+		if p.Fixed == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Options controls Run.
+type Options struct {
+	// Fix repairs the problems: positions are renumbered in their order,
+	// orphans are moved to a RecoveredBoard board, colors are corrected or
+	// cleared and attachments stored as text are stored as blobs.
+	// Integrity and foreign key problems other than orphans, and damaged
+	// images, are not fixed.
+	Fix bool
+	// DeleteOrphans deletes the orphans with the rows below them instead
+	// of moving them, see store.RepairOrphans.
+	DeleteOrphans bool
+}
+
+// levels are the board tables with positions, children after parents.
+var levels = []struct {
+	table, parentColumn, parent string
+}{
+	{"swimlanes", "board_id", "boards"},
+	{"lists", "swimlane_id", "swimlanes"},
+	{"cards", "list_id", "lists"},
+}
+
+// colorColumns are the color columns of the board tables.
+var colorColumns = []string{"text_color", "background_color"}
+
+var hexColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Run checks the database and, with opts.Fix, repairs the problems found.
+func Run(db *sql.DB, opts Options) (*Report, error) {
+	r := &Report{}
+	if err := checkIntegrity(db, r); err != nil {
+		return nil, err
+	}
+	if opts.Fix && r.Unfixed() > 0 {
+		return r, ErrDamaged
+	}
+	orphans, err := checkOrphans(db, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkForeignKeys(db, r, orphans); err != nil {
+		return nil, err
+	}
+	if opts.Fix && len(orphans) > 0 {
+		if err := fixOrphans(db, r, orphans, opts.DeleteOrphans); err != nil {
+			return nil, err
+		}
+	}
+
+	// The other problems are checked and fixed in one transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("check database: %w", err)
+	}
+	defer tx.Rollback()
+	for _, check := range []func(*sql.Tx, *Report, bool) error{checkPositions, checkColors, checkAttachments} {
+		if err := check(tx, r, opts.Fix); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("check database: %w", err)
+	}
+	return r, nil
+}
+
+func checkIntegrity(db *sql.DB, r *Report) error {
+	rows, err := db.Query("PRAGMA integrity_check(100)")
+	if err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return fmt.Errorf("integrity check: %w", err)
+		}
+		if msg != "ok" {
+			r.Problems = append(r.Problems, Problem{Kind: Integrity, Message: msg})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	return nil
+}
+
+func checkOrphans(db *sql.DB, r *Report) ([]store.Orphan, error) {
+	orphans, err := store.FindOrphans(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orphans {
+		r.Problems = append(r.Problems, Problem{Kind: Orphan, Table: o.Table, ID: o.ID,
+			Message: fmt.Sprintf("%s %d is missing", singular(o.Parent), o.ParentID)})
+	}
+	return orphans, nil
+}
+
+// checkForeignKeys reports the rows violating foreign keys that are not
+// orphans, such as references to missing rows without ON DELETE CASCADE.
+func checkForeignKeys(db *sql.DB, r *Report, orphans []store.Orphan) error {
+	isOrphan := make(map[string]bool)
+	for _, o := range orphans {
+		isOrphan[fmt.Sprintf("%s %d", o.Table, o.ID)] = true
+	}
+	rows, err := db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return fmt.Errorf("foreign key check: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return fmt.Errorf("foreign key check: %w", err)
+		}
+		if isOrphan[fmt.Sprintf("%s %d", table, rowid.Int64)] {
+			continue
+		}
+		r.Problems = append(r.Problems, Problem{Kind: ForeignKey, Table: table, ID: int(rowid.Int64),
+			Message: fmt.Sprintf("references a missing %s row", parent)})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("foreign key check: %w", err)
+	}
+	return nil
+}
+
+// fixOrphans deletes the orphans, or moves the swimlanes, lists and cards
+// among them to a new RecoveredBoard board and deletes the others.
+func fixOrphans(db *sql.DB, r *Report, orphans []store.Orphan, deleteAll bool) error {
+	fixed := make(map[string]string)
+	key := func(table string, id int) string { return fmt.Sprintf("%s %d", table, id) }
+	if deleteAll {
+		if _, err := store.RepairOrphans(db); err != nil {
+			return err
+		}
+		for _, o := range orphans {
+			fixed[key(o.Table, o.ID)] = "deleted with the rows below it"
+		}
+	} else {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("recover orphans: %w", err)
+		}
+		defer tx.Rollback()
+		rec := &recovery{tx: tx}
+		for _, o := range orphans {
+			msg, err := rec.recover(o)
+			if err != nil {
+				return fmt.Errorf("recover %s %d: %w", singular(o.Table), o.ID, err)
+			}
+			fixed[key(o.Table, o.ID)] = msg
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("recover orphans: %w", err)
+		}
+	}
+	for i, p := range r.Problems {
+		if p.Kind == Orphan {
+			r.Problems[i].Fixed = fixed[key(p.Table, p.ID)]
+		}
+	}
+	return nil
+}
+
+// recovery moves orphans to the recovered board, creating it and its
+// swimlane and list when first needed.
+type recovery struct {
+	tx                          *sql.Tx
+	boardID, swimlaneID, listID int
+}
+
+func (rec *recovery) recover(o store.Orphan) (string, error) {
+	var parentColumn string
+	var parentID int
+	var err error
+	switch o.Table {
+	case "swimlanes":
+		parentColumn = "board_id"
+		parentID, err = rec.board()
+	case "lists":
+		parentColumn = "swimlane_id"
+		parentID, err = rec.swimlane()
+	case "cards":
+		parentColumn = "list_id"
+		parentID, err = rec.list()
+	default:
+		// Rules, saved filters and such are of no use without their row
+		if _, err := rec.tx.Exec("DELETE FROM "+o.Table+" WHERE id = ?", o.ID); err != nil {
+			return "", err
+		}
+		return "deleted", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := rec.append(o.Table, parentColumn, parentID, o.ID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("moved to board %d %q", rec.boardID, RecoveredBoard), nil
+}
+
+func (rec *recovery) board() (int, error) {
+	if rec.boardID == 0 {
+		res, err := rec.tx.Exec("INSERT INTO boards (name, description) VALUES (?, ?)",
+			RecoveredBoard, "Swimlanes, lists and cards whose parent was deleted")
+		if err != nil {
+			return 0, err
+		}
+		id, _ := res.LastInsertId()
+		rec.boardID = int(id)
+	}
+	return rec.boardID, nil
+}
+
+func (rec *recovery) swimlane() (int, error) {
+	if rec.swimlaneID == 0 {
+		boardID, err := rec.board()
+		if err != nil {
+			return 0, err
+		}
+		if rec.swimlaneID, err = rec.insert("swimlanes", "board_id", boardID); err != nil {
+			return 0, err
+		}
+	}
+	return rec.swimlaneID, nil
+}
+
+func (rec *recovery) list() (int, error) {
+	if rec.listID == 0 {
+		swimlaneID, err := rec.swimlane()
+		if err != nil {
+			return 0, err
+		}
+		if rec.listID, err = rec.insert("lists", "swimlane_id", swimlaneID); err != nil {
+			return 0, err
+		}
+	}
+	return rec.listID, nil
+}
+
+// insert adds a "Recovered" swimlane or list at the end of its parent.
+func (rec *recovery) insert(table, parentColumn string, parentID int) (int, error) {
+	res, err := rec.tx.Exec(fmt.Sprintf(`INSERT INTO %s (%s, name, position)
+		SELECT ?, 'Recovered', COALESCE(MAX(position), -1) + 1 FROM %s WHERE %s = ?`,
+		table, parentColumn, table, parentColumn), parentID, parentID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// append moves a row to the end of a parent.
+func (rec *recovery) append(table, parentColumn string, parentID, id int) error {
+	_, err := rec.tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = ?,
+		position = (SELECT COALESCE(MAX(position), -1) + 1 FROM %s WHERE %s = ?) WHERE id = ?`,
+		table, parentColumn, table, parentColumn), parentID, parentID, id)
+	return err
+}
+
+// checkPositions reports the parents whose children are not at positions
+// 0 to n-1, and renumbers them in position order with fix. Archived cards
+// are numbered with the others, as store.MoveCards and the GUI keep them.
+func checkPositions(tx *sql.Tx, r *Report, fix bool) error {
+	for _, lv := range levels {
+		rows, err := tx.Query(fmt.Sprintf(`SELECT c.%s, c.id, c.position FROM %s c
+			JOIN %s p ON p.id = c.%s ORDER BY c.%s, c.position, c.id`,
+			lv.parentColumn, lv.table, lv.parent, lv.parentColumn, lv.parentColumn))
+		if err != nil {
+			return fmt.Errorf("check %s positions: %w", singular(lv.table), err)
+		}
+		type child struct {
+			id       int
+			position sql.NullInt64
+		}
+		var parents []int
+		children := make(map[int][]child)
+		for rows.Next() {
+			var parentID int
+			var c child
+			if err := rows.Scan(&parentID, &c.id, &c.position); err != nil {
+				rows.Close()
+				return fmt.Errorf("check %s positions: %w", singular(lv.table), err)
+			}
+			if children[parentID] == nil {
+				parents = append(parents, parentID)
+			}
+			children[parentID] = append(children[parentID], c)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("check %s positions: %w", singular(lv.table), err)
+		}
+
+		for _, parentID := range parents {
+			cs := children[parentID]
+			ok := true
+			positions := make([]string, len(cs))
+			for i, c := range cs {
+				if !c.position.Valid || c.position.Int64 != int64(i) {
+					ok = false
+				}
+				positions[i] = "NULL"
+				if c.position.Valid {
+					positions[i] = fmt.Sprint(c.position.Int64)
+				}
+			}
+			if ok {
+				continue
+			}
+			if len(positions) > 10 {
+				positions = append(positions[:10], "...")
+			}
+			p := Problem{Kind: Position, Table: lv.parent, ID: parentID,
+				Message: fmt.Sprintf("%s positions %s are not 0 to %d", singular(lv.table), strings.Join(positions, ", "), len(cs)-1)}
+			if fix {
+				for i, c := range cs {
+					if _, err := tx.Exec("UPDATE "+lv.table+" SET position = ? WHERE id = ?", i, c.id); err != nil {
+						return fmt.Errorf("renumber %s: %w", lv.table, err)
+					}
+				}
+				p.Fixed = fmt.Sprintf("renumbered 0 to %d", len(cs)-1)
+			}
+			r.Problems = append(r.Problems, p)
+		}
+	}
+	return nil
+}
+
+// checkColors reports colors that are not "#RRGGBB". With fix, "#RGB" and
+// colors with surrounding spaces are corrected and the others cleared,
+// which shows the default colors.
+func checkColors(tx *sql.Tx, r *Report, fix bool) error {
+	for _, lv := range levels {
+		for _, column := range colorColumns {
+			var exists int
+			err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", lv.table, column).Scan(&exists)
+			if err != nil {
+				return fmt.Errorf("check colors: %w", err)
+			}
+			if exists == 0 {
+				continue
+			}
+			rows, err := tx.Query(fmt.Sprintf("SELECT id, %s FROM %s WHERE %s IS NOT NULL AND %s != '' ORDER BY id",
+				column, lv.table, column, column))
+			if err != nil {
+				return fmt.Errorf("check colors: %w", err)
+			}
+			var problems []Problem
+			var fixes []string
+			for rows.Next() {
+				var id int
+				var value string
+				if err := rows.Scan(&id, &value); err != nil {
+					rows.Close()
+					return fmt.Errorf("check colors: %w", err)
+				}
+				if hexColor.MatchString(value) {
+					continue
+				}
+				problems = append(problems, Problem{Kind: Color, Table: lv.table, ID: id,
+					Message: fmt.Sprintf("%s %q is not a #RRGGBB color", strings.ReplaceAll(column, "_", " "), value)})
+				fixes = append(fixes, fixColor(value))
+			}
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				return fmt.Errorf("check colors: %w", err)
+			}
+
+			for i, p := range problems {
+				if fix {
+					if _, err := tx.Exec("UPDATE "+lv.table+" SET "+column+" = ? WHERE id = ?", fixes[i], p.ID); err != nil {
+						return fmt.Errorf("fix color: %w", err)
+					}
+					p.Fixed = "cleared"
+					if fixes[i] != "" {
+						p.Fixed = "set to " + fixes[i]
+					}
+				}
+				r.Problems = append(r.Problems, p)
+			}
+		}
+	}
+	return nil
+}
+
+// fixColor returns the "#RRGGBB" form of a color, or "" if there is none.
+func fixColor(value string) string {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "#") {
+		value = "#" + value
+	}
+	if len(value) == 4 {
+		value = string([]byte{'#', value[1], value[1], value[2], value[2], value[3], value[3]})
+	}
+	if !hexColor.MatchString(value) {
+		return ""
+	}
+	return strings.ToUpper(value)
+}
+
+// checkAttachments reports attachments stored as text or numbers, which
+// the programs cannot show as files, empty attachments, and images that
+// cannot be decoded. With fix the first are stored as blobs and empty ones
+// removed; damaged images are kept.
+func checkAttachments(tx *sql.Tx, r *Report, fix bool) error {
+	rows, err := tx.Query("SELECT id, typeof(attachment), attachment FROM cards WHERE attachment IS NOT NULL ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("check attachments: %w", err)
+	}
+	type repair struct {
+		query string // "" for none
+		fixed string
+	}
+	var problems []Problem
+	var repairs []repair
+	for rows.Next() {
+		var id int
+		var typ string
+		var data []byte
+		if err := rows.Scan(&id, &typ, &data); err != nil {
+			rows.Close()
+			return fmt.Errorf("check attachments: %w", err)
+		}
+		p := Problem{Kind: Attachment, Table: "cards", ID: id}
+		var rep repair
+		switch {
+		case typ != "blob":
+			p.Message = fmt.Sprintf("stored as %s, not as a file", typ)
+			rep = repair{"UPDATE cards SET attachment = CAST(attachment AS BLOB) WHERE id = ?", "stored as a blob"}
+		case len(data) == 0:
+			p.Message = "empty"
+			rep = repair{"UPDATE cards SET attachment = NULL WHERE id = ?", "removed"}
+		default:
+			if _, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+				if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+					p.Message = fmt.Sprintf("damaged %s image: %v", format, err)
+				}
+			}
+		}
+		if p.Message != "" {
+			problems = append(problems, p)
+			repairs = append(repairs, rep)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("check attachments: %w", err)
+	}
+
+	for i, p := range problems {
+		if fix && repairs[i].query != "" {
+			if _, err := tx.Exec(repairs[i].query, p.ID); err != nil {
+				return fmt.Errorf("fix attachment: %w", err)
+			}
+			p.Fixed = repairs[i].fixed
+		}
+		r.Problems = append(r.Problems, p)
+	}
+	return nil
+}
+
+// singular returns "card" for "cards".
+func singular(table string) string {
+	return strings.TrimSuffix(table, "s")
+}
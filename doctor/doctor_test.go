@@ -0,0 +1,263 @@
+package doctor
+
+import (
+	"bytes"
+	"database/sql"
+	"image"
+	"image/png"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"tcl-tk-kanban/store"
+)
+
+const testSchema = `
+	CREATE TABLE boards (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		description TEXT
+	);
+	CREATE TABLE swimlanes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		board_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		position INTEGER DEFAULT 0,
+		text_color TEXT DEFAULT '',
+		background_color TEXT DEFAULT '',
+		FOREIGN KEY (board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);
+	CREATE TABLE lists (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		swimlane_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		position INTEGER DEFAULT 0,
+		text_color TEXT DEFAULT '',
+		background_color TEXT DEFAULT '',
+		FOREIGN KEY (swimlane_id) REFERENCES swimlanes(id) ON DELETE CASCADE
+	);
+	CREATE TABLE cards (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		list_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		position INTEGER DEFAULT 0,
+		attachment BLOB,
+		text_color TEXT DEFAULT '',
+		background_color TEXT DEFAULT '',
+		FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE
+	);
+	CREATE TABLE links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		card_id INTEGER REFERENCES cards(id)
+	);
+	INSERT INTO boards (id, name) VALUES (1, 'Board'), (2, 'Deleted');
+	INSERT INTO swimlanes (id, board_id, name, position, text_color) VALUES
+		(1, 1, 'Lane', 0, '#fff'), (2, 1, 'Second', 1, '#00FF00'), (3, 2, 'Lost lane', 0, '');
+	INSERT INTO lists (id, swimlane_id, name, position, background_color) VALUES
+		(1, 1, 'Todo', 0, 'red'), (2, 1, 'Deleted', 1, ''), (3, 3, 'Lost list', 0, ' #123abc ');
+	INSERT INTO cards (id, list_id, title, position, attachment) VALUES
+		(1, 1, 'One', 0, NULL), (2, 1, 'Two', 0, 'not a blob'), (3, 1, 'Three', 2, X''),
+		(4, 2, 'Lost card', 0, NULL), (5, 3, 'In lost list', 0, NULL), (6, 1, 'Image', 3, ?);
+	INSERT INTO links (card_id) VALUES (99);
+`
+
+// openTestDB creates a database with an orphaned swimlane, an orphaned
+// card, a rule of a deleted board, misnumbered cards, bad colors and
+// attachments, and a link to a missing card.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "wekan.db")
+
+	// Written without foreign keys, as older versions did
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var img bytes.Buffer
+	png.Encode(&img, image.NewGray(image.Rect(0, 0, 64, 64)))
+	damaged := img.Bytes()[:img.Len()/2]
+	if _, err := old.Exec(testSchema, damaged); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.EnsureRules(old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`INSERT INTO rules (board_id, name, rule) VALUES (2, 'Rule', '{}');
+		DELETE FROM boards WHERE id = 2; DELETE FROM lists WHERE id = 2`); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	db, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func problemStrings(r *Report) []string {
+	var out []string
+	for _, p := range r.Problems {
+		s := p.String()
+		if p.Fixed != "" {
+			s += " => " + p.Fixed
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestCheck(t *testing.T) {
+	db := openTestDB(t)
+
+	r, err := Run(db, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`orphan: swimlane 3: board 2 is missing`,
+		`orphan: rule 1: board 2 is missing`,
+		`orphan: card 4: list 2 is missing`,
+		`foreign-key: link 1: references a missing cards row`,
+		`position: list 1: card positions 0, 0, 2, 3 are not 0 to 3`,
+		`color: swimlane 1: text color "#fff" is not a #RRGGBB color`,
+		`color: list 1: background color "red" is not a #RRGGBB color`,
+		`color: list 3: background color " #123abc " is not a #RRGGBB color`,
+		`attachment: card 2: stored as text, not as a file`,
+		`attachment: card 3: empty`,
+		`attachment: card 6: damaged png image: png: invalid format: not enough pixel data`,
+	}
+	if got := problemStrings(r); !reflect.DeepEqual(got, want) {
+		t.Errorf("problems:\n%q\nwant\n%q", got, want)
+	}
+	if r.Unfixed() != len(want) {
+		t.Errorf("%d unfixed without Fix", r.Unfixed())
+	}
+}
+
+func TestFix(t *testing.T) {
+	db := openTestDB(t)
+
+	r, err := Run(db, Options{Fix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`orphan: swimlane 3: board 2 is missing => moved to board 3 "Recovered items"`,
+		`orphan: rule 1: board 2 is missing => deleted`,
+		`orphan: card 4: list 2 is missing => moved to board 3 "Recovered items"`,
+		`foreign-key: link 1: references a missing cards row`,
+		`position: list 1: card positions 0, 0, 2, 3 are not 0 to 3 => renumbered 0 to 3`,
+		`color: swimlane 1: text color "#fff" is not a #RRGGBB color => set to #FFFFFF`,
+		`color: list 1: background color "red" is not a #RRGGBB color => cleared`,
+		`color: list 3: background color " #123abc " is not a #RRGGBB color => set to #123ABC`,
+		`attachment: card 2: stored as text, not as a file => stored as a blob`,
+		`attachment: card 3: empty => removed`,
+		`attachment: card 6: damaged png image: png: invalid format: not enough pixel data`,
+	}
+	if got := problemStrings(r); !reflect.DeepEqual(got, want) {
+		t.Errorf("problems:\n%q\nwant\n%q", got, want)
+	}
+	if r.Unfixed() != 2 {
+		t.Errorf("%d unfixed", r.Unfixed())
+	}
+
+	// The lost swimlane keeps its list and card; the lost card is in a
+	// new list
+	rows, err := db.Query(`SELECT s.name || '/' || l.name || '/' || c.title FROM swimlanes s
+		JOIN lists l ON l.swimlane_id = s.id JOIN cards c ON c.list_id = l.id
+		WHERE s.board_id = 3 ORDER BY s.position, l.position, c.position`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tree []string
+	for rows.Next() {
+		var path string
+		rows.Scan(&path)
+		tree = append(tree, path)
+	}
+	rows.Close()
+	if want := []string{"Lost lane/Lost list/In lost list", "Recovered/Recovered/Lost card"}; !reflect.DeepEqual(tree, want) {
+		t.Errorf("recovered board %q, want %q", tree, want)
+	}
+	var attachment []byte
+	db.QueryRow("SELECT attachment FROM cards WHERE id = 2").Scan(&attachment)
+	if string(attachment) != "not a blob" {
+		t.Errorf("attachment %q", attachment)
+	}
+
+	r, err = Run(db, Options{Fix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := problemStrings(r); len(got) != 2 {
+		t.Errorf("after fixing: %q", got)
+	}
+}
+
+func TestFixDeleteOrphans(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := Run(db, Options{Fix: true, DeleteOrphans: true}); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM cards WHERE id IN (4, 5)").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d orphaned cards left", n)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM boards").Scan(&n)
+	if n != 1 {
+		t.Errorf("%d boards", n)
+	}
+}
+
+// Moves keep archived cards in the numbering, so a list with archived cards
+// that were moved around is clean, and fixing a list keeps their places.
+func TestArchivedPositions(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "wekan.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := store.Init(db); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO boards (id, name) VALUES (1, 'Board');
+		INSERT INTO swimlanes (id, board_id, name) VALUES (1, 1, 'Lane');
+		INSERT INTO lists (id, swimlane_id, name, position) VALUES (1, 1, 'Todo', 0), (2, 1, 'Done', 1);
+		INSERT INTO cards (id, list_id, title, position, archived) VALUES
+			(1, 1, 'A', 0, 0), (2, 1, 'X', 1, 1), (3, 1, 'B', 2, 0), (4, 1, 'C', 3, 0),
+			(5, 2, 'Y', 0, 1), (6, 2, 'D', 1, 0)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MoveCards(db, []int{4}, store.Target{ParentID: 1, BeforeID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MoveCards(db, []int{3}, store.Target{ParentID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := Run(db, Options{}); err != nil || len(r.Problems) != 0 {
+		t.Fatalf("after moves: %q, %v", problemStrings(r), err)
+	}
+
+	// Numbered without the archived card, as by older versions
+	if _, err := db.Exec("UPDATE cards SET position = 1 WHERE id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Run(db, Options{Fix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"position: list 1: card positions 0, 1, 1 are not 0 to 2 => renumbered 0 to 2"}
+	if got := problemStrings(r); !reflect.DeepEqual(got, want) {
+		t.Errorf("problems %q, want %q", got, want)
+	}
+	var order string
+	db.QueryRow("SELECT group_concat(title, ' ') FROM (SELECT title FROM cards WHERE list_id = 1 ORDER BY position)").Scan(&order)
+	if order != "C A X" {
+		t.Errorf("order after fix %q", order)
+	}
+}
@@ -0,0 +1,153 @@
+// Package export writes boards stored in wekan.db to XLSX, ODS, PDF and
+// JSON documents, and imports boards from JSON.
+package export
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Board is a fully loaded board tree ready to be written to a document.
+type Board struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Swimlanes   []Swimlane `json:"swimlanes"`
+}
+
+type Swimlane struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Position        int    `json:"position"`
+	TextColor       string `json:"textColor,omitempty"`
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	BackgroundImage string `json:"backgroundImage,omitempty"`
+	Lists           []List `json:"lists"`
+}
+
+type List struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Position        int    `json:"position"`
+	TextColor       string `json:"textColor,omitempty"`
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	BackgroundImage string `json:"backgroundImage,omitempty"`
+	Cards           []Card `json:"cards"`
+}
+
+type Card struct {
+	ID              int    `json:"id"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	Position        int    `json:"position"`
+	CreatedAt       string `json:"createdAt,omitempty"`
+	Attachment      []byte `json:"attachment,omitempty"` // base64 in JSON
+	TextColor       string `json:"textColor,omitempty"`
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	Archived        bool   `json:"archived,omitempty"`
+	ArchiveAt       string `json:"archiveAt,omitempty"` // scheduled archive time, UTC
+}
+
+// LoadBoard reads a board with all of its swimlanes, lists and cards,
+// ordered by position. It uses one query per level instead of one per parent.
+// Archived cards are loaded too; the documents meant for reading leave them
+// out with withoutArchived.
+func LoadBoard(db *sql.DB, boardID int) (*Board, error) {
+	var b Board
+	err := db.QueryRow("SELECT id, name, COALESCE(description, '') FROM boards WHERE id = ?", boardID).
+		Scan(&b.ID, &b.Name, &b.Description)
+	if err != nil {
+		return nil, fmt.Errorf("load board %d: %w", boardID, err)
+	}
+
+	// Swimlanes
+	rows, err := db.Query(`SELECT id, name, position, COALESCE(text_color, ''), COALESCE(background_color, ''), COALESCE(background_image, '')
+		FROM swimlanes WHERE board_id = ? ORDER BY position, id`, boardID)
+	if err != nil {
+		return nil, fmt.Errorf("load swimlanes: %w", err)
+	}
+	swimlaneIndex := make(map[int]int)
+	for rows.Next() {
+		var s Swimlane
+		if err := rows.Scan(&s.ID, &s.Name, &s.Position, &s.TextColor, &s.BackgroundColor, &s.BackgroundImage); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan swimlane: %w", err)
+		}
+		swimlaneIndex[s.ID] = len(b.Swimlanes)
+		b.Swimlanes = append(b.Swimlanes, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("load swimlanes: %w", err)
+	}
+
+	// Lists
+	rows, err = db.Query(`SELECT l.id, l.swimlane_id, l.name, l.position, COALESCE(l.text_color, ''), COALESCE(l.background_color, ''), COALESCE(l.background_image, '')
+		FROM lists l JOIN swimlanes s ON s.id = l.swimlane_id
+		WHERE s.board_id = ? ORDER BY l.position, l.id`, boardID)
+	if err != nil {
+		return nil, fmt.Errorf("load lists: %w", err)
+	}
+	type listRef struct{ swimlane, list int }
+	listIndex := make(map[int]listRef)
+	for rows.Next() {
+		var l List
+		var swimlaneID int
+		if err := rows.Scan(&l.ID, &swimlaneID, &l.Name, &l.Position, &l.TextColor, &l.BackgroundColor, &l.BackgroundImage); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan list: %w", err)
+		}
+		si := swimlaneIndex[swimlaneID]
+		listIndex[l.ID] = listRef{si, len(b.Swimlanes[si].Lists)}
+		b.Swimlanes[si].Lists = append(b.Swimlanes[si].Lists, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("load lists: %w", err)
+	}
+
+	// Cards
+	rows, err = db.Query(`SELECT c.id, c.list_id, c.title, COALESCE(c.description, ''), c.position, COALESCE(c.created_at, ''), c.attachment,
+		COALESCE(c.text_color, ''), COALESCE(c.background_color, ''), COALESCE(c.archived, 0), COALESCE(c.archive_at, '')
+		FROM cards c JOIN lists l ON l.id = c.list_id JOIN swimlanes s ON s.id = l.swimlane_id
+		WHERE s.board_id = ? ORDER BY c.position, c.id`, boardID)
+	if err != nil {
+		return nil, fmt.Errorf("load cards: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c Card
+		var listID int
+		if err := rows.Scan(&c.ID, &listID, &c.Title, &c.Description, &c.Position, &c.CreatedAt, &c.Attachment, &c.TextColor, &c.BackgroundColor, &c.Archived, &c.ArchiveAt); err != nil {
+			return nil, fmt.Errorf("scan card: %w", err)
+		}
+		ref := listIndex[listID]
+		lists := b.Swimlanes[ref.swimlane].Lists
+		lists[ref.list].Cards = append(lists[ref.list].Cards, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("load cards: %w", err)
+	}
+	return &b, nil
+}
+
+// withoutArchived returns a copy of b without its archived cards.
+func withoutArchived(b *Board) *Board {
+	c := *b
+	c.Swimlanes = append([]Swimlane(nil), b.Swimlanes...)
+	for i := range c.Swimlanes {
+		s := &c.Swimlanes[i]
+		s.Lists = append([]List(nil), s.Lists...)
+		for j := range s.Lists {
+			l := &s.Lists[j]
+			var cards []Card
+			for _, card := range l.Cards {
+				if !card.Archived {
+					cards = append(cards, card)
+				}
+			}
+			l.Cards = cards
+		}
+	}
+	return &c
+}
@@ -0,0 +1,52 @@
+package export
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Options selects the spreadsheet layout and PDF page setup for ExportBoard.
+type Options struct {
+	// Layout applies to .xlsx and .ods files. Empty means LayoutFlat.
+	Layout Layout
+	PDF    PDFOptions
+}
+
+// Formats lists the file extensions understood by ExportBoard.
+var Formats = []string{".xlsx", ".ods", ".pdf", ".json"}
+
+// ExportBoard writes a board to outputFile, choosing the format from the
+// file extension.
+func ExportBoard(db *sql.DB, boardID int, outputFile string, opts Options) error {
+	layout, err := ParseLayout(string(opts.Layout))
+	if err != nil {
+		return err
+	}
+	switch ext := strings.ToLower(filepath.Ext(outputFile)); ext {
+	case ".xlsx":
+		return ExportBoardToXLSX(db, boardID, outputFile, layout)
+	case ".ods":
+		return ExportBoardToODS(db, boardID, outputFile, layout)
+	case ".pdf":
+		return ExportBoardToPDF(db, boardID, outputFile, opts.PDF)
+	case ".json":
+		return ExportBoardToJSON(db, boardID, outputFile)
+	default:
+		return fmt.Errorf("unsupported export format %q, use one of %s", ext, strings.Join(Formats, ", "))
+	}
+}
+
+// ParseLayout validates a layout name. An empty name selects LayoutFlat.
+func ParseLayout(s string) (Layout, error) {
+	if s == "" {
+		return LayoutFlat, nil
+	}
+	for _, l := range Layouts {
+		if strings.EqualFold(s, string(l)) {
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("unknown layout %q", s)
+}
@@ -0,0 +1,201 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var singleSheetLayouts = []Layout{LayoutFlat, LayoutHierarchical}
+
+func TestExportBoardXLSX(t *testing.T) {
+	db := openTestDB(t)
+	for _, layout := range singleSheetLayouts {
+		out := filepath.Join(t.TempDir(), "board.xlsx")
+		if err := ExportBoard(db, 1, out, Options{Layout: layout}); err != nil {
+			t.Fatalf("%s: %v", layout, err)
+		}
+		f, err := excelize.OpenFile(out)
+		if err != nil {
+			t.Fatalf("%s: %v", layout, err)
+		}
+		rows, err := f.GetRows(xlsxSheet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := flatten(rows)
+		for _, want := range []string{"Weekly Board", "Development", "Todo", "Write exporter", "Pure Go PDF output", "#FFEEAA"} {
+			if !strings.Contains(text, want) {
+				t.Errorf("%s: sheet missing %q", layout, want)
+			}
+		}
+		if strings.Contains(text, "Archived card") {
+			t.Errorf("%s: sheet contains the archived card", layout)
+		}
+		pics, err := f.GetPictureCells(xlsxSheet)
+		if err != nil || len(pics) != 1 || pics[0] != "I"+rowOf(rows, "Screenshot card") {
+			t.Errorf("%s: picture cells %v (%v), want one in column I of the attachment row", layout, pics, err)
+		}
+		f.Close()
+	}
+}
+
+func TestExportBoardXLSXBoardLayout(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec("INSERT INTO swimlanes (board_id, name, position) VALUES (1, 'Design', 2)"); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "board.xlsx")
+	if err := ExportBoard(db, 1, out, Options{Layout: LayoutBoard}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	wantSheets := []string{"Summary", "Development", "Design", "Design (2)"}
+	if got := f.GetSheetList(); strings.Join(got, ",") != strings.Join(wantSheets, ",") {
+		t.Fatalf("sheets %v, want %v", got, wantSheets)
+	}
+
+	// Summary: one row per swimlane, linked to its sheet.
+	for i, name := range wantSheets[1:] {
+		cell := "D" + strconv.Itoa(5+i)
+		ok, target, err := f.GetCellHyperLink("Summary", cell)
+		if err != nil || !ok || target != "'"+name+"'!A1" {
+			t.Errorf("summary %s links to %q (%v, %v), want sheet %q", cell, target, ok, err, name)
+		}
+	}
+	if v, _ := f.GetCellValue("Summary", "C5"); v != "3" {
+		t.Errorf("Development card count %q, want 3", v)
+	}
+
+	// Swimlane sheet: lists as columns, cards below, colors from the database.
+	rows, err := f.GetRows("Development")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) < 3 || rows[0][0] != "Todo" || rows[0][1] != "Done" ||
+		!strings.HasPrefix(rows[1][0], "Write exporter\n") || rows[2][0] != "Screenshot card" || !strings.HasPrefix(rows[1][1], "Long card") {
+		t.Errorf("unexpected Development sheet %q", rows)
+	}
+	styleID, _ := f.GetCellStyle("Development", "A2")
+	style, err := f.GetStyle(styleID)
+	if err != nil || len(style.Fill.Color) != 1 || !strings.EqualFold(strings.TrimPrefix(style.Fill.Color[0], "#"), "FFEEAA") {
+		t.Errorf("card fill %+v (%v), want #FFEEAA", style.Fill, err)
+	}
+	props, _ := f.GetSheetProps("Development")
+	if props.TabColorRGB == nil || !strings.EqualFold(*props.TabColorRGB, "FF336699") && !strings.EqualFold(*props.TabColorRGB, "336699") {
+		t.Errorf("tab color %v, want 336699", props.TabColorRGB)
+	}
+
+	// Frozen header row and autofilter on every sheet.
+	for _, name := range wantSheets {
+		panes, err := f.GetPanes(name)
+		if err != nil || !panes.Freeze || panes.YSplit == 0 {
+			t.Errorf("%s: header not frozen: %+v (%v)", name, panes, err)
+		}
+	}
+}
+
+func TestExportBoardODS(t *testing.T) {
+	db := openTestDB(t)
+	for _, layout := range singleSheetLayouts {
+		out := filepath.Join(t.TempDir(), "board.ods")
+		if err := ExportBoard(db, 1, out, Options{Layout: layout}); err != nil {
+			t.Fatalf("%s: %v", layout, err)
+		}
+		zr, err := zip.OpenReader(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := make(map[string]string)
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(data)
+		}
+		if first := zr.File[0]; first.Name != "mimetype" || first.Method != zip.Store || files["mimetype"] != odsMimeType {
+			t.Errorf("%s: first entry %q (method %d) is not the stored mimetype", layout, first.Name, first.Method)
+		}
+		zr.Close()
+
+		for _, name := range []string{"content.xml", "styles.xml", "META-INF/manifest.xml"} {
+			if err := wellFormed(files[name]); err != nil {
+				t.Errorf("%s: %s: %v", layout, name, err)
+			}
+		}
+		content := files["content.xml"]
+		for _, want := range []string{"Weekly Board", "Development", "Todo", "Write exporter", "#FFEEAA", `fo:background-color="#FFEEAA"`, `xlink:href="Pictures/image1.png"`} {
+			if !strings.Contains(content, want) {
+				t.Errorf("%s: content.xml missing %q", layout, want)
+			}
+		}
+		if strings.Contains(content, "Archived card") {
+			t.Errorf("%s: content.xml contains the archived card", layout)
+		}
+		if _, ok := files["Pictures/image1.png"]; !ok || !strings.Contains(files["META-INF/manifest.xml"], "Pictures/image1.png") {
+			t.Errorf("%s: image not stored in package or manifest", layout)
+		}
+	}
+}
+
+func TestExportBoardFormat(t *testing.T) {
+	db := openTestDB(t)
+	dir := t.TempDir()
+	if err := ExportBoard(db, 1, filepath.Join(dir, "board.csv"), Options{}); err == nil {
+		t.Error("unsupported extension: expected error")
+	}
+	if err := ExportBoard(db, 1, filepath.Join(dir, "board.ods"), Options{Layout: "tree"}); err == nil {
+		t.Error("unknown layout: expected error")
+	}
+	if err := ExportBoard(db, 1, filepath.Join(dir, "BOARD.PDF"), Options{}); err != nil {
+		t.Errorf("upper case extension: %v", err)
+	}
+}
+
+func flatten(rows [][]string) string {
+	var b strings.Builder
+	for _, row := range rows {
+		b.WriteString(strings.Join(row, "\t"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// rowOf returns the 1-based row number of the first row containing value.
+func rowOf(rows [][]string, value string) string {
+	for i, row := range rows {
+		for _, cell := range row {
+			if cell == value {
+				return strconv.Itoa(i + 1)
+			}
+		}
+	}
+	return ""
+}
+
+func wellFormed(doc string) error {
+	d := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package export
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ExportBoardToJSON writes a board with its swimlanes, lists and cards to a
+// JSON file that ImportBoardFromJSON can read back.
+func ExportBoardToJSON(db *sql.DB, boardID int, outputFile string) error {
+	b, err := LoadBoard(db, boardID)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := WriteJSON(f, b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteJSON writes a loaded board as indented JSON, archived cards
+// included. Attachments are base64 encoded.
+func WriteJSON(w io.Writer, b *Board) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// ImportBoardFromJSON reads a board written by ExportBoardToJSON and stores
+// it as a new board. It returns the ID of the new board.
+func ImportBoardFromJSON(db *sql.DB, inputFile string) (int, error) {
+	f, err := os.Open(inputFile)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return ReadJSON(db, f)
+}
+
+// ReadJSON decodes a board from r and inserts it, with new IDs, in a single
+// transaction. Positions, colors, creation dates, attachments and archiving
+// are kept.
+func ReadJSON(db *sql.DB, r io.Reader) (int, error) {
+	var b Board
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return 0, fmt.Errorf("decode board: %w", err)
+	}
+	if b.Name == "" {
+		return 0, fmt.Errorf("decode board: missing board name")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO boards (name, description) VALUES (?, ?)", b.Name, b.Description)
+	if err != nil {
+		return 0, fmt.Errorf("insert board: %w", err)
+	}
+	boardID, _ := res.LastInsertId()
+
+	for _, s := range b.Swimlanes {
+		res, err := tx.Exec(`INSERT INTO swimlanes (board_id, name, position, text_color, background_color, background_image)
+			VALUES (?, ?, ?, ?, ?, ?)`, boardID, s.Name, s.Position, s.TextColor, s.BackgroundColor, s.BackgroundImage)
+		if err != nil {
+			return 0, fmt.Errorf("insert swimlane %q: %w", s.Name, err)
+		}
+		swimlaneID, _ := res.LastInsertId()
+
+		for _, l := range s.Lists {
+			res, err := tx.Exec(`INSERT INTO lists (swimlane_id, name, position, text_color, background_color, background_image)
+				VALUES (?, ?, ?, ?, ?, ?)`, swimlaneID, l.Name, l.Position, l.TextColor, l.BackgroundColor, l.BackgroundImage)
+			if err != nil {
+				return 0, fmt.Errorf("insert list %q: %w", l.Name, err)
+			}
+			listID, _ := res.LastInsertId()
+
+			for _, c := range l.Cards {
+				var attachment any
+				if len(c.Attachment) > 0 {
+					attachment = c.Attachment
+				}
+				_, err := tx.Exec(`INSERT INTO cards (list_id, title, description, position, created_at, attachment, text_color, background_color, archived, archive_at)
+					VALUES (?, ?, ?, ?, COALESCE(NULLIF(?, ''), CURRENT_TIMESTAMP), ?, ?, ?, ?, NULLIF(?, ''))`,
+					listID, c.Title, c.Description, c.Position, c.CreatedAt, attachment, c.TextColor, c.BackgroundColor, c.Archived, c.ArchiveAt)
+				if err != nil {
+					return 0, fmt.Errorf("insert card %q: %w", c.Title, err)
+				}
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(boardID), nil
+}
@@ -0,0 +1,82 @@
+package export
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec("UPDATE cards SET archive_at = '2030-01-02 03:04:05' WHERE title = 'Long card'"); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "board.json")
+	if err := ExportBoard(db, 1, out, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	newID, err := ImportBoardFromJSON(db, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newID == 1 {
+		t.Fatalf("import reused board ID %d", newID)
+	}
+
+	orig, err := LoadBoard(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := LoadBoard(db, newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stripIDs(copied), stripIDs(orig); !reflect.DeepEqual(got, want) {
+		t.Errorf("imported board differs:\n got %+v\nwant %+v", got, want)
+	}
+
+	// The archived card and the scheduled archive are exported and imported
+	todo, done := copied.Swimlanes[0].Lists[0].Cards, copied.Swimlanes[0].Lists[1].Cards
+	if len(todo) != 3 || todo[2].Title != "Archived card" || !todo[2].Archived {
+		t.Errorf("imported Todo cards %+v, want the archived card last", todo)
+	}
+	if len(done) != 1 || done[0].Archived || done[0].ArchiveAt != "2030-01-02 03:04:05" {
+		t.Errorf("imported Done cards %+v, want an archive at 2030-01-02 03:04:05", done)
+	}
+}
+
+func TestReadJSONErrors(t *testing.T) {
+	db := openTestDB(t)
+	for _, doc := range []string{"", "{", `{"description": "no name"}`} {
+		if _, err := ReadJSON(db, bytes.NewBufferString(doc)); err == nil {
+			t.Errorf("ReadJSON(%q): expected error", doc)
+		}
+	}
+	var boards int
+	db.QueryRow("SELECT COUNT(*) FROM boards").Scan(&boards)
+	if boards != 1 {
+		t.Errorf("failed imports left %d boards, want 1", boards)
+	}
+}
+
+// stripIDs returns a copy of b with all database IDs zeroed.
+func stripIDs(b *Board) Board {
+	c := *b
+	c.ID = 0
+	c.Swimlanes = append([]Swimlane(nil), b.Swimlanes...)
+	for i := range c.Swimlanes {
+		s := &c.Swimlanes[i]
+		s.ID = 0
+		s.Lists = append([]List(nil), s.Lists...)
+		for j := range s.Lists {
+			l := &s.Lists[j]
+			l.ID = 0
+			l.Cards = append([]Card(nil), l.Cards...)
+			for k := range l.Cards {
+				l.Cards[k].ID = 0
+			}
+		}
+	}
+	return c
+}
@@ -0,0 +1,243 @@
+package export
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const odsMimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+// ExportBoardToODS writes a board to an OpenDocument spreadsheet using the
+// given layout. The columns and images match the XLSX export.
+func ExportBoardToODS(db *sql.DB, boardID int, outputFile string, layout Layout) error {
+	b, err := LoadBoard(db, boardID)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := WriteODS(f, b, layout); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteODS writes a board as an OpenDocument spreadsheet with one table
+// per worksheet of the layout. Archived cards are left out.
+func WriteODS(w io.Writer, b *Board, layout Layout) error {
+	doc := newODSDocument()
+	for _, sh := range workbookSheets(b, layout) {
+		doc.addTable(sh)
+	}
+	return doc.write(w)
+}
+
+// odsDocument collects tables, automatic cell styles and pictures before
+// they are written into the zip package.
+type odsDocument struct {
+	tables   strings.Builder
+	styles   map[string]string // formatting key -> cell style name
+	styleXML strings.Builder
+	pictures []odsFile
+}
+
+// odsFile is a file stored in the zip package next to content.xml.
+type odsFile struct {
+	path string
+	mime string
+	data []byte
+}
+
+func newODSDocument() *odsDocument {
+	return &odsDocument{styles: make(map[string]string)}
+}
+
+func (d *odsDocument) addTable(sh sheet) {
+	t := &d.tables
+	columns := len(sh.Widths)
+	fmt.Fprintf(t, `<table:table table:name="%s">`, xmlEscape(sh.Name))
+	for _, w := range sh.Widths {
+		fmt.Fprintf(t, `<table:table-column table:style-name="%s"/>`, d.columnStyle(w))
+	}
+	for _, row := range sh.Rows {
+		if row.Image != nil {
+			_, h := imageBox(row.Image, xlsxImagePx)
+			fmt.Fprintf(t, `<table:table-row table:style-name="%s">`, d.rowStyle(h))
+		} else {
+			t.WriteString(`<table:table-row>`)
+		}
+		cells := make([]*sheetCell, columns)
+		for i := range row.Cells {
+			cells[row.Cells[i].Col-1] = &row.Cells[i]
+		}
+		for col, c := range cells {
+			var frame string
+			if row.Image != nil && row.ImageCol == col+1 {
+				frame = d.imageFrame(row.Image)
+			}
+			switch {
+			case c == nil && frame == "":
+				t.WriteString(`<table:table-cell/>`)
+			case c == nil:
+				fmt.Fprintf(t, `<table:table-cell>%s</table:table-cell>`, frame)
+			case c.Numeric:
+				fmt.Fprintf(t, `<table:table-cell table:style-name="%s" office:value-type="float" office:value="%s">%s<text:p>%s</text:p></table:table-cell>`,
+					d.cellStyle(*c), xmlEscape(c.Value), frame, xmlEscape(c.Value))
+			default:
+				fmt.Fprintf(t, `<table:table-cell table:style-name="%s" office:value-type="string">%s`, d.cellStyle(*c), frame)
+				for _, line := range strings.Split(c.Value, "\n") {
+					if c.Link != "" {
+						line = fmt.Sprintf(`<text:a xlink:type="simple" xlink:href="#'%s'.A1">%s</text:a>`, xmlEscape(c.Link), xmlEscape(line))
+					} else {
+						line = xmlEscape(line)
+					}
+					fmt.Fprintf(t, `<text:p>%s</text:p>`, line)
+				}
+				t.WriteString(`</table:table-cell>`)
+			}
+		}
+		t.WriteString(`</table:table-row>`)
+	}
+	t.WriteString(`</table:table>`)
+}
+
+// columnStyle returns an automatic column style for a width in characters.
+func (d *odsDocument) columnStyle(width float64) string {
+	key := fmt.Sprintf("column|%g", width)
+	if name, ok := d.styles[key]; ok {
+		return name
+	}
+	name := fmt.Sprintf("co%d", len(d.styles)+1)
+	d.styles[key] = name
+	// One character of the default font is about 7 pixels, or 5.25 points.
+	fmt.Fprintf(&d.styleXML, `<style:style style:name="%s" style:family="table-column"><style:table-column-properties style:column-width="%.2fpt"/></style:style>`,
+		name, width*5.25+5)
+	return name
+}
+
+// cellStyle returns the automatic style for a cell's font and fill colors.
+func (d *odsDocument) cellStyle(c sheetCell) string {
+	fg, bg := cellColors(c)
+	key := fmt.Sprintf("cell|%t|%t|%s|%s", c.Bold, c.Link != "", fg, bg)
+	if name, ok := d.styles[key]; ok {
+		return name
+	}
+	name := fmt.Sprintf("ce%d", len(d.styles)+1)
+	d.styles[key] = name
+	fmt.Fprintf(&d.styleXML, `<style:style style:name="%s" style:family="table-cell">`, name)
+	d.styleXML.WriteString(`<style:table-cell-properties style:vertical-align="top" fo:wrap-option="wrap"`)
+	if bg != "" {
+		fmt.Fprintf(&d.styleXML, ` fo:background-color="%s"`, bg)
+	}
+	d.styleXML.WriteString(`/><style:text-properties`)
+	if c.Bold {
+		d.styleXML.WriteString(` fo:font-weight="bold"`)
+	}
+	if fg != "" {
+		fmt.Fprintf(&d.styleXML, ` fo:color="%s"`, fg)
+	}
+	if c.Link != "" {
+		d.styleXML.WriteString(` style:text-underline-style="solid" style:text-underline-width="auto" style:text-underline-color="font-color"`)
+	}
+	d.styleXML.WriteString(`/></style:style>`)
+	return name
+}
+
+// rowStyle returns an automatic row style tall enough for an image of h pixels.
+func (d *odsDocument) rowStyle(h int) string {
+	key := fmt.Sprintf("row|%d", h)
+	if name, ok := d.styles[key]; ok {
+		return name
+	}
+	name := fmt.Sprintf("ro%d", len(d.styles)+1)
+	d.styles[key] = name
+	fmt.Fprintf(&d.styleXML, `<style:style style:name="%s" style:family="table-row"><style:table-row-properties style:row-height="%.2fpt" style:use-optimal-row-height="false"/></style:style>`,
+		name, float64(h)*0.75+4)
+	return name
+}
+
+// imageFrame stores the picture in the package and returns the draw:frame
+// that anchors it in its cell.
+func (d *odsDocument) imageFrame(img *sheetImage) string {
+	n := len(d.pictures) + 1
+	path := fmt.Sprintf("Pictures/image%d%s", n, img.Ext)
+	mime := map[string]string{".png": "image/png", ".jpg": "image/jpeg", ".gif": "image/gif"}[img.Ext]
+	d.pictures = append(d.pictures, odsFile{path: path, mime: mime, data: img.Data})
+
+	w, h := imageBox(img, xlsxImagePx)
+	return fmt.Sprintf(`<draw:frame draw:name="Image %d" draw:z-index="%d" svg:width="%.2fpt" svg:height="%.2fpt" svg:x="2pt" svg:y="2pt">`+
+		`<draw:image xlink:href="%s" xlink:type="simple" xlink:show="embed" xlink:actuate="onLoad"><text:p/></draw:image></draw:frame>`,
+		n, n-1, float64(w)*0.75, float64(h)*0.75, path)
+}
+
+const odsNamespaces = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" ` +
+	`xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" ` +
+	`xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" ` +
+	`xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" ` +
+	`xmlns:draw="urn:oasis:names:tc:opendocument:xmlns:drawing:1.0" ` +
+	`xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0" ` +
+	`xmlns:xlink="http://www.w3.org/1999/xlink" ` +
+	`xmlns:svg="urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0" ` +
+	`office:version="1.2"`
+
+func (d *odsDocument) write(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	// The mimetype entry must come first and be stored uncompressed.
+	mt, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mt, odsMimeType); err != nil {
+		return err
+	}
+
+	var manifest strings.Builder
+	manifest.WriteString(xml.Header)
+	manifest.WriteString(`<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">`)
+	fmt.Fprintf(&manifest, `<manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="%s"/>`, odsMimeType)
+	manifest.WriteString(`<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>`)
+	manifest.WriteString(`<manifest:file-entry manifest:full-path="styles.xml" manifest:media-type="text/xml"/>`)
+	for _, p := range d.pictures {
+		fmt.Fprintf(&manifest, `<manifest:file-entry manifest:full-path="%s" manifest:media-type="%s"/>`, p.path, p.mime)
+	}
+	manifest.WriteString(`</manifest:manifest>`)
+
+	styles := xml.Header + `<office:document-styles ` + odsNamespaces + `><office:styles/></office:document-styles>`
+
+	content := xml.Header + `<office:document-content ` + odsNamespaces + `>` +
+		`<office:automatic-styles>` + d.styleXML.String() +
+		`</office:automatic-styles>` +
+		`<office:body><office:spreadsheet>` + d.tables.String() + `</office:spreadsheet></office:body>` +
+		`</office:document-content>`
+
+	files := []odsFile{
+		{path: "META-INF/manifest.xml", data: []byte(manifest.String())},
+		{path: "styles.xml", data: []byte(styles)},
+		{path: "content.xml", data: []byte(content)},
+	}
+	for _, file := range append(files, d.pictures...) {
+		fw, err := zw.Create(file.path)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(file.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
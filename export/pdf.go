@@ -0,0 +1,369 @@
+package export
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/draw"
+)
+
+// PDFOptions controls the page setup of a PDF board report.
+type PDFOptions struct {
+	// PageSize is one of A3, A4, A5, Letter, Legal or Tabloid. Empty means A4.
+	PageSize string
+	// Landscape selects landscape instead of portrait orientation.
+	Landscape bool
+}
+
+// PDFPageSizes lists the page sizes accepted in PDFOptions.PageSize.
+var PDFPageSizes = []string{"A3", "A4", "A5", "Letter", "Legal", "Tabloid"}
+
+const (
+	pdfMargin    = 15.0 // page margin in mm
+	pdfLineH     = 5.0  // body text line height in mm
+	pdfThumbSize = 26.0 // max thumbnail width/height in mm
+	pdfThumbPx   = 240  // max thumbnail width/height in pixels
+)
+
+// ExportBoardToPDF writes a printable report of a board to outputFile. The
+// report is rendered before outputFile is created, so invalid options do
+// not leave an empty or truncated file behind.
+func ExportBoardToPDF(db *sql.DB, boardID int, outputFile string, opts PDFOptions) error {
+	b, err := LoadBoard(db, boardID)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, b, opts); err != nil {
+		return err
+	}
+	return os.WriteFile(outputFile, buf.Bytes(), 0o666)
+}
+
+// WritePDF renders a board report: a cover page with the board name and
+// description, then one section per swimlane with a card table per list.
+// Archived cards are left out.
+func WritePDF(w io.Writer, b *Board, opts PDFOptions) error {
+	b = withoutArchived(b)
+	size := opts.PageSize
+	if size == "" {
+		size = "A4"
+	}
+	orientation := "P"
+	if opts.Landscape {
+		orientation = "L"
+	}
+
+	pdf := gofpdf.New(orientation, "mm", size, "")
+	if pdf.Err() {
+		return fmt.Errorf("pdf setup: %w", pdf.Error())
+	}
+	r := &pdfReport{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+	pdf.SetTitle(b.Name, true)
+	pdf.SetCreator("Go Kanban Board", true)
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pdfMargin + 5)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 5, r.tr(fmt.Sprintf("%s - Page %d", b.Name, pdf.PageNo())), "", 0, "C", false, 0, "")
+	})
+
+	r.cover(b)
+	for _, s := range b.Swimlanes {
+		r.swimlane(s)
+	}
+	return pdf.Output(w)
+}
+
+type pdfReport struct {
+	pdf *gofpdf.Fpdf
+	tr  func(string) string
+}
+
+func (r *pdfReport) cover(b *Board) {
+	pdf := r.pdf
+	pdf.AddPage()
+	_, pageH := pdf.GetPageSize()
+
+	lists, cards := 0, 0
+	for _, s := range b.Swimlanes {
+		lists += len(s.Lists)
+		for _, l := range s.Lists {
+			cards += len(l.Cards)
+		}
+	}
+
+	pdf.SetY(pageH / 4)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "B", 28)
+	pdf.MultiCell(0, 12, r.tr(b.Name), "", "C", false)
+	pdf.Ln(6)
+	if b.Description != "" {
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.MultiCell(0, 6, r.tr(b.Description), "", "C", false)
+		pdf.Ln(6)
+	}
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetTextColor(96, 96, 96)
+	pdf.MultiCell(0, 5, r.tr(fmt.Sprintf("%d swimlanes, %d lists, %d cards", len(b.Swimlanes), lists, cards)), "", "C", false)
+	pdf.MultiCell(0, 5, r.tr("Exported "+time.Now().Format("2006-01-02 15:04")), "", "C", false)
+}
+
+func (r *pdfReport) swimlane(s Swimlane) {
+	pdf := r.pdf
+	pdf.AddPage()
+	pdf.Bookmark(r.tr(s.Name), 0, -1)
+
+	bg := rgbOr(s.BackgroundColor, [3]int{240, 240, 240})
+	fg := rgbOr(s.TextColor, [3]int{0, 0, 0})
+	pdf.SetFillColor(bg[0], bg[1], bg[2])
+	pdf.SetTextColor(fg[0], fg[1], fg[2])
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, r.tr(s.Name), "", 1, "L", true, 0, "")
+
+	if len(s.Lists) == 0 {
+		pdf.Ln(3)
+		pdf.SetTextColor(96, 96, 96)
+		pdf.SetFont("Helvetica", "I", 10)
+		pdf.CellFormat(0, pdfLineH, "This swimlane has no lists.", "", 1, "L", false, 0, "")
+		return
+	}
+	for _, l := range s.Lists {
+		r.list(l)
+	}
+}
+
+// Column layout of a list table.
+type pdfColumns struct {
+	x     [5]float64
+	w     [5]float64
+	title [5]string
+}
+
+func (r *pdfReport) columns() pdfColumns {
+	pageW, _ := r.pdf.GetPageSize()
+	content := pageW - 2*pdfMargin
+	fixed := 8.0 + 30.0 + pdfThumbSize + 2
+	rest := content - fixed
+	c := pdfColumns{
+		w:     [5]float64{8, rest * 0.35, rest * 0.65, 30, pdfThumbSize + 2},
+		title: [5]string{"#", "Title", "Description", "Created", "Image"},
+	}
+	x := pdfMargin
+	for i := range c.w {
+		c.x[i] = x
+		x += c.w[i]
+	}
+	return c
+}
+
+func (r *pdfReport) list(l List) {
+	pdf := r.pdf
+	_, pageH := pdf.GetPageSize()
+	cols := r.columns()
+
+	// Keep the list heading together with the table header and first row.
+	if pdf.GetY()+8+2*pdfLineH+pdfThumbSize > pageH-pdfMargin {
+		pdf.AddPage()
+	} else {
+		pdf.Ln(4)
+	}
+	r.listHeading(l, "")
+
+	if len(l.Cards) == 0 {
+		pdf.SetTextColor(96, 96, 96)
+		pdf.SetFont("Helvetica", "I", 10)
+		pdf.CellFormat(0, pdfLineH, "This list has no cards yet.", "", 1, "L", false, 0, "")
+		return
+	}
+
+	r.tableHeader(cols)
+	for i, c := range l.Cards {
+		title, desc, thumb, thumbW, thumbH := r.cardCells(c, cols)
+		rowH := float64(max(len(title), len(desc), 1))*pdfLineH + 2
+		if thumb != "" {
+			rowH = max(rowH, thumbH+2)
+		}
+		if pdf.GetY()+rowH > pageH-pdfMargin {
+			pdf.AddPage()
+			r.listHeading(l, " (continued)")
+			r.tableHeader(cols)
+		}
+		y := pdf.GetY()
+
+		if bg, ok := parseHexColor(c.BackgroundColor); ok {
+			pdf.SetFillColor(bg[0], bg[1], bg[2])
+			pdf.Rect(cols.x[0], y, cols.x[4]+cols.w[4]-cols.x[0], rowH, "F")
+		}
+		pdf.SetDrawColor(180, 180, 180)
+		for k := range cols.w {
+			pdf.Rect(cols.x[k], y, cols.w[k], rowH, "D")
+		}
+
+		fg := rgbOr(c.TextColor, [3]int{0, 0, 0})
+		pdf.SetTextColor(fg[0], fg[1], fg[2])
+		pdf.SetFont("Helvetica", "", 9)
+		r.cellLines(cols.x[0], y, cols.w[0], []string{fmt.Sprint(i + 1)})
+		pdf.SetFont("Helvetica", "B", 9)
+		r.cellLines(cols.x[1], y, cols.w[1], title)
+		pdf.SetFont("Helvetica", "", 9)
+		r.cellLines(cols.x[2], y, cols.w[2], desc)
+		r.cellLines(cols.x[3], y, cols.w[3], []string{formatCreated(c.CreatedAt)})
+		if thumb != "" {
+			pdf.ImageOptions(thumb, cols.x[4]+1, y+1, thumbW, thumbH, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		} else if len(c.Attachment) > 0 {
+			pdf.SetFont("Helvetica", "I", 8)
+			r.cellLines(cols.x[4], y, cols.w[4], []string{"(attachment)"})
+		}
+		pdf.SetXY(pdfMargin, y+rowH)
+	}
+}
+
+func (r *pdfReport) listHeading(l List, suffix string) {
+	pdf := r.pdf
+	bg := rgbOr(l.BackgroundColor, [3]int{250, 250, 250})
+	fg := rgbOr(l.TextColor, [3]int{0, 0, 0})
+	pdf.SetFillColor(bg[0], bg[1], bg[2])
+	pdf.SetTextColor(fg[0], fg[1], fg[2])
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 8, r.tr(fmt.Sprintf("%s (%d cards)%s", l.Name, len(l.Cards), suffix)), "B", 1, "L", true, 0, "")
+}
+
+func (r *pdfReport) tableHeader(cols pdfColumns) {
+	pdf := r.pdf
+	pdf.SetFillColor(220, 220, 220)
+	pdf.SetDrawColor(180, 180, 180)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "B", 9)
+	for k, title := range cols.title {
+		pdf.CellFormat(cols.w[k], 6, title, "1", 0, "L", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// cardCells wraps the title and description to their column widths and
+// registers the attachment thumbnail, if any, returning its image name and size.
+func (r *pdfReport) cardCells(c Card, cols pdfColumns) (title, desc []string, thumb string, thumbW, thumbH float64) {
+	pdf := r.pdf
+	_, pageH := pdf.GetPageSize()
+
+	pdf.SetFont("Helvetica", "B", 9)
+	title = r.split(c.Title, cols.w[1]-2)
+	pdf.SetFont("Helvetica", "", 9)
+	desc = r.split(c.Description, cols.w[2]-2)
+
+	// A single row must fit on a fresh page below the list and table headings.
+	maxLines := int((pageH-2*pdfMargin-8-6-2)/pdfLineH) - 1
+	if len(title) > maxLines {
+		title = append(title[:maxLines-1], title[maxLines-1]+" ...")
+	}
+	if len(desc) > maxLines {
+		desc = append(desc[:maxLines-1], desc[maxLines-1]+" ...")
+	}
+
+	if len(c.Attachment) == 0 {
+		return title, desc, "", 0, 0
+	}
+	data, ok := thumbnailPNG(c.Attachment)
+	if !ok {
+		return title, desc, "", 0, 0
+	}
+	thumb = fmt.Sprintf("card-%d", c.ID)
+	info := pdf.RegisterImageOptionsReader(thumb, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(data))
+	if info == nil || pdf.Err() {
+		return title, desc, "", 0, 0
+	}
+	thumbW, thumbH = pdfThumbSize, pdfThumbSize
+	if info.Width() > info.Height() {
+		thumbH = pdfThumbSize * info.Height() / info.Width()
+	} else {
+		thumbW = pdfThumbSize * info.Width() / info.Height()
+	}
+	return title, desc, thumb, thumbW, thumbH
+}
+
+func (r *pdfReport) split(text string, w float64) []string {
+	var lines []string
+	for _, line := range r.pdf.SplitLines([]byte(r.tr(text)), w) {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+func (r *pdfReport) cellLines(x, y, w float64, lines []string) {
+	for i, line := range lines {
+		r.pdf.SetXY(x+1, y+1+float64(i)*pdfLineH)
+		r.pdf.CellFormat(w-2, pdfLineH, line, "", 0, "L", false, 0, "")
+	}
+}
+
+// thumbnailPNG decodes a PNG, JPEG or GIF attachment and re-encodes it as a
+// small PNG. Re-encoding keeps the PDF small and avoids PNG variants that
+// the PDF writer cannot embed, such as interlaced images.
+func thumbnailPNG(data []byte) ([]byte, bool) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return nil, false
+	}
+	if w > pdfThumbPx || h > pdfThumbPx {
+		if w > h {
+			w, h = pdfThumbPx, max(1, h*pdfThumbPx/w)
+		} else {
+			w, h = max(1, w*pdfThumbPx/h), pdfThumbPx
+		}
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// parseHexColor parses a "#RRGGBB" color as stored in the color columns.
+func parseHexColor(s string) ([3]int, bool) {
+	if len(s) < 7 || s[0] != '#' {
+		return [3]int{}, false
+	}
+	var r, g, b uint8
+	if n, _ := fmt.Sscanf(s, "#%02x%02x%02x", &r, &g, &b); n != 3 {
+		return [3]int{}, false
+	}
+	return [3]int{int(r), int(g), int(b)}, true
+}
+
+func rgbOr(s string, def [3]int) [3]int {
+	if c, ok := parseHexColor(s); ok {
+		return c
+	}
+	return def
+}
+
+// formatCreated shortens a created_at timestamp to minutes.
+func formatCreated(s string) string {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02 15:04")
+		}
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,176 @@
+package export
+
+import (
+	"bytes"
+	"database/sql"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ledongthuc/pdf"
+
+	"tcl-tk-kanban/store"
+)
+
+// openTestDB creates a database with one board: two swimlanes, three lists
+// and cards with colors, a long description and a PNG attachment, and an
+// archived card.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "wekan.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := store.Init(db); err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 32; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 4), 0, uint8(y * 8), 255})
+		}
+	}
+	var attachment bytes.Buffer
+	if err := png.Encode(&attachment, img); err != nil {
+		t.Fatal(err)
+	}
+
+	stmts := []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO boards (id, name, description) VALUES (1, 'Weekly Board', 'State of the team')", nil},
+		{"INSERT INTO swimlanes (id, board_id, name, position, background_color, text_color) VALUES (1, 1, 'Development', 0, '#336699', '#FFFFFF')", nil},
+		{"INSERT INTO swimlanes (id, board_id, name, position) VALUES (2, 1, 'Design', 1)", nil},
+		{"INSERT INTO lists (id, swimlane_id, name, position) VALUES (1, 1, 'Todo', 0)", nil},
+		{"INSERT INTO lists (id, swimlane_id, name, position) VALUES (2, 1, 'Done', 1)", nil},
+		{"INSERT INTO lists (id, swimlane_id, name, position) VALUES (3, 2, 'Ideas', 0)", nil},
+		{"INSERT INTO cards (list_id, title, description, position, background_color) VALUES (1, 'Write exporter', 'Pure Go PDF output', 0, '#FFEEAA')", nil},
+		{"INSERT INTO cards (list_id, title, description, position, attachment) VALUES (1, 'Screenshot card', NULL, 1, ?)", []any{attachment.Bytes()}},
+		{"INSERT INTO cards (list_id, title, description, position) VALUES (2, 'Long card', ?, 0)", []any{strings.Repeat("lorem ipsum dolor ", 400)}},
+		{"INSERT INTO cards (list_id, title, position, archived) VALUES (1, 'Archived card', 2, 1)", nil},
+	}
+	for _, s := range stmts {
+		if _, err := db.Exec(s.query, s.args...); err != nil {
+			t.Fatalf("%s: %v", s.query, err)
+		}
+	}
+	return db
+}
+
+func readPDF(t *testing.T, path string) (*pdf.Reader, string) {
+	t.Helper()
+	f, r, err := pdf.Open(path)
+	if err != nil {
+		t.Fatalf("open generated PDF: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	var text strings.Builder
+	for i := 1; i <= r.NumPage(); i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+		s, err := p.GetPlainText(nil)
+		if err != nil {
+			t.Fatalf("page %d text: %v", i, err)
+		}
+		text.WriteString(s)
+	}
+	return r, text.String()
+}
+
+func TestExportBoardToPDF(t *testing.T) {
+	db := openTestDB(t)
+	out := filepath.Join(t.TempDir(), "board.pdf")
+	if err := ExportBoardToPDF(db, 1, out, PDFOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, text := readPDF(t, out)
+	// Cover, one page per swimlane and at least one overflow page for the long card.
+	if r.NumPage() < 4 {
+		t.Errorf("got %d pages, want at least 4", r.NumPage())
+	}
+	for _, want := range []string{"Weekly Board", "State of the team", "Development", "Design",
+		"Todo", "Done", "Ideas", "Write exporter", "Pure Go PDF output", "Screenshot card", "Long card"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("PDF text missing %q", want)
+		}
+	}
+	if strings.Contains(text, "Archived card") {
+		t.Error("PDF contains the archived card")
+	}
+
+	// The attachment is embedded as an image XObject on the first swimlane page.
+	xobjects := r.Page(2).Resources().Key("XObject")
+	if len(xobjects.Keys()) != 1 {
+		t.Errorf("got %d images on swimlane page, want 1", len(xobjects.Keys()))
+	}
+}
+
+func TestExportBoardToPDFPageSetup(t *testing.T) {
+	db := openTestDB(t)
+	tests := []struct {
+		opts          PDFOptions
+		width, height float64 // points
+	}{
+		{PDFOptions{}, 595.28, 841.89},
+		{PDFOptions{PageSize: "A4", Landscape: true}, 841.89, 595.28},
+		{PDFOptions{PageSize: "Letter"}, 612, 792},
+		{PDFOptions{PageSize: "A3", Landscape: true}, 1190.55, 841.89},
+	}
+	for _, tt := range tests {
+		out := filepath.Join(t.TempDir(), "board.pdf")
+		if err := ExportBoardToPDF(db, 1, out, tt.opts); err != nil {
+			t.Fatalf("%+v: %v", tt.opts, err)
+		}
+		r, _ := readPDF(t, out)
+		box := r.Page(1).V.Key("MediaBox")
+		if box.IsNull() {
+			box = r.Page(1).V.Key("Parent").Key("MediaBox")
+		}
+		w, h := box.Index(2).Float64(), box.Index(3).Float64()
+		if abs(w-tt.width) > 0.5 || abs(h-tt.height) > 0.5 {
+			t.Errorf("%+v: page is %.2fx%.2f, want %.2fx%.2f", tt.opts, w, h, tt.width, tt.height)
+		}
+	}
+}
+
+func TestExportBoardToPDFErrors(t *testing.T) {
+	db := openTestDB(t)
+	out := filepath.Join(t.TempDir(), "board.pdf")
+	if err := ExportBoardToPDF(db, 1, out, PDFOptions{PageSize: "B7"}); err == nil {
+		t.Error("unknown page size: expected error")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("unknown page size created the file: %v", err)
+	}
+	// An existing file is kept
+	if err := os.WriteFile(out, []byte("old report"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportBoardToPDF(db, 1, out, PDFOptions{PageSize: "B7"}); err == nil {
+		t.Error("unknown page size: expected error")
+	}
+	if data, _ := os.ReadFile(out); string(data) != "old report" {
+		t.Errorf("unknown page size overwrote the file with %q", data)
+	}
+	if err := ExportBoardToPDF(db, 42, out, PDFOptions{}); err == nil {
+		t.Error("missing board: expected error")
+	}
+}
+
+func abs(f float64) float64 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,278 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Layout selects how a board is arranged in a spreadsheet.
+type Layout string
+
+const (
+	// LayoutFlat writes one row per card with the board, swimlane and list
+	// repeated in the leading columns.
+	LayoutFlat Layout = "flat"
+	// LayoutHierarchical writes the board, swimlane, list and card on
+	// separate rows, indented one column per level.
+	LayoutHierarchical Layout = "hierarchical"
+	// LayoutBoard writes a summary sheet plus one sheet per swimlane with
+	// the lists as columns, like the on-screen board.
+	LayoutBoard Layout = "board"
+)
+
+// Layouts lists the accepted spreadsheet layouts.
+var Layouts = []Layout{LayoutFlat, LayoutHierarchical, LayoutBoard}
+
+// sheet is one worksheet of an exported workbook.
+type sheet struct {
+	Name      string
+	Rows      []sheetRow
+	Widths    []float64 // column widths in characters
+	HeaderRow int       // 1-based row that is frozen and filtered, 0 for none
+	TabColor  string
+}
+
+// sheetCell is one spreadsheet cell. Col is 1-based.
+type sheetCell struct {
+	Col       int
+	Value     string
+	Numeric   bool // Value holds an integer
+	Bold      bool
+	Header    bool
+	TextColor string
+	FillColor string
+	Link      string // name of a sheet to link to
+}
+
+// sheetRow is one spreadsheet row with an optional image anchored at ImageCol.
+type sheetRow struct {
+	Cells    []sheetCell
+	Image    *sheetImage
+	ImageCol int
+}
+
+type sheetImage struct {
+	Data          []byte
+	Ext           string // ".png", ".jpg" or ".gif"
+	Width, Height int    // pixels
+}
+
+// Flat layout columns.
+var flatHeader = []string{"Board", "Swimlane", "List", "Card Title", "Description", "Created At", "Text Color", "Background Color", "Image"}
+
+// workbookSheets arranges a board into worksheets for the given layout,
+// leaving out archived cards.
+func workbookSheets(b *Board, layout Layout) []sheet {
+	b = withoutArchived(b)
+	switch layout {
+	case LayoutBoard:
+		return boardSheets(b)
+	case LayoutHierarchical:
+		return []sheet{{Name: "Sheet1", Rows: hierarchicalRows(b), Widths: columnWidths(9, 18)}}
+	default:
+		return []sheet{{Name: "Sheet1", Rows: flatRows(b), Widths: columnWidths(len(flatHeader), 18), HeaderRow: 1}}
+	}
+}
+
+func columnWidths(n int, width float64) []float64 {
+	widths := make([]float64, n)
+	for i := range widths {
+		widths[i] = width
+	}
+	return widths
+}
+
+func flatRows(b *Board) []sheetRow {
+	header := sheetRow{}
+	for i, title := range flatHeader {
+		header.Cells = append(header.Cells, sheetCell{Col: i + 1, Value: title, Bold: true, Header: true})
+	}
+	rows := []sheetRow{header}
+	for _, s := range b.Swimlanes {
+		for _, l := range s.Lists {
+			for _, c := range l.Cards {
+				row := sheetRow{Cells: []sheetCell{
+					{Col: 1, Value: b.Name},
+					{Col: 2, Value: s.Name, TextColor: s.TextColor, FillColor: s.BackgroundColor},
+					{Col: 3, Value: l.Name, TextColor: l.TextColor, FillColor: l.BackgroundColor},
+					{Col: 4, Value: c.Title, TextColor: c.TextColor, FillColor: c.BackgroundColor},
+					{Col: 5, Value: c.Description},
+					{Col: 6, Value: c.CreatedAt},
+					{Col: 7, Value: c.TextColor},
+					{Col: 8, Value: c.BackgroundColor},
+				}}
+				if img, ok := decodeSheetImage(c.Attachment); ok {
+					row.Image, row.ImageCol = img, 9
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+func hierarchicalRows(b *Board) []sheetRow {
+	rows := []sheetRow{
+		{Cells: []sheetCell{{Col: 1, Value: "Board:", Bold: true}, {Col: 2, Value: b.Name, Bold: true}}},
+		{Cells: []sheetCell{{Col: 1, Value: "Description:", Bold: true}, {Col: 2, Value: b.Description}}},
+	}
+	for _, s := range b.Swimlanes {
+		rows = append(rows, sheetRow{Cells: []sheetCell{
+			{Col: 1, Value: "Swimlane:", Bold: true},
+			{Col: 2, Value: s.Name, Bold: true, TextColor: s.TextColor, FillColor: s.BackgroundColor},
+		}})
+		for _, l := range s.Lists {
+			rows = append(rows, sheetRow{Cells: []sheetCell{
+				{Col: 2, Value: "List:", Bold: true},
+				{Col: 3, Value: l.Name, Bold: true, TextColor: l.TextColor, FillColor: l.BackgroundColor},
+			}})
+			for _, c := range l.Cards {
+				row := sheetRow{Cells: []sheetCell{
+					{Col: 3, Value: "Card:", Bold: true},
+					{Col: 4, Value: c.Title, TextColor: c.TextColor, FillColor: c.BackgroundColor},
+					{Col: 5, Value: c.Description},
+					{Col: 6, Value: c.CreatedAt},
+					{Col: 7, Value: c.TextColor},
+					{Col: 8, Value: c.BackgroundColor},
+				}}
+				if img, ok := decodeSheetImage(c.Attachment); ok {
+					row.Image, row.ImageCol = img, 9
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+const summarySheet = "Summary"
+
+// boardSheets builds the summary sheet, with a row and a link per swimlane,
+// followed by one sheet per swimlane where each list is a column of cards.
+func boardSheets(b *Board) []sheet {
+	names := sheetNames{strings.ToLower(summarySheet): true}
+	summary := sheet{
+		Name:      summarySheet,
+		Widths:    []float64{32, 10, 10, 24},
+		HeaderRow: 4,
+		Rows: []sheetRow{
+			{Cells: []sheetCell{{Col: 1, Value: b.Name, Bold: true}}},
+			{Cells: []sheetCell{{Col: 1, Value: b.Description}}},
+			{},
+			{Cells: []sheetCell{
+				{Col: 1, Value: "Swimlane", Bold: true, Header: true},
+				{Col: 2, Value: "Lists", Bold: true, Header: true},
+				{Col: 3, Value: "Cards", Bold: true, Header: true},
+				{Col: 4, Value: "Sheet", Bold: true, Header: true},
+			}},
+		},
+	}
+	sheets := []sheet{summary}
+
+	for _, s := range b.Swimlanes {
+		sh := sheet{Name: names.unique(s.Name), HeaderRow: 1, TabColor: normalizeHexColor(s.BackgroundColor)}
+
+		header := sheetRow{}
+		cards := 0
+		for j, l := range s.Lists {
+			header.Cells = append(header.Cells, sheetCell{Col: j + 1, Value: l.Name, Bold: true, Header: true,
+				TextColor: l.TextColor, FillColor: l.BackgroundColor})
+			sh.Widths = append(sh.Widths, 32)
+			cards += len(l.Cards)
+		}
+		if len(s.Lists) == 0 {
+			header.Cells = []sheetCell{{Col: 1, Value: "This swimlane has no lists yet.", Header: true}}
+			sh.Widths = []float64{32}
+		}
+		sh.Rows = append(sh.Rows, header)
+
+		for i := 0; ; i++ {
+			row := sheetRow{}
+			for j, l := range s.Lists {
+				if i >= len(l.Cards) {
+					continue
+				}
+				c := l.Cards[i]
+				value := c.Title
+				if c.Description != "" {
+					value += "\n" + c.Description
+				}
+				row.Cells = append(row.Cells, sheetCell{Col: j + 1, Value: value, TextColor: c.TextColor, FillColor: c.BackgroundColor})
+			}
+			if len(row.Cells) == 0 {
+				break
+			}
+			sh.Rows = append(sh.Rows, row)
+		}
+		sheets = append(sheets, sh)
+
+		sheets[0].Rows = append(sheets[0].Rows, sheetRow{Cells: []sheetCell{
+			{Col: 1, Value: s.Name, TextColor: s.TextColor, FillColor: s.BackgroundColor},
+			{Col: 2, Value: strconv.Itoa(len(s.Lists)), Numeric: true},
+			{Col: 3, Value: strconv.Itoa(cards), Numeric: true},
+			{Col: 4, Value: sh.Name, Link: sh.Name},
+		}})
+	}
+	return sheets
+}
+
+// sheetNames hands out sheet names that are valid in both XLSX and ODS:
+// at most 31 characters, none of []*?:/\' and unique ignoring case.
+type sheetNames map[string]bool
+
+func (n sheetNames) unique(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]*?:/\'`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		name = "Swimlane"
+	}
+	name = truncateRunes(name, 31)
+	candidate := name
+	for i := 2; n[strings.ToLower(candidate)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		candidate = truncateRunes(name, 31-len(suffix)) + suffix
+	}
+	n[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// decodeSheetImage detects the format and size of an image attachment.
+// Attachments that are not PNG, JPEG or GIF images are skipped.
+func decodeSheetImage(data []byte) (*sheetImage, bool) {
+	if len(data) == 0 {
+		return nil, false
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 {
+		return nil, false
+	}
+	ext := map[string]string{"png": ".png", "jpeg": ".jpg", "gif": ".gif"}[format]
+	if ext == "" {
+		return nil, false
+	}
+	return &sheetImage{Data: data, Ext: ext, Width: cfg.Width, Height: cfg.Height}, true
+}
+
+// normalizeHexColor returns a "#RRGGBB" color in upper case, or "" if s is
+// not a valid hex color.
+func normalizeHexColor(s string) string {
+	if _, ok := parseHexColor(s); !ok {
+		return ""
+	}
+	return strings.ToUpper(s[:7])
+}
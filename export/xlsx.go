@@ -0,0 +1,189 @@
+package export
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxSheet = "Sheet1"
+
+// ExportBoardToXLSX writes a board to an Excel workbook using the given layout.
+func ExportBoardToXLSX(db *sql.DB, boardID int, outputFile string, layout Layout) error {
+	b, err := LoadBoard(db, boardID)
+	if err != nil {
+		return err
+	}
+	f, err := xlsxWorkbook(b, layout)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.SaveAs(outputFile)
+}
+
+func xlsxWorkbook(b *Board, layout Layout) (*excelize.File, error) {
+	f := excelize.NewFile()
+	styles := xlsxStyles{f: f, ids: make(map[string]int)}
+	for i, sh := range workbookSheets(b, layout) {
+		var err error
+		if i == 0 {
+			err = f.SetSheetName(xlsxSheet, sh.Name)
+		} else {
+			_, err = f.NewSheet(sh.Name)
+		}
+		if err == nil {
+			err = writeXLSXSheet(f, styles, sh)
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("sheet %q: %w", sh.Name, err)
+		}
+	}
+	f.SetActiveSheet(0)
+	return f, nil
+}
+
+// writeXLSXSheet fills one worksheet: styled cells, sheet links, image
+// attachments anchored in their image column, and a frozen, filtered
+// header row.
+func writeXLSXSheet(f *excelize.File, styles xlsxStyles, sh sheet) error {
+	name := sh.Name
+	for i, w := range sh.Widths {
+		col, _ := excelize.ColumnNumberToName(i + 1)
+		if err := f.SetColWidth(name, col, col, w); err != nil {
+			return err
+		}
+	}
+	for i, row := range sh.Rows {
+		rowNum := i + 1
+		for _, c := range row.Cells {
+			cell, _ := excelize.CoordinatesToCellName(c.Col, rowNum)
+			var value interface{} = c.Value
+			if c.Numeric {
+				value, _ = strconv.Atoi(c.Value)
+			}
+			if err := f.SetCellValue(name, cell, value); err != nil {
+				return err
+			}
+			styleID, err := styles.get(c)
+			if err != nil {
+				return err
+			}
+			if err := f.SetCellStyle(name, cell, cell, styleID); err != nil {
+				return err
+			}
+			if c.Link != "" {
+				if err := f.SetCellHyperLink(name, cell, fmt.Sprintf("'%s'!A1", c.Link), "Location"); err != nil {
+					return err
+				}
+			}
+		}
+		if row.Image != nil {
+			w, h := imageBox(row.Image, xlsxImagePx)
+			if err := f.SetRowHeight(name, rowNum, float64(h)*0.75+4); err != nil {
+				return err
+			}
+			imageCell, _ := excelize.CoordinatesToCellName(row.ImageCol, rowNum)
+			scale := float64(w) / float64(row.Image.Width)
+			err := f.AddPictureFromBytes(name, imageCell, &excelize.Picture{
+				Extension: row.Image.Ext,
+				File:      row.Image.Data,
+				Format:    &excelize.GraphicOptions{ScaleX: scale, ScaleY: scale, OffsetX: 2, OffsetY: 2},
+			})
+			if err != nil {
+				return fmt.Errorf("add image at %s: %w", imageCell, err)
+			}
+		}
+	}
+
+	if sh.HeaderRow > 0 {
+		below, _ := excelize.CoordinatesToCellName(1, sh.HeaderRow+1)
+		err := f.SetPanes(name, &excelize.Panes{Freeze: true, YSplit: sh.HeaderRow, TopLeftCell: below, ActivePane: "bottomLeft"})
+		if err != nil {
+			return err
+		}
+		first, _ := excelize.CoordinatesToCellName(1, sh.HeaderRow)
+		last, _ := excelize.CoordinatesToCellName(len(sh.Widths), max(len(sh.Rows), sh.HeaderRow))
+		if err := f.AutoFilter(name, first+":"+last, nil); err != nil {
+			return err
+		}
+	}
+	// Set after AutoFilter, which replaces the sheet properties.
+	if sh.TabColor != "" {
+		tab := strings.TrimPrefix(sh.TabColor, "#")
+		if err := f.SetSheetProps(name, &excelize.SheetPropsOptions{TabColorRGB: &tab}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Maximum image width/height in pixels inside a spreadsheet cell.
+const xlsxImagePx = 96
+
+// imageBox scales an image to fit a limit x limit box, keeping its aspect ratio.
+func imageBox(img *sheetImage, limit int) (w, h int) {
+	w, h = img.Width, img.Height
+	if w > limit || h > limit {
+		if w > h {
+			w, h = limit, max(1, h*limit/w)
+		} else {
+			w, h = max(1, w*limit/h), limit
+		}
+	}
+	return w, h
+}
+
+// Fill color of header cells without a color of their own.
+const headerFill = "#DDDDDD"
+
+// Font color of sheet links without a color of their own.
+const linkColor = "#0563C1"
+
+// xlsxStyles caches one excelize style per combination of cell formatting.
+type xlsxStyles struct {
+	f   *excelize.File
+	ids map[string]int
+}
+
+func (s xlsxStyles) get(c sheetCell) (int, error) {
+	fg, bg := cellColors(c)
+	link := c.Link != ""
+	key := fmt.Sprintf("%t|%t|%s|%s", c.Bold, link, fg, bg)
+	if id, ok := s.ids[key]; ok {
+		return id, nil
+	}
+	style := &excelize.Style{
+		Font:      &excelize.Font{Bold: c.Bold, Color: strings.TrimPrefix(fg, "#")},
+		Alignment: &excelize.Alignment{Vertical: "top", WrapText: true},
+	}
+	if link {
+		style.Font.Underline = "single"
+	}
+	if bg != "" {
+		style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{bg}}
+	}
+	id, err := s.f.NewStyle(style)
+	if err != nil {
+		return 0, err
+	}
+	s.ids[key] = id
+	return id, nil
+}
+
+// cellColors returns the normalized font and fill colors of a cell,
+// falling back to the header fill and link color.
+func cellColors(c sheetCell) (fg, bg string) {
+	fg, bg = normalizeHexColor(c.TextColor), normalizeHexColor(c.FillColor)
+	if c.Header && bg == "" {
+		bg = headerFill
+	}
+	if c.Link != "" && fg == "" {
+		fg = linkColor
+	}
+	return fg, bg
+}
@@ -0,0 +1,153 @@
+//go:build ignore
+
+// gen_header.go
+// Generates kanban_api.h, the documented C header of the shared library
+// built from xlsx_exporter_embed.go. cgo writes its own header during the
+// build but drops the doc comments.
+//
+// Run with: go generate xlsx_exporter_embed.go
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+)
+
+const (
+	source = "xlsx_exporter_embed.go"
+	output = "kanban_api.h"
+)
+
+// cTypes maps the cgo types used in exported signatures to C.
+var cTypes = map[string]string{
+	"C.int":   "int",
+	"*C.char": "char*",
+}
+
+func main() {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, source, nil, parser.ParseComments)
+	if err != nil {
+		fmt.Println("Failed to parse", source+":", err)
+		os.Exit(1)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "/* Code generated by gen_header.go from %s. DO NOT EDIT. */\n\n", source)
+	b.WriteString("#ifndef KANBAN_API_H\n#define KANBAN_API_H\n\n")
+	b.WriteString("/*\n")
+	// The file comment, without its first line naming the file.
+	for _, line := range docLines(f.Comments[0])[1:] {
+		b.WriteString(strings.TrimRight(" * "+line, " ") + "\n")
+	}
+	b.WriteString(" */\n\n#ifdef __cplusplus\nextern \"C\" {\n#endif\n")
+
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || !exported(fn) {
+			continue
+		}
+		proto, err := prototype(fset, fn)
+		if err != nil {
+			fmt.Println("Failed to generate header:", err)
+			os.Exit(1)
+		}
+		b.WriteString("\n/*\n")
+		for _, line := range docLines(fn.Doc) {
+			b.WriteString(strings.TrimRight(" * "+line, " ") + "\n")
+		}
+		b.WriteString(" */\n" + proto + "\n")
+	}
+	b.WriteString("\n#ifdef __cplusplus\n}\n#endif\n\n#endif /* KANBAN_API_H */\n")
+
+	if err := os.WriteFile(output, b.Bytes(), 0644); err != nil {
+		fmt.Println("Failed to write", output+":", err)
+		os.Exit(1)
+	}
+}
+
+func exported(fn *ast.FuncDecl) bool {
+	if fn.Doc == nil {
+		return false
+	}
+	for _, c := range fn.Doc.List {
+		if strings.HasPrefix(c.Text, "//export ") {
+			return true
+		}
+	}
+	return false
+}
+
+// docLines returns the lines of a comment without //export directives or
+// trailing blank lines.
+func docLines(cg *ast.CommentGroup) []string {
+	var lines []string
+	for _, line := range strings.Split(cg.Text(), "\n") {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func prototype(fset *token.FileSet, fn *ast.FuncDecl) (string, error) {
+	ret := "void"
+	if fn.Type.Results != nil {
+		t, err := cType(fset, fn.Type.Results.List[0].Type)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", fn.Name.Name, err)
+		}
+		ret = t
+	}
+	var params []string
+	for _, field := range fn.Type.Params.List {
+		t, err := cType(fset, field.Type)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", fn.Name.Name, err)
+		}
+		for _, name := range field.Names {
+			params = append(params, t+" "+name.Name)
+		}
+	}
+	if len(params) == 0 {
+		params = []string{"void"}
+	}
+	return fmt.Sprintf("extern %s %s(%s);", ret, fn.Name.Name, strings.Join(params, ", ")), nil
+}
+
+func cType(fset *token.FileSet, expr ast.Expr) (string, error) {
+	var b bytes.Buffer
+	if err := printExpr(&b, expr); err != nil {
+		return "", err
+	}
+	t, ok := cTypes[b.String()]
+	if !ok {
+		return "", fmt.Errorf("%s: no C type for %s", fset.Position(expr.Pos()), b.String())
+	}
+	return t, nil
+}
+
+func printExpr(b *bytes.Buffer, expr ast.Expr) error {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		b.WriteString("*")
+		return printExpr(b, e.X)
+	case *ast.SelectorExpr:
+		if err := printExpr(b, e.X); err != nil {
+			return err
+		}
+		b.WriteString("." + e.Sel.Name)
+		return nil
+	case *ast.Ident:
+		b.WriteString(e.Name)
+		return nil
+	}
+	return fmt.Errorf("unsupported type expression %T", expr)
+}
@@ -0,0 +1,509 @@
+package gitstore
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// level is a table of the board tree.
+type level struct {
+	table string
+	kind  string
+	// parent is the column of the parent row, "" for boards
+	parent string
+}
+
+var levels = []level{
+	{"boards", "board", ""},
+	{"swimlanes", "swimlane", "board_id"},
+	{"lists", "list", "swimlane_id"},
+	{"cards", "card", "list_id"},
+}
+
+var (
+	boardDir    = regexp.MustCompile(`^board-(\d+)$`)
+	boardFile   = regexp.MustCompile(`^(board\.(json|swimlanes)|(swimlane|list|card)-\d+\.[a-z_]+)$`)
+	orderedName = regexp.MustCompile(`^(swimlane|list|card)-(\d+)$`)
+)
+
+// fileName returns the name of the files of a row, without extension:
+// "board" for the board of its directory, "card-4" for card 4.
+func fileName(depth int, id int64) string {
+	if depth == 0 {
+		return "board"
+	}
+	return fmt.Sprintf("%s-%d", levels[depth].kind, id)
+}
+
+// row is a row of the board tree.
+type row struct {
+	id, parent int64
+	// fields are the columns written to the JSON file: all but the ID, the
+	// parent, the position and the blobs
+	fields map[string]any
+	blobs  map[string][]byte
+}
+
+// title returns the title or the name of the row.
+func title(fields map[string]any) string {
+	for _, column := range []string{"title", "name"} {
+		if s, ok := fields[column].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// columns returns the columns of a table and their declared types.
+func columns(q interface {
+	Query(string, ...any) (*sql.Rows, error)
+}, table string) ([]string, map[string]string, error) {
+	rows, err := q.Query("SELECT name, type FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+	var names []string
+	types := make(map[string]string)
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, nil, fmt.Errorf("read columns of %s: %w", table, err)
+		}
+		names = append(names, name)
+		types[name] = strings.ToUpper(typ)
+	}
+	return names, types, rows.Err()
+}
+
+// readRows reads the rows of a level, grouped by parent and in order.
+func readRows(tx *sql.Tx, lv level) (map[int64][]row, error) {
+	names, types, err := columns(tx, lv.table)
+	if err != nil {
+		return nil, err
+	}
+	order := "id"
+	// +column drops the declared type, so that the driver returns
+	// timestamps as they are stored
+	exprs := make([]string, len(names))
+	for i, name := range names {
+		exprs[i] = fmt.Sprintf("+%q", name)
+		if name == "position" {
+			order = "position, id"
+		}
+	}
+	rows, err := tx.Query("SELECT " + strings.Join(exprs, ", ") + " FROM " + lv.table + " ORDER BY " + order)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", lv.table, err)
+	}
+	defer rows.Close()
+	out := make(map[int64][]row)
+	values := make([]any, len(names))
+	dest := make([]any, len(names))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("read %s: %w", lv.table, err)
+		}
+		r := row{fields: make(map[string]any), blobs: make(map[string][]byte)}
+		for i, name := range names {
+			switch v := values[i].(type) {
+			case []byte:
+				r.blobs[name] = bytes.Clone(v)
+			default:
+				switch {
+				case name == "id":
+					r.id, _ = v.(int64)
+				case name == lv.parent:
+					r.parent, _ = v.(int64)
+				// A blob column without a file is NULL
+				case name == "position", v == nil && types[name] == "BLOB":
+				default:
+					r.fields[name] = v
+				}
+			}
+		}
+		out[r.parent] = append(out[r.parent], r)
+	}
+	return out, rows.Err()
+}
+
+// encode returns the JSON file of a row.
+func encode(fields map[string]any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(fields); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// render returns the files of the boards of the database by path.
+func (r *Repo) render() (map[string][]byte, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("read boards: %w", err)
+	}
+	defer tx.Rollback()
+	tree := make([]map[int64][]row, len(levels))
+	for depth, lv := range levels {
+		if tree[depth], err = readRows(tx, lv); err != nil {
+			return nil, err
+		}
+	}
+
+	files := make(map[string][]byte)
+	var add func(dir string, depth int, r row) error
+	add = func(dir string, depth int, r row) error {
+		name := path.Join(dir, fileName(depth, r.id))
+		data, err := encode(r.fields)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", name, err)
+		}
+		files[name+".json"] = data
+		for column, blob := range r.blobs {
+			files[name+"."+column] = blob
+		}
+		if depth+1 == len(levels) {
+			return nil
+		}
+		var order bytes.Buffer
+		for _, child := range tree[depth+1][r.id] {
+			line := strings.Join(strings.Fields(title(child.fields)), " ")
+			fmt.Fprintln(&order, strings.TrimSpace(fileName(depth+1, child.id)+" "+line))
+			if err := add(dir, depth+1, child); err != nil {
+				return err
+			}
+		}
+		files[name+"."+levels[depth+1].table] = order.Bytes()
+		return nil
+	}
+	for _, board := range tree[0][0] {
+		if err := add(fmt.Sprintf("board-%d", board.id), 0, board); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// existing returns the paths of the board files in the directory.
+func (r *Repo) existing() (map[string]bool, error) {
+	dirs, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", r.dir, err)
+	}
+	out := make(map[string]bool)
+	for _, d := range dirs {
+		if !d.IsDir() || !boardDir.MatchString(d.Name()) {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(r.dir, d.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", d.Name(), err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() && boardFile.MatchString(e.Name()) {
+				out[d.Name()+"/"+e.Name()] = true
+			}
+		}
+	}
+	return out, nil
+}
+
+// Save writes the boards of the database to the directory, removes the
+// files of deleted rows and returns the files changed, by path.
+func (r *Repo) Save() ([]Change, error) {
+	files, err := r.render()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := r.existing()
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, name := range sortedKeys(files) {
+		data := files[name]
+		file := filepath.Join(r.dir, filepath.FromSlash(name))
+		action := Modified
+		old, err := os.ReadFile(file)
+		switch {
+		case os.IsNotExist(err):
+			action = Added
+		case err != nil:
+			return changes, fmt.Errorf("read %s: %w", name, err)
+		case bytes.Equal(old, data):
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return changes, fmt.Errorf("write %s: %w", name, err)
+		}
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			return changes, fmt.Errorf("write %s: %w", name, err)
+		}
+		changes = append(changes, Change{Path: name, Action: action, Name: nameOf(name, data)})
+	}
+	for _, name := range sortedKeys(existing) {
+		if _, ok := files[name]; ok {
+			continue
+		}
+		file := filepath.Join(r.dir, filepath.FromSlash(name))
+		old, err := os.ReadFile(file)
+		if err != nil {
+			return changes, fmt.Errorf("read %s: %w", name, err)
+		}
+		if err := os.Remove(file); err != nil {
+			return changes, fmt.Errorf("remove %s: %w", name, err)
+		}
+		changes = append(changes, Change{Path: name, Action: Deleted, Name: nameOf(name, old)})
+		// The directory of a deleted board goes when it is empty
+		os.Remove(filepath.Dir(file))
+	}
+	return changes, nil
+}
+
+// nameOf returns the title or name in a JSON file.
+func nameOf(name string, data []byte) string {
+	if !strings.HasSuffix(name, ".json") {
+		return ""
+	}
+	var fields map[string]any
+	if json.Unmarshal(data, &fields) != nil {
+		return ""
+	}
+	return title(fields)
+}
+
+// loader reads the files of the boards into the database.
+type loader struct {
+	tx  *sql.Tx
+	dir string
+	// seen are the IDs loaded per level
+	seen []map[int64]bool
+	// columns are the declared types of the columns per table
+	columns map[string]map[string]string
+}
+
+// Load replaces the boards of the database with those of the files,
+// keeping the IDs of the file names so that rules and saved filters still
+// apply. A directory without boards loads nothing, so that the next Commit
+// adds the boards of the database.
+func (r *Repo) Load() error {
+	dirs, err := os.ReadDir(r.dir)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", r.dir, err)
+	}
+	var boards []int64
+	for _, d := range dirs {
+		if m := boardDir.FindStringSubmatch(d.Name()); m != nil && d.IsDir() {
+			id, _ := strconv.ParseInt(m[1], 10, 64)
+			boards = append(boards, id)
+		}
+	}
+	if len(boards) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("load boards: %w", err)
+	}
+	defer tx.Rollback()
+	l := &loader{tx: tx, dir: r.dir, columns: make(map[string]map[string]string)}
+	for range levels {
+		l.seen = append(l.seen, make(map[int64]bool))
+	}
+	for _, id := range boards {
+		if err := l.load(fmt.Sprintf("board-%d", id), 0, id, 0, 0); err != nil {
+			return err
+		}
+	}
+	// Rows missing from the files were deleted, children first
+	for depth := len(levels) - 1; depth >= 0; depth-- {
+		if err := l.deleteMissing(depth); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("load boards: %w", err)
+	}
+	return nil
+}
+
+// load reads a row and its children from dir.
+func (l *loader) load(dir string, depth int, id, parent int64, position int) error {
+	lv := levels[depth]
+	name := path.Join(dir, fileName(depth, id))
+	data, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(name+".json")))
+	if err != nil {
+		return fmt.Errorf("load %s: %w", lv.kind, err)
+	}
+	var fields map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return fmt.Errorf("load %s.json: %w", name, err)
+	}
+	if err := l.upsert(lv, name, id, parent, position, fields); err != nil {
+		return err
+	}
+	l.seen[depth][id] = true
+	if depth+1 == len(levels) {
+		return nil
+	}
+
+	orderFile := name + "." + levels[depth+1].table
+	f, err := os.Open(filepath.Join(l.dir, filepath.FromSlash(orderFile)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load %s: %w", orderFile, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n, i := 1, 0; scanner.Scan(); n++ {
+		first, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if first == "" {
+			continue
+		}
+		m := orderedName.FindStringSubmatch(first)
+		if m == nil || m[1] != levels[depth+1].kind {
+			return fmt.Errorf("load %s line %d: %q is not a %s", orderFile, n, first, levels[depth+1].kind)
+		}
+		childID, _ := strconv.ParseInt(m[2], 10, 64)
+		if err := l.load(dir, depth+1, childID, id, i); err != nil {
+			return err
+		}
+		i++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("load %s: %w", orderFile, err)
+	}
+	return nil
+}
+
+// upsert inserts or updates a row from the fields of its JSON file and its
+// blob files. Columns missing from the file keep their value.
+func (l *loader) upsert(lv level, name string, id, parent int64, position int, fields map[string]any) error {
+	types, ok := l.columns[lv.table]
+	if !ok {
+		var err error
+		if _, types, err = columns(l.tx, lv.table); err != nil {
+			return err
+		}
+		l.columns[lv.table] = types
+	}
+	values := map[string]any{"id": id}
+	if lv.parent != "" {
+		values[lv.parent] = parent
+	}
+	if _, ok := types["position"]; ok {
+		values["position"] = position
+	}
+	for column, value := range fields {
+		if _, ok := types[column]; !ok {
+			continue
+		}
+		if _, ok := values[column]; ok {
+			continue
+		}
+		switch v := value.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				value = n
+			} else if value, err = v.Float64(); err != nil {
+				return fmt.Errorf("load %s.json: %s: %w", name, column, err)
+			}
+		case bool:
+			value = 0
+			if v {
+				value = 1
+			}
+		case string, nil:
+		default:
+			return fmt.Errorf("load %s.json: %s is not a string or a number", name, column)
+		}
+		values[column] = value
+	}
+	for column, typ := range types {
+		if _, ok := values[column]; ok || typ != "BLOB" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(name+"."+column)))
+		switch {
+		case os.IsNotExist(err):
+			values[column] = nil
+		case err != nil:
+			return fmt.Errorf("load %s.%s: %w", name, column, err)
+		default:
+			values[column] = data
+		}
+	}
+
+	names := sortedKeys(values)
+	quoted := make([]string, len(names))
+	args := make([]any, len(names))
+	var set, old, updated []string
+	for i, column := range names {
+		quoted[i] = fmt.Sprintf("%q", column)
+		args[i] = values[column]
+		if column != "id" {
+			set = append(set, fmt.Sprintf("%s = excluded.%s", quoted[i], quoted[i]))
+			old = append(old, lv.table+"."+quoted[i])
+			updated = append(updated, "excluded."+quoted[i])
+		}
+	}
+	// Unchanged rows are not written, so that loading the files again
+	// changes nothing
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (id) DO UPDATE SET %s WHERE (%s) IS NOT (%s)",
+		lv.table, strings.Join(quoted, ", "), strings.Repeat(", ?", len(names))[2:], strings.Join(set, ", "),
+		strings.Join(old, ", "), strings.Join(updated, ", "))
+	if _, err := l.tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("load %s: %w", name, err)
+	}
+	return nil
+}
+
+// deleteMissing deletes the rows of a level that the files do not have.
+func (l *loader) deleteMissing(depth int) error {
+	lv := levels[depth]
+	rows, err := l.tx.Query("SELECT id FROM " + lv.table)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", lv.table, err)
+	}
+	var missing []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("load %s: %w", lv.table, err)
+		}
+		if !l.seen[depth][id] {
+			missing = append(missing, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("load %s: %w", lv.table, err)
+	}
+	for _, id := range missing {
+		if _, err := l.tx.Exec("DELETE FROM "+lv.table+" WHERE id = ?", id); err != nil {
+			return fmt.Errorf("delete %s %d: %w", lv.kind, id, err)
+		}
+	}
+	return nil
+}
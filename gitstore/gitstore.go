@@ -0,0 +1,266 @@
+// Package gitstore keeps the boards of a kanban database as a directory of
+// small JSON files in a git repository and commits every change, so that
+// the history of the boards is in git log, changes of the backlog can be
+// reviewed as diffs, and the boards can live in the repository of the code
+// they plan.
+//
+// Each board is a directory:
+//
+//	board-1/board.json           the columns of the board
+//	board-1/board.swimlanes      its swimlanes in order, one per line
+//	board-1/swimlane-2.json
+//	board-1/swimlane-2.lists     the lists of swimlane 2 in order
+//	board-1/list-3.json
+//	board-1/list-3.cards         the cards of list 3 in order
+//	board-1/card-4.json
+//	board-1/card-4.attachment    the attachment of card 4, if it has one
+//
+// A line of an ordering file is the name of a row followed by its title,
+// for readers: "card-4 Fix the login". The JSON files hold neither
+// positions nor parents, which follow from the ordering files, so moving
+// a card changes only ordering files.
+//
+// A database in the directory, ignored by git, is the working copy. Load
+// reads the files into it, keeping the row IDs of the file names, and
+// Commit writes its boards back to the files and commits them. Storage is
+// the store.Storage of the directory: each of its changes is made in the
+// working copy and committed right away, along with the changes of the rules
+// it runs. Changes made to the working copy by other means, such as by other
+// programs, are committed with the next Commit.
+package gitstore
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WorkingCopy is the name of the working copy database in the directory.
+const WorkingCopy = ".kanban.db"
+
+// pathspecs limit git to the files of the boards, so that other changes
+// of the repository are not committed with them.
+var pathspecs = []string{":(glob)board-*/**", ".gitignore"}
+
+// Repo is a directory of boards and the database it is loaded into.
+type Repo struct {
+	db  *sql.DB
+	dir string
+}
+
+// Open returns the directory dir of boards, loaded into db.
+func Open(db *sql.DB, dir string) *Repo {
+	return &Repo{db: db, dir: dir}
+}
+
+// Init prepares dir for keeping boards: it creates the directory, a git
+// repository if dir is not in one, and .gitignore entries for the working
+// copy and its backups. It returns the path of the working copy.
+func Init(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create %s: %w", dir, err)
+	}
+	if _, err := git(dir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		if _, err := git(dir, "init", "-q"); err != nil {
+			return "", err
+		}
+	}
+	ignore := filepath.Join(dir, ".gitignore")
+	data, err := os.ReadFile(ignore)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("read .gitignore: %w", err)
+	}
+	written := len(data)
+	// The backups of the working copy go next to it; see backup.Dir
+	for _, entry := range []string{WorkingCopy + "*", "backups/"} {
+		if strings.Contains("\n"+string(data), "\n"+entry+"\n") {
+			continue
+		}
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+			data = append(data, '\n')
+		}
+		data = append(data, entry+"\n"...)
+	}
+	if len(data) != written {
+		if err := os.WriteFile(ignore, data, 0o644); err != nil {
+			return "", fmt.Errorf("write .gitignore: %w", err)
+		}
+	}
+	return filepath.Join(dir, WorkingCopy), nil
+}
+
+// Action is what happened to a file.
+type Action string
+
+const (
+	Added    Action = "add"
+	Modified Action = "edit"
+	Deleted  Action = "delete"
+)
+
+// Change is a file written or removed by Save.
+type Change struct {
+	// Path is relative to the directory, such as "board-1/card-4.json".
+	Path   string
+	Action Action
+	// Name is the title or name of the row of a JSON file, "" for other
+	// files.
+	Name string
+}
+
+// kind returns what the file holds: "card" for card-4.json, "cards" for
+// list-3.cards and "attachment" for card-4.attachment.
+func (c Change) kind() string {
+	base := path.Base(c.Path)
+	name, ext, _ := strings.Cut(base, ".")
+	if ext == "json" {
+		kind, _, _ := strings.Cut(name, "-")
+		return kind
+	}
+	return ext
+}
+
+func (c Change) String() string {
+	if c.Name == "" {
+		return fmt.Sprintf("%s %s", c.Action, c.Path)
+	}
+	return fmt.Sprintf("%s %s (%q)", c.Action, c.Path, c.Name)
+}
+
+// Commit saves the boards to the files and commits the changed files, and
+// only those, with a message describing the changes. It returns the
+// changes, none if the files were up to date.
+func (r *Repo) Commit() ([]Change, error) {
+	changes, err := r.Save()
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+	if _, err := git(r.dir, append([]string{"add", "-A", "--"}, pathspecs...)...); err != nil {
+		return changes, err
+	}
+	// A file added and removed again between two commits leaves nothing
+	// to commit
+	if _, err := git(r.dir, append([]string{"diff", "--cached", "--quiet", "--"}, pathspecs...)...); err == nil {
+		return changes, nil
+	}
+	args := append([]string{"commit", "-q", "-m", message(changes), "--"}, pathspecs...)
+	if _, err := git(r.dir, args...); err != nil {
+		return changes, err
+	}
+	return changes, nil
+}
+
+// message describes changes for a commit: a subject such as
+// `Edit card "Fix the login"` or "Add 2 cards; delete 1 list, 1 card",
+// and the changed files.
+func message(changes []Change) string {
+	type group struct {
+		action Action
+		kind   string
+	}
+	counts := make(map[group]int)
+	var groups []group
+	var rows []Change
+	orderings := make(map[string]int)
+	for _, c := range changes {
+		kind := c.kind()
+		switch {
+		case strings.HasSuffix(c.Path, ".json"):
+			g := group{c.Action, kind}
+			if counts[g] == 0 {
+				groups = append(groups, g)
+			}
+			counts[g]++
+			rows = append(rows, c)
+		case kind == "swimlanes" || kind == "lists" || kind == "cards":
+			orderings[kind]++
+		}
+	}
+
+	var subject string
+	switch {
+	case len(rows) == 1:
+		name := rows[0].Name
+		if name == "" {
+			name = strings.TrimSuffix(path.Base(rows[0].Path), ".json")
+		}
+		subject = fmt.Sprintf("%s %s %q", rows[0].Action, rows[0].kind(), name)
+	case len(rows) > 1:
+		rank := func(g group) int {
+			r := strings.Index("add edit delete", string(g.action)) * 10
+			for i, lv := range levels {
+				if lv.kind == g.kind {
+					r += i
+				}
+			}
+			return r
+		}
+		sort.Slice(groups, func(i, j int) bool { return rank(groups[i]) < rank(groups[j]) })
+		var parts []string
+		for i, g := range groups {
+			part := fmt.Sprintf("%d %s", counts[g], g.kind)
+			if counts[g] > 1 {
+				part += "s"
+			}
+			if i == 0 || groups[i-1].action != g.action {
+				parts = append(parts, fmt.Sprintf("%s %s", g.action, part))
+			} else {
+				parts[len(parts)-1] += ", " + part
+			}
+		}
+		subject = strings.Join(parts, "; ")
+	case len(orderings) > 0:
+		var parts []string
+		for _, kind := range []string{"swimlanes", "lists", "cards"} {
+			switch {
+			case orderings[kind] > 1:
+				parts = append(parts, "move "+kind)
+			case orderings[kind] == 1:
+				parts = append(parts, "reorder "+kind)
+			}
+		}
+		subject = strings.Join(parts, ", ")
+	default:
+		subject = fmt.Sprintf("%s %s", changes[0].Action, changes[0].Path)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(subject[:1]) + subject[1:] + "\n\n")
+	for _, c := range changes {
+		b.WriteString(c.String() + "\n")
+	}
+	return b.String()
+}
+
+// git runs git in dir and returns its output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+	}
+	return string(out), nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
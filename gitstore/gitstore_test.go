@@ -0,0 +1,312 @@
+package gitstore
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tcl-tk-kanban/store"
+)
+
+const testSchema = `
+	CREATE TABLE boards (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		description TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE swimlanes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		board_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		position INTEGER DEFAULT 0,
+		FOREIGN KEY (board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);
+	CREATE TABLE lists (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		swimlane_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		position INTEGER DEFAULT 0,
+		wip_limit INTEGER DEFAULT 0,
+		FOREIGN KEY (swimlane_id) REFERENCES swimlanes(id) ON DELETE CASCADE
+	);
+	CREATE TABLE cards (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		list_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT,
+		position INTEGER DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		attachment BLOB,
+		FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE
+	);
+	CREATE TABLE rules (
+		id INTEGER PRIMARY KEY,
+		board_id INTEGER NOT NULL REFERENCES boards(id) ON DELETE CASCADE
+	);
+`
+
+const sampleBoard = `
+	INSERT INTO boards (name, description, created_at) VALUES ('Project', 'Shared <team> board', '2024-01-02 03:04:05');
+	INSERT INTO swimlanes (board_id, name) VALUES (1, 'Lane');
+	INSERT INTO lists (swimlane_id, name, position, wip_limit) VALUES (1, 'Todo', 0, 3), (1, 'Done', 1, 0);
+	INSERT INTO cards (list_id, title, description, position, created_at, attachment) VALUES
+		(1, 'Write', 'Text
+on two lines', 0, '2024-01-02 03:04:05', X'89504E47'),
+		(1, 'Review', NULL, 1, '2024-01-03 00:00:00', NULL);
+	INSERT INTO rules (board_id) VALUES (1);
+`
+
+// newRepo makes a git repository with a board directory in it and a
+// working copy database with the kanban schema and setup.
+func newRepo(t *testing.T, setup string) (*sql.DB, *Repo, string) {
+	t.Helper()
+	if _, err := git(".", "--version"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	root := t.TempDir()
+	if _, err := git(root, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, "kanban")
+	path, err := Init(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := openDB(t, path, setup)
+	return db, Open(db, dir), root
+}
+
+func openDB(t *testing.T, path, setup string) *sql.DB {
+	t.Helper()
+	db, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	execSQL(t, db, testSchema+setup)
+	return db
+}
+
+func execSQL(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commit(t *testing.T, r *Repo) []Change {
+	t.Helper()
+	changes, err := r.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return changes
+}
+
+// dump lists the rows of db with their IDs and positions.
+func dump(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`
+		SELECT 'board ' || id || ' ' || name || ' ' || COALESCE(description, '') || ' ' || created_at FROM boards
+		UNION ALL
+		SELECT 'swimlane ' || id || ' ' || board_id || ' ' || position || ' ' || name FROM swimlanes
+		UNION ALL
+		SELECT 'list ' || id || ' ' || swimlane_id || ' ' || position || ' ' || name || ' ' || wip_limit FROM lists
+		UNION ALL
+		SELECT 'card ' || id || ' ' || list_id || ' ' || position || ' ' || title || ' ' || COALESCE(description, '') || ' ' ||
+			created_at || ' ' || quote(attachment) FROM cards
+		ORDER BY 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// subjects returns the subjects of the commits, newest first.
+func subjects(t *testing.T, root string) []string {
+	t.Helper()
+	out, err := git(root, "log", "--format=%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(out), "\n")
+}
+
+func TestRoundTrip(t *testing.T) {
+	db, r, _ := newRepo(t, sampleBoard)
+	if _, err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(r.dir, "board-1", "list-1.cards"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "card-1 Write\ncard-2 Review\n" {
+		t.Errorf("list-1.cards: %q", data)
+	}
+	data, err = os.ReadFile(filepath.Join(r.dir, "board-1", "card-1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{
+  "created_at": "2024-01-02 03:04:05",
+  "description": "Text\non two lines",
+  "title": "Write"
+}
+`
+	if string(data) != want {
+		t.Errorf("card-1.json:\n%s", data)
+	}
+
+	copied := openDB(t, filepath.Join(t.TempDir(), "copy.db"), "")
+	if err := Open(copied, r.dir).Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dump(t, copied), dump(t, db); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded:\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCommitEveryChange(t *testing.T) {
+	db, r, root := newRepo(t, sampleBoard)
+	// Other changes of the repository are not committed with the boards
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(root, "add", "main.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	if changes := commit(t, r); len(changes) != 11 {
+		t.Errorf("first commit: %v", changes)
+	}
+	execSQL(t, db, "UPDATE cards SET title = 'Written' WHERE id = 1")
+	commit(t, r)
+	execSQL(t, db, "UPDATE cards SET list_id = 2, position = 0 WHERE id = 2")
+	changes := commit(t, r)
+	if len(changes) != 2 || changes[0].Path != "board-1/list-1.cards" || changes[1].Path != "board-1/list-2.cards" {
+		t.Errorf("move: %v", changes)
+	}
+	if changes := commit(t, r); len(changes) != 0 {
+		t.Errorf("unchanged: %v", changes)
+	}
+	execSQL(t, db, "INSERT INTO cards (list_id, title, position) VALUES (1, 'New', 1), (1, 'Newer', 2)")
+	execSQL(t, db, "DELETE FROM lists WHERE id = 2")
+	commit(t, r)
+
+	want := []string{
+		"Add 2 cards; delete 1 list, 1 card",
+		"Move cards",
+		`Edit card "Written"`,
+		"Add 1 board, 1 swimlane, 2 lists, 2 cards",
+	}
+	if got := subjects(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("log:\n%q\nwant\n%q", got, want)
+	}
+	out, err := git(root, "status", "--porcelain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "A  main.go\n" {
+		t.Errorf("status:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(r.dir, "board-1", "card-2.json")); !os.IsNotExist(err) {
+		t.Errorf("card-2.json of the deleted card: %v", err)
+	}
+
+	execSQL(t, db, "DELETE FROM boards")
+	commit(t, r)
+	if _, err := os.Stat(filepath.Join(r.dir, "board-1")); !os.IsNotExist(err) {
+		t.Errorf("directory of the deleted board: %v", err)
+	}
+}
+
+func TestLoadChangedFiles(t *testing.T) {
+	db, r, _ := newRepo(t, sampleBoard)
+	commit(t, r)
+
+	// As a git pull would: card 2 is renamed and moved to the top of
+	// list 2, card 1 loses its attachment and list 1 is deleted
+	board := filepath.Join(r.dir, "board-1")
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(board, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("card-2.json", `{"title": "Reviewed", "created_at": "2024-01-03 00:00:00", "description": null}`)
+	write("list-2.cards", "card-2 Reviewed\ncard-1\n")
+	write("swimlane-1.lists", "list-2 Done\n")
+	for _, name := range []string{"card-1.attachment", "list-1.json", "list-1.cards"} {
+		if err := os.Remove(filepath.Join(board, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.Load(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"board 1 Project Shared <team> board 2024-01-02 03:04:05",
+		"card 1 2 1 Write Text\non two lines 2024-01-02 03:04:05 NULL",
+		"card 2 2 0 Reviewed  2024-01-03 00:00:00 NULL",
+		"list 2 1 0 Done 0",
+		"swimlane 1 1 0 Lane",
+	}
+	if got := dump(t, db); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded:\n%q\nwant\n%q", got, want)
+	}
+	// The board kept its ID, and so its rules
+	var rules int
+	db.QueryRow("SELECT COUNT(*) FROM rules").Scan(&rules)
+	if rules != 1 {
+		t.Errorf("%d rules", rules)
+	}
+	// Saving writes the files as they were loaded, apart from the
+	// title of card 1 in the ordering file and the JSON formatting
+	changes, err := r.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+	}
+	if want := []string{"board-1/card-2.json", "board-1/list-2.cards"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("saved %v, want %v", paths, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	db, r, _ := newRepo(t, sampleBoard)
+	commit(t, r)
+	before := dump(t, db)
+	if err := os.WriteFile(filepath.Join(r.dir, "board-1", "list-1.cards"), []byte("list-2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Load(); err == nil || !strings.Contains(err.Error(), `list-1.cards line 1: "list-2" is not a card`) {
+		t.Errorf("load: %v", err)
+	}
+	if got := dump(t, db); !reflect.DeepEqual(got, before) {
+		t.Errorf("failed load changed the database:\n%q", got)
+	}
+}
@@ -0,0 +1,180 @@
+package gitstore
+
+import (
+	"time"
+
+	"tcl-tk-kanban/store"
+)
+
+// Storage is the store.Storage of a Repo. Each change is made in the
+// working copy, then the boards are written to the files and committed, so
+// that every change is a commit of its own. Reads go to the working copy.
+type Storage struct {
+	// SQLite is the working copy; its changing methods are all wrapped
+	*store.SQLite
+	repo *Repo
+}
+
+var _ store.Storage = (*Storage)(nil)
+
+// NewStorage returns the Storage of r, working on its working copy.
+func NewStorage(r *Repo) *Storage {
+	return &Storage{SQLite: store.NewSQLite(r.db), repo: r}
+}
+
+// Commit commits the changes made to the working copy other than through
+// s, such as by other programs, as Repo.Commit.
+func (s *Storage) Commit() ([]Change, error) {
+	return s.repo.Commit()
+}
+
+// commit commits a change that succeeded with err == nil and returns the
+// error of the change or of the commit.
+func (s *Storage) commit(err error) error {
+	if err != nil {
+		return err
+	}
+	_, err = s.repo.Commit()
+	return err
+}
+
+func (s *Storage) CreateBoard(name, description string) (int, error) {
+	id, err := s.SQLite.CreateBoard(name, description)
+	return id, s.commit(err)
+}
+
+func (s *Storage) UpdateBoard(id int, name, description string) error {
+	return s.commit(s.SQLite.UpdateBoard(id, name, description))
+}
+
+func (s *Storage) DeleteBoard(id int) error {
+	return s.commit(s.SQLite.DeleteBoard(id))
+}
+
+func (s *Storage) CloneBoard(id int, opts store.CloneOptions) (int, error) {
+	newID, err := s.SQLite.CloneBoard(id, opts)
+	return newID, s.commit(err)
+}
+
+func (s *Storage) CreateSwimlane(boardID int, name string) (int, error) {
+	id, err := s.SQLite.CreateSwimlane(boardID, name)
+	return id, s.commit(err)
+}
+
+func (s *Storage) RenameSwimlane(id int, name string) error {
+	return s.commit(s.SQLite.RenameSwimlane(id, name))
+}
+
+func (s *Storage) DeleteSwimlane(id int) error {
+	return s.commit(s.SQLite.DeleteSwimlane(id))
+}
+
+func (s *Storage) CloneSwimlane(id int, opts store.CloneOptions) (int, error) {
+	newID, err := s.SQLite.CloneSwimlane(id, opts)
+	return newID, s.commit(err)
+}
+
+func (s *Storage) MoveSwimlanes(ids []int, t store.Target) error {
+	return s.commit(s.SQLite.MoveSwimlanes(ids, t))
+}
+
+func (s *Storage) CopySwimlanes(ids []int, t store.Target) ([]int, error) {
+	newIDs, err := s.SQLite.CopySwimlanes(ids, t)
+	return newIDs, s.commit(err)
+}
+
+func (s *Storage) SetSwimlaneColors(id int, text, background, image string) error {
+	return s.commit(s.SQLite.SetSwimlaneColors(id, text, background, image))
+}
+
+func (s *Storage) CreateList(swimlaneID int, name string) (int, error) {
+	id, err := s.SQLite.CreateList(swimlaneID, name)
+	return id, s.commit(err)
+}
+
+func (s *Storage) RenameList(id int, name string) error {
+	return s.commit(s.SQLite.RenameList(id, name))
+}
+
+func (s *Storage) DeleteList(id int) error {
+	return s.commit(s.SQLite.DeleteList(id))
+}
+
+func (s *Storage) CloneList(id int, opts store.CloneOptions) (int, error) {
+	newID, err := s.SQLite.CloneList(id, opts)
+	return newID, s.commit(err)
+}
+
+func (s *Storage) MoveLists(ids []int, t store.Target) error {
+	return s.commit(s.SQLite.MoveLists(ids, t))
+}
+
+func (s *Storage) CopyLists(ids []int, t store.Target) ([]int, error) {
+	newIDs, err := s.SQLite.CopyLists(ids, t)
+	return newIDs, s.commit(err)
+}
+
+func (s *Storage) SetListColors(id int, text, background, image string) error {
+	return s.commit(s.SQLite.SetListColors(id, text, background, image))
+}
+
+func (s *Storage) SetWIPLimit(listID, limit int, hard bool) error {
+	return s.commit(s.SQLite.SetWIPLimit(listID, limit, hard))
+}
+
+func (s *Storage) CreateCard(listID int, title, description string) (int, error) {
+	id, err := s.SQLite.CreateCard(listID, title, description)
+	return id, s.commit(err)
+}
+
+func (s *Storage) UpdateCard(id int, title, description string) error {
+	return s.commit(s.SQLite.UpdateCard(id, title, description))
+}
+
+func (s *Storage) DeleteCard(id int) error {
+	return s.commit(s.SQLite.DeleteCard(id))
+}
+
+func (s *Storage) CloneCard(id int, opts store.CloneOptions) (int, error) {
+	newID, err := s.SQLite.CloneCard(id, opts)
+	return newID, s.commit(err)
+}
+
+func (s *Storage) MoveCards(ids []int, t store.Target) error {
+	return s.commit(s.SQLite.MoveCards(ids, t))
+}
+
+func (s *Storage) CopyCards(ids []int, t store.Target) ([]int, error) {
+	newIDs, err := s.SQLite.CopyCards(ids, t)
+	return newIDs, s.commit(err)
+}
+
+func (s *Storage) SetCardColors(id int, text, background string) error {
+	return s.commit(s.SQLite.SetCardColors(id, text, background))
+}
+
+func (s *Storage) SetAttachment(cardID int, data []byte) error {
+	return s.commit(s.SQLite.SetAttachment(cardID, data))
+}
+
+func (s *Storage) ArchiveCard(id int) error {
+	return s.commit(s.SQLite.ArchiveCard(id))
+}
+
+func (s *Storage) ScheduleArchive(id int, at time.Time) error {
+	return s.commit(s.SQLite.ScheduleArchive(id, at))
+}
+
+// ArchiveDue commits only when it archived cards, since the board view
+// calls it on every load.
+func (s *Storage) ArchiveDue(now time.Time) (int, error) {
+	n, err := s.SQLite.ArchiveDue(now)
+	if err != nil || n == 0 {
+		return n, err
+	}
+	return n, s.commit(nil)
+}
+
+func (s *Storage) SetTemplate(kind store.TemplateKind, id int, on bool) error {
+	return s.commit(s.SQLite.SetTemplate(kind, id, on))
+}
@@ -0,0 +1,126 @@
+package gitstore
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"tcl-tk-kanban/store"
+)
+
+// newStorage returns the Storage of a new repository whose working copy
+// has the full kanban schema, in place of the stub rules table of testSchema.
+func newStorage(t *testing.T) (*sql.DB, *Storage, string) {
+	t.Helper()
+	db, r, root := newRepo(t, "")
+	execSQL(t, db, "DROP TABLE rules")
+	if err := store.Init(db); err != nil {
+		t.Fatal(err)
+	}
+	return db, NewStorage(r), root
+}
+
+func TestStorageCommitsEveryChange(t *testing.T) {
+	db, s, root := newStorage(t)
+	id := func(id int, err error) int {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	board := id(s.CreateBoard("Project", ""))
+	lane := id(s.CreateSwimlane(board, "Lane"))
+	todo := id(s.CreateList(lane, "Todo"))
+	done := id(s.CreateList(lane, "Done"))
+	card := id(s.CreateCard(todo, "Write", ""))
+	for _, err := range []error{
+		s.MoveCards([]int{card}, store.Target{ParentID: done}),
+		s.SetCardColors(card, "#000000", "#FFFFFF"),
+		s.SetWIPLimit(todo, 2, false),
+		s.SetAttachment(card, []byte("png")),
+		s.ArchiveCard(card),
+		s.DeleteList(todo),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Neither reads, failed changes nor changes without effect commit
+	if _, err := s.LoadBoard(board); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateCard(99, "Missing", ""); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateCard(99) error %v, want sql.ErrNoRows", err)
+	}
+	if n, err := s.ArchiveDue(time.Now()); n != 0 || err != nil {
+		t.Errorf("ArchiveDue = %d, %v", n, err)
+	}
+	if err := s.RenameList(done, "Done"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`Delete list "Todo"`,
+		`Edit card "Write"`,
+		"Add board-1/card-1.attachment",
+		`Edit list "Todo"`,
+		`Edit card "Write"`,
+		"Move cards",
+		`Add card "Write"`,
+		`Add list "Done"`,
+		`Add list "Todo"`,
+		`Add swimlane "Lane"`,
+		`Add board "Project"`,
+	}
+	if got := subjects(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("log:\n%q\nwant\n%q", got, want)
+	}
+
+	// Changes made to the working copy directly wait for Commit
+	execSQL(t, db, "UPDATE cards SET title = 'Written' WHERE id = ?", card)
+	if changes, err := s.Commit(); err != nil || len(changes) != 2 {
+		t.Errorf("Commit = %v, %v", changes, err)
+	}
+	if got := subjects(t, root)[0]; got != `Edit card "Written"` {
+		t.Errorf("last commit %q", got)
+	}
+}
+
+func TestStorageCommitsRules(t *testing.T) {
+	db, s, root := newStorage(t)
+	board, _ := s.CreateBoard("Project", "")
+	lane, _ := s.CreateSwimlane(board, "Lane")
+	todo, _ := s.CreateList(lane, "Todo")
+	done, _ := s.CreateList(lane, "Done")
+	card, err := s.CreateCard(todo, "Write", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := store.Rule{BoardID: board, Name: "Done is green", Enabled: true,
+		Trigger: store.Trigger{Event: store.EventMoved, ListID: done},
+		Action:  store.Action{Kind: store.ActionBackgroundColor, Color: "#C8E6C9"}}
+	if err := s.SaveRule(&rule); err != nil {
+		t.Fatal(err)
+	}
+	before := len(subjects(t, root))
+	if err := s.MoveCards([]int{card}, store.Target{ParentID: done}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The color set by the rule is committed with the move, in one commit
+	if got := subjects(t, root); len(got) != before+1 || got[0] != `Edit card "Write"` {
+		t.Errorf("log %q", got)
+	}
+	if changes, err := s.Commit(); err != nil || len(changes) != 0 {
+		t.Errorf("Commit = %v, %v", changes, err)
+	}
+	var color string
+	db.QueryRow("SELECT background_color FROM cards WHERE id = ?", card).Scan(&color)
+	if color != "#C8E6C9" {
+		t.Errorf("background color %q", color)
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 
 	"fyne.io/fyne/v2"
@@ -9,11 +12,29 @@ import (
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"fyne.io/fyne/v2/driver/desktop"
-	"github.com/xuri/excelize/v2"
 	_ "github.com/mattn/go-sqlite3"
+	"image"
 	"image/color"
+	"net/http"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"tcl-tk-kanban/backup"
+	"tcl-tk-kanban/doctor"
+	"tcl-tk-kanban/export"
+	"tcl-tk-kanban/gitstore"
+	"tcl-tk-kanban/store"
+	"tcl-tk-kanban/syncer"
+	"tcl-tk-kanban/workspace"
 )
 
 // Structs for data
@@ -21,6 +42,7 @@ type Board struct {
 	ID          int
 	Name        string
 	Description string
+	IsTemplate  bool
 }
 
 type Swimlane struct {
@@ -43,20 +65,14 @@ type List struct {
 	BackgroundImage string
 }
 
-type Card struct {
-	ID          int
-	ListID      int
-	Title       string
-	Description string
-	Position    int
-	CreatedAt   string
-	Attachment  []byte
-	TextColor       string
-	BackgroundColor string
-}
-
 // Global variables
+// storage holds the boards shown; db is the database behind it, nil when
+// the boards are not in a database, as with store.Memory in the tests.
+// Search, saved filters, rules, export and backups need the database.
+var storage store.Storage
 var db *sql.DB
+var dbPath string
+var attachments *store.Attachments
 var currentBoardID int
 var currentSwimlaneID int
 var mainArea *container.Scroll
@@ -72,6 +88,9 @@ var selectedBoards = make(map[int]bool)
 var selectedSwimlanes = make(map[int]bool)
 var selectedLists = make(map[int]bool)
 var selectedCards = make(map[int]bool)
+
+// Cards matching the toolbar search, highlighted on the board
+var searchMatches = make(map[int]bool)
 var boardsCheckboxChecked bool
 
 // Drag visual feedback
@@ -80,68 +99,51 @@ var dragPreview *canvas.Rectangle
 var currentDropSlot *DropSlot
 var draggedItemName string
 
-// Reorder helpers move an item to a target index and re-pack positions 0..n-1
+// Reorder helpers move an item to a target index and re-pack positions 0..n-1.
+// The index is that of a drop slot, counting the moved item: slot i is in
+// front of the item at position i, so moving an item down skips itself.
+// Reordering cards within a list does not run the board rules.
 func reorderCards(listID int, cardID int, newIndex int) {
-	cards := getCards(listID)
-	// build ordered slice of IDs excluding moved card
-	ids := make([]int, 0, len(cards))
-	for _, c := range cards {
-		if c.ID != cardID {
-			ids = append(ids, c.ID)
-		}
-	}
-	if newIndex < 0 {
-		newIndex = 0
-	}
-	if newIndex > len(ids) {
-		newIndex = len(ids)
-	}
-	// insert cardID at newIndex
-	ids = append(ids[:newIndex], append([]int{cardID}, ids[newIndex:]...)...)
-	// persist positions
-	for i, id := range ids {
-		db.Exec("UPDATE cards SET position = ? WHERE id = ?", i, id)
+	// Slots are between the shown cards; archived cards keep their places
+	// around them, as with store.MoveCards
+	beforeID := slotBefore(getCardIDs(listID), newIndex)
+	if err := storage.MoveCards([]int{cardID}, store.Target{ParentID: listID, BeforeID: beforeID}); err != nil {
+		fmt.Println("Error moving card:", err)
 	}
 }
 
 func reorderLists(swimlaneID int, listID int, newIndex int) {
-	lists := getLists(swimlaneID)
-	ids := make([]int, 0, len(lists))
-	for _, l := range lists {
-		if l.ID != listID {
-			ids = append(ids, l.ID)
-		}
+	var ids []int
+	for _, l := range getLists(swimlaneID) {
+		ids = append(ids, l.ID)
 	}
-	if newIndex < 0 {
-		newIndex = 0
-	}
-	if newIndex > len(ids) {
-		newIndex = len(ids)
-	}
-	ids = append(ids[:newIndex], append([]int{listID}, ids[newIndex:]...)...)
-	for i, id := range ids {
-		db.Exec("UPDATE lists SET position = ? WHERE id = ?", i, id)
+	err := storage.MoveLists([]int{listID}, store.Target{ParentID: swimlaneID, BeforeID: slotBefore(ids, newIndex)})
+	if err != nil {
+		fmt.Println("Error moving list:", err)
 	}
 }
 
 func reorderSwimlanes(boardID int, swimlaneID int, newIndex int) {
-	swimlanes := getSwimlanes(boardID)
-	ids := make([]int, 0, len(swimlanes))
-	for _, s := range swimlanes {
-		if s.ID != swimlaneID {
-			ids = append(ids, s.ID)
-		}
+	var ids []int
+	for _, s := range getSwimlanes(boardID) {
+		ids = append(ids, s.ID)
 	}
-	if newIndex < 0 {
-		newIndex = 0
+	err := storage.MoveSwimlanes([]int{swimlaneID}, store.Target{ParentID: boardID, BeforeID: slotBefore(ids, newIndex)})
+	if err != nil {
+		fmt.Println("Error moving swimlane:", err)
 	}
-	if newIndex > len(ids) {
-		newIndex = len(ids)
+}
+
+// slotBefore returns the ID in front of which drop slot index puts an item
+// of ids, 0 for the end
+func slotBefore(ids []int, index int) int {
+	if index < 0 {
+		index = 0
 	}
-	ids = append(ids[:newIndex], append([]int{swimlaneID}, ids[newIndex:]...)...)
-	for i, id := range ids {
-		db.Exec("UPDATE swimlanes SET position = ? WHERE id = ?", i, id)
+	if index < len(ids) {
+		return ids[index]
 	}
+	return 0
 }
 
 // Selection operations
@@ -155,7 +157,6 @@ func moveSelectedUp() {
 	for id := range selectedSwimlanes {
 		moveSwimlaneUp(id)
 	}
-	loadBoard(currentBoardID)
 }
 
 func moveSelectedDown() {
@@ -168,7 +169,6 @@ func moveSelectedDown() {
 	for id := range selectedSwimlanes {
 		moveSwimlaneDown(id)
 	}
-	loadBoard(currentBoardID)
 }
 
 func moveSelectedLeft() {
@@ -178,7 +178,6 @@ func moveSelectedLeft() {
 	for id := range selectedLists {
 		moveListLeft(id)
 	}
-	loadBoard(currentBoardID)
 }
 
 func moveSelectedRight() {
@@ -188,7 +187,6 @@ func moveSelectedRight() {
 	for id := range selectedLists {
 		moveListRight(id)
 	}
-	loadBoard(currentBoardID)
 }
 
 func editSelected() {
@@ -207,23 +205,291 @@ func editSelected() {
 	}
 }
 
+// cloneSelected asks where to clone the selected items and whether to
+// leave out their cards, then clones them
 func cloneSelected() {
+	if len(selectedBoards)+len(selectedSwimlanes)+len(selectedLists)+len(selectedCards) == 0 {
+		return
+	}
+
+	const thisBoard = "Same place"
+	boardIDs := map[string]int{thisBoard: 0}
+	options := []string{thisBoard}
+	for _, b := range getBoards() {
+		label := fmt.Sprintf("%d: %s", b.ID, b.Name)
+		boardIDs[label] = b.ID
+		options = append(options, label)
+	}
+	boardSelect := widget.NewSelect(options, nil)
+	boardSelect.SetSelected(thisBoard)
+	withoutCards := widget.NewCheck("Without cards", nil)
+
+	cancelBtn := widget.NewButton("Cancel", func() {})
+	cloneBtn := widget.NewButton("Clone", func() {})
+	content := container.NewVBox(
+		widget.NewLabel("Clone Selected"),
+		widget.NewLabel("Swimlanes, lists and cards into board:"),
+		boardSelect,
+		withoutCards,
+		container.NewHBox(cancelBtn, cloneBtn),
+	)
+	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
+	cancelBtn.OnTapped = dialog.Hide
+	cloneBtn.OnTapped = func() {
+		dialog.Hide()
+		cloneSelectedInto(boardIDs[boardSelect.Selected], withoutCards.Checked)
+	}
+	dialog.Show()
+}
+
+// cloneSelectedInto clones the selected items. With a board ID, swimlanes
+// go to that board, lists to its first swimlane and cards to the first
+// list of its first swimlane.
+func cloneSelectedInto(boardID int, withoutCards bool) {
+	opts := store.CloneOptions{WithoutCards: withoutCards}
 	for id := range selectedBoards {
-		cloneBoard(id)
+		cloneBoard(id, opts)
 	}
-	for id := range selectedCards {
-		cloneCard(id)
+
+	var swimlaneID, listID int
+	if boardID != 0 {
+		if swimlanes := getSwimlanes(boardID); len(swimlanes) > 0 {
+			swimlaneID = swimlanes[0].ID
+			if lists := getLists(swimlaneID); len(lists) > 0 {
+				listID = lists[0].ID
+			}
+		}
+	}
+	for id := range selectedSwimlanes {
+		o := opts
+		o.ParentID = boardID
+		cloneSwimlane(id, o)
 	}
 	for id := range selectedLists {
-		cloneList(id)
+		if boardID != 0 && swimlaneID == 0 {
+			fmt.Println("Cannot clone list: the target board has no swimlane")
+			break
+		}
+		o := opts
+		o.ParentID = swimlaneID
+		cloneList(id, o)
 	}
-	for id := range selectedSwimlanes {
-		cloneSwimlane(id)
+	for id := range selectedCards {
+		if boardID != 0 && listID == 0 {
+			fmt.Println("Cannot clone card: the target board has no list")
+			break
+		}
+		o := opts
+		o.ParentID = listID
+		cloneCard(id, o)
 	}
 	loadBoard(currentBoardID)
 	refreshBoardContainer()
 }
 
+// showMoveCopyDialog picks a target on any board for the selected
+// swimlanes, lists and cards and moves or copies them there. Swimlanes go
+// to the chosen board, lists to the chosen swimlane and cards to the
+// chosen list. The position applies to the innermost kind selected; the
+// other kinds go to the end.
+func showMoveCopyDialog(copyItems bool) {
+	if len(selectedSwimlanes)+len(selectedLists)+len(selectedCards) == 0 {
+		return
+	}
+	action := "Move"
+	if copyItems {
+		action = "Copy"
+	}
+
+	const atEnd = "At the end"
+	var swimlaneIDs, listIDs, beforeIDs map[string]int
+	swimlaneSelect := widget.NewSelect(nil, nil)
+	listSelect := widget.NewSelect(nil, nil)
+	positionSelect := widget.NewSelect(nil, nil)
+	setPositions := func(items map[int]string, order []int) {
+		beforeIDs = map[string]int{atEnd: 0}
+		options := []string{atEnd}
+		for _, id := range order {
+			label := fmt.Sprintf("Before %d: %s", id, items[id])
+			beforeIDs[label] = id
+			options = append(options, label)
+		}
+		positionSelect.Options = options
+		positionSelect.SetSelected(atEnd)
+	}
+
+	listSelect.OnChanged = func(label string) {
+		if len(selectedCards) == 0 {
+			return
+		}
+		items := make(map[int]string)
+		var order []int
+		if l, err := storage.LoadList(listIDs[label]); err == nil {
+			for _, c := range l.Cards {
+				items[c.ID] = c.Title
+				order = append(order, c.ID)
+			}
+		}
+		setPositions(items, order)
+	}
+	swimlaneSelect.OnChanged = func(label string) {
+		listIDs = make(map[string]int)
+		var options []string
+		items := make(map[int]string)
+		var order []int
+		for _, l := range getLists(swimlaneIDs[label]) {
+			option := fmt.Sprintf("%d: %s", l.ID, l.Name)
+			listIDs[option] = l.ID
+			options = append(options, option)
+			items[l.ID] = l.Name
+			order = append(order, l.ID)
+		}
+		listSelect.Options = options
+		listSelect.ClearSelected()
+		if len(options) > 0 {
+			listSelect.SetSelected(options[0])
+		} else {
+			listSelect.OnChanged("")
+		}
+		if len(selectedCards) == 0 && len(selectedLists) > 0 {
+			setPositions(items, order)
+		}
+	}
+	boardIDs := make(map[string]int)
+	var boardOptions []string
+	var current string
+	for _, b := range getBoards() {
+		label := fmt.Sprintf("%d: %s", b.ID, b.Name)
+		boardIDs[label] = b.ID
+		boardOptions = append(boardOptions, label)
+		if b.ID == currentBoardID {
+			current = label
+		}
+	}
+	boardSelect := widget.NewSelect(boardOptions, func(label string) {
+		swimlaneIDs = make(map[string]int)
+		var options []string
+		items := make(map[int]string)
+		var order []int
+		for _, s := range getSwimlanes(boardIDs[label]) {
+			option := fmt.Sprintf("%d: %s", s.ID, s.Name)
+			swimlaneIDs[option] = s.ID
+			options = append(options, option)
+			items[s.ID] = s.Name
+			order = append(order, s.ID)
+		}
+		swimlaneSelect.Options = options
+		swimlaneSelect.ClearSelected()
+		if len(options) > 0 {
+			swimlaneSelect.SetSelected(options[0])
+		} else {
+			swimlaneSelect.OnChanged("")
+		}
+		if len(selectedCards)+len(selectedLists) == 0 {
+			setPositions(items, order)
+		}
+	})
+	boardSelect.SetSelected(current)
+
+	form := container.NewVBox(widget.NewLabel("Board:"), boardSelect)
+	if len(selectedLists)+len(selectedCards) > 0 {
+		form.Add(widget.NewLabel("Swimlane:"))
+		form.Add(swimlaneSelect)
+	}
+	if len(selectedCards) > 0 {
+		form.Add(widget.NewLabel("List:"))
+		form.Add(listSelect)
+	}
+	form.Add(widget.NewLabel("Position:"))
+	form.Add(positionSelect)
+
+	cancelBtn := widget.NewButton("Cancel", func() {})
+	okBtn := widget.NewButton(action, func() {})
+	content := container.NewVBox(
+		widget.NewLabel(action+" Selected To"),
+		form,
+		container.NewHBox(cancelBtn, okBtn),
+	)
+	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
+	cancelBtn.OnTapped = dialog.Hide
+	okBtn.OnTapped = func() {
+		swimlaneID := swimlaneIDs[swimlaneSelect.Selected]
+		listID := listIDs[listSelect.Selected]
+		if (len(selectedLists) > 0 && swimlaneID == 0) || (len(selectedCards) > 0 && listID == 0) {
+			return
+		}
+		dialog.Hide()
+		moveCopySelected(copyItems, store.Target{ParentID: boardIDs[boardSelect.Selected]},
+			store.Target{ParentID: swimlaneID}, store.Target{ParentID: listID}, beforeIDs[positionSelect.Selected])
+	}
+	dialog.Resize(fyne.NewSize(450, content.MinSize().Height))
+	dialog.Show()
+}
+
+// moveCopySelected moves or copies the selected swimlanes, lists and cards
+// to their targets. beforeID is the position within the target of the
+// innermost kind selected. Cards go through the WIP limit of their list
+// and the rules like moved cards, or new ones when copied.
+func moveCopySelected(copyItems bool, boardTarget, swimlaneTarget, listTarget store.Target, beforeID int) {
+	switch {
+	case len(selectedCards) > 0:
+		listTarget.BeforeID = beforeID
+	case len(selectedLists) > 0:
+		swimlaneTarget.BeforeID = beforeID
+	default:
+		boardTarget.BeforeID = beforeID
+	}
+	swimlaneIDs := selectedIDs(selectedSwimlanes)
+	listIDs := selectedIDs(selectedLists)
+	cardIDs := selectedIDs(selectedCards)
+
+	added := 0
+	for _, id := range cardIDs {
+		if copyItems || cardListID(id) != listTarget.ParentID {
+			added++
+		}
+	}
+
+	checkWIPLimitFor(listTarget.ParentID, added, func() {
+		if copyItems {
+			if _, err := storage.CopySwimlanes(swimlaneIDs, boardTarget); err != nil {
+				fmt.Println("Error copying swimlanes:", err)
+			}
+			if _, err := storage.CopyLists(listIDs, swimlaneTarget); err != nil {
+				fmt.Println("Error copying lists:", err)
+			}
+			if _, err := storage.CopyCards(cardIDs, listTarget); err != nil {
+				fmt.Println("Error copying cards:", err)
+			}
+		} else {
+			if err := storage.MoveSwimlanes(swimlaneIDs, boardTarget); err != nil {
+				fmt.Println("Error moving swimlanes:", err)
+			}
+			if err := storage.MoveLists(listIDs, swimlaneTarget); err != nil {
+				fmt.Println("Error moving lists:", err)
+			}
+			if err := storage.MoveCards(cardIDs, listTarget); err != nil {
+				fmt.Println("Error moving cards:", err)
+			}
+			// Moved items may have left the board
+			clear(selectedSwimlanes)
+			clear(selectedLists)
+			clear(selectedCards)
+		}
+		loadBoard(currentBoardID)
+		refreshBoardContainer()
+	})
+}
+
+// selectedIDs returns the IDs of a selection
+func selectedIDs(selected map[int]bool) []int {
+	ids := make([]int, 0, len(selected))
+	for id := range selected {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func deleteSelected() {
 	// Build confirmation message
 	msg := "Are you sure you want to delete:\n"
@@ -239,9 +505,11 @@ func deleteSelected() {
 	if len(selectedCards) > 0 {
 		msg += fmt.Sprintf("- %d card(s)\n", len(selectedCards))
 	}
-	msg += "\nThis action cannot be undone."
+	if db != nil {
+		msg += "\nA backup of the database is saved in " + backup.Dir(dbPath) + " first."
+	}
 	
-	showConfirmDialog("Delete Selected Items", msg, func() {
+	deleteNow := func() {
 		for id := range selectedBoards {
 			deleteBoard(id)
 		}
@@ -255,10 +523,10 @@ func deleteSelected() {
 			deleteSwimlane(id)
 		}
 		// Clear selections
-		selectedBoards = make(map[int]bool)
-		selectedCards = make(map[int]bool)
-		selectedLists = make(map[int]bool)
-		selectedSwimlanes = make(map[int]bool)
+		clear(selectedBoards)
+		clear(selectedCards)
+		clear(selectedLists)
+		clear(selectedSwimlanes)
 		
 		// Select first available board or reload current
 		boards := getBoards()
@@ -270,16 +538,30 @@ func deleteSelected() {
 			loadBoard(0)
 		}
 		refreshBoardContainer()
+	}
+	showConfirmDialog("Delete Selected Items", msg, func() {
+		if db == nil {
+			deleteNow()
+			return
+		}
+		// Deletes cascade to everything below; keep a way back
+		if _, err := backup.Take(db, dbPath, backup.ReasonDelete, time.Now(), backup.DefaultRotation); err != nil {
+			fmt.Println("Error backing up before delete:", err)
+			showConfirmDialog("Backup Failed", fmt.Sprintf("No backup could be saved:\n%v\n\nDelete anyway?", err), deleteNow)
+			return
+		}
+		deleteNow()
 	})
 }
 
 func clearSelections() {
-	selectedBoards = make(map[int]bool)
-	selectedCards = make(map[int]bool)
-	selectedLists = make(map[int]bool)
-	selectedSwimlanes = make(map[int]bool)
+	clear(selectedBoards)
+	clear(selectedCards)
+	clear(selectedLists)
+	clear(selectedSwimlanes)
 	boardsCheckboxChecked = false
-	loadBoard(currentBoardID)
+	view.syncChecks()
+	updateSelectionInfo()
 	refreshBoardContainer()
 }
 
@@ -293,10 +575,7 @@ func createNew() {
 	// Add cards below checked cards
 	if len(selectedCards) > 0 {
 		for cardID := range selectedCards {
-			// Get the list for this card
-			var listID int
-			db.QueryRow("SELECT list_id FROM cards WHERE id = ?", cardID).Scan(&listID)
-			showNewCardDialog(listID)
+			showNewCardDialog(cardListID(cardID))
 			return // Show one dialog at a time
 		}
 	}
@@ -304,10 +583,7 @@ func createNew() {
 	// Add lists below checked lists
 	if len(selectedLists) > 0 {
 		for listID := range selectedLists {
-			// Get swimlane for this list
-			var swimlaneID int
-			db.QueryRow("SELECT swimlane_id FROM lists WHERE id = ?", listID).Scan(&swimlaneID)
-			showNewListDialog(swimlaneID)
+			showNewListDialog(listSwimlaneID(listID))
 			return
 		}
 	}
@@ -315,39 +591,81 @@ func createNew() {
 	// Add swimlanes below checked swimlanes
 	if len(selectedSwimlanes) > 0 {
 		for swimlaneID := range selectedSwimlanes {
-			// Get board for this swimlane
-			var boardID int
-			db.QueryRow("SELECT board_id FROM swimlanes WHERE id = ?", swimlaneID).Scan(&boardID)
-			showNewSwimlaneDialog(boardID)
+			showNewSwimlaneDialog(swimlaneBoardID(swimlaneID))
 			return
 		}
 	}
 }
 
 func exportSelected() {
-	// Export checked boards/swimlanes/lists/cards to Excel
-	if len(selectedBoards) > 0 {
-		// Export first selected board
-		for boardID := range selectedBoards {
-			outputFile := fmt.Sprintf("board_%d_export.xlsx", boardID)
-			err := exportBoardToXLSX(boardID, outputFile)
-			if err != nil {
-				fmt.Println("Export failed:", err)
-			} else {
-				fmt.Println("Exported to", outputFile)
-			}
-			return // Export one at a time
+	if !haveDatabase("Export") {
+		return
+	}
+	// Export first checked board, or the current board if nothing is checked
+	boardID := currentBoardID
+	for id := range selectedBoards {
+		boardID = id
+		break
+	}
+	if boardID > 0 {
+		showExportDialog(boardID)
+	}
+}
+
+func showExportDialog(boardID int) {
+	// The output format is chosen by the file extension
+	fileEntry := widget.NewEntry()
+	fileEntry.SetText(fmt.Sprintf("board_%d_export.xlsx", boardID))
+	
+	formatSelect := widget.NewSelect(export.Formats, func(ext string) {
+		name := strings.TrimSuffix(fileEntry.Text, filepath.Ext(fileEntry.Text))
+		fileEntry.SetText(name + ext)
+	})
+	formatSelect.SetSelected(".xlsx")
+	
+	layouts := make([]string, len(export.Layouts))
+	for i, l := range export.Layouts {
+		layouts[i] = string(l)
+	}
+	layoutSelect := widget.NewSelect(layouts, nil)
+	layoutSelect.SetSelected(string(export.LayoutFlat))
+	
+	pageSizeSelect := widget.NewSelect(export.PDFPageSizes, nil)
+	pageSizeSelect.SetSelected("A4")
+	landscapeCheck := widget.NewCheck("Landscape", nil)
+	
+	cancelBtn := widget.NewButton("Cancel", func() {})
+	exportBtn := widget.NewButton("Export", func() {})
+	
+	content := container.NewVBox(
+		widget.NewLabel("Export Board"),
+		widget.NewLabel("File:"),
+		fileEntry,
+		container.NewGridWithColumns(2, widget.NewLabel("Format:"), formatSelect),
+		container.NewGridWithColumns(2, widget.NewLabel("Spreadsheet layout:"), layoutSelect),
+		container.NewGridWithColumns(2, widget.NewLabel("PDF page size:"), pageSizeSelect),
+		landscapeCheck,
+		container.NewHBox(cancelBtn, exportBtn),
+	)
+	
+	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
+	cancelBtn.OnTapped = dialog.Hide
+	exportBtn.OnTapped = func() {
+		opts := export.Options{
+			Layout: export.Layout(layoutSelect.Selected),
+			PDF:    export.PDFOptions{PageSize: pageSizeSelect.Selected, Landscape: landscapeCheck.Checked},
 		}
-	} else if currentBoardID > 0 {
-		// Export current board if nothing selected
-		outputFile := fmt.Sprintf("board_%d_export.xlsx", currentBoardID)
-		err := exportBoardToXLSX(currentBoardID, outputFile)
+		err := export.ExportBoard(db, boardID, fileEntry.Text, opts)
 		if err != nil {
 			fmt.Println("Export failed:", err)
 		} else {
-			fmt.Println("Exported to", outputFile)
+			fmt.Println("Exported to", fileEntry.Text)
 		}
+		dialog.Hide()
 	}
+	
+	dialog.Resize(fyne.NewSize(400, 300))
+	dialog.Show()
 }
 
 func showColorDialog() {
@@ -356,18 +674,24 @@ func showColorDialog() {
 	
 	// Try to get colors from selected items (priority: swimlanes > lists > cards)
 	for id := range selectedSwimlanes {
-		db.QueryRow("SELECT text_color, background_color FROM swimlanes WHERE id = ?", id).Scan(&existingTextColor, &existingBgColor)
+		if s, err := storage.LoadSwimlane(id); err == nil {
+			existingTextColor, existingBgColor = s.TextColor, s.BackgroundColor
+		}
 		break
 	}
 	if existingTextColor == "" && existingBgColor == "" {
 		for id := range selectedLists {
-			db.QueryRow("SELECT text_color, background_color FROM lists WHERE id = ?", id).Scan(&existingTextColor, &existingBgColor)
+			if l, err := storage.LoadList(id); err == nil {
+				existingTextColor, existingBgColor = l.TextColor, l.BackgroundColor
+			}
 			break
 		}
 	}
 	if existingTextColor == "" && existingBgColor == "" {
 		for id := range selectedCards {
-			db.QueryRow("SELECT text_color, background_color FROM cards WHERE id = ?", id).Scan(&existingTextColor, &existingBgColor)
+			if c, err := storage.LoadCard(id); err == nil {
+				existingTextColor, existingBgColor = c.TextColor, c.BackgroundColor
+			}
 			break
 		}
 	}
@@ -502,43 +826,41 @@ func showColorDialog() {
 		
 		// Apply to selected swimlanes
 		for id := range selectedSwimlanes {
-			result, err := db.Exec("UPDATE swimlanes SET text_color = ?, background_color = ?, background_image = ? WHERE id = ?",
-				textColor, bgColor, bgImage, id)
-			if err != nil {
+			if err := storage.SetSwimlaneColors(id, textColor, bgColor, bgImage); err != nil {
 				fmt.Printf("Error updating swimlane %d: %v\n", id, err)
 			} else {
-				rows, _ := result.RowsAffected()
-				fmt.Printf("Updated swimlane %d (%d rows affected)\n", id, rows)
+				fmt.Printf("Updated swimlane %d\n", id)
 			}
 		}
 		
 		// Apply to selected lists
 		for id := range selectedLists {
-			result, err := db.Exec("UPDATE lists SET text_color = ?, background_color = ?, background_image = ? WHERE id = ?",
-				textColor, bgColor, bgImage, id)
-			if err != nil {
+			if err := storage.SetListColors(id, textColor, bgColor, bgImage); err != nil {
 				fmt.Printf("Error updating list %d: %v\n", id, err)
 			} else {
-				rows, _ := result.RowsAffected()
-				fmt.Printf("Updated list %d (%d rows affected)\n", id, rows)
+				fmt.Printf("Updated list %d\n", id)
 			}
 		}
 		
 		// Apply to selected cards
 		for id := range selectedCards {
-			result, err := db.Exec("UPDATE cards SET text_color = ?, background_color = ? WHERE id = ?",
-				textColor, bgColor, id)
-			if err != nil {
+			if err := storage.SetCardColors(id, textColor, bgColor); err != nil {
 				fmt.Printf("Error updating card %d: %v\n", id, err)
 			} else {
-				rows, _ := result.RowsAffected()
-				fmt.Printf("Updated card %d (%d rows affected)\n", id, rows)
+				fmt.Printf("Updated card %d\n", id)
 			}
 		}
 		
 		dialog.Hide()
-		fmt.Println("Reloading board after color change...")
-		loadBoard(currentBoardID)
+		for id := range selectedSwimlanes {
+			refreshSwimlane(id)
+		}
+		for id := range selectedLists {
+			refreshList(id)
+		}
+		for id := range selectedCards {
+			refreshCard(id)
+		}
 	}
 	
 	cancelBtn.OnTapped = dialog.Hide
@@ -589,15 +911,18 @@ func (d *DraggableList) DragEnd() {
 func (d *DraggableList) Dropped(ev *fyne.DragEvent) {
 	// Drag and drop
 	if draggedCard != nil && draggedCard.ListID != d.ListID {
-		// Move card to this list
-		_, err := db.Exec("UPDATE cards SET list_id = ? WHERE id = ?", d.ListID, draggedCard.CardID)
-		if err == nil {
-			draggedCard.ListID = d.ListID
-			// Update positions
-			updateCardPositions(d.ListID)
-			// Refresh the board
-			loadBoard(currentBoardID)
-		}
+		// Move card to this list, unless it is full
+		card := draggedCard
+		checkWIPLimit(d.ListID, func() {
+			oldListID := card.ListID
+			err := storage.MoveCards([]int{card.CardID}, store.Target{ParentID: d.ListID})
+			if err == nil {
+				card.ListID = d.ListID
+				// Refresh both lists
+				refreshList(oldListID)
+				refreshList(d.ListID)
+			}
+		})
 	}
 }
 
@@ -640,25 +965,38 @@ func (d *DraggableIcon) Dragged(ev *fyne.DragEvent) {
 		}
 	}
 	
+	draggedItemName = ""
 	if d.Card != nil {
 		draggedCard = d.Card
 		// Get card title for preview
-		var title string
-		db.QueryRow("SELECT title FROM cards WHERE id = ?", d.Card.CardID).Scan(&title)
-		draggedItemName = title
+		if c, err := storage.LoadCard(d.Card.CardID); err == nil {
+			draggedItemName = c.Title
+		}
 	} else if d.List != nil {
 		draggedList = d.List
 		// Get list name for preview
-		var name string
-		db.QueryRow("SELECT name FROM lists WHERE id = ?", d.List.ListID).Scan(&name)
-		draggedItemName = name
+		if l, err := storage.LoadList(d.List.ListID); err == nil {
+			draggedItemName = l.Name
+		}
 	} else if d.SwimlaneID != 0 {
 		draggingSwimlane = true
 		draggedSwimlaneID = d.SwimlaneID
 		// Get swimlane name for preview
-		var name string
-		db.QueryRow("SELECT name FROM swimlanes WHERE id = ?", d.SwimlaneID).Scan(&name)
-		draggedItemName = name
+		if s, err := storage.LoadSwimlane(d.SwimlaneID); err == nil {
+			draggedItemName = s.Name
+		}
+	}
+
+	// Fyne sends the drag to the handle only: highlight the drop slot
+	// under the pointer, which DragEnd drops on
+	slot := dropSlotAt(d.kind(), ev.AbsolutePosition)
+	if slot != currentDropSlot {
+		if currentDropSlot != nil {
+			currentDropSlot.DragEnd()
+		}
+		if slot != nil {
+			slot.Dragged(ev)
+		}
 	}
 }
 
@@ -671,15 +1009,58 @@ func (d *DraggableIcon) DragEnd() {
 		}
 	}
 	
+	// Drop on the highlighted slot, which resets it
+	if currentDropSlot != nil {
+		currentDropSlot.Dropped(nil)
+	}
+	
 	draggedCard = nil
 	draggedList = nil
 	draggingSwimlane = false
 	draggedItemName = ""
-	
-	// Reset any highlighted drop slots
-	if currentDropSlot != nil {
-		currentDropSlot.DragEnd()
+}
+
+// kind returns the kind of the drop slots the handle's item can be dropped on.
+func (d *DraggableIcon) kind() string {
+	switch {
+	case d.Card != nil:
+		return "card"
+	case d.List != nil:
+		return "list"
+	}
+	return "swimlane"
+}
+
+// dropSlotAt returns the drop slot of a kind under an absolute position on
+// the displayed board, or nil.
+func dropSlotAt(kind string, pos fyne.Position) *DropSlot {
+	if view == nil || view.root == nil {
+		return nil
+	}
+	driver := fyne.CurrentApp().Driver()
+	var found *DropSlot
+	var walk func(objects []fyne.CanvasObject)
+	walk = func(objects []fyne.CanvasObject) {
+		for _, obj := range objects {
+			if found != nil {
+				return
+			}
+			if !obj.Visible() {
+				continue
+			}
+			switch o := obj.(type) {
+			case *DropSlot:
+				p, size := driver.AbsolutePositionForObject(o), o.Size()
+				if o.Kind == kind && pos.X >= p.X && pos.Y >= p.Y && pos.X < p.X+size.Width && pos.Y < p.Y+size.Height {
+					found = o
+				}
+			case *fyne.Container:
+				walk(o.Objects)
+			}
+		}
 	}
+	walk(view.root.Objects)
+	return found
 }
 
 // Generic drop slot that can accept cards, lists, or swimlanes and place them at a target index
@@ -726,16 +1107,12 @@ func (d *DropSlot) Dropped(ev *fyne.DragEvent) {
 	case "card":
 		if draggedCard != nil && draggedCard.ListID == d.ListID {
 			reorderCards(d.ListID, draggedCard.CardID, d.Index)
-			var boardID int
-			db.QueryRow(`SELECT s.board_id FROM swimlanes s JOIN lists l ON s.id = l.swimlane_id WHERE l.id = ?`, d.ListID).Scan(&boardID)
-			loadBoard(boardID)
+			refreshList(d.ListID)
 		}
 	case "list":
 		if draggedList != nil && draggedList.SwimlaneID == d.SwimlaneID {
 			reorderLists(d.SwimlaneID, draggedList.ListID, d.Index)
-			var boardID int
-			db.QueryRow("SELECT board_id FROM swimlanes WHERE id = ?", d.SwimlaneID).Scan(&boardID)
-			loadBoard(boardID)
+			refreshSwimlane(d.SwimlaneID)
 		}
 	case "swimlane":
 		if draggingSwimlane && draggedSwimlaneID != 0 && d.BoardID != 0 {
@@ -762,269 +1139,188 @@ func NewDropSlot(kind string, boardID, swimlaneID, listID, index int) *DropSlot
 	return slot
 }
 
-// Update list positions in a swimlane
-func updateListPositions(swimlaneID int) {
-	lists := getLists(swimlaneID)
-	for i, list := range lists {
-		db.Exec("UPDATE lists SET position = ? WHERE id = ?", i, list.ID)
-	}
-}
-
-// Update card positions in a list
-func updateCardPositions(listID int) {
-	cards := getCards(listID)
-	for i, card := range cards {
-		db.Exec("UPDATE cards SET position = ? WHERE id = ?", i, card.ID)
-	}
-}
-
 // Move functions for swimlanes, lists, and cards
 func moveSwimlaneUp(swimlaneID int) {
-	var currentPos int
-	err := db.QueryRow("SELECT position FROM swimlanes WHERE id = ?", swimlaneID).Scan(&currentPos)
-	if err != nil || currentPos <= 0 {
+	boardID := swimlaneBoardID(swimlaneID)
+	ids := swimlaneIDs(boardID)
+	i := indexOf(ids, swimlaneID)
+	if i <= 0 {
 		return
 	}
-	
-	var boardID int
-	db.QueryRow("SELECT board_id FROM swimlanes WHERE id = ?", swimlaneID).Scan(&boardID)
-	
-	newPos := currentPos - 1
-	var targetSwimlaneID int
-	err = db.QueryRow("SELECT id FROM swimlanes WHERE board_id = ? AND position = ?", boardID, newPos).Scan(&targetSwimlaneID)
-	if err == nil {
-		db.Exec("UPDATE swimlanes SET position = ? WHERE id = ?", currentPos, targetSwimlaneID)
-		db.Exec("UPDATE swimlanes SET position = ? WHERE id = ?", newPos, swimlaneID)
+	if err := storage.MoveSwimlanes([]int{swimlaneID}, store.Target{ParentID: boardID, BeforeID: ids[i-1]}); err == nil {
 		loadBoard(boardID)
 	}
 }
 
 func moveSwimlaneDown(swimlaneID int) {
-	var boardID, currentPos int
-	db.QueryRow("SELECT board_id, position FROM swimlanes WHERE id = ?", swimlaneID).Scan(&boardID, &currentPos)
-	
-	var maxPos int
-	db.QueryRow("SELECT MAX(position) FROM swimlanes WHERE board_id = ?", boardID).Scan(&maxPos)
-	
-	if currentPos >= maxPos {
+	boardID := swimlaneBoardID(swimlaneID)
+	ids := swimlaneIDs(boardID)
+	i := indexOf(ids, swimlaneID)
+	if i < 0 || i >= len(ids)-1 {
 		return
 	}
-	
-	newPos := currentPos + 1
-	var targetSwimlaneID int
-	err := db.QueryRow("SELECT id FROM swimlanes WHERE board_id = ? AND position = ?", boardID, newPos).Scan(&targetSwimlaneID)
-	if err == nil {
-		db.Exec("UPDATE swimlanes SET position = ? WHERE id = ?", currentPos, targetSwimlaneID)
-		db.Exec("UPDATE swimlanes SET position = ? WHERE id = ?", newPos, swimlaneID)
+	if err := storage.MoveSwimlanes([]int{swimlaneID}, store.Target{ParentID: boardID, BeforeID: slotBefore(ids, i+2)}); err == nil {
 		loadBoard(boardID)
 	}
 }
 
 func moveListLeft(listID int) {
-	var currentPos, swimlaneID int
-	db.QueryRow("SELECT position, swimlane_id FROM lists WHERE id = ?", listID).Scan(&currentPos, &swimlaneID)
-	
-	if currentPos <= 0 {
+	swimlaneID := listSwimlaneID(listID)
+	ids := listIDs(swimlaneID)
+	i := indexOf(ids, listID)
+	if i <= 0 {
 		return
 	}
-	
-	newPos := currentPos - 1
-	var targetListID int
-	err := db.QueryRow("SELECT id FROM lists WHERE swimlane_id = ? AND position = ?", swimlaneID, newPos).Scan(&targetListID)
-	if err == nil {
-		db.Exec("UPDATE lists SET position = ? WHERE id = ?", currentPos, targetListID)
-		db.Exec("UPDATE lists SET position = ? WHERE id = ?", newPos, listID)
-		var boardID int
-		db.QueryRow("SELECT board_id FROM swimlanes WHERE id = ?", swimlaneID).Scan(&boardID)
-		loadBoard(boardID)
+	if err := storage.MoveLists([]int{listID}, store.Target{ParentID: swimlaneID, BeforeID: ids[i-1]}); err == nil {
+		refreshSwimlane(swimlaneID)
 	}
 }
 
 func moveListRight(listID int) {
-	var swimlaneID, currentPos int
-	db.QueryRow("SELECT swimlane_id, position FROM lists WHERE id = ?", listID).Scan(&swimlaneID, &currentPos)
-	
-	var maxPos int
-	db.QueryRow("SELECT MAX(position) FROM lists WHERE swimlane_id = ?", swimlaneID).Scan(&maxPos)
-	
-	if currentPos >= maxPos {
+	swimlaneID := listSwimlaneID(listID)
+	ids := listIDs(swimlaneID)
+	i := indexOf(ids, listID)
+	if i < 0 || i >= len(ids)-1 {
 		return
 	}
-	
-	newPos := currentPos + 1
-	var targetListID int
-	err := db.QueryRow("SELECT id FROM lists WHERE swimlane_id = ? AND position = ?", swimlaneID, newPos).Scan(&targetListID)
-	if err == nil {
-		db.Exec("UPDATE lists SET position = ? WHERE id = ?", currentPos, targetListID)
-		db.Exec("UPDATE lists SET position = ? WHERE id = ?", newPos, listID)
-		var boardID int
-		db.QueryRow("SELECT board_id FROM swimlanes WHERE id = ?", swimlaneID).Scan(&boardID)
-		loadBoard(boardID)
+	if err := storage.MoveLists([]int{listID}, store.Target{ParentID: swimlaneID, BeforeID: slotBefore(ids, i+2)}); err == nil {
+		refreshSwimlane(swimlaneID)
 	}
 }
 
 func moveListToAboveSwimlane(listID int) {
-	var srcSwimlaneID, srcPos int
-	db.QueryRow("SELECT swimlane_id, position FROM lists WHERE id = ?", listID).Scan(&srcSwimlaneID, &srcPos)
-	
-	var boardID, swPos int
-	db.QueryRow("SELECT board_id, position FROM swimlanes WHERE id = ?", srcSwimlaneID).Scan(&boardID, &swPos)
-	
-	if swPos <= 0 {
-		return
-	}
-	
-	var prevSwimlaneID int
-	err := db.QueryRow("SELECT id FROM swimlanes WHERE board_id = ? AND position = ?", boardID, swPos-1).Scan(&prevSwimlaneID)
-	if err != nil {
-		return
-	}
-	
-	// Compact positions in source swimlane
-	db.Exec("UPDATE lists SET position = position - 1 WHERE swimlane_id = ? AND position > ?", srcSwimlaneID, srcPos)
-	
-	// Append to end of target swimlane
-	var targetPos int
-	db.QueryRow("SELECT COALESCE(MAX(position), -1) FROM lists WHERE swimlane_id = ?", prevSwimlaneID).Scan(&targetPos)
-	newPos := targetPos + 1
-	db.Exec("UPDATE lists SET swimlane_id = ?, position = ? WHERE id = ?", prevSwimlaneID, newPos, listID)
-	loadBoard(boardID)
+	moveListToSwimlane(listID, -1)
 }
 
 func moveListToBelowSwimlane(listID int) {
-	var srcSwimlaneID, srcPos int
-	db.QueryRow("SELECT swimlane_id, position FROM lists WHERE id = ?", listID).Scan(&srcSwimlaneID, &srcPos)
-	
-	var boardID, swPos int
-	db.QueryRow("SELECT board_id, position FROM swimlanes WHERE id = ?", srcSwimlaneID).Scan(&boardID, &swPos)
-	
-	var nextSwimlaneID int
-	err := db.QueryRow("SELECT id FROM swimlanes WHERE board_id = ? AND position = ?", boardID, swPos+1).Scan(&nextSwimlaneID)
-	if err != nil {
+	moveListToSwimlane(listID, 1)
+}
+
+// moveListToSwimlane moves a list to the end of the swimlane offset places
+// above (-1) or below (1) its own
+func moveListToSwimlane(listID, offset int) {
+	srcSwimlaneID := listSwimlaneID(listID)
+	ids := swimlaneIDs(swimlaneBoardID(srcSwimlaneID))
+	i := indexOf(ids, srcSwimlaneID) + offset
+	if i < 0 || i >= len(ids) || srcSwimlaneID == 0 {
 		return
 	}
-	
-	// Compact positions in source swimlane
-	db.Exec("UPDATE lists SET position = position - 1 WHERE swimlane_id = ? AND position > ?", srcSwimlaneID, srcPos)
-	
-	// Append to end of target swimlane
-	var targetPos int
-	db.QueryRow("SELECT COALESCE(MAX(position), -1) FROM lists WHERE swimlane_id = ?", nextSwimlaneID).Scan(&targetPos)
-	newPos := targetPos + 1
-	db.Exec("UPDATE lists SET swimlane_id = ?, position = ? WHERE id = ?", nextSwimlaneID, newPos, listID)
-	loadBoard(boardID)
+	if err := storage.MoveLists([]int{listID}, store.Target{ParentID: ids[i]}); err != nil {
+		fmt.Println("Error moving list:", err)
+		return
+	}
+	refreshSwimlane(srcSwimlaneID)
+	refreshSwimlane(ids[i])
 }
 
 func moveCardUp(cardID int) {
-	var listID, currentPos int
-	db.QueryRow("SELECT list_id, position FROM cards WHERE id = ?", cardID).Scan(&listID, &currentPos)
+	listID := cardListID(cardID)
 	
-	if currentPos <= 0 {
+	// In front of the shown card above; archived cards are skipped
+	cards := getCardIDs(listID)
+	i := indexOf(cards, cardID)
+	if i <= 0 {
 		return
 	}
-	
-	newPos := currentPos - 1
-	var targetCardID int
-	err := db.QueryRow("SELECT id FROM cards WHERE list_id = ? AND position = ?", listID, newPos).Scan(&targetCardID)
-	if err == nil {
-		db.Exec("UPDATE cards SET position = ? WHERE id = ?", currentPos, targetCardID)
-		db.Exec("UPDATE cards SET position = ? WHERE id = ?", newPos, cardID)
-		var boardID int
-		db.QueryRow(`SELECT s.board_id FROM swimlanes s 
-			JOIN lists l ON s.id = l.swimlane_id 
-			WHERE l.id = ?`, listID).Scan(&boardID)
-		loadBoard(boardID)
+	if err := storage.MoveCards([]int{cardID}, store.Target{ParentID: listID, BeforeID: cards[i-1]}); err == nil {
+		refreshList(listID)
 	}
 }
 
 func moveCardDown(cardID int) {
-	var listID, currentPos int
-	db.QueryRow("SELECT list_id, position FROM cards WHERE id = ?", cardID).Scan(&listID, &currentPos)
-	
-	var maxPos int
-	db.QueryRow("SELECT MAX(position) FROM cards WHERE list_id = ?", listID).Scan(&maxPos)
+	listID := cardListID(cardID)
 	
-	if currentPos >= maxPos {
+	// Behind the shown card below
+	cards := getCardIDs(listID)
+	i := indexOf(cards, cardID)
+	if i < 0 || i >= len(cards)-1 {
 		return
 	}
-	
-	newPos := currentPos + 1
-	var targetCardID int
-	err := db.QueryRow("SELECT id FROM cards WHERE list_id = ? AND position = ?", listID, newPos).Scan(&targetCardID)
-	if err == nil {
-		db.Exec("UPDATE cards SET position = ? WHERE id = ?", currentPos, targetCardID)
-		db.Exec("UPDATE cards SET position = ? WHERE id = ?", newPos, cardID)
-		var boardID int
-		db.QueryRow(`SELECT s.board_id FROM swimlanes s 
-			JOIN lists l ON s.id = l.swimlane_id 
-			WHERE l.id = ?`, listID).Scan(&boardID)
-		loadBoard(boardID)
+	if err := storage.MoveCards([]int{cardID}, store.Target{ParentID: listID, BeforeID: slotBefore(cards, i+2)}); err == nil {
+		refreshList(listID)
 	}
 }
 
 func moveCardToLeftList(cardID int) {
-	var currentListID, currentPos int
-	db.QueryRow("SELECT list_id, position FROM cards WHERE id = ?", cardID).Scan(&currentListID, &currentPos)
-	
-	var swimlaneID, listPos int
-	db.QueryRow("SELECT swimlane_id, position FROM lists WHERE id = ?", currentListID).Scan(&swimlaneID, &listPos)
-	
-	if listPos <= 0 {
+	moveCardToNextList(cardID, -1)
+}
+
+func moveCardToRightList(cardID int) {
+	moveCardToNextList(cardID, 1)
+}
+
+// moveCardToNextList moves a card to the list offset places left (-1) or
+// right (1) of its own in the swimlane
+func moveCardToNextList(cardID, offset int) {
+	currentListID := cardListID(cardID)
+	ids := listIDs(listSwimlaneID(currentListID))
+	i := indexOf(ids, currentListID) + offset
+	if i < 0 || i >= len(ids) || currentListID == 0 {
 		return
 	}
+	targetListID := ids[i]
 	
-	var targetListID int
-	err := db.QueryRow("SELECT id FROM lists WHERE swimlane_id = ? AND position = ?", swimlaneID, listPos-1).Scan(&targetListID)
-	if err != nil {
+	checkWIPLimit(targetListID, func() {
+		moveCardToList(cardID, currentListID, targetListID)
+	})
+}
+
+// moveCardToList moves a card from the current list to the end of the
+// target list
+func moveCardToList(cardID, currentListID, targetListID int) {
+	if err := storage.MoveCards([]int{cardID}, store.Target{ParentID: targetListID}); err != nil {
+		fmt.Println("Error moving card:", err)
 		return
 	}
 	
-	// Compact positions in source list
-	db.Exec("UPDATE cards SET position = position - 1 WHERE list_id = ? AND position > ?", currentListID, currentPos)
-	
-	// Append to end of target list
-	var targetPos int
-	db.QueryRow("SELECT COALESCE(MAX(position), -1) FROM cards WHERE list_id = ?", targetListID).Scan(&targetPos)
-	newPos := targetPos + 1
-	db.Exec("UPDATE cards SET list_id = ?, position = ? WHERE id = ?", targetListID, newPos, cardID)
-	
-	var boardID int
-	db.QueryRow("SELECT board_id FROM swimlanes WHERE id = ?", swimlaneID).Scan(&boardID)
-	loadBoard(boardID)
+	refreshList(currentListID)
+	refreshList(targetListID)
 }
 
-func moveCardToRightList(cardID int) {
-	var currentListID, currentPos int
-	db.QueryRow("SELECT list_id, position FROM cards WHERE id = ?", cardID).Scan(&currentListID, &currentPos)
-	
-	var swimlaneID, listPos int
-	db.QueryRow("SELECT swimlane_id, position FROM lists WHERE id = ?", currentListID).Scan(&swimlaneID, &listPos)
-	
-	var maxListPos int
-	db.QueryRow("SELECT MAX(position) FROM lists WHERE swimlane_id = ?", swimlaneID).Scan(&maxListPos)
-	
-	if listPos >= maxListPos {
+// checkWIPLimit runs action, which adds one card to a list, if the list
+// has room under its hard WIP limit, and asks for an override otherwise.
+// Soft limits only show in the list header.
+func checkWIPLimit(listID int, action func()) {
+	checkWIPLimitFor(listID, 1, action)
+}
+
+// checkWIPLimitFor is checkWIPLimit for an action adding n cards
+func checkWIPLimitFor(listID, n int, action func()) {
+	if n <= 0 {
+		action()
 		return
 	}
-	
-	var targetListID int
-	err := db.QueryRow("SELECT id FROM lists WHERE swimlane_id = ? AND position = ?", swimlaneID, listPos+1).Scan(&targetListID)
+	l, err := storage.LoadList(listID)
 	if err != nil {
+		fmt.Println("Error checking WIP limit:", err)
+		action()
 		return
 	}
-	
-	// Compact positions in source list
-	db.Exec("UPDATE cards SET position = position - 1 WHERE list_id = ? AND position > ?", currentListID, currentPos)
-	
-	// Append to end of target list
-	var targetPos int
-	db.QueryRow("SELECT COALESCE(MAX(position), -1) FROM cards WHERE list_id = ?", targetListID).Scan(&targetPos)
-	newPos := targetPos + 1
-	db.Exec("UPDATE cards SET list_id = ?, position = ? WHERE id = ?", targetListID, newPos, cardID)
-	
-	var boardID int
-	db.QueryRow("SELECT board_id FROM swimlanes WHERE id = ?", swimlaneID).Scan(&boardID)
-	loadBoard(boardID)
+	wip := l.WIP()
+	if wip.Allows(n) {
+		action()
+		return
+	}
+	question := "Add another card anyway?"
+	if n > 1 {
+		question = fmt.Sprintf("Add %d more cards anyway?", n)
+	}
+
+	cancelBtn := widget.NewButton("Cancel", func() {})
+	overrideBtn := widget.NewButton("Override", func() {})
+	overrideBtn.Importance = widget.WarningImportance
+	content := container.NewVBox(
+		widget.NewLabel("WIP Limit Reached"),
+		widget.NewLabel(fmt.Sprintf("%q already has %s cards. %s", l.Name, wip, question)),
+		container.NewHBox(cancelBtn, overrideBtn),
+	)
+	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
+	cancelBtn.OnTapped = dialog.Hide
+	overrideBtn.OnTapped = func() {
+		dialog.Hide()
+		action()
+	}
+	dialog.Show()
 }
 
 // Drop zone for swimlanes
@@ -1036,13 +1332,13 @@ type DroppableSwimlane struct {
 func (d *DroppableSwimlane) Dropped(ev *fyne.DragEvent) {
 	if draggedList != nil && draggedList.SwimlaneID != d.SwimlaneID {
 		// Move list to this swimlane
-		_, err := db.Exec("UPDATE lists SET swimlane_id = ? WHERE id = ?", d.SwimlaneID, draggedList.ListID)
+		oldSwimlaneID := draggedList.SwimlaneID
+		err := storage.MoveLists([]int{draggedList.ListID}, store.Target{ParentID: d.SwimlaneID})
 		if err == nil {
 			draggedList.SwimlaneID = d.SwimlaneID
-			// Update positions
-			updateListPositions(d.SwimlaneID)
-			// Refresh the board
-			loadBoard(currentBoardID)
+			// Refresh both swimlanes
+			refreshSwimlane(oldSwimlaneID)
+			refreshSwimlane(d.SwimlaneID)
 		}
 	}
 }
@@ -1052,113 +1348,76 @@ func (d *DroppableSwimlane) Dragged(ev *fyne.DragEvent) {}
 func (d *DroppableSwimlane) DragEnd() {}
 
 // Database functions
-func initDatabase() {
+func initDatabase(path string) {
 	var err error
-	db, err = sql.Open("sqlite3", "wekan.db")
+	db, err = store.Open(path)
 	if err != nil {
 		panic(err)
 	}
+	// One connection, so that changes of other programs can be told from
+	// our own; see store.Watcher
+	db.SetMaxOpenConns(1)
+	dbPath = path
 
-	// Create tables
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS boards (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			description TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		);
-		CREATE TABLE IF NOT EXISTS swimlanes (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			board_id INTEGER NOT NULL,
-			name TEXT NOT NULL,
-			position INTEGER DEFAULT 0,
-			text_color TEXT DEFAULT '',
-			background_color TEXT DEFAULT '',
-			background_image TEXT DEFAULT '',
-			FOREIGN KEY (board_id) REFERENCES boards(id) ON DELETE CASCADE
-		);
-		CREATE TABLE IF NOT EXISTS lists (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			swimlane_id INTEGER NOT NULL,
-			name TEXT NOT NULL,
-			position INTEGER DEFAULT 0,
-			text_color TEXT DEFAULT '',
-			background_color TEXT DEFAULT '',
-			background_image TEXT DEFAULT '',
-			FOREIGN KEY (swimlane_id) REFERENCES swimlanes(id) ON DELETE CASCADE
-		);
-		CREATE TABLE IF NOT EXISTS cards (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			list_id INTEGER NOT NULL,
-			title TEXT NOT NULL,
-			description TEXT,
-			position INTEGER DEFAULT 0,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			attachment BLOB,
-			FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE
-		);
-	`)
+	// Back up databases of older versions before migrating them
+	version, err := store.LoadSchemaVersion(db)
 	if err != nil {
 		panic(err)
 	}
-	
-	// Migrate existing databases: add color columns if they don't exist
-	// Check if swimlanes has text_color column
-	var colCount int
-	db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('swimlanes') WHERE name='text_color'").Scan(&colCount)
-	if colCount == 0 {
-		// Add color columns to swimlanes
-		db.Exec("ALTER TABLE swimlanes ADD COLUMN text_color TEXT DEFAULT ''")
-		db.Exec("ALTER TABLE swimlanes ADD COLUMN background_color TEXT DEFAULT ''")
-		db.Exec("ALTER TABLE swimlanes ADD COLUMN background_image TEXT DEFAULT ''")
+	var tables int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'boards'").Scan(&tables)
+	if version < store.SchemaVersion && tables > 0 {
+		if _, err := backup.Take(db, path, backup.ReasonMigration, time.Now(), backup.DefaultRotation); err != nil {
+			fmt.Println("Error backing up before migration:", err)
+		}
 	}
-	
-	// Check if lists has text_color column
-	db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('lists') WHERE name='text_color'").Scan(&colCount)
-	if colCount == 0 {
-		// Add color columns to lists
-		db.Exec("ALTER TABLE lists ADD COLUMN text_color TEXT DEFAULT ''")
-		db.Exec("ALTER TABLE lists ADD COLUMN background_color TEXT DEFAULT ''")
-		db.Exec("ALTER TABLE lists ADD COLUMN background_image TEXT DEFAULT ''")
+
+	if err := store.Init(db); err != nil {
+		panic(err)
 	}
-	
-	// Check if cards has text_color column
-	db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('cards') WHERE name='text_color'").Scan(&colCount)
-	if colCount == 0 {
-		// Add color columns to cards
-		db.Exec("ALTER TABLE cards ADD COLUMN text_color TEXT DEFAULT ''")
-		db.Exec("ALTER TABLE cards ADD COLUMN background_color TEXT DEFAULT ''")
+	// Deletes did not cascade before foreign keys were enforced; leave the
+	// orphans to doctor, which can recover them instead of deleting them
+	if tables > 0 {
+		orphans, err := store.FindOrphans(db)
+		if err != nil {
+			fmt.Println("Error checking for orphaned rows:", err)
+		} else if len(orphans) > 0 {
+			fmt.Printf("Found %d orphaned rows left by deletes of older versions.\n", len(orphans))
+			fmt.Println("Run kanban_go doctor -fix to move them to a \"" + doctor.RecoveredBoard + "\" board.")
+		}
+	}
+	if err := store.SetSchemaVersion(db, store.SchemaVersion); err != nil {
+		fmt.Println("Error setting schema version:", err)
+	}
+	if changes, err = store.NewWatcher(db); err != nil {
+		fmt.Println("Error watching for changes:", err)
 	}
 }
 
 func getBoardByID(boardID int) *Board {
-	row := db.QueryRow("SELECT id, name, description FROM boards WHERE id = ?", boardID)
-	var board Board
-	err := row.Scan(&board.ID, &board.Name, &board.Description)
+	b, err := storage.Board(boardID)
 	if err != nil {
 		return nil
 	}
-	return &board
+	return &Board{ID: b.ID, Name: b.Name, Description: b.Description, IsTemplate: b.IsTemplate}
 }
 
 func getBoards() []Board {
-	rows, err := db.Query("SELECT id, name, description FROM boards ORDER BY name")
+	loaded, err := storage.Boards()
 	if err != nil {
+		fmt.Println("Error loading boards:", err)
 		return nil
 	}
-	defer rows.Close()
 
 	var boards []Board
-	for rows.Next() {
-		var b Board
-		rows.Scan(&b.ID, &b.Name, &b.Description)
-		boards = append(boards, b)
+	for _, b := range loaded {
+		boards = append(boards, Board{ID: b.ID, Name: b.Name, Description: b.Description, IsTemplate: b.IsTemplate})
 	}
 	return boards
 }
 
 func createBoard(name, desc string) {
-	_, err := db.Exec("INSERT INTO boards (name, description) VALUES (?, ?)", name, desc)
+	_, err := storage.CreateBoard(name, desc)
 	if err != nil {
 		fmt.Println("Error creating board:", err)
 	}
@@ -1166,364 +1425,187 @@ func createBoard(name, desc string) {
 }
 
 func getSwimlanes(boardID int) []Swimlane {
-	rows, err := db.Query("SELECT id, board_id, name, position, COALESCE(text_color, ''), COALESCE(background_color, ''), COALESCE(background_image, '') FROM swimlanes WHERE board_id = ? ORDER BY position", boardID)
+	tree, err := storage.LoadBoard(boardID)
 	if err != nil {
 		fmt.Printf("Error querying swimlanes for board %d: %v\n", boardID, err)
 		return nil
 	}
-	defer rows.Close()
 
 	var swimlanes []Swimlane
-	for rows.Next() {
-		var s Swimlane
-		err := rows.Scan(&s.ID, &s.BoardID, &s.Name, &s.Position, &s.TextColor, &s.BackgroundColor, &s.BackgroundImage)
-		if err != nil {
-			fmt.Printf("Error scanning swimlane: %v\n", err)
-			continue
-		}
-		swimlanes = append(swimlanes, s)
+	for _, s := range tree {
+		swimlanes = append(swimlanes, Swimlane{ID: s.ID, BoardID: s.BoardID, Name: s.Name, Position: s.Position,
+			TextColor: s.TextColor, BackgroundColor: s.BackgroundColor, BackgroundImage: s.BackgroundImage})
 	}
 	fmt.Printf("Found %d swimlanes for board %d\n", len(swimlanes), boardID)
 	return swimlanes
 }
 
 func getLists(swimlaneID int) []List {
-	rows, err := db.Query("SELECT id, swimlane_id, name, position, COALESCE(text_color, ''), COALESCE(background_color, ''), COALESCE(background_image, '') FROM lists WHERE swimlane_id = ? ORDER BY position", swimlaneID)
+	s, err := storage.LoadSwimlane(swimlaneID)
 	if err != nil {
 		fmt.Printf("Error querying lists for swimlane %d: %v\n", swimlaneID, err)
 		return nil
 	}
-	defer rows.Close()
 
 	var lists []List
-	for rows.Next() {
-		var l List
-		err := rows.Scan(&l.ID, &l.SwimlaneID, &l.Name, &l.Position, &l.TextColor, &l.BackgroundColor, &l.BackgroundImage)
-		if err != nil {
-			fmt.Printf("Error scanning list: %v\n", err)
-			continue
-		}
-		lists = append(lists, l)
+	for _, l := range s.Lists {
+		lists = append(lists, List{ID: l.ID, SwimlaneID: l.SwimlaneID, Name: l.Name, Position: l.Position,
+			TextColor: l.TextColor, BackgroundColor: l.BackgroundColor, BackgroundImage: l.BackgroundImage})
 	}
 	return lists
 }
 
-func getCards(listID int) []Card {
-	rows, err := db.Query("SELECT id, list_id, title, description, position, created_at, attachment, COALESCE(text_color, ''), COALESCE(background_color, '') FROM cards WHERE list_id = ? ORDER BY position", listID)
+// getCardIDs returns the IDs of the unarchived cards of a list in position
+// order
+func getCardIDs(listID int) []int {
+	l, err := storage.LoadList(listID)
 	if err != nil {
+		fmt.Println("Error querying cards:", err)
 		return nil
 	}
-	defer rows.Close()
-
-	var cards []Card
-	for rows.Next() {
-		var c Card
-		rows.Scan(&c.ID, &c.ListID, &c.Title, &c.Description, &c.Position, &c.CreatedAt, &c.Attachment, &c.TextColor, &c.BackgroundColor)
-		cards = append(cards, c)
+	ids := make([]int, 0, len(l.Cards))
+	for _, c := range l.Cards {
+		ids = append(ids, c.ID)
 	}
-	return cards
+	return ids
 }
 
-// Board management functions
-func deleteBoard(boardID int) {
-	_, err := db.Exec("DELETE FROM boards WHERE id = ?", boardID)
-	if err != nil {
-		fmt.Println("Error deleting board:", err)
+// swimlaneIDs returns the swimlane IDs of a board in position order
+func swimlaneIDs(boardID int) []int {
+	var ids []int
+	for _, s := range getSwimlanes(boardID) {
+		ids = append(ids, s.ID)
 	}
-	refreshBoardList()
+	return ids
 }
 
-func cloneBoard(boardID int) {
-	// Get original board info
-	var origName, origDesc string
-	err := db.QueryRow("SELECT name, description FROM boards WHERE id = ?", boardID).Scan(&origName, &origDesc)
-	if err != nil {
-		fmt.Println("Error getting board info:", err)
-		return
-	}
-
-	// Create new board
-	newName := origName + " (Copy)"
-	_, err = db.Exec("INSERT INTO boards (name, description) VALUES (?, ?)", newName, origDesc)
-	if err != nil {
-		fmt.Println("Error creating board copy:", err)
-		return
-	}
-
-	// Get the new board ID
-	var newBoardID int
-	err = db.QueryRow("SELECT last_insert_rowid()").Scan(&newBoardID)
-	if err != nil {
-		fmt.Println("Error getting new board ID:", err)
-		return
-	}
-
-	// Clone all swimlanes
-	swimlanes := getSwimlanes(boardID)
-	for _, s := range swimlanes {
-		cloneSwimlaneToBoard(s.ID, newBoardID)
+// listIDs returns the list IDs of a swimlane in position order
+func listIDs(swimlaneID int) []int {
+	var ids []int
+	for _, l := range getLists(swimlaneID) {
+		ids = append(ids, l.ID)
 	}
-	refreshBoardList()
+	return ids
 }
 
-// Swimlane management functions
-func createSwimlane(boardID int, name string) {
-	// Get max position
-	var maxPos int
-	err := db.QueryRow("SELECT COALESCE(MAX(position), -1) FROM swimlanes WHERE board_id = ?", boardID).Scan(&maxPos)
-	if err != nil {
-		fmt.Println("Error getting max position:", err)
-		return
-	}
-	
-	_, err = db.Exec("INSERT INTO swimlanes (board_id, name, position) VALUES (?, ?, ?)", boardID, name, maxPos+1)
+// swimlaneBoardID, listSwimlaneID and cardListID return the parent of an
+// item, 0 if it does not exist
+func swimlaneBoardID(swimlaneID int) int {
+	s, err := storage.LoadSwimlane(swimlaneID)
 	if err != nil {
-		fmt.Println("Error creating swimlane:", err)
+		return 0
 	}
+	return s.BoardID
 }
 
-func deleteSwimlane(swimlaneID int) {
-	_, err := db.Exec("DELETE FROM swimlanes WHERE id = ?", swimlaneID)
+func listSwimlaneID(listID int) int {
+	l, err := storage.LoadList(listID)
 	if err != nil {
-		fmt.Println("Error deleting swimlane:", err)
+		return 0
 	}
+	return l.SwimlaneID
 }
 
-func cloneSwimlane(swimlaneID int) {
-	// Get original swimlane info
-	var boardID int
-	var origName string
-	var origPos int
-	err := db.QueryRow("SELECT board_id, name, position FROM swimlanes WHERE id = ?", swimlaneID).Scan(&boardID, &origName, &origPos)
-	if err != nil {
-		fmt.Println("Error getting swimlane info:", err)
-		return
-	}
-
-	// Increment position of all swimlanes below the original
-	_, err = db.Exec("UPDATE swimlanes SET position = position + 1 WHERE board_id = ? AND position > ?", boardID, origPos)
-	if err != nil {
-		fmt.Println("Error updating positions:", err)
-		return
-	}
-
-	// Create new swimlane
-	newName := origName + " (Copy)"
-	newPos := origPos + 1
-	_, err = db.Exec("INSERT INTO swimlanes (board_id, name, position) VALUES (?, ?, ?)", boardID, newName, newPos)
+func cardListID(cardID int) int {
+	c, err := storage.LoadCard(cardID)
 	if err != nil {
-		fmt.Println("Error creating swimlane copy:", err)
-		return
+		return 0
 	}
+	return c.ListID
+}
 
-	// Get the new swimlane ID
-	var newSwimlaneID int
-	err = db.QueryRow("SELECT last_insert_rowid()").Scan(&newSwimlaneID)
-	if err != nil {
-		fmt.Println("Error getting new swimlane ID:", err)
-		return
+// haveDatabase reports whether the boards are in a database, which feature
+// needs, and tells otherwise
+func haveDatabase(feature string) bool {
+	if db == nil {
+		fmt.Println(feature, "needs the boards in a database file")
 	}
+	return db != nil
+}
 
-	// Clone all lists
-	lists := getLists(swimlaneID)
-	for _, l := range lists {
-		cloneListToSwimlane(l.ID, newSwimlaneID)
+// Board management functions
+func deleteBoard(boardID int) {
+	if err := storage.DeleteBoard(boardID); err != nil {
+		fmt.Println("Error deleting board:", err)
 	}
+	refreshBoardList()
 }
 
-func cloneSwimlaneToBoard(swimlaneID, newBoardID int) {
-	// Get original swimlane info
-	var origName string
-	var origPos int
-	err := db.QueryRow("SELECT name, position FROM swimlanes WHERE id = ?", swimlaneID).Scan(&origName, &origPos)
-	if err != nil {
-		fmt.Println("Error getting swimlane info:", err)
-		return
+// cloneBoard copies a board with everything on it; see store.CloneBoard
+func cloneBoard(boardID int, opts store.CloneOptions) {
+	if _, err := storage.CloneBoard(boardID, opts); err != nil {
+		fmt.Println("Error cloning board:", err)
 	}
+	refreshBoardList()
+}
 
-	// Create new swimlane in the new board
-	_, err = db.Exec("INSERT INTO swimlanes (board_id, name, position) VALUES (?, ?, ?)", newBoardID, origName, origPos)
-	if err != nil {
-		fmt.Println("Error creating swimlane in new board:", err)
-		return
+// Swimlane management functions
+func createSwimlane(boardID int, name string) {
+	if _, err := storage.CreateSwimlane(boardID, name); err != nil {
+		fmt.Println("Error creating swimlane:", err)
 	}
+}
 
-	// Get the new swimlane ID
-	var newSwimlaneID int
-	err = db.QueryRow("SELECT last_insert_rowid()").Scan(&newSwimlaneID)
-	if err != nil {
-		fmt.Println("Error getting new swimlane ID:", err)
-		return
+func deleteSwimlane(swimlaneID int) {
+	if err := storage.DeleteSwimlane(swimlaneID); err != nil {
+		fmt.Println("Error deleting swimlane:", err)
 	}
+}
 
-	// Clone all lists
-	lists := getLists(swimlaneID)
-	for _, l := range lists {
-		cloneListToSwimlane(l.ID, newSwimlaneID)
+// cloneSwimlane copies a swimlane to the end of its board, or of the board
+// opts.ParentID
+func cloneSwimlane(swimlaneID int, opts store.CloneOptions) {
+	if _, err := storage.CloneSwimlane(swimlaneID, opts); err != nil {
+		fmt.Println("Error cloning swimlane:", err)
 	}
 }
 
 // List management functions
 func createList(swimlaneID int, name string) {
-	// Get max position
-	var maxPos int
-	err := db.QueryRow("SELECT COALESCE(MAX(position), -1) FROM lists WHERE swimlane_id = ?", swimlaneID).Scan(&maxPos)
-	if err != nil {
-		fmt.Println("Error getting max position:", err)
-		return
-	}
-	
-	_, err = db.Exec("INSERT INTO lists (swimlane_id, name, position) VALUES (?, ?, ?)", swimlaneID, name, maxPos+1)
-	if err != nil {
+	if _, err := storage.CreateList(swimlaneID, name); err != nil {
 		fmt.Println("Error creating list:", err)
 	}
 }
 
 func deleteList(listID int) {
-	_, err := db.Exec("DELETE FROM lists WHERE id = ?", listID)
-	if err != nil {
+	if err := storage.DeleteList(listID); err != nil {
 		fmt.Println("Error deleting list:", err)
 	}
 }
 
-func cloneList(listID int) {
-	// Get original list info
-	var swimlaneID int
-	var origName string
-	err := db.QueryRow("SELECT swimlane_id, name FROM lists WHERE id = ?", listID).Scan(&swimlaneID, &origName)
-	if err != nil {
-		fmt.Println("Error getting list info:", err)
-		return
-	}
-
-	// Find max position and add at end
-	var maxPos int
-	err = db.QueryRow("SELECT COALESCE(MAX(position), -1) FROM lists WHERE swimlane_id = ?", swimlaneID).Scan(&maxPos)
-	if err != nil {
-		fmt.Println("Error getting max position:", err)
-		return
-	}
-
-	newName := origName + " (Copy)"
-	newPos := maxPos + 1
-	
-	_, err = db.Exec("INSERT INTO lists (swimlane_id, name, position) VALUES (?, ?, ?)", swimlaneID, newName, newPos)
-	if err != nil {
-		fmt.Println("Error creating list copy:", err)
-		return
-	}
-
-	// Get the new list ID
-	var newListID int
-	err = db.QueryRow("SELECT last_insert_rowid()").Scan(&newListID)
-	if err != nil {
-		fmt.Println("Error getting new list ID:", err)
-		return
-	}
-
-	// Clone all cards
-	cards := getCards(listID)
-	for _, c := range cards {
-		cloneCardToList(c.ID, newListID)
-	}
-}
-
-func cloneListToSwimlane(listID, newSwimlaneID int) {
-	// Get original list info
-	var origName string
-	var origPos int
-	err := db.QueryRow("SELECT name, position FROM lists WHERE id = ?", listID).Scan(&origName, &origPos)
-	if err != nil {
-		fmt.Println("Error getting list info:", err)
-		return
-	}
-
-	// Create new list in the new swimlane
-	_, err = db.Exec("INSERT INTO lists (swimlane_id, name, position) VALUES (?, ?, ?)", newSwimlaneID, origName, origPos)
-	if err != nil {
-		fmt.Println("Error creating list in new swimlane:", err)
-		return
-	}
-
-	// Get the new list ID
-	var newListID int
-	err = db.QueryRow("SELECT last_insert_rowid()").Scan(&newListID)
-	if err != nil {
-		fmt.Println("Error getting new list ID:", err)
-		return
-	}
-
-	// Clone all cards
-	cards := getCards(listID)
-	for _, c := range cards {
-		cloneCardToList(c.ID, newListID)
+// cloneList copies a list to the end of its swimlane, or of the swimlane
+// opts.ParentID
+func cloneList(listID int, opts store.CloneOptions) {
+	if _, err := storage.CloneList(listID, opts); err != nil {
+		fmt.Println("Error cloning list:", err)
 	}
 }
 
 // Card management functions
+// createCard adds a card at the end of a list and refreshes the list. A
+// hard WIP limit on the list needs an override.
 func createCard(listID int, title, description string) {
-	// Get max position
-	var maxPos int
-	err := db.QueryRow("SELECT COALESCE(MAX(position), -1) FROM cards WHERE list_id = ?", listID).Scan(&maxPos)
-	if err != nil {
-		fmt.Println("Error getting max position:", err)
-		return
-	}
-	
-	_, err = db.Exec("INSERT INTO cards (list_id, title, description, position) VALUES (?, ?, ?, ?)", listID, title, description, maxPos+1)
-	if err != nil {
-		fmt.Println("Error creating card:", err)
-	}
+	checkWIPLimit(listID, func() {
+		if _, err := storage.CreateCard(listID, title, description); err != nil {
+			fmt.Println("Error creating card:", err)
+			return
+		}
+		refreshList(listID)
+	})
 }
 
 func deleteCard(cardID int) {
-	_, err := db.Exec("DELETE FROM cards WHERE id = ?", cardID)
-	if err != nil {
+	if err := storage.DeleteCard(cardID); err != nil {
 		fmt.Println("Error deleting card:", err)
 	}
+	attachments.Invalidate(cardID)
 }
 
-func cloneCard(cardID int) {
-	// Get original card info
-	var listID int
-	var origTitle, origDesc string
-	err := db.QueryRow("SELECT list_id, title, description FROM cards WHERE id = ?", cardID).Scan(&listID, &origTitle, &origDesc)
-	if err != nil {
-		fmt.Println("Error getting card info:", err)
-		return
-	}
-
-	// Find max position and add at end
-	var maxPos int
-	err = db.QueryRow("SELECT COALESCE(MAX(position), -1) FROM cards WHERE list_id = ?", listID).Scan(&maxPos)
-	if err != nil {
-		fmt.Println("Error getting max position:", err)
-		return
-	}
-
-	newTitle := origTitle + " (Copy)"
-	newPos := maxPos + 1
-	
-	_, err = db.Exec("INSERT INTO cards (list_id, title, description, position) VALUES (?, ?, ?, ?)", listID, newTitle, origDesc, newPos)
-	if err != nil {
-		fmt.Println("Error creating card copy:", err)
-	}
-}
-
-func cloneCardToList(cardID, newListID int) {
-	// Get original card info
-	var origTitle, origDesc string
-	var origPos int
-	err := db.QueryRow("SELECT title, description, position FROM cards WHERE id = ?", cardID).Scan(&origTitle, &origDesc, &origPos)
-	if err != nil {
-		fmt.Println("Error getting card info:", err)
-		return
-	}
-
-	// Create new card in the new list
-	_, err = db.Exec("INSERT INTO cards (list_id, title, description, position) VALUES (?, ?, ?, ?)", newListID, origTitle, origDesc, origPos)
-	if err != nil {
-		fmt.Println("Error creating card in new list:", err)
+// cloneCard copies a card to the end of its list, or of the list
+// opts.ParentID
+func cloneCard(cardID int, opts store.CloneOptions) {
+	if _, err := storage.CloneCard(cardID, opts); err != nil {
+		fmt.Println("Error cloning card:", err)
 	}
 }
 
@@ -1538,15 +1620,21 @@ func showNewBoardDialog() {
 	cancelBtn := widget.NewButton("Cancel", func() {})
 	createBtn := widget.NewButton("Create", func() {})
 	
+	templateBtn := widget.NewButton("From Template...", func() {})
+	
 	content := container.NewVBox(
 		widget.NewLabel("Create New Board"),
 		nameEntry,
 		descEntry,
-		container.NewHBox(cancelBtn, createBtn),
+		container.NewHBox(cancelBtn, createBtn, templateBtn),
 	)
 	
 	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
 	cancelBtn.OnTapped = dialog.Hide
+	templateBtn.OnTapped = func() {
+		dialog.Hide()
+		showTemplateDialog(store.TemplateBoard, 0)
+	}
 	createBtn.OnTapped = func() {
 		if nameEntry.Text != "" {
 			createBoard(nameEntry.Text, descEntry.Text)
@@ -1570,14 +1658,20 @@ func showNewSwimlaneDialog(boardID int) {
 	cancelBtn := widget.NewButton("Cancel", func() {})
 	createBtn := widget.NewButton("Create", func() {})
 	
+	templateBtn := widget.NewButton("From Template...", func() {})
+	
 	content := container.NewVBox(
 		widget.NewLabel("Create New Swimlane"),
 		nameEntry,
-		container.NewHBox(cancelBtn, createBtn),
+		container.NewHBox(cancelBtn, createBtn, templateBtn),
 	)
 	
 	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
 	cancelBtn.OnTapped = dialog.Hide
+	templateBtn.OnTapped = func() {
+		dialog.Hide()
+		showTemplateDialog(store.TemplateSwimlane, boardID)
+	}
 	createBtn.OnTapped = func() {
 		if nameEntry.Text != "" {
 			createSwimlane(boardID, nameEntry.Text)
@@ -1596,21 +1690,24 @@ func showNewListDialog(swimlaneID int) {
 	cancelBtn := widget.NewButton("Cancel", func() {})
 	createBtn := widget.NewButton("Create", func() {})
 	
+	templateBtn := widget.NewButton("From Template...", func() {})
+	
 	content := container.NewVBox(
 		widget.NewLabel("Create New List"),
 		nameEntry,
-		container.NewHBox(cancelBtn, createBtn),
+		container.NewHBox(cancelBtn, createBtn, templateBtn),
 	)
 	
 	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
 	cancelBtn.OnTapped = dialog.Hide
+	templateBtn.OnTapped = func() {
+		dialog.Hide()
+		showTemplateDialog(store.TemplateList, swimlaneID)
+	}
 	createBtn.OnTapped = func() {
 		if nameEntry.Text != "" {
 			createList(swimlaneID, nameEntry.Text)
-			// Find board ID and refresh
-			var boardID int
-			db.QueryRow("SELECT board_id FROM swimlanes WHERE id = ?", swimlaneID).Scan(&boardID)
-			loadBoard(boardID)
+			refreshSwimlane(swimlaneID)
 		}
 		dialog.Hide()
 	}
@@ -1628,24 +1725,24 @@ func showNewCardDialog(listID int) {
 	cancelBtn := widget.NewButton("Cancel", func() {})
 	createBtn := widget.NewButton("Create", func() {})
 	
+	templateBtn := widget.NewButton("From Template...", func() {})
+	
 	content := container.NewVBox(
 		widget.NewLabel("Create New Card"),
 		titleEntry,
 		descEntry,
-		container.NewHBox(cancelBtn, createBtn),
+		container.NewHBox(cancelBtn, createBtn, templateBtn),
 	)
 	
 	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
 	cancelBtn.OnTapped = dialog.Hide
+	templateBtn.OnTapped = func() {
+		dialog.Hide()
+		showTemplateDialog(store.TemplateCard, listID)
+	}
 	createBtn.OnTapped = func() {
 		if titleEntry.Text != "" {
 			createCard(listID, titleEntry.Text, descEntry.Text)
-			// Find board ID and refresh
-			var boardID int
-			db.QueryRow(`SELECT s.board_id FROM swimlanes s 
-				JOIN lists l ON s.id = l.swimlane_id 
-				WHERE l.id = ?`, listID).Scan(&boardID)
-			loadBoard(boardID)
 		}
 		dialog.Hide()
 	}
@@ -1655,51 +1752,46 @@ func showNewCardDialog(listID int) {
 
 // Update functions
 func updateBoard(boardID int, name, description string) {
-	_, err := db.Exec("UPDATE boards SET name = ?, description = ? WHERE id = ?", name, description, boardID)
-	if err != nil {
+	if err := storage.UpdateBoard(boardID, name, description); err != nil {
 		fmt.Println("Error updating board:", err)
 	}
 	refreshBoardContainer()
 	// Update window title if current board was edited
 	if boardID == currentBoardID {
-		loadBoard(boardID)
+		updateWindowTitle(boardID)
 	}
 }
 
 func updateSwimlane(swimlaneID int, name string) {
-	_, err := db.Exec("UPDATE swimlanes SET name = ? WHERE id = ?", name, swimlaneID)
-	if err != nil {
+	if err := storage.RenameSwimlane(swimlaneID, name); err != nil {
 		fmt.Println("Error updating swimlane:", err)
 	}
 }
 
 func updateList(listID int, name string) {
-	_, err := db.Exec("UPDATE lists SET name = ? WHERE id = ?", name, listID)
-	if err != nil {
+	if err := storage.RenameList(listID, name); err != nil {
 		fmt.Println("Error updating list:", err)
 	}
 }
 
 func updateCard(cardID int, title, description string) {
-	_, err := db.Exec("UPDATE cards SET title = ?, description = ? WHERE id = ?", title, description, cardID)
-	if err != nil {
+	if err := storage.UpdateCard(cardID, title, description); err != nil {
 		fmt.Println("Error updating card:", err)
 	}
 }
 
 func showEditBoardDialog(boardID int) {
-	var currentName, currentDesc string
-	err := db.QueryRow("SELECT name, description FROM boards WHERE id = ?", boardID).Scan(&currentName, &currentDesc)
+	board, err := storage.Board(boardID)
 	if err != nil {
 		fmt.Println("Error getting board info:", err)
 		return
 	}
 	
 	nameEntry := widget.NewEntry()
-	nameEntry.SetText(currentName)
+	nameEntry.SetText(board.Name)
 	
 	descEntry := widget.NewMultiLineEntry()
-	descEntry.SetText(currentDesc)
+	descEntry.SetText(board.Description)
 	
 	cancelBtn := widget.NewButton("Cancel", func() {})
 	saveBtn := widget.NewButton("Save", func() {})
@@ -1718,7 +1810,6 @@ func showEditBoardDialog(boardID int) {
 	saveBtn.OnTapped = func() {
 		if nameEntry.Text != "" {
 			updateBoard(boardID, nameEntry.Text, descEntry.Text)
-			loadBoard(boardID)
 		}
 		dialog.Hide()
 	}
@@ -1727,16 +1818,14 @@ func showEditBoardDialog(boardID int) {
 }
 
 func showEditSwimlaneDialog(swimlaneID int) {
-	var currentName string
-	var boardID int
-	err := db.QueryRow("SELECT name, board_id FROM swimlanes WHERE id = ?", swimlaneID).Scan(&currentName, &boardID)
+	swimlane, err := storage.LoadSwimlane(swimlaneID)
 	if err != nil {
 		fmt.Println("Error getting swimlane info:", err)
 		return
 	}
 	
 	nameEntry := widget.NewEntry()
-	nameEntry.SetText(currentName)
+	nameEntry.SetText(swimlane.Name)
 	
 	cancelBtn := widget.NewButton("Cancel", func() {})
 	saveBtn := widget.NewButton("Save", func() {})
@@ -1753,7 +1842,7 @@ func showEditSwimlaneDialog(swimlaneID int) {
 	saveBtn.OnTapped = func() {
 		if nameEntry.Text != "" {
 			updateSwimlane(swimlaneID, nameEntry.Text)
-			loadBoard(boardID)
+			refreshSwimlane(swimlaneID)
 		}
 		dialog.Hide()
 	}
@@ -1762,18 +1851,20 @@ func showEditSwimlaneDialog(swimlaneID int) {
 }
 
 func showEditListDialog(listID int) {
-	var currentName string
-	var boardID int
-	err := db.QueryRow(`SELECT l.name, s.board_id FROM lists l 
-		JOIN swimlanes s ON l.swimlane_id = s.id 
-		WHERE l.id = ?`, listID).Scan(&currentName, &boardID)
+	list, err := storage.LoadList(listID)
 	if err != nil {
 		fmt.Println("Error getting list info:", err)
 		return
 	}
 	
 	nameEntry := widget.NewEntry()
-	nameEntry.SetText(currentName)
+	nameEntry.SetText(list.Name)
+
+	wip := list.WIP()
+	limitEntry := widget.NewEntry()
+	limitEntry.SetText(strconv.Itoa(wip.Limit))
+	hardCheck := widget.NewCheck("Hard limit (confirm before exceeding)", nil)
+	hardCheck.SetChecked(wip.Hard)
 	
 	cancelBtn := widget.NewButton("Cancel", func() {})
 	saveBtn := widget.NewButton("Save", func() {})
@@ -1782,16 +1873,28 @@ func showEditListDialog(listID int) {
 		widget.NewLabel("Edit List"),
 		widget.NewLabel("List Name:"),
 		nameEntry,
+		widget.NewLabel("WIP Limit (0 for none):"),
+		limitEntry,
+		hardCheck,
 		container.NewHBox(cancelBtn, saveBtn),
 	)
 	
 	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
 	cancelBtn.OnTapped = dialog.Hide
 	saveBtn.OnTapped = func() {
+		limit, err := strconv.Atoi(strings.TrimSpace(limitEntry.Text))
+		if err != nil {
+			fmt.Println("Invalid WIP limit:", limitEntry.Text)
+			return
+		}
+		if err := storage.SetWIPLimit(listID, limit, hardCheck.Checked); err != nil {
+			fmt.Println("Error setting WIP limit:", err)
+			return
+		}
 		if nameEntry.Text != "" {
 			updateList(listID, nameEntry.Text)
-			loadBoard(boardID)
 		}
+		refreshList(listID)
 		dialog.Hide()
 	}
 	
@@ -1799,22 +1902,17 @@ func showEditListDialog(listID int) {
 }
 
 func showEditCardDialog(cardID int) {
-	var currentTitle, currentDesc string
-	var boardID int
-	err := db.QueryRow(`SELECT c.title, c.description, s.board_id FROM cards c
-		JOIN lists l ON c.list_id = l.id
-		JOIN swimlanes s ON l.swimlane_id = s.id
-		WHERE c.id = ?`, cardID).Scan(&currentTitle, &currentDesc, &boardID)
+	card, err := storage.LoadCard(cardID)
 	if err != nil {
 		fmt.Println("Error getting card info:", err)
 		return
 	}
 	
 	titleEntry := widget.NewEntry()
-	titleEntry.SetText(currentTitle)
+	titleEntry.SetText(card.Title)
 	
 	descEntry := widget.NewMultiLineEntry()
-	descEntry.SetText(currentDesc)
+	descEntry.SetText(card.Description)
 	
 	cancelBtn := widget.NewButton("Cancel", func() {})
 	saveBtn := widget.NewButton("Save", func() {})
@@ -1833,7 +1931,7 @@ func showEditCardDialog(cardID int) {
 	saveBtn.OnTapped = func() {
 		if titleEntry.Text != "" {
 			updateCard(cardID, titleEntry.Text, descEntry.Text)
-			loadBoard(boardID)
+			refreshCard(cardID)
 		}
 		dialog.Hide()
 	}
@@ -1858,15 +1956,16 @@ func refreshBoardContainer() {
 				} else {
 					delete(selectedBoards, b.ID)
 				}
-				// Just refresh toolbar to update selection count
-				if currentBoardID > 0 {
-					loadBoard(currentBoardID)
-				}
+				updateSelectionInfo()
 			}
 		}(board))
 		boardCheck.Checked = selectedBoards[board.ID]
 		
-		boardBtn := widget.NewButton(fmt.Sprintf("%d: %s", board.ID, board.Name), func(b Board) func() {
+		boardLabel := fmt.Sprintf("%d: %s", board.ID, board.Name)
+		if board.IsTemplate {
+			boardLabel += " " + templateMark
+		}
+		boardBtn := widget.NewButton(boardLabel, func(b Board) func() {
 			return func() {
 				currentBoardID = b.ID
 				loadBoard(b.ID)
@@ -1878,7 +1977,7 @@ func refreshBoardContainer() {
 		boardContainer.Add(boardRow)
 	}
 	
-	if mainWindow != nil {
+	if mainWindow != nil && mainWindow.Content() != nil {
 		mainWindow.Content().Refresh()
 	}
 }
@@ -1911,78 +2010,1475 @@ func showConfirmDialog(title, message string, onConfirm func()) {
 	dialog.Show()
 }
 
-// XLSX Export function (from xlsx_exporter.go)
-func exportBoardToXLSX(boardID int, outputFile string) error {
-	f := excelize.NewFile()
-	defer f.Close()
+// Marker of template boards and badge of template swimlanes, lists and cards
+const templateMark = "[Template]"
 
-	streamWriter, err := f.NewStreamWriter("Sheet1")
-	if err != nil {
-		return err
-	}
-
-	// Write header
-	header := []interface{}{"Board", "Swimlane", "List", "Card Title", "Description", "Created At"}
-	if err := streamWriter.SetRow("A1", header); err != nil {
-		return err
-	}
-
-	// Query data
-	rows, err := db.Query(`
-		SELECT b.name AS board_name, s.name AS swimlane_name, l.name AS list_name,
-		       c.title, c.description, c.created_at, c.attachment
-		FROM boards b
-		JOIN swimlanes s ON b.id = s.board_id
-		JOIN lists l ON s.id = l.swimlane_id
-		JOIN cards c ON l.id = c.list_id
-		WHERE b.id = ?
-		ORDER BY s.position, l.position, c.position
-	`, boardID)
+func templateBadge() fyne.CanvasObject {
+	text := canvas.NewText(templateMark, theme.Color(theme.ColorNamePrimary))
+	text.TextSize = 10
+	text.TextStyle.Italic = true
+	return container.NewCenter(text)
+}
+
+// toggleTemplateSelected marks the selected boards, swimlanes, lists and
+// cards as templates, or unmarks them if they all are templates already.
+func toggleTemplateSelected() {
+	type item struct {
+		kind store.TemplateKind
+		id   int
+	}
+	var items []item
+	for id := range selectedBoards {
+		items = append(items, item{store.TemplateBoard, id})
+	}
+	for id := range selectedSwimlanes {
+		items = append(items, item{store.TemplateSwimlane, id})
+	}
+	for id := range selectedLists {
+		items = append(items, item{store.TemplateList, id})
+	}
+	for id := range selectedCards {
+		items = append(items, item{store.TemplateCard, id})
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	mark := false
+	for _, it := range items {
+		if ok, err := storage.IsTemplate(it.kind, it.id); err == nil && !ok {
+			mark = true
+		}
+	}
+	for _, it := range items {
+		if err := storage.SetTemplate(it.kind, it.id, mark); err != nil {
+			fmt.Println("Error marking template:", err)
+		}
+	}
+	loadBoard(currentBoardID)
+	refreshBoardContainer()
+}
+
+// showTemplateDialog creates an item from a template: a board, a swimlane
+// of the board targetID, a list of the swimlane targetID or a card of the
+// list targetID. Each placeholder of the template gets an entry, with the
+// built-in ones filled in.
+func showTemplateDialog(kind store.TemplateKind, targetID int) {
+	templates, err := storage.Templates(kind)
 	if err != nil {
-		return err
+		fmt.Println("Error loading templates:", err)
+		return
+	}
+	byLabel := make(map[string]store.Template)
+	labels := make([]string, 0, len(templates))
+	for _, t := range templates {
+		label := fmt.Sprintf("%s (%s, #%d)", t.Name, t.Board, t.ID)
+		byLabel[label] = t
+		labels = append(labels, label)
 	}
-	defer rows.Close()
 
-	rowNum := 2
-	for rows.Next() {
-		var boardName, swimlaneName, listName, title, description, createdAt string
-		var attachment []byte
-		err := rows.Scan(&boardName, &swimlaneName, &listName, &title, &description, &createdAt, &attachment)
+	var dialog *widget.PopUp
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Name")
+	fields := container.NewVBox()
+	entries := make(map[string]*widget.Entry)
+	defaults := store.PlaceholderDefaults(time.Now())
+	info := widget.NewLabel("")
+	if len(templates) == 0 {
+		info.SetText(fmt.Sprintf("No %s templates yet. Select one and tap Template to mark it.", kind))
+	}
+
+	templateSelect := widget.NewSelect(labels, func(label string) {
+		t := byLabel[label]
+		nameEntry.SetText(t.Name)
+		names, err := store.TemplatePlaceholders(storage, kind, t.ID)
 		if err != nil {
-			continue
+			fmt.Println("Error reading template:", err)
 		}
-
-		row := []interface{}{boardName, swimlaneName, listName, title, description, createdAt}
-		cell, _ := excelize.CoordinatesToCellName(1, rowNum)
-		if err := streamWriter.SetRow(cell, row); err != nil {
-			continue
+		fields.RemoveAll()
+		entries = make(map[string]*widget.Entry)
+		for _, name := range names {
+			entry := widget.NewEntry()
+			entry.SetText(defaults[name])
+			entries[name] = entry
+			fields.Add(container.NewBorder(nil, nil, widget.NewLabel("{{"+name+"}}"), nil, entry))
 		}
-
-		// If attachment exists, add as image
-		if len(attachment) > 0 {
-			imageCell, _ := excelize.CoordinatesToCellName(7, rowNum)
-			f.AddPictureFromBytes("Sheet1", imageCell, &excelize.Picture{
-				File:      attachment,
-				Extension: ".png",
-			})
+		if dialog != nil {
+			dialog.Resize(fyne.NewSize(450, dialog.Content.MinSize().Height))
 		}
+	})
+	templateSelect.PlaceHolder = fmt.Sprintf("Choose a %s template", kind)
 
-		rowNum++
-	}
-
-	if err := streamWriter.Flush(); err != nil {
-		return err
+	cancelBtn := widget.NewButton("Cancel", func() {})
+	createBtn := widget.NewButton("Create", func() {})
+	content := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("New %s from Template", kind)),
+		templateSelect,
+		nameEntry,
+		fields,
+		info,
+		container.NewHBox(cancelBtn, createBtn),
+	)
+	dialog = widget.NewModalPopUp(content, mainWindow.Canvas())
+	cancelBtn.OnTapped = dialog.Hide
+	createBtn.OnTapped = func() {
+		t, ok := byLabel[templateSelect.Selected]
+		if !ok || nameEntry.Text == "" {
+			return
+		}
+		values := make(map[string]string)
+		for name, entry := range entries {
+			values[name] = entry.Text
+		}
+		fill := func(s string) string { return store.FillPlaceholders(s, values) }
+		dialog.Hide()
+		instantiateTemplate(t, targetID, fill(nameEntry.Text), fill)
 	}
-
-	// Save file
-	return f.SaveAs(outputFile)
+	dialog.Resize(fyne.NewSize(450, content.MinSize().Height))
+	dialog.Show()
 }
 
-// GUI functions
-func createMainWindow(a fyne.App) fyne.Window {
-	w := a.NewWindow("Go Kanban Board")
-	mainWindow = w
-
+// instantiateTemplate deep-copies a template under a new name, with the
+// texts of the copied items rewritten by text. Swimlanes, lists and cards
+// are added at the end of the target; cards go through the WIP limit and
+// the rules like new cards. Neither the copy nor the items in it are
+// templates, since clones never are.
+func instantiateTemplate(t store.Template, targetID int, name string, text func(string) string) {
+	opts := store.CloneOptions{ParentID: targetID, Name: name, Text: text}
+	switch t.Kind {
+	case store.TemplateBoard:
+		newBoardID, err := storage.CloneBoard(t.ID, opts)
+		if err != nil {
+			fmt.Println("Error creating board from template:", err)
+			return
+		}
+		currentBoardID = newBoardID
+		loadBoard(currentBoardID)
+		refreshBoardList()
+	case store.TemplateSwimlane:
+		if _, err := storage.CloneSwimlane(t.ID, opts); err != nil {
+			fmt.Println("Error creating swimlane from template:", err)
+			return
+		}
+		loadBoard(currentBoardID)
+	case store.TemplateList:
+		if _, err := storage.CloneList(t.ID, opts); err != nil {
+			fmt.Println("Error creating list from template:", err)
+			return
+		}
+		refreshSwimlane(targetID)
+	case store.TemplateCard:
+		checkWIPLimit(targetID, func() {
+			if _, err := storage.CloneCard(t.ID, opts); err != nil {
+				fmt.Println("Error creating card from template:", err)
+				return
+			}
+			refreshList(targetID)
+		})
+	}
+}
+
+// Header background of lists over their WIP limit
+var wipExceededColor = color.NRGBA{211, 47, 47, 255}
+
+// showAttachmentDialog shows the attachment of a card, previewing images
+func showAttachmentDialog(cardID int) {
+	data, err := attachments.Get(cardID)
+	if err != nil {
+		fmt.Println("Error loading attachment:", err)
+		return
+	}
+
+	var preview fyne.CanvasObject
+	if _, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		img := canvas.NewImageFromReader(bytes.NewReader(data), "attachment."+format)
+		img.FillMode = canvas.ImageFillContain
+		img.SetMinSize(fyne.NewSize(400, 300))
+		preview = img
+	} else {
+		preview = widget.NewLabel(fmt.Sprintf("Attachment of %d bytes (not an image)", len(data)))
+	}
+
+	closeBtn := widget.NewButton("Close", func() {})
+	content := container.NewVBox(
+		widget.NewLabel("Attachment"),
+		preview,
+		closeBtn,
+	)
+	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
+	closeBtn.OnTapped = dialog.Hide
+	dialog.Show()
+}
+
+// Filter applied to the cards of the displayed board, set in the filter bar
+var activeFilter store.Filter
+
+// Overlay of cards dimmed by the filter
+var filterDim = color.NRGBA{255, 255, 255, 180}
+
+// filters is the filter bar below the toolbar
+var filters *filterBar
+
+// hiddenByFilter reports whether a card is left out of the board
+func hiddenByFilter(c store.Card) bool {
+	return activeFilter.Display != store.DisplayDim && !activeFilter.Matches(c)
+}
+
+type filterBar struct {
+	obj        *fyne.Container
+	boardID    int
+	text       *widget.Entry
+	textColor  *widget.Entry
+	bgColor    *widget.Entry
+	from       *widget.Entry
+	to         *widget.Entry
+	attachment *widget.Select
+	match      *widget.RadioGroup
+	display    *widget.Select
+	saved      *widget.Select
+	info       *widget.Label
+}
+
+// Labels of the filter bar choices
+var (
+	attachmentChoices = map[string]store.AttachmentFilter{
+		"Any attachment":     store.AttachmentAny,
+		"With attachment":    store.AttachmentWith,
+		"Without attachment": store.AttachmentWithout,
+	}
+	matchChoices   = map[string]store.MatchMode{"Match all": store.MatchAll, "Match any": store.MatchAny}
+	displayChoices = map[string]store.DisplayMode{"Hide others": store.DisplayHide, "Dim others": store.DisplayDim}
+)
+
+func choiceLabel[T comparable](choices map[string]T, value T, fallback string) string {
+	for label, v := range choices {
+		if v == value {
+			return label
+		}
+	}
+	return fallback
+}
+
+// createFilterBar builds the filter bar, hidden until the Filter button
+// of the toolbar is tapped.
+func createFilterBar() *filterBar {
+	f := &filterBar{
+		text:       widget.NewEntry(),
+		textColor:  widget.NewEntry(),
+		bgColor:    widget.NewEntry(),
+		from:       widget.NewEntry(),
+		to:         widget.NewEntry(),
+		attachment: widget.NewSelect([]string{"Any attachment", "With attachment", "Without attachment"}, nil),
+		match:      widget.NewRadioGroup([]string{"Match all", "Match any"}, nil),
+		display:    widget.NewSelect([]string{"Hide others", "Dim others"}, nil),
+		info:       widget.NewLabel(""),
+	}
+	f.text.SetPlaceHolder("Text")
+	f.textColor.SetPlaceHolder("Text color #RRGGBB")
+	f.bgColor.SetPlaceHolder("Background #RRGGBB")
+	f.from.SetPlaceHolder("From YYYY-MM-DD")
+	f.to.SetPlaceHolder("To YYYY-MM-DD")
+	f.match.Horizontal = true
+	f.set(store.Filter{})
+	for _, e := range []*widget.Entry{f.text, f.textColor, f.bgColor, f.from, f.to} {
+		e.OnSubmitted = func(string) { f.apply() }
+	}
+
+	f.saved = widget.NewSelect(nil, func(name string) {
+		if db == nil {
+			return
+		}
+		saved, err := store.SavedFilters(db, currentBoardID)
+		if err != nil {
+			fmt.Println("Error loading saved filters:", err)
+			return
+		}
+		for _, sf := range saved {
+			if sf.Name == name {
+				f.set(sf.Filter)
+				f.apply()
+			}
+		}
+	})
+	f.saved.PlaceHolder = "Saved filters"
+
+	applyBtn := widget.NewButton("Apply", f.apply)
+	applyBtn.Importance = widget.HighImportance
+	clearBtn := widget.NewButton("Clear", func() {
+		f.saved.ClearSelected()
+		f.set(store.Filter{Display: activeFilter.Display})
+		f.apply()
+	})
+	saveBtn := widget.NewButton("Save...", f.showSaveDialog)
+	deleteBtn := widget.NewButton("Delete", func() {
+		name := f.saved.Selected
+		if name == "" {
+			return
+		}
+		showConfirmDialog("Delete Filter", fmt.Sprintf("Delete the saved filter %q?", name), func() {
+			if err := store.DeleteFilter(db, currentBoardID, name); err != nil {
+				fmt.Println("Error deleting filter:", err)
+			}
+			f.saved.ClearSelected()
+			f.refreshSaved(currentBoardID)
+		})
+	})
+
+	f.obj = container.NewVBox(
+		container.NewGridWithColumns(5, f.text, f.textColor, f.bgColor, f.from, f.to),
+		container.NewHBox(f.attachment, f.match, f.display, applyBtn, clearBtn,
+			layout.NewSpacer(), f.saved, saveBtn, deleteBtn),
+		f.info,
+	)
+	f.obj.Hide()
+	return f
+}
+
+// set shows a filter in the fields of the bar
+func (f *filterBar) set(filter store.Filter) {
+	f.text.SetText(filter.Text)
+	f.textColor.SetText(filter.TextColor)
+	f.bgColor.SetText(filter.BackgroundColor)
+	f.from.SetText(filter.CreatedFrom)
+	f.to.SetText(filter.CreatedTo)
+	f.attachment.SetSelected(choiceLabel(attachmentChoices, filter.Attachment, "Any attachment"))
+	f.match.SetSelected(choiceLabel(matchChoices, filter.Match, "Match all"))
+	f.display.SetSelected(choiceLabel(displayChoices, filter.Display, "Hide others"))
+}
+
+// filter reads the filter from the fields of the bar
+func (f *filterBar) filter() store.Filter {
+	return store.Filter{
+		Match:           matchChoices[f.match.Selected],
+		Text:            strings.TrimSpace(f.text.Text),
+		TextColor:       strings.TrimSpace(f.textColor.Text),
+		BackgroundColor: strings.TrimSpace(f.bgColor.Text),
+		CreatedFrom:     strings.TrimSpace(f.from.Text),
+		CreatedTo:       strings.TrimSpace(f.to.Text),
+		Attachment:      attachmentChoices[f.attachment.Selected],
+		Display:         displayChoices[f.display.Selected],
+	}
+}
+
+// apply makes the filter of the bar the active filter and reloads the board
+func (f *filterBar) apply() {
+	filter := f.filter()
+	if err := filter.Validate(); err != nil {
+		f.info.SetText(err.Error())
+		return
+	}
+	activeFilter = filter
+	if currentBoardID > 0 {
+		loadBoard(currentBoardID)
+	}
+}
+
+func (f *filterBar) showSaveDialog() {
+	if !haveDatabase("Saved filters") {
+		return
+	}
+	filter := f.filter()
+	if err := filter.Validate(); err != nil {
+		f.info.SetText(err.Error())
+		return
+	}
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Filter name")
+	nameEntry.SetText(f.saved.Selected)
+
+	saveBtn := widget.NewButton("Save", func() {})
+	cancelBtn := widget.NewButton("Cancel", func() {})
+	content := container.NewVBox(
+		widget.NewLabel("Save Filter for This Board"),
+		nameEntry,
+		container.NewHBox(saveBtn, cancelBtn),
+	)
+	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
+	cancelBtn.OnTapped = dialog.Hide
+	saveBtn.OnTapped = func() {
+		if nameEntry.Text == "" {
+			return
+		}
+		if err := store.SaveFilter(db, currentBoardID, nameEntry.Text, filter); err != nil {
+			fmt.Println("Error saving filter:", err)
+			return
+		}
+		dialog.Hide()
+		f.refreshSaved(currentBoardID)
+		f.saved.Selected = strings.TrimSpace(nameEntry.Text)
+		f.saved.Refresh()
+	}
+	dialog.Resize(fyne.NewSize(300, 150))
+	dialog.Show()
+}
+
+// refreshSaved lists the saved filters of a board
+func (f *filterBar) refreshSaved(boardID int) {
+	if db == nil {
+		return
+	}
+	saved, err := store.SavedFilters(db, boardID)
+	if err != nil {
+		fmt.Println("Error loading saved filters:", err)
+	}
+	names := make([]string, 0, len(saved))
+	for _, sf := range saved {
+		names = append(names, sf.Name)
+	}
+	f.saved.SetOptions(names)
+}
+
+// update is called by loadBoard with the loaded board to list its saved
+// filters and count the matching cards.
+func (f *filterBar) update(boardID int, tree []store.Swimlane) {
+	if f == nil {
+		return
+	}
+	if f.boardID != boardID {
+		// Saved filters belong to a board; the active filter stays
+		f.boardID = boardID
+		f.saved.ClearSelected()
+	}
+	f.refreshSaved(boardID)
+	if !activeFilter.Active() {
+		f.info.SetText("No filter applied")
+		return
+	}
+	matched, total := 0, 0
+	for _, s := range tree {
+		for _, l := range s.Lists {
+			for _, c := range l.Cards {
+				total++
+				if activeFilter.Matches(c) {
+					matched++
+				}
+			}
+		}
+	}
+	f.info.SetText(fmt.Sprintf("%d of %d cards match", matched, total))
+}
+
+// Labels of the rule events and actions in the rule editor
+var ruleEvents = []struct {
+	event store.Event
+	label string
+}{
+	{store.EventCreated, "Card created"},
+	{store.EventMoved, "Card moved into list"},
+}
+
+var ruleActions = []struct {
+	kind  store.ActionKind
+	label string
+}{
+	{store.ActionBackgroundColor, "Set background color"},
+	{store.ActionTextColor, "Set text color"},
+	{store.ActionChecklist, "Add checklist"},
+	{store.ActionArchive, "Archive"},
+}
+
+// describeRule returns a sentence like "When a card is moved into Done,
+// archive it after 3 days".
+func describeRule(r store.Rule) string {
+	var where []string
+	if r.Trigger.SwimlaneID != 0 {
+		var name string
+		if s, err := storage.LoadSwimlane(r.Trigger.SwimlaneID); err == nil {
+			name = s.Name
+		}
+		where = append(where, "swimlane "+name)
+	}
+	if r.Trigger.ListID != 0 {
+		var name string
+		if l, err := storage.LoadList(r.Trigger.ListID); err == nil {
+			name = l.Name
+		}
+		where = append(where, "list "+name)
+	}
+	s := "When a card is created"
+	if r.Trigger.Event == store.EventMoved {
+		s = "When a card is moved"
+	}
+	if len(where) > 0 {
+		s += " in " + strings.Join(where, ", ")
+	}
+
+	a := r.Action
+	switch a.Kind {
+	case store.ActionBackgroundColor:
+		s += ", set background color " + a.Color
+	case store.ActionTextColor:
+		s += ", set text color " + a.Color
+	case store.ActionChecklist:
+		s += ", add checklist " + strings.Join(a.Items, ", ")
+	case store.ActionArchive:
+		if a.Days == 0 {
+			s += ", archive it"
+		} else {
+			s += fmt.Sprintf(", archive it after %d days", a.Days)
+		}
+	}
+	return s
+}
+
+// showRulesDialog lists the rules of the current board with buttons to
+// add, edit, disable and delete them.
+func showRulesDialog() {
+	if currentBoardID == 0 {
+		return
+	}
+	rules, err := storage.Rules(currentBoardID)
+	if err != nil {
+		fmt.Println("Error loading rules:", err)
+		return
+	}
+
+	var dialog *widget.PopUp
+	rows := container.NewVBox()
+	for _, r := range rules {
+		r := r
+		enabledCheck := widget.NewCheck("", nil)
+		enabledCheck.SetChecked(r.Enabled)
+		enabledCheck.OnChanged = func(on bool) {
+			r.Enabled = on
+			if err := storage.SaveRule(&r); err != nil {
+				fmt.Println("Error saving rule:", err)
+			}
+		}
+		label := widget.NewLabel(r.Name + ": " + describeRule(r))
+		label.Wrapping = fyne.TextWrapWord
+		editBtn := widget.NewButton("Edit", func() {
+			dialog.Hide()
+			showRuleEditor(r)
+		})
+		deleteBtn := widget.NewButton("Delete", func() {
+			if err := storage.DeleteRule(r.ID); err != nil {
+				fmt.Println("Error deleting rule:", err)
+			}
+			dialog.Hide()
+			showRulesDialog()
+		})
+		rows.Add(container.NewBorder(nil, nil, enabledCheck, container.NewHBox(editBtn, deleteBtn), label))
+	}
+	if len(rules) == 0 {
+		rows.Add(widget.NewLabel("No rules for this board"))
+	}
+
+	addBtn := widget.NewButton("Add Rule", func() {
+		dialog.Hide()
+		showRuleEditor(store.Rule{
+			BoardID: currentBoardID,
+			Enabled: true,
+			Trigger: store.Trigger{Event: store.EventMoved},
+			Action:  store.Action{Kind: store.ActionBackgroundColor},
+		})
+	})
+	closeBtn := widget.NewButton("Close", func() {})
+	content := container.NewBorder(
+		widget.NewLabel("Rules of This Board"),
+		container.NewHBox(addBtn, closeBtn),
+		nil, nil,
+		container.NewVScroll(rows),
+	)
+	dialog = widget.NewModalPopUp(content, mainWindow.Canvas())
+	closeBtn.OnTapped = dialog.Hide
+	dialog.Resize(fyne.NewSize(600, 400))
+	dialog.Show()
+}
+
+// showRuleEditor edits a rule of the current board, or adds it if it has
+// no ID, and returns to the rules dialog.
+func showRuleEditor(rule store.Rule) {
+	tree, err := storage.LoadBoard(rule.BoardID)
+	if err != nil {
+		fmt.Println("Error loading board:", err)
+		return
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Rule name")
+	nameEntry.SetText(rule.Name)
+
+	eventSelect := widget.NewSelect(nil, nil)
+	for _, e := range ruleEvents {
+		eventSelect.Options = append(eventSelect.Options, e.label)
+		if e.event == rule.Trigger.Event {
+			eventSelect.Selected = e.label
+		}
+	}
+
+	// Swimlanes and lists by label, 0 for any
+	const anySwimlane, anyList = "Any swimlane", "Any list"
+	swimlaneIDs := map[string]int{anySwimlane: 0}
+	listIDs := map[string]int{anyList: 0}
+	swimlaneSelect := widget.NewSelect([]string{anySwimlane}, nil)
+	swimlaneSelect.Selected = anySwimlane
+	listSelect := widget.NewSelect([]string{anyList}, nil)
+	listSelect.Selected = anyList
+	for _, s := range tree {
+		label := fmt.Sprintf("%s (#%d)", s.Name, s.ID)
+		swimlaneIDs[label] = s.ID
+		swimlaneSelect.Options = append(swimlaneSelect.Options, label)
+		if s.ID == rule.Trigger.SwimlaneID {
+			swimlaneSelect.Selected = label
+		}
+		for _, l := range s.Lists {
+			label := fmt.Sprintf("%s / %s (#%d)", s.Name, l.Name, l.ID)
+			listIDs[label] = l.ID
+			listSelect.Options = append(listSelect.Options, label)
+			if l.ID == rule.Trigger.ListID {
+				listSelect.Selected = label
+			}
+		}
+	}
+
+	colorEntry := widget.NewEntry()
+	colorEntry.SetPlaceHolder("#RRGGBB")
+	colorEntry.SetText(rule.Action.Color)
+	itemsEntry := widget.NewMultiLineEntry()
+	itemsEntry.SetPlaceHolder("One checklist item per line")
+	itemsEntry.SetText(strings.Join(rule.Action.Items, "\n"))
+	daysEntry := widget.NewEntry()
+	daysEntry.SetText(strconv.Itoa(rule.Action.Days))
+	colorRow := container.NewBorder(nil, nil, widget.NewLabel("Color:"), nil, colorEntry)
+	itemsRow := container.NewBorder(nil, nil, widget.NewLabel("Items:"), nil, itemsEntry)
+	daysRow := container.NewBorder(nil, nil, widget.NewLabel("After days (0 for now):"), nil, daysEntry)
+
+	actionSelect := widget.NewSelect(nil, nil)
+	for _, a := range ruleActions {
+		actionSelect.Options = append(actionSelect.Options, a.label)
+	}
+	actionSelect.OnChanged = func(label string) {
+		colorRow.Hide()
+		itemsRow.Hide()
+		daysRow.Hide()
+		switch label {
+		case "Set background color", "Set text color":
+			colorRow.Show()
+		case "Add checklist":
+			itemsRow.Show()
+		case "Archive":
+			daysRow.Show()
+		}
+	}
+	for _, a := range ruleActions {
+		if a.kind == rule.Action.Kind {
+			actionSelect.SetSelected(a.label)
+		}
+	}
+
+	enabledCheck := widget.NewCheck("Enabled", nil)
+	enabledCheck.SetChecked(rule.Enabled)
+	info := widget.NewLabel("")
+
+	saveBtn := widget.NewButton("Save", func() {})
+	cancelBtn := widget.NewButton("Cancel", func() {})
+	content := container.NewVBox(
+		widget.NewLabel("Edit Rule"),
+		nameEntry,
+		widget.NewLabel("When:"),
+		eventSelect,
+		swimlaneSelect,
+		listSelect,
+		widget.NewLabel("Then:"),
+		actionSelect,
+		colorRow,
+		itemsRow,
+		daysRow,
+		enabledCheck,
+		info,
+		container.NewHBox(saveBtn, cancelBtn),
+	)
+	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
+	cancelBtn.OnTapped = func() {
+		dialog.Hide()
+		showRulesDialog()
+	}
+	saveBtn.OnTapped = func() {
+		rule.Name = nameEntry.Text
+		rule.Enabled = enabledCheck.Checked
+		rule.Trigger = store.Trigger{
+			SwimlaneID: swimlaneIDs[swimlaneSelect.Selected],
+			ListID:     listIDs[listSelect.Selected],
+		}
+		for _, e := range ruleEvents {
+			if e.label == eventSelect.Selected {
+				rule.Trigger.Event = e.event
+			}
+		}
+		rule.Action = store.Action{}
+		for _, a := range ruleActions {
+			if a.label == actionSelect.Selected {
+				rule.Action.Kind = a.kind
+			}
+		}
+		switch rule.Action.Kind {
+		case store.ActionBackgroundColor, store.ActionTextColor:
+			rule.Action.Color = strings.TrimSpace(colorEntry.Text)
+		case store.ActionChecklist:
+			rule.Action.Items = strings.Split(itemsEntry.Text, "\n")
+		case store.ActionArchive:
+			days, err := strconv.Atoi(strings.TrimSpace(daysEntry.Text))
+			if err != nil {
+				info.SetText("Days must be a number")
+				return
+			}
+			rule.Action.Days = days
+		}
+		if err := storage.SaveRule(&rule); err != nil {
+			info.SetText(err.Error())
+			return
+		}
+		dialog.Hide()
+		showRulesDialog()
+	}
+	dialog.Resize(fyne.NewSize(500, content.MinSize().Height))
+	dialog.Show()
+}
+
+// Outline of cards matching the toolbar search
+var searchHighlight = color.NRGBA{255, 170, 0, 255}
+
+// searchCards runs the toolbar search, highlights the matching cards and
+// lists them with a button to jump to each. An empty search clears the
+// highlights.
+func searchCards(text string) {
+	if !haveDatabase("Search") {
+		return
+	}
+	results, err := store.Search(db, text, store.SearchOptions{})
+	if err != nil {
+		fmt.Println("Error searching cards:", err)
+		return
+	}
+
+	changed := searchMatches
+	searchMatches = make(map[int]bool)
+	for _, r := range results {
+		searchMatches[r.CardID] = true
+		changed[r.CardID] = true
+	}
+	for id := range changed {
+		if _, ok := view.lookupCard(id); ok {
+			refreshCard(id)
+		}
+	}
+
+	if strings.TrimSpace(text) != "" {
+		showSearchResults(text, results)
+	}
+}
+
+func showSearchResults(text string, results []store.SearchResult) {
+	closeBtn := widget.NewButton("Close", func() {})
+	var dialog *widget.PopUp
+
+	rows := container.NewVBox()
+	for _, r := range results {
+		titleBtn := widget.NewButton(r.Title, func() {
+			dialog.Hide()
+			jumpToCard(r)
+		})
+		titleBtn.Alignment = widget.ButtonAlignLeading
+		location := widget.NewLabel(fmt.Sprintf("%s › %s › %s", r.Board, r.Swimlane, r.List))
+		snippet := widget.NewLabel(r.Snippet)
+		snippet.Wrapping = fyne.TextWrapWord
+		rows.Add(container.NewVBox(titleBtn, location, snippet, widget.NewSeparator()))
+	}
+	if len(results) == 0 {
+		rows.Add(widget.NewLabel("No cards match."))
+	}
+	scroll := container.NewVScroll(rows)
+	scroll.SetMinSize(fyne.NewSize(500, 400))
+
+	content := container.NewBorder(
+		widget.NewLabel(fmt.Sprintf("Search results for %q: %d", text, len(results))),
+		closeBtn, nil, nil, scroll,
+	)
+	dialog = widget.NewModalPopUp(content, mainWindow.Canvas())
+	closeBtn.OnTapped = dialog.Hide
+	dialog.Show()
+}
+
+// jumpToCard shows the board of a search result, scrolls to its card and
+// puts the keyboard cursor on it
+func jumpToCard(r store.SearchResult) {
+	if r.BoardID != currentBoardID {
+		currentBoardID = r.BoardID
+		loadBoard(currentBoardID)
+		refreshBoardContainer()
+	}
+	cv, ok := view.lookupCard(r.CardID)
+	if !ok {
+		return
+	}
+	driver := fyne.CurrentApp().Driver()
+	offset := driver.AbsolutePositionForObject(cv.obj).Subtract(driver.AbsolutePositionForObject(view.root))
+	mainArea.ScrollToOffset(offset)
+	setFocus(focusItem{"card", r.CardID})
+}
+
+// Keyboard navigation
+
+// focusItem is the swimlane, list or card under the keyboard cursor. The
+// kind is "swimlane", "list" or "card", as for drop slots.
+type focusItem struct {
+	kind string
+	id   int
+}
+
+var focus focusItem
+
+// Outline of the item under the keyboard cursor
+var focusColor = color.NRGBA{25, 118, 210, 255}
+
+// focusRing returns the outline of an item, drawn when it has the keyboard
+// cursor. Like the search highlight it lets input through.
+func focusRing(kind string, id int) *canvas.Rectangle {
+	ring := canvas.NewRectangle(color.Transparent)
+	ring.StrokeWidth = 3
+	if focus == (focusItem{kind, id}) {
+		ring.StrokeColor = focusColor
+	}
+	return ring
+}
+
+// focusedObject returns the widget and outline of an item on the shown
+// board.
+func focusedObject(f focusItem) (fyne.CanvasObject, *canvas.Rectangle, bool) {
+	switch f.kind {
+	case "swimlane":
+		if sv, ok := view.lookupSwimlane(f.id); ok {
+			return sv.obj, sv.ring, true
+		}
+	case "list":
+		if lv, ok := view.lookupList(f.id); ok {
+			return lv.obj, lv.ring, true
+		}
+	case "card":
+		if cv, ok := view.lookupCard(f.id); ok {
+			return cv.obj, cv.ring, true
+		}
+	}
+	return nil, nil, false
+}
+
+// setFocus moves the keyboard cursor and scrolls its item into view
+func setFocus(f focusItem) {
+	if _, ring, ok := focusedObject(focus); ok {
+		ring.StrokeColor = color.Transparent
+		ring.Refresh()
+	}
+	focus = f
+	obj, ring, ok := focusedObject(f)
+	if !ok {
+		return
+	}
+	ring.StrokeColor = focusColor
+	ring.Refresh()
+	ensureVisible(obj)
+}
+
+// ensureVisible scrolls the board so that obj is shown, if it is not
+func ensureVisible(obj fyne.CanvasObject) {
+	driver := fyne.CurrentApp().Driver()
+	pos := driver.AbsolutePositionForObject(obj).Subtract(driver.AbsolutePositionForObject(view.root))
+	offset, size := mainArea.Offset, mainArea.Size()
+	if pos.X < offset.X || pos.X+obj.Size().Width > offset.X+size.Width {
+		offset.X = pos.X
+	}
+	if pos.Y < offset.Y || pos.Y+obj.Size().Height > offset.Y+size.Height {
+		offset.Y = pos.Y
+	}
+	if offset != mainArea.Offset {
+		mainArea.ScrollToOffset(offset)
+	}
+}
+
+// swimlaneOrder returns the IDs of the shown swimlanes from top to bottom
+func swimlaneOrder() []int {
+	ids := make([]int, len(view.swimlanes))
+	for id, sv := range view.swimlanes {
+		ids[sv.index] = id
+	}
+	return ids
+}
+
+func indexOf(ids []int, id int) int {
+	for i, x := range ids {
+		if x == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveFocus moves the keyboard cursor by one step. Up and down go through
+// the swimlanes of the board and the cards of a list; left and right go
+// through the lists of a swimlane. Up from a first card goes to its list
+// and from a list to its swimlane; right from a swimlane and down from a
+// list go inside.
+func moveFocus(dx, dy int) {
+	if view == nil {
+		return
+	}
+	if _, _, ok := focusedObject(focus); !ok {
+		if order := swimlaneOrder(); len(order) > 0 {
+			setFocus(focusItem{"swimlane", order[0]})
+		}
+		return
+	}
+
+	switch focus.kind {
+	case "swimlane":
+		sv := view.swimlanes[focus.id]
+		switch {
+		case dx > 0 && len(sv.listIDs) > 0:
+			setFocus(focusItem{"list", sv.listIDs[0]})
+		case dy != 0:
+			order := swimlaneOrder()
+			if i := sv.index + dy; i >= 0 && i < len(order) {
+				setFocus(focusItem{"swimlane", order[i]})
+			}
+		}
+	case "list":
+		lv := view.lists[focus.id]
+		listIDs := view.swimlanes[lv.swimlaneID].listIDs
+		switch {
+		case dy < 0 || (dx < 0 && lv.index == 0):
+			setFocus(focusItem{"swimlane", lv.swimlaneID})
+		case dy > 0 && len(lv.cardIDs) > 0:
+			setFocus(focusItem{"card", lv.cardIDs[0]})
+		case dx != 0 && lv.index+dx < len(listIDs):
+			setFocus(focusItem{"list", listIDs[lv.index+dx]})
+		}
+	case "card":
+		cv := view.cards[focus.id]
+		lv := view.lists[cv.listID]
+		i := indexOf(lv.cardIDs, focus.id)
+		switch {
+		case dy < 0 && i == 0:
+			setFocus(focusItem{"list", cv.listID})
+		case dy != 0:
+			if i+dy < len(lv.cardIDs) {
+				setFocus(focusItem{"card", lv.cardIDs[i+dy]})
+			}
+		case dx < 0 && lv.index == 0:
+			setFocus(focusItem{"swimlane", lv.swimlaneID})
+		case dx != 0:
+			// The card at the same height in the neighbour list
+			listIDs := view.swimlanes[lv.swimlaneID].listIDs
+			if lv.index+dx >= len(listIDs) {
+				return
+			}
+			next := view.lists[listIDs[lv.index+dx]]
+			if len(next.cardIDs) == 0 {
+				setFocus(focusItem{"list", listIDs[lv.index+dx]})
+				return
+			}
+			setFocus(focusItem{"card", next.cardIDs[min(i, len(next.cardIDs)-1)]})
+		}
+	}
+}
+
+// toggleFocusedSelection selects or unselects the item under the cursor
+func toggleFocusedSelection() {
+	var selected map[int]bool
+	var check *widget.Check
+	switch focus.kind {
+	case "swimlane":
+		if sv, ok := view.lookupSwimlane(focus.id); ok {
+			selected, check = selectedSwimlanes, sv.check
+		}
+	case "list":
+		if lv, ok := view.lookupList(focus.id); ok {
+			selected, check = selectedLists, lv.check
+		}
+	case "card":
+		if cv, ok := view.lookupCard(focus.id); ok {
+			selected, check = selectedCards, cv.check
+		}
+	}
+	if check == nil {
+		return
+	}
+	if selected[focus.id] {
+		delete(selected, focus.id)
+	} else {
+		selected[focus.id] = true
+	}
+	setChecked(check, selected[focus.id])
+	updateSelectionInfo()
+}
+
+// editFocused opens the edit dialog of the item under the cursor
+func editFocused() {
+	if _, _, ok := focusedObject(focus); !ok {
+		return
+	}
+	switch focus.kind {
+	case "swimlane":
+		showEditSwimlaneDialog(focus.id)
+	case "list":
+		showEditListDialog(focus.id)
+	case "card":
+		showEditCardDialog(focus.id)
+	}
+}
+
+// keyBindings are the keyboard shortcuts of the main window, in the order
+// of the help overlay. Keys without modifier work while no text field has
+// the focus. The arrow keys and the help keys are handled by
+// registerShortcuts.
+var keyBindings = []struct {
+	keys     string
+	help     string
+	key      fyne.KeyName
+	modifier fyne.KeyModifier
+	action   func()
+}{
+	{"Arrow keys", "Move the cursor between swimlanes, lists and cards", "", 0, nil},
+	{"Space", "Select or unselect the item under the cursor", fyne.KeySpace, 0, toggleFocusedSelection},
+	{"Enter", "Edit the item under the cursor", fyne.KeyReturn, 0, editFocused},
+	{"Shift+Up", "Move the selection up", fyne.KeyUp, fyne.KeyModifierShift, moveSelectedUp},
+	{"Shift+Down", "Move the selection down", fyne.KeyDown, fyne.KeyModifierShift, moveSelectedDown},
+	{"Shift+Left", "Move the selection left", fyne.KeyLeft, fyne.KeyModifierShift, moveSelectedLeft},
+	{"Shift+Right", "Move the selection right", fyne.KeyRight, fyne.KeyModifierShift, moveSelectedRight},
+	{"Ctrl+N", "New item (as the New button)", fyne.KeyN, fyne.KeyModifierShortcutDefault, createNew},
+	{"Ctrl+E", "Edit the selection", fyne.KeyE, fyne.KeyModifierShortcutDefault, editSelected},
+	{"Ctrl+D", "Clone the selection", fyne.KeyD, fyne.KeyModifierShortcutDefault, cloneSelected},
+	{"Delete", "Delete the selection", fyne.KeyDelete, 0, deleteSelected},
+	{"Ctrl+Shift+E", "Export the selected boards", fyne.KeyE, fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift, exportSelected},
+	{"Ctrl+F", "Search cards", fyne.KeyF, fyne.KeyModifierShortcutDefault, focusSearch},
+	{"Escape", "Close the dialog, or hide the cursor", fyne.KeyEscape, 0, hideFocus},
+	{"F1, ?", "Show this help", fyne.KeyF1, 0, nil},
+}
+
+// arrowSteps are the cursor moves of the arrow keys
+var arrowSteps = map[fyne.KeyName][2]int{
+	fyne.KeyUp:    {0, -1},
+	fyne.KeyDown:  {0, 1},
+	fyne.KeyLeft:  {-1, 0},
+	fyne.KeyRight: {1, 0},
+}
+
+// registerShortcuts binds keyBindings and the arrow keys to the window.
+// While a dialog is open only Escape works, closing it.
+func registerShortcuts(c fyne.Canvas) {
+	dialogOpen := func() bool { return c.Overlays().Top() != nil }
+	c.SetOnTypedKey(func(ev *fyne.KeyEvent) {
+		if dialogOpen() {
+			if ev.Name == fyne.KeyEscape {
+				c.Overlays().Top().Hide()
+			}
+			return
+		}
+		if step, ok := arrowSteps[ev.Name]; ok {
+			moveFocus(step[0], step[1])
+			return
+		}
+		if ev.Name == fyne.KeyF1 {
+			showShortcutHelp()
+			return
+		}
+		for _, b := range keyBindings {
+			if b.modifier == 0 && b.key == ev.Name && b.action != nil {
+				b.action()
+				return
+			}
+		}
+	})
+	c.SetOnTypedRune(func(r rune) {
+		if r == '?' && !dialogOpen() {
+			showShortcutHelp()
+		}
+	})
+	for _, b := range keyBindings {
+		if b.modifier == 0 {
+			continue
+		}
+		action := b.action
+		c.AddShortcut(&desktop.CustomShortcut{KeyName: b.key, Modifier: b.modifier}, func(fyne.Shortcut) {
+			if !dialogOpen() {
+				action()
+			}
+		})
+	}
+}
+
+func focusSearch() {
+	mainWindow.Canvas().Focus(searchEntry)
+}
+
+func hideFocus() {
+	setFocus(focusItem{})
+}
+
+// showShortcutHelp lists the keyboard shortcuts
+func showShortcutHelp() {
+	rows := container.NewGridWithColumns(2)
+	for _, b := range keyBindings {
+		rows.Add(widget.NewLabelWithStyle(b.keys, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		rows.Add(widget.NewLabel(b.help))
+	}
+	closeBtn := widget.NewButton("Close", func() {})
+	content := container.NewVBox(
+		widget.NewLabel("Keyboard Shortcuts"),
+		rows,
+		widget.NewLabel("Ctrl is Cmd on macOS."),
+		closeBtn,
+	)
+	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
+	closeBtn.OnTapped = dialog.Hide
+	dialog.Show()
+}
+
+// Workspaces
+
+// rememberWorkspace puts a database first in the recent list of the
+// user's configuration
+func rememberWorkspace(path string) {
+	config, err := workspace.LoadConfig()
+	if err != nil {
+		fmt.Println("Error reading configuration:", err)
+		return
+	}
+	config.AddRecent(path)
+	if err := config.Save(); err != nil {
+		fmt.Println("Error saving configuration:", err)
+	}
+}
+
+// refreshWorkspaceMenu builds the File menu with the recent databases
+func refreshWorkspaceMenu() {
+	config, err := workspace.LoadConfig()
+	if err != nil {
+		fmt.Println("Error reading configuration:", err)
+		config = &workspace.Config{}
+	}
+
+	var recentItems []*fyne.MenuItem
+	for _, path := range config.Recent {
+		item := fyne.NewMenuItem(path, func() { openWorkspace(path) })
+		item.Checked = path == dbPath
+		recentItems = append(recentItems, item)
+	}
+	recent := fyne.NewMenuItem("Open Recent", nil)
+	recent.ChildMenu = fyne.NewMenu("", recentItems...)
+	recent.Disabled = len(recentItems) == 0
+	openAtStartup := fyne.NewMenuItem("Open This Workspace at Startup", func() {
+		config.Database = dbPath
+		if err := config.Save(); err != nil {
+			fmt.Println("Error saving configuration:", err)
+		}
+		refreshWorkspaceMenu()
+	})
+	openAtStartup.Checked = config.Database == dbPath
+
+	mainWindow.SetMainMenu(fyne.NewMainMenu(fyne.NewMenu("File",
+		fyne.NewMenuItem("New Workspace...", func() { showWorkspaceDialog(true) }),
+		fyne.NewMenuItem("Open Workspace...", func() { showWorkspaceDialog(false) }),
+		recent,
+		fyne.NewMenuItemSeparator(),
+		openAtStartup,
+	)))
+}
+
+// showWorkspaceDialog asks for the database file of a new workspace, or of
+// one to open
+func showWorkspaceDialog(create bool) {
+	title, action := "Open Workspace", "Open"
+	pathEntry := widget.NewEntry()
+	pathEntry.SetText(dbPath)
+	if create {
+		title, action = "New Workspace", "Create"
+		pathEntry.SetText(filepath.Join(filepath.Dir(dbPath), "workspace.db"))
+	}
+	errorLabel := widget.NewLabel("")
+	errorLabel.Hide()
+
+	cancelBtn := widget.NewButton("Cancel", func() {})
+	okBtn := widget.NewButton(action, func() {})
+	content := container.NewVBox(
+		widget.NewLabel(title),
+		widget.NewLabel("Database file:"),
+		pathEntry,
+		errorLabel,
+		container.NewHBox(cancelBtn, okBtn),
+	)
+	dialog := widget.NewModalPopUp(content, mainWindow.Canvas())
+	cancelBtn.OnTapped = dialog.Hide
+	okBtn.OnTapped = func() {
+		path, err := filepath.Abs(strings.TrimSpace(pathEntry.Text))
+		if err == nil {
+			_, err = os.Stat(path)
+			switch {
+			case create && err == nil:
+				err = fmt.Errorf("%s already exists", path)
+			case create && os.IsNotExist(err):
+				err = os.MkdirAll(filepath.Dir(path), 0o755)
+			}
+		}
+		if err != nil {
+			errorLabel.SetText(err.Error())
+			errorLabel.Show()
+			return
+		}
+		dialog.Hide()
+		openWorkspace(path)
+	}
+	dialog.Resize(fyne.NewSize(500, content.MinSize().Height))
+	dialog.Show()
+}
+
+// openWorkspace switches to another database file, creating it if needed,
+// and shows its first board
+func openWorkspace(path string) {
+	// Check the file before closing the current database
+	conn, err := store.Open(path)
+	if err == nil {
+		var n int
+		err = conn.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&n)
+		conn.Close()
+	}
+	if err != nil {
+		fmt.Printf("Error opening workspace %s: %v\n", path, err)
+		return
+	}
+
+	// Other workspaces are not kept in the -git directory
+	gitDir = ""
+	if stopWatching != nil {
+		stopWatching()
+		stopWatching = nil
+	}
+	db.Close()
+	initDatabase(path)
+	useStorage(store.NewSQLite(db))
+	rememberWorkspace(path)
+	refreshWorkspaceMenu()
+	startSync(path)
+	watchChanges()
+
+	clear(selectedBoards)
+	clear(selectedSwimlanes)
+	clear(selectedLists)
+	clear(selectedCards)
+	searchMatches = make(map[int]bool)
+	focus = focusItem{}
+	updateSelectionInfo()
+
+	showFirstBoard()
+	refreshBoardContainer()
+}
+
+// showFirstBoard shows the first board of the workspace, or a message if
+// there is none
+func showFirstBoard() {
+	currentBoardID = 0
+	if boards := getBoards(); len(boards) > 0 {
+		currentBoardID = boards[0].ID
+		loadBoard(currentBoardID)
+	} else {
+		view = nil
+		mainArea.Content = container.NewVBox(widget.NewLabel("This workspace is empty. Tap New with Boards checked to add a board."))
+		mainArea.Refresh()
+		updateWindowTitle(0)
+	}
+}
+
+// How often the database is checked for changes of other programs
+const changePollInterval = time.Second
+
+// changes reports the changes of other programs to the open database;
+// stopWatching ends the polling of watchChanges
+var (
+	changes      *store.Watcher
+	stopWatching func()
+)
+
+// watchChanges shows the changes that other programs, such as the Tcl/Tk
+// GUI or create_sample_data.tcl, make to the database, until stopWatching
+// is called
+func watchChanges() {
+	if stopWatching != nil {
+		stopWatching()
+		stopWatching = nil
+	}
+	done := make(chan struct{})
+	stopWatching = func() { close(done) }
+	go func() {
+		ticker := time.NewTicker(changePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				fyne.Do(reloadIfChanged)
+			}
+		}
+	}()
+}
+
+// How often the GUI syncs with the -sync server
+const syncInterval = 10 * time.Second
+
+// The -sync server and its token; stopSync ends the sync of the open
+// database
+var (
+	syncServer string
+	syncToken  string
+	stopSync   func()
+)
+
+// startSync syncs the database with the -sync server in the background,
+// on a connection of its own; watchChanges shows the changes it pulls
+// like those of any other program
+func startSync(path string) {
+	if stopSync != nil {
+		stopSync()
+		stopSync = nil
+	}
+	if syncServer == "" {
+		return
+	}
+	conn, err := store.Open(path)
+	if err != nil {
+		fmt.Println("Error opening database for sync:", err)
+		return
+	}
+	client, err := syncer.NewClient(conn, syncServer, syncToken)
+	if err != nil {
+		fmt.Println("Error enabling sync:", err)
+		conn.Close()
+		return
+	}
+	done := make(chan struct{})
+	stopSync = func() { close(done) }
+	go func() {
+		defer conn.Close()
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+		for {
+			syncOnce(client)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// The -git directory of boards
+var gitDir string
+
+// startGit loads the boards of the -git directory into its working copy and
+// returns the storage committing every change to them
+func startGit() store.Storage {
+	repo := gitstore.Open(db, gitDir)
+	if err := repo.Load(); err != nil {
+		fmt.Println("Error loading boards from", gitDir+":", err)
+	}
+	return gitstore.NewStorage(repo)
+}
+
+// commitGit commits the changes made to the working copy of the -git
+// directory other than through storage, such as by other programs
+func commitGit() {
+	s, ok := storage.(*gitstore.Storage)
+	if !ok {
+		return
+	}
+	changes, err := s.Commit()
+	if err != nil {
+		fmt.Println("Error committing boards:", err)
+	} else if len(changes) > 0 {
+		fmt.Printf("Committed %d changed files to %s\n", len(changes), gitDir)
+	}
+}
+
+// syncOnce runs one sync and prints its conflicts
+func syncOnce(client *syncer.Client) {
+	res, err := client.Sync()
+	if err != nil {
+		fmt.Println("Error syncing:", err)
+	}
+	if res != nil {
+		if res.Pulled > 0 || res.Pushed > 0 {
+			fmt.Printf("Synced: %d changes pulled, %d pushed\n", res.Pulled, res.Pushed)
+		}
+		for _, c := range res.Conflicts {
+			fmt.Println("Sync conflict:", c)
+		}
+	}
+}
+
+// reloadIfChanged reloads the board list and the shown board if another
+// program changed the database, keeping the selection, the keyboard focus
+// and the scroll position
+func reloadIfChanged() {
+	// Rebuilding the board would end a drag; check again later
+	if changes == nil || draggedCard != nil || draggedList != nil || draggingSwimlane {
+		return
+	}
+	changed, err := changes.Changed()
+	if err != nil {
+		fmt.Println("Error checking for changes:", err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	pruneSelection()
+	if getBoardByID(currentBoardID) == nil {
+		focus = focusItem{}
+		showFirstBoard()
+	} else {
+		offset := mainArea.Offset
+		loadBoard(currentBoardID)
+		mainArea.ScrollToOffset(offset)
+	}
+	refreshBoardContainer()
+}
+
+// pruneSelection unselects the boards, swimlanes, lists and cards deleted
+// by another program
+func pruneSelection() {
+	for _, kind := range []struct {
+		selected map[int]bool
+		load     func(int) error
+	}{
+		{selectedBoards, func(id int) error { _, err := storage.Board(id); return err }},
+		{selectedSwimlanes, func(id int) error { _, err := storage.LoadSwimlane(id); return err }},
+		{selectedLists, func(id int) error { _, err := storage.LoadList(id); return err }},
+		{selectedCards, func(id int) error { _, err := storage.LoadCard(id); return err }},
+	} {
+		for id := range kind.selected {
+			if errors.Is(kind.load(id), sql.ErrNoRows) {
+				delete(kind.selected, id)
+			}
+		}
+	}
+	updateSelectionInfo()
+}
+
+// useStorage shows the boards of s from now on
+func useStorage(s store.Storage) {
+	storage = s
+	attachments = store.NewAttachments(s, store.DefaultAttachmentCacheSize)
+}
+
+// GUI functions
+// createMainWindow shows the boards of s, which is the storage of db if db
+// is open
+func createMainWindow(a fyne.App, s store.Storage) fyne.Window {
+	useStorage(s)
+	w := a.NewWindow("Go Kanban Board")
+	mainWindow = w
+
 	// Sidebar
 	boardContainer = container.NewVBox()
 	refreshBoardContainer()
@@ -1990,7 +3486,6 @@ func createMainWindow(a fyne.App) fyne.Window {
 	// "Boards" label with checkbox
 	boardsCheck := widget.NewCheck("", func(checked bool) {
 		boardsCheckboxChecked = checked
-		loadBoard(currentBoardID)
 	})
 	boardsHeader := container.NewHBox(boardsCheck, widget.NewLabel("Boards"))
 
@@ -2002,37 +3497,141 @@ func createMainWindow(a fyne.App) fyne.Window {
 	// Main area
 	mainArea = container.NewScroll(container.NewVBox())
 	
-	// Create toolbar (will be populated in loadBoard)
-	toolbar = container.NewVBox()
+	// Toolbar with the action buttons and selection count
+	toolbar = createToolbar()
 
 	// Combine toolbar and main area with Border layout
 	mainContent := container.NewBorder(toolbar, nil, nil, nil, mainArea)
 	content := container.NewBorder(nil, nil, sidebar, nil, mainContent)
 	w.SetContent(content)
+	registerShortcuts(w.Canvas())
+	refreshWorkspaceMenu()
+	updateWindowTitle(0)
+
+	// Auto-select first board if available (after mainArea is initialized)
+	boards := getBoards()
+	if len(boards) > 0 {
+		currentBoardID = boards[0].ID
+		loadBoard(currentBoardID)
+	}
+
+	return w
+}
+
+// boardView keeps the widgets of the displayed board keyed by ID, so that a
+// change to one swimlane, list or card only rebuilds that part of the board.
+type boardView struct {
+	boardID   int
+	root      *fyne.Container
+	swimlanes map[int]*swimlaneView
+	lists     map[int]*listView
+	cards     map[int]*cardView
+}
+
+type swimlaneView struct {
+	obj     fyne.CanvasObject
+	check   *widget.Check
+	ring    *canvas.Rectangle
+	index   int
+	listRow *fyne.Container
+	listIDs []int
+}
+
+type listView struct {
+	obj        fyne.CanvasObject
+	check      *widget.Check
+	ring       *canvas.Rectangle
+	swimlaneID int
+	index      int
+	cardsBox   *fyne.Container
+	cardIDs    []int
+}
+
+type cardView struct {
+	obj    fyne.CanvasObject
+	check  *widget.Check
+	ring   *canvas.Rectangle
+	listID int
+}
+
+var view *boardView
+var selectionInfo *widget.Label
+var searchEntry *widget.Entry
+
+// parseHexColor parses "#RRGGBB" colors as stored in the database.
+func parseHexColor(s string) (color.NRGBA, bool) {
+	if len(s) < 7 || s[0] != '#' {
+		return color.NRGBA{}, false
+	}
+	var r, g, b uint8
+	if n, _ := fmt.Sscanf(s, "#%02x%02x%02x", &r, &g, &b); n != 3 {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{r, g, b, 255}, true
+}
 
-	// Auto-select first board if available (after mainArea is initialized)
-	boards := getBoards()
-	if len(boards) > 0 {
-		currentBoardID = boards[0].ID
-		loadBoard(currentBoardID)
+// coloredLabel shows text in the given hex color, or returns label if the
+// color is not set.
+func coloredLabel(label fyne.CanvasObject, text, hexColor string, size float32, bold bool) fyne.CanvasObject {
+	c, ok := parseHexColor(hexColor)
+	if !ok {
+		return label
+	}
+	t := canvas.NewText(text, c)
+	t.TextSize = size
+	t.TextStyle = fyne.TextStyle{Bold: bold}
+	return t
+}
+
+// backgroundRect fills with the given hex color, or with fallback if unset.
+func backgroundRect(hexColor string, fallback color.NRGBA) *canvas.Rectangle {
+	if c, ok := parseHexColor(hexColor); ok {
+		return canvas.NewRectangle(c)
 	}
+	return canvas.NewRectangle(fallback)
+}
 
-	return w
+// selectionCheck returns a checkbox bound to one of the selection maps.
+// Toggling it only updates the selection count; the board is not rebuilt.
+func selectionCheck(selected map[int]bool, id int) *widget.Check {
+	check := widget.NewCheck("", func(checked bool) {
+		if checked {
+			selected[id] = true
+		} else {
+			delete(selected, id)
+		}
+		updateSelectionInfo()
+		// A focused checkbox would take the keys of the board cursor
+		mainWindow.Canvas().Unfocus()
+	})
+	check.Checked = selected[id]
+	return check
 }
 
-func loadBoard(boardID int) {
-	fmt.Printf("Loading board ID: %d\n", boardID)
-	// Update window title with current board
+func updateSelectionInfo() {
+	if selectionInfo == nil {
+		return
+	}
+	selectionInfo.SetText(fmt.Sprintf("Selected: %d boards, %d swimlanes, %d lists, %d cards",
+		len(selectedBoards), len(selectedSwimlanes), len(selectedLists), len(selectedCards)))
+}
+
+func updateWindowTitle(boardID int) {
+	title := "Go Kanban Board"
 	if boardID > 0 {
-		board := getBoardByID(boardID)
-		if board != nil {
-			mainWindow.SetTitle(fmt.Sprintf("Go Kanban Board - %d: %s", board.ID, board.Name))
+		if board := getBoardByID(boardID); board != nil {
+			title += fmt.Sprintf(" - %d: %s", board.ID, board.Name)
 		}
-	} else {
-		mainWindow.SetTitle("Go Kanban Board")
 	}
-	
-	// Update toolbar with action buttons
+	if dbPath != "" {
+		title += fmt.Sprintf(" (%s)", filepath.Base(dbPath))
+	}
+	mainWindow.SetTitle(title)
+}
+
+// createToolbar builds the action buttons once; loadBoard only updates the
+// selection count.
+func createToolbar() *fyne.Container {
 	upBtn := widget.NewButton("▲", moveSelectedUp)
 	downBtn := widget.NewButton("▼", moveSelectedDown)
 	leftBtn := widget.NewButton("◀", moveSelectedLeft)
@@ -2041,266 +3640,83 @@ func loadBoard(boardID int) {
 	editBtn := widget.NewButton("Edit", editSelected)
 	colorBtn := widget.NewButton("Color", showColorDialog)
 	cloneBtn := widget.NewButton("Clone", cloneSelected)
+	moveToBtn := widget.NewButton("Move To...", func() { showMoveCopyDialog(false) })
+	copyToBtn := widget.NewButton("Copy To...", func() { showMoveCopyDialog(true) })
 	deleteBtn := widget.NewButton("Delete", deleteSelected)
 	clearBtn := widget.NewButton("Clear Selection", clearSelections)
 	exportBtn := widget.NewButton("Export", exportSelected)
-	
-	selectionInfo := widget.NewLabel(fmt.Sprintf("Selected: %d boards, %d swimlanes, %d lists, %d cards", 
-		len(selectedBoards), len(selectedSwimlanes), len(selectedLists), len(selectedCards)))
-	
+	rulesBtn := widget.NewButton("Rules", showRulesDialog)
+	templateBtn := widget.NewButton("Template", toggleTemplateSelected)
+
+	selectionInfo = widget.NewLabel("")
+	updateSelectionInfo()
+
+	searchEntry = widget.NewEntry()
+	searchEntry.SetPlaceHolder("Search cards on all boards")
+	searchEntry.OnSubmitted = searchCards
+	searchBtn := widget.NewButton("Search", func() { searchCards(searchEntry.Text) })
+
+	filters = createFilterBar()
+	filterBtn := widget.NewButton("Filter", func() {
+		if filters.obj.Visible() {
+			filters.obj.Hide()
+		} else {
+			filters.obj.Show()
+		}
+		toolbar.Refresh()
+	})
+
 	// Arrow keys layout (keyboard/joystick style)
 	arrowKeys := container.NewVBox(
 		container.NewHBox(layout.NewSpacer(), upBtn, layout.NewSpacer()),
 		container.NewHBox(leftBtn, downBtn, rightBtn),
 	)
-	
+
 	// Action buttons and info in right section
 	rightSection := container.NewVBox(
-		container.NewHBox(newBtn, editBtn, colorBtn, cloneBtn, deleteBtn, clearBtn, exportBtn),
+		container.NewHBox(newBtn, editBtn, colorBtn, cloneBtn, moveToBtn, copyToBtn, deleteBtn, clearBtn, exportBtn, templateBtn, filterBtn, rulesBtn),
+		container.NewBorder(nil, nil, nil, searchBtn, searchEntry),
 		selectionInfo,
 	)
-	
-	toolbar.Objects = []fyne.CanvasObject{
+
+	return container.NewVBox(
 		container.NewHBox(
 			arrowKeys,
 			layout.NewSpacer(),
 			rightSection,
 		),
+		filters.obj,
 		widget.NewSeparator(),
-	}
-	toolbar.Refresh()
-	
-	// Clear and set new content
-	mainArea.Content = container.NewVBox()
+	)
+}
 
-	swimlanes := getSwimlanes(boardID)
-	swimlaneContainers := make([]fyne.CanvasObject, len(swimlanes))
-	for i, s := range swimlanes {
-		// Swimlane header with checkbox and drag handle only
-		var swimlaneLabel fyne.CanvasObject = widget.NewLabel(s.Name)
-		
-		fmt.Printf("Swimlane %d: Name=%s, TextColor='%s', BgColor='%s'\n", s.ID, s.Name, s.TextColor, s.BackgroundColor)
-		
-		// Apply text color if set (hex format only for now)
-		if s.TextColor != "" && len(s.TextColor) >= 7 && s.TextColor[0] == '#' {
-			var r, g, b uint8
-			n, _ := fmt.Sscanf(s.TextColor, "#%02x%02x%02x", &r, &g, &b)
-			if n == 3 {
-				textColor := color.NRGBA{r, g, b, 255}
-				fmt.Printf("Applying text color RGB(%d, %d, %d) to swimlane %s\n", r, g, b, s.Name)
-				// Create label with colored text
-				textObj := canvas.NewText(s.Name, textColor)
-				textObj.TextSize = 14
-				textObj.TextStyle = fyne.TextStyle{Bold: true}
-				swimlaneLabel = textObj
-			}
-		}
-		
-		swimlaneCheck := widget.NewCheck("", func(checked bool) {
-			if checked {
-				selectedSwimlanes[s.ID] = true
-			} else {
-				delete(selectedSwimlanes, s.ID)
-			}
-			loadBoard(boardID)
-		})
-		swimlaneCheck.Checked = selectedSwimlanes[s.ID]
-		
-		swimlaneDragHandle := NewDraggableIcon(nil, nil, s.ID)
-		
-		swimlaneHeaderContent := container.NewHBox(swimlaneCheck, swimlaneLabel, layout.NewSpacer(), swimlaneDragHandle)
-		
-		// Create bordered header with background color
-		swimlaneHeaderBg := canvas.NewRectangle(color.NRGBA{240, 240, 240, 255})
-		if s.BackgroundColor != "" && len(s.BackgroundColor) >= 7 && s.BackgroundColor[0] == '#' {
-			// Parse hex color
-			var r, g, b uint8
-			n, _ := fmt.Sscanf(s.BackgroundColor, "#%02x%02x%02x", &r, &g, &b)
-			if n == 3 {
-				swimlaneHeaderBg = canvas.NewRectangle(color.NRGBA{r, g, b, 255})
-			}
-		}
-		
-		swimlaneHeader := container.NewMax(swimlaneHeaderBg, container.NewPadded(swimlaneHeaderContent))
-
-		lists := getLists(s.ID)
-		fmt.Printf("Swimlane %d (%s) has %d lists\n", s.ID, s.Name, len(lists))
-		listRow := make([]fyne.CanvasObject, 0, len(lists)*2+1)
-		// leading list drop slot (index 0)
-		listRow = append(listRow, NewDropSlot("list", 0, s.ID, 0, 0))
-		for j, l := range lists {
-			// List header with checkbox and drag handle only
-			var listLabel fyne.CanvasObject = widget.NewLabel(l.Name)
-			
-			// Apply text color if set (hex format only for now)
-			if l.TextColor != "" && len(l.TextColor) >= 7 && l.TextColor[0] == '#' {
-				var r, g, b uint8
-				n, _ := fmt.Sscanf(l.TextColor, "#%02x%02x%02x", &r, &g, &b)
-				if n == 3 {
-					textColor := color.NRGBA{r, g, b, 255}
-					// Create label with colored text
-					textObj := canvas.NewText(l.Name, textColor)
-					textObj.TextSize = 12
-					textObj.TextStyle = fyne.TextStyle{Bold: true}
-					listLabel = textObj
-				}
-			}
-			
-			listCheck := widget.NewCheck("", func(checked bool) {
-				if checked {
-					selectedLists[l.ID] = true
-				} else {
-					delete(selectedLists, l.ID)
-				}
-				loadBoard(boardID)
-			})
-			listCheck.Checked = selectedLists[l.ID]
-
-			// Create draggable list container
-			draggableList := &DraggableList{
-				ListID:     l.ID,
-				SwimlaneID: s.ID,
-				Container:  container.NewVBox(),
-			}
-			// Drag handle for list
-			listHandle := NewDraggableIcon(nil, draggableList, 0)
-			
-			listHeaderContent := container.NewHBox(listCheck, listLabel, layout.NewSpacer(), listHandle)
-			
-			// Create bordered header with background color
-			listHeaderBg := canvas.NewRectangle(color.NRGBA{250, 250, 250, 255})
-			if l.BackgroundColor != "" && len(l.BackgroundColor) >= 7 && l.BackgroundColor[0] == '#' {
-				// Parse hex color
-				var r, g, b uint8
-				n, _ := fmt.Sscanf(l.BackgroundColor, "#%02x%02x%02x", &r, &g, &b)
-				if n == 3 {
-					listHeaderBg = canvas.NewRectangle(color.NRGBA{r, g, b, 255})
-				}
-			}
-			
-			listHeader := container.NewMax(listHeaderBg, container.NewPadded(listHeaderContent))
-			draggableList.Container.Add(listHeader)
-
-			// Cards with drop slots
-			cards := getCards(l.ID)
-			cardObjs := make([]fyne.CanvasObject, 0, len(cards)*2+1)
-			// leading card slot index 0
-			cardObjs = append(cardObjs, NewDropSlot("card", 0, 0, l.ID, 0))
-			for idx, c := range cards {
-				draggableCard := &DraggableCard{
-					CardID: c.ID,
-					ListID: l.ID,
-					Card:   widget.NewCard("", c.Title, widget.NewLabel(c.Description)),
-				}
-				
-				// Checkbox for card selection
-				cardCheck := widget.NewCheck("", func(checked bool) {
-					if checked {
-						selectedCards[c.ID] = true
-					} else {
-						delete(selectedCards, c.ID)
-					}
-					loadBoard(boardID)
-				})
-				cardCheck.Checked = selectedCards[c.ID]
-				
-			// drag handle for card
-			cardHandle := NewDraggableIcon(draggableCard, nil, 0)
-			
-			// Apply text color to title if set
-			var cardTitle fyne.CanvasObject = widget.NewLabelWithStyle(c.Title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
-			if c.TextColor != "" && len(c.TextColor) >= 7 && c.TextColor[0] == '#' {
-				var r, g, b uint8
-				n, _ := fmt.Sscanf(c.TextColor, "#%02x%02x%02x", &r, &g, &b)
-				if n == 3 {
-					textColor := color.NRGBA{r, g, b, 255}
-					textObj := canvas.NewText(c.Title, textColor)
-					textObj.TextSize = 12
-					textObj.TextStyle = fyne.TextStyle{Bold: true}
-					cardTitle = textObj
-				}
-			}
-			
-			// Apply text color to description if set
-			var cardDesc fyne.CanvasObject = widget.NewLabel(c.Description)
-			if c.TextColor != "" && len(c.TextColor) >= 7 && c.TextColor[0] == '#' {
-				var r, g, b uint8
-				n, _ := fmt.Sscanf(c.TextColor, "#%02x%02x%02x", &r, &g, &b)
-				if n == 3 {
-					textColor := color.NRGBA{r, g, b, 255}
-					descObj := canvas.NewText(c.Description, textColor)
-					descObj.TextSize = 10
-					cardDesc = descObj
-				}
-			}
-			
-			cardTitleContainer := container.NewHBox(
-				cardCheck,
-				cardTitle,
-				layout.NewSpacer(),
-				cardHandle,
-			)
-			
-			// Create card content with background color if set
-			cardContent := container.NewVBox(cardTitleContainer, cardDesc)
-			
-			if c.BackgroundColor != "" && len(c.BackgroundColor) >= 7 && c.BackgroundColor[0] == '#' {
-				var r, g, b uint8
-				n, _ := fmt.Sscanf(c.BackgroundColor, "#%02x%02x%02x", &r, &g, &b)
-				if n == 3 {
-					cardBg := canvas.NewRectangle(color.NRGBA{r, g, b, 255})
-					cardContent = container.NewStack(cardBg, container.NewPadded(cardContent))
-				}
-			}
-			
-			// Recreate card with custom header
-			draggableCard.Card = widget.NewCard("", "", cardContent)				// Add the Card widget to make it visible
-				cardObjs = append(cardObjs, draggableCard.Card)
-				cardObjs = append(cardObjs, NewDropSlot("card", 0, 0, l.ID, idx+1))
-			}
-			if len(cards) == 0 {
-				addCardButton := widget.NewButton("Add Card", func() { showNewCardDialog(l.ID) })
-				addCardButton.Importance = widget.HighImportance
-				draggableList.Container.Add(container.NewVBox(
-					widget.NewLabel("This list has no cards yet."),
-					addCardButton,
-				))
-			} else {
-			draggableList.Container.Add(container.NewVBox(cardObjs...))
-		}
+func loadBoard(boardID int) {
+	fmt.Printf("Loading board ID: %d\n", boardID)
+	updateWindowTitle(boardID)
+	updateSelectionInfo()
 
-		// Add the Container to make it visible
-		listRow = append(listRow, draggableList.Container)
-		listRow = append(listRow, NewDropSlot("list", 0, s.ID, 0, j+1))
-	}		// If no lists exist in this swimlane, show "Add List" message/button
-		if len(lists) == 0 {
-			addListButton := widget.NewButton("Add List", func() { showNewListDialog(s.ID) })
-			addListButton.Importance = widget.HighImportance
-			listRow = append(listRow, container.NewVBox(
-				widget.NewLabel("This swimlane has no lists yet."),
-				addListButton,
-			))
-		}
-
-		// Create droppable swimlane container with swimlane-level drop slots at edges
-		droppableSwimlane := &DroppableSwimlane{
-			SwimlaneID: s.ID,
-			Container:  container.NewVBox(),
-		}
-		droppableSwimlane.Container.Add(swimlaneHeader)
-		// add swimlane drop slots before and after the lists row for reordering
-		topSwimlaneSlot := NewDropSlot("swimlane", s.BoardID, 0, 0, i) // index i before current
-		bottomSwimlaneSlot := NewDropSlot("swimlane", s.BoardID, 0, 0, i+1)
-		droppableSwimlane.Container.Add(topSwimlaneSlot)
-		droppableSwimlane.Container.Add(container.NewHBox(listRow...))
-		droppableSwimlane.Container.Add(bottomSwimlaneSlot)
-		
-		// Add the Container itself since we need CanvasObject
-		swimlaneContainers[i] = droppableSwimlane.Container
+	view = &boardView{
+		boardID:   boardID,
+		swimlanes: make(map[int]*swimlaneView),
+		lists:     make(map[int]*listView),
+		cards:     make(map[int]*cardView),
+	}
+	// Archive the cards whose time set by an archive rule has come
+	if _, err := storage.ArchiveDue(time.Now()); err != nil {
+		fmt.Println("Error archiving cards:", err)
+	}
+	tree, err := storage.LoadBoard(boardID)
+	if err != nil {
+		fmt.Println("Error loading board:", err)
+	}
+	filters.update(boardID, tree)
+	swimlaneContainers := make([]fyne.CanvasObject, 0, len(tree))
+	for i, s := range tree {
+		swimlaneContainers = append(swimlaneContainers, view.newSwimlane(s, i))
 	}
 
 	// If no swimlanes exist, show "Add Swimlane" button
-	if len(swimlanes) == 0 {
+	if len(tree) == 0 {
 		addSwimlaneBtn := widget.NewButton("➕ Add Swimlane", func() {
 			showNewSwimlaneDialog(boardID)
 		})
@@ -2312,22 +3728,653 @@ func loadBoard(boardID int) {
 	}
 
 	// Update main area content (swimlanes only, toolbar is separate)
-	fmt.Printf("Setting main area with %d swimlane containers\n", len(swimlaneContainers))
-	mainArea.Content = container.NewVBox(swimlaneContainers...)
+	view.root = container.NewVBox(swimlaneContainers...)
+	mainArea.Content = view.root
 	mainArea.Refresh()
-	
-	// Also refresh the window to ensure the layout updates
-	if mainWindow != nil {
-		mainWindow.Canvas().Refresh(mainArea)
+	fmt.Printf("Board load complete: %d swimlanes, %d lists, %d cards\n", len(view.swimlanes), len(view.lists), len(view.cards))
+}
+
+// newSwimlane builds the widgets of a swimlane and its lists and registers
+// them in the view. index is the position of the swimlane on the board.
+func (v *boardView) newSwimlane(s store.Swimlane, index int) fyne.CanvasObject {
+	sv := &swimlaneView{index: index, check: selectionCheck(selectedSwimlanes, s.ID)}
+
+	// Swimlane header with checkbox and drag handle only
+	swimlaneLabel := coloredLabel(widget.NewLabel(s.Name), s.Name, s.TextColor, 14, true)
+	swimlaneDragHandle := NewDraggableIcon(nil, nil, s.ID)
+	swimlaneHeaderContent := container.NewHBox(sv.check, swimlaneLabel)
+	if s.IsTemplate {
+		swimlaneHeaderContent.Add(templateBadge())
+	}
+	swimlaneHeaderContent.Add(layout.NewSpacer())
+	swimlaneHeaderContent.Add(swimlaneDragHandle)
+	swimlaneHeaderBg := backgroundRect(s.BackgroundColor, color.NRGBA{240, 240, 240, 255})
+	swimlaneHeader := container.NewMax(swimlaneHeaderBg, container.NewPadded(swimlaneHeaderContent))
+
+	listRow := make([]fyne.CanvasObject, 0, len(s.Lists)*2+1)
+	// leading list drop slot (index 0)
+	listRow = append(listRow, NewDropSlot("list", 0, s.ID, 0, 0))
+	for j, l := range s.Lists {
+		listRow = append(listRow, v.newList(l, j))
+		listRow = append(listRow, NewDropSlot("list", 0, s.ID, 0, j+1))
+		sv.listIDs = append(sv.listIDs, l.ID)
+	}
+	// If no lists exist in this swimlane, show "Add List" message/button
+	if len(s.Lists) == 0 {
+		swimlaneID := s.ID
+		addListButton := widget.NewButton("Add List", func() { showNewListDialog(swimlaneID) })
+		addListButton.Importance = widget.HighImportance
+		listRow = append(listRow, container.NewVBox(
+			widget.NewLabel("This swimlane has no lists yet."),
+			addListButton,
+		))
+	}
+
+	// Create droppable swimlane container with swimlane-level drop slots at edges
+	droppableSwimlane := &DroppableSwimlane{
+		SwimlaneID: s.ID,
+		Container:  container.NewVBox(),
+	}
+	droppableSwimlane.Container.Add(swimlaneHeader)
+	// add swimlane drop slots before and after the lists row for reordering
+	droppableSwimlane.Container.Add(NewDropSlot("swimlane", s.BoardID, 0, 0, index))
+	sv.listRow = container.NewHBox(listRow...)
+	droppableSwimlane.Container.Add(sv.listRow)
+	droppableSwimlane.Container.Add(NewDropSlot("swimlane", s.BoardID, 0, 0, index+1))
+
+	sv.ring = focusRing("swimlane", s.ID)
+	sv.obj = container.NewStack(droppableSwimlane.Container, sv.ring)
+	v.swimlanes[s.ID] = sv
+	return sv.obj
+}
+
+// newList builds the widgets of a list and its cards. index is the position
+// of the list in its swimlane.
+func (v *boardView) newList(l store.List, index int) fyne.CanvasObject {
+	lv := &listView{swimlaneID: l.SwimlaneID, index: index, check: selectionCheck(selectedLists, l.ID)}
+
+	// Create draggable list container
+	draggableList := &DraggableList{
+		ListID:     l.ID,
+		SwimlaneID: l.SwimlaneID,
+		Container:  container.NewVBox(),
+	}
+	listHandle := NewDraggableIcon(nil, draggableList, 0)
+	listLabel := coloredLabel(widget.NewLabel(l.Name), l.Name, l.TextColor, 12, true)
+	wip := l.WIP()
+	wipText := canvas.NewText(wip.String(), theme.Color(theme.ColorNameForeground))
+	if wip.Hard {
+		wipText.Text += " (hard)"
+	}
+	listHeaderContent := container.NewHBox(lv.check, listLabel)
+	if l.IsTemplate {
+		listHeaderContent.Add(templateBadge())
+	}
+	listHeaderContent.Add(layout.NewSpacer())
+	listHeaderContent.Add(container.NewCenter(wipText))
+	listHeaderContent.Add(listHandle)
+	listHeaderBg := backgroundRect(l.BackgroundColor, color.NRGBA{250, 250, 250, 255})
+	if wip.Exceeded() {
+		listHeaderBg.FillColor = wipExceededColor
+		wipText.Color = color.White
+		wipText.TextStyle.Bold = true
+	}
+	draggableList.Container.Add(container.NewMax(listHeaderBg, container.NewPadded(listHeaderContent)))
+
+	if len(l.Cards) == 0 {
+		listID := l.ID
+		addCardButton := widget.NewButton("Add Card", func() { showNewCardDialog(listID) })
+		addCardButton.Importance = widget.HighImportance
+		draggableList.Container.Add(container.NewVBox(
+			widget.NewLabel("This list has no cards yet."),
+			addCardButton,
+		))
+	} else {
+		// Cards with drop slots; leading card slot index 0
+		cardObjs := make([]fyne.CanvasObject, 0, len(l.Cards)*2+1)
+		cardObjs = append(cardObjs, NewDropSlot("card", 0, 0, l.ID, 0))
+		for idx, c := range l.Cards {
+			if hiddenByFilter(c) {
+				continue
+			}
+			cardObjs = append(cardObjs, v.newCard(c))
+			cardObjs = append(cardObjs, NewDropSlot("card", 0, 0, l.ID, idx+1))
+			lv.cardIDs = append(lv.cardIDs, c.ID)
+		}
+		lv.cardsBox = container.NewVBox(cardObjs...)
+		draggableList.Container.Add(lv.cardsBox)
+	}
+
+	lv.ring = focusRing("list", l.ID)
+	lv.obj = container.NewStack(draggableList.Container, lv.ring)
+	v.lists[l.ID] = lv
+	return lv.obj
+}
+
+// newCard builds the widget of one card.
+func (v *boardView) newCard(c store.Card) fyne.CanvasObject {
+	cv := &cardView{listID: c.ListID, check: selectionCheck(selectedCards, c.ID)}
+
+	draggableCard := &DraggableCard{
+		CardID: c.ID,
+		ListID: c.ListID,
+	}
+	cardHandle := NewDraggableIcon(draggableCard, nil, 0)
+	cardTitle := coloredLabel(widget.NewLabelWithStyle(c.Title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		c.Title, c.TextColor, 12, true)
+	cardDesc := coloredLabel(widget.NewLabel(c.Description), c.Description, c.TextColor, 10, false)
+	cardTitleContainer := container.NewHBox(cv.check, cardTitle)
+	if c.IsTemplate {
+		cardTitleContainer.Add(templateBadge())
+	}
+	cardTitleContainer.Add(layout.NewSpacer())
+	if c.AttachmentSize > 0 {
+		// The attachment itself is loaded when the button is tapped
+		cardID := c.ID
+		attachmentBtn := widget.NewButton("📎", func() { showAttachmentDialog(cardID) })
+		attachmentBtn.Importance = widget.LowImportance
+		cardTitleContainer.Add(attachmentBtn)
+	}
+	cardTitleContainer.Add(cardHandle)
+
+	// Create card content with background color if set
+	cardContent := container.NewVBox(cardTitleContainer, cardDesc)
+	if bg, ok := parseHexColor(c.BackgroundColor); ok {
+		cardContent = container.NewStack(canvas.NewRectangle(bg), container.NewPadded(cardContent))
+	}
+	draggableCard.Card = widget.NewCard("", "", cardContent)
+
+	cv.obj = draggableCard.Card
+	if searchMatches[c.ID] {
+		highlight := canvas.NewRectangle(color.Transparent)
+		highlight.StrokeColor = searchHighlight
+		highlight.StrokeWidth = 3
+		cv.obj = container.NewStack(draggableCard.Card, highlight)
+	}
+	if !activeFilter.Matches(c) {
+		// Dimmed by the filter bar; the overlay lets input through
+		cv.obj = container.NewStack(cv.obj, canvas.NewRectangle(filterDim))
+	}
+	cv.ring = focusRing("card", c.ID)
+	cv.obj = container.NewStack(cv.obj, cv.ring)
+	v.cards[c.ID] = cv
+	return cv.obj
+}
+
+// forgetSwimlane and forgetList drop the views of an item and its children
+// before it is rebuilt.
+func (v *boardView) forgetSwimlane(id int) {
+	if sv, ok := v.swimlanes[id]; ok {
+		for _, listID := range sv.listIDs {
+			v.forgetList(listID)
+		}
+		delete(v.swimlanes, id)
+	}
+}
+
+func (v *boardView) forgetList(id int) {
+	if lv, ok := v.lists[id]; ok {
+		for _, cardID := range lv.cardIDs {
+			delete(v.cards, cardID)
+		}
+		delete(v.lists, id)
+	}
+}
+
+// replaceObject swaps old for obj in c and refreshes only c.
+func replaceObject(c *fyne.Container, old, obj fyne.CanvasObject) bool {
+	for i, o := range c.Objects {
+		if o == old {
+			c.Objects[i] = obj
+			c.Refresh()
+			return true
+		}
+	}
+	return false
+}
+
+// refreshSwimlane reloads one swimlane with its lists and cards and replaces
+// its widgets. Swimlanes that are not shown yet, such as new ones, reload
+// the board.
+func refreshSwimlane(swimlaneID int) {
+	sv, ok := view.lookupSwimlane(swimlaneID)
+	s, err := storage.LoadSwimlane(swimlaneID)
+	if !ok || err != nil || s.BoardID != view.boardID {
+		loadBoard(currentBoardID)
+		return
+	}
+	view.forgetSwimlane(swimlaneID)
+	if !replaceObject(view.root, sv.obj, view.newSwimlane(*s, sv.index)) {
+		loadBoard(currentBoardID)
+	}
+}
+
+// refreshList reloads one list with its cards and replaces its widgets. New
+// lists and lists that moved to another swimlane refresh their swimlane.
+func refreshList(listID int) {
+	lv, ok := view.lookupList(listID)
+	l, err := storage.LoadList(listID)
+	if err != nil {
+		// Deleted: refresh the swimlane that showed it
+		if ok {
+			refreshSwimlane(lv.swimlaneID)
+		}
+		return
+	}
+	if !ok || lv.swimlaneID != l.SwimlaneID {
+		if ok {
+			refreshSwimlane(lv.swimlaneID)
+		}
+		refreshSwimlane(l.SwimlaneID)
+		return
+	}
+	sv := view.swimlanes[lv.swimlaneID]
+	view.forgetList(listID)
+	if !replaceObject(sv.listRow, lv.obj, view.newList(*l, lv.index)) {
+		refreshSwimlane(lv.swimlaneID)
+	}
+}
+
+// refreshCard reloads one card and replaces its widget. Cards that are new
+// or changed list refresh their list.
+func refreshCard(cardID int) {
+	cv, ok := view.lookupCard(cardID)
+	attachments.Invalidate(cardID)
+	c, err := storage.LoadCard(cardID)
+	if err != nil {
+		if ok {
+			refreshList(cv.listID)
+		}
+		return
+	}
+	if !ok || cv.listID != c.ListID || hiddenByFilter(*c) {
+		if ok && cv.listID != c.ListID {
+			refreshList(cv.listID)
+		}
+		refreshList(c.ListID)
+		return
+	}
+	lv := view.lists[c.ListID]
+	if lv == nil || lv.cardsBox == nil || !replaceObject(lv.cardsBox, cv.obj, view.newCard(*c)) {
+		refreshList(c.ListID)
+	}
+}
+
+func (v *boardView) lookupSwimlane(id int) (*swimlaneView, bool) {
+	if v == nil {
+		return nil, false
+	}
+	sv, ok := v.swimlanes[id]
+	return sv, ok
+}
+
+func (v *boardView) lookupList(id int) (*listView, bool) {
+	if v == nil {
+		return nil, false
+	}
+	lv, ok := v.lists[id]
+	return lv, ok
+}
+
+func (v *boardView) lookupCard(id int) (*cardView, bool) {
+	if v == nil {
+		return nil, false
+	}
+	cv, ok := v.cards[id]
+	return cv, ok
+}
+
+// syncChecks updates the checkboxes after the selection maps changed.
+func (v *boardView) syncChecks() {
+	if v == nil {
+		return
+	}
+	for id, sv := range v.swimlanes {
+		setChecked(sv.check, selectedSwimlanes[id])
+	}
+	for id, lv := range v.lists {
+		setChecked(lv.check, selectedLists[id])
+	}
+	for id, cv := range v.cards {
+		setChecked(cv.check, selectedCards[id])
+	}
+}
+
+// setChecked changes a checkbox without running its OnChanged callback.
+func setChecked(check *widget.Check, checked bool) {
+	if check.Checked != checked {
+		check.Checked = checked
+		check.Refresh()
 	}
-	fmt.Println("Board load complete")
 }
 
 func main() {
-	initDatabase()
-	defer db.Close()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backup":
+			backupCommand(os.Args[2:])
+			return
+		case "restore":
+			restoreCommand(os.Args[2:])
+			return
+		case "doctor":
+			doctorCommand(os.Args[2:])
+			return
+		case "sync-server":
+			syncServerCommand(os.Args[2:])
+			return
+		case "sync":
+			syncCommand(os.Args[2:])
+			return
+		case "git-init":
+			gitInitCommand(os.Args[2:])
+			return
+		}
+	}
+
+	dbFlag := workspace.AddFlag(flag.CommandLine)
+	flag.StringVar(&syncServer, "sync", "", "URL of a kanban_go sync-server to sync the boards with")
+	flag.StringVar(&syncToken, "sync-token", os.Getenv(syncTokenEnvVar), "token of the sync server (default $"+syncTokenEnvVar+")")
+	flag.StringVar(&gitDir, "git", "", "directory in a git repository to keep the boards in, committing every change")
+	flag.Parse()
+	path, err := workspace.Resolve(*dbFlag)
+	if err != nil {
+		panic(err)
+	}
+	if gitDir != "" {
+		// The boards are worked on in a working copy in the directory
+		if path, err = gitstore.Init(gitDir); err != nil {
+			fmt.Printf("Failed to open %s: %v\n", gitDir, err)
+			os.Exit(1)
+		}
+	}
+	initDatabase(path)
+	defer func() { db.Close() }()
+	if gitDir == "" {
+		rememberWorkspace(path)
+	}
+	startSync(path)
+	var s store.Storage = store.NewSQLite(db)
+	if gitDir != "" {
+		s = startGit()
+	}
 
 	a := app.New()
-	w := createMainWindow(a)
+	w := createMainWindow(a, s)
+	// The first commit adds the boards that the files lack
+	commitGit()
+	watchChanges()
 	w.ShowAndRun()
-}
\ No newline at end of file
+}
+
+// openExistingDatabase opens the database of the -db flag for the command
+// line, which does not create databases
+func openExistingDatabase(dbFlag string) (*sql.DB, string) {
+	path, err := workspace.Resolve(dbFlag)
+	if err == nil {
+		_, err = os.Stat(path)
+	}
+	if err != nil {
+		fmt.Printf("Failed to open database: %v\n", err)
+		os.Exit(1)
+	}
+	db, err := store.Open(path)
+	if err != nil {
+		fmt.Printf("Failed to open database: %v\n", err)
+		os.Exit(1)
+	}
+	return db, path
+}
+
+// backupCommand takes a snapshot of the database into its backups
+// directory and rotates the snapshots, or copies the database to a file.
+// Both are safe while the GUI is running.
+func backupCommand(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dbFlag := workspace.AddFlag(fs)
+	keep := fs.Int("keep", backup.DefaultRotation.Last, "number of latest snapshots to keep")
+	daily := fs.Int("daily", backup.DefaultRotation.Daily, "number of days to keep a daily snapshot of")
+	weekly := fs.Int("weekly", backup.DefaultRotation.Weekly, "number of weeks to keep a weekly snapshot of")
+	fs.Usage = func() {
+		fmt.Println("Usage: kanban_go backup [-db file] [-keep n] [-daily n] [-weekly n] [copy.db]")
+		fmt.Println("Without copy.db, the snapshot goes to the backups directory next to the database.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	db, path := openExistingDatabase(*dbFlag)
+	defer db.Close()
+	if fs.NArg() == 1 {
+		if err := backup.Copy(db, fs.Arg(0)); err != nil {
+			fmt.Printf("Backup failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Backed up %s to %s\n", path, fs.Arg(0))
+		return
+	}
+	snapshot, err := backup.Take(db, path, "", time.Now(), backup.Rotation{Last: *keep, Daily: *daily, Weekly: *weekly})
+	if err != nil {
+		fmt.Printf("Backup failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Backed up %s to %s\n", path, snapshot.Path)
+}
+
+// restoreCommand replaces the database with a backup after checking it,
+// keeping a snapshot of the replaced content
+func restoreCommand(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dbFlag := workspace.AddFlag(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage: kanban_go restore [-db file] <backup.db>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	file := fs.Arg(0)
+
+	version, err := backup.Check(file)
+	if err != nil {
+		fmt.Printf("Cannot restore: %v\n", err)
+		os.Exit(1)
+	}
+	db, path := openExistingDatabase(*dbFlag)
+	defer db.Close()
+	snapshot, err := backup.Take(db, path, backup.ReasonRestore, time.Now(), backup.DefaultRotation)
+	if err != nil {
+		fmt.Printf("Cannot back up the current database, not restoring: %v\n", err)
+		os.Exit(1)
+	}
+	if err := backup.Restore(db, file); err != nil {
+		fmt.Printf("Restore failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Restored %s from %s; the replaced content is in %s\n", path, file, snapshot.Path)
+	if version < store.SchemaVersion {
+		fmt.Printf("The backup has schema version %d and is migrated to %d when kanban_go opens it.\n", version, store.SchemaVersion)
+	}
+}
+
+// doctorCommand checks the database and with -fix repairs it after taking
+// a snapshot. It exits with status 1 if problems are left.
+func doctorCommand(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	dbFlag := workspace.AddFlag(fs)
+	fix := fs.Bool("fix", false, "repair the problems found")
+	deleteOrphans := fs.Bool("delete-orphans", false, "with -fix, delete orphaned rows instead of moving them to a \""+doctor.RecoveredBoard+"\" board")
+	fs.Usage = func() {
+		fmt.Println("Usage: kanban_go doctor [-db file] [-fix [-delete-orphans]]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	db, path := openExistingDatabase(*dbFlag)
+	defer db.Close()
+	report, err := doctor.Run(db, doctor.Options{})
+	if err == nil && *fix && len(report.Problems) > 0 {
+		snapshot, err := backup.Take(db, path, backup.ReasonRepair, time.Now(), backup.DefaultRotation)
+		if err != nil {
+			fmt.Printf("Cannot back up the database, not repairing: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved a backup in %s\n", snapshot.Path)
+		report, err = doctor.Run(db, doctor.Options{Fix: true, DeleteOrphans: *deleteOrphans})
+	}
+	if report != nil {
+		for _, p := range report.Problems {
+			fmt.Println(p)
+			if p.Fixed != "" {
+				fmt.Printf("    fixed: %s\n", p.Fixed)
+			}
+		}
+		fmt.Printf("%s: %d problems, %d fixed\n", path, len(report.Problems), len(report.Problems)-report.Unfixed())
+	}
+	if err != nil {
+		fmt.Printf("Check failed: %v\n", err)
+		os.Exit(1)
+	}
+	if report.Unfixed() > 0 {
+		if !*fix {
+			fmt.Println("Run kanban_go doctor -fix to repair them.")
+		}
+		os.Exit(1)
+	}
+}
+
+// syncTokenEnvVar names the environment variable holding the token of the
+// sync server
+const syncTokenEnvVar = "KANBAN_SYNC_TOKEN"
+
+// syncServerCommand runs the server that the desktops sync their boards
+// through, keeping the operation log in a database of its own
+func syncServerCommand(args []string) {
+	fs := flag.NewFlagSet("sync-server", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8547", "address to listen on; other machines can only connect with -token set")
+	data := fs.String("data", "", "database of the operation log (default sync-server.db in the data directory)")
+	token := fs.String("token", os.Getenv(syncTokenEnvVar), "token the clients must send (default $"+syncTokenEnvVar+")")
+	fs.Usage = func() {
+		fmt.Println("Usage: kanban_go sync-server [-addr host:port] [-data file] [-token token]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *token == "" && !syncer.Loopback(*addr) {
+		fmt.Printf("Refusing to listen on %s without -token: anyone who can reach the server could change the boards.\n", *addr)
+		os.Exit(1)
+	}
+
+	path := *data
+	if path == "" {
+		dir, err := workspace.DataDir()
+		if err == nil {
+			err = os.MkdirAll(dir, 0o755)
+		}
+		if err != nil {
+			fmt.Printf("Failed to open the operation log: %v\n", err)
+			os.Exit(1)
+		}
+		path = filepath.Join(dir, "sync-server.db")
+	}
+	logDB, err := store.Open(path)
+	if err != nil {
+		fmt.Printf("Failed to open the operation log: %v\n", err)
+		os.Exit(1)
+	}
+	defer logDB.Close()
+	server, err := syncer.NewServer(logDB, *token)
+	if err != nil {
+		fmt.Printf("Failed to open the operation log: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Sync server listening on %s, operation log in %s\n", *addr, path)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		fmt.Printf("Sync server failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// syncCommand syncs the database with a sync server once and prints the
+// conflicts. It exits with status 1 if the sync failed.
+func syncCommand(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	dbFlag := workspace.AddFlag(fs)
+	server := fs.String("server", "", "URL of the sync server, such as http://host:8547")
+	token := fs.String("token", os.Getenv(syncTokenEnvVar), "token of the sync server (default $"+syncTokenEnvVar+")")
+	fs.Usage = func() {
+		fmt.Println("Usage: kanban_go sync [-db file] -server url [-token token]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 || *server == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	db, path := openExistingDatabase(*dbFlag)
+	defer db.Close()
+	client, err := syncer.NewClient(db, *server, *token)
+	if err != nil {
+		fmt.Printf("Sync failed: %v\n", err)
+		os.Exit(1)
+	}
+	res, err := client.Sync()
+	for _, c := range res.Conflicts {
+		fmt.Println("Conflict:", c)
+	}
+	if err != nil {
+		fmt.Printf("Sync failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Synced %s: %d changes pulled, %d pushed, %d conflicts\n", path, res.Pulled, res.Pushed, len(res.Conflicts))
+}
+
+// gitInitCommand writes the boards of a database to a directory in a git
+// repository and commits them, for kanban_go -git to work on. Rules, saved
+// filters and backups stay in the database.
+func gitInitCommand(args []string) {
+	fs := flag.NewFlagSet("git-init", flag.ExitOnError)
+	dbFlag := workspace.AddFlag(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage: kanban_go git-init [-db file] <directory>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	dir := fs.Arg(0)
+
+	// Committing would replace the boards already in the directory
+	if boards, _ := filepath.Glob(filepath.Join(dir, "board-*")); len(boards) > 0 {
+		fmt.Printf("git-init failed: %s already has boards; open them with kanban_go -git %s\n", dir, dir)
+		os.Exit(1)
+	}
+	db, path := openExistingDatabase(*dbFlag)
+	defer db.Close()
+	if _, err := gitstore.Init(dir); err != nil {
+		fmt.Printf("git-init failed: %v\n", err)
+		os.Exit(1)
+	}
+	changes, err := gitstore.Open(db, dir).Commit()
+	if err != nil {
+		fmt.Printf("git-init failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Committed the boards of %s to %s (%d files); open them with kanban_go -git %s\n", path, dir, len(changes), dir)
+}
@@ -53,9 +53,32 @@ proc hideTooltip {widget} {
     catch {destroy .tooltip}
 }
 
+# Database file: -db or --db on the command line, else $KANBAN_DB, else
+# wekan.db in the current directory
+proc databasePath {} {
+    set i [lsearch -regexp $::argv {^--?db$}]
+    if {$i >= 0 && $i + 1 < [llength $::argv]} {
+        return [file normalize [lindex $::argv $i+1]]
+    }
+    if {[info exists ::env(KANBAN_DB)] && $::env(KANBAN_DB) ne ""} {
+        return [file normalize $::env(KANBAN_DB)]
+    }
+    return [file normalize wekan.db]
+}
+
 # Initialize database
 proc initDatabase {} {
-    sqlite3 db wekan.db
+    set ::dbPath [databasePath]
+    sqlite3 db $::dbPath
+
+    # The connection settings of the Go programs: enforce foreign keys so
+    # that deletes cascade, WAL so that readers don't block the writer, and
+    # wait for locks held by other programs
+    db timeout 5000
+    db eval {
+        PRAGMA foreign_keys = ON;
+        PRAGMA journal_mode = WAL;
+    }
     
     # Create tables if they don't exist
     db eval {
@@ -1251,42 +1274,42 @@ proc showCardDetails {cardId} {
     }
 }
 
-# Remove Tcl xlsx export logic
-# New export handler will call Go binary
+# Export a board through the kanbango Go package, or the Go binary if the
+# package has not been built
 proc exportBoardToExcel {boardId} {
     # Get the directory of the current script (kanban.tcl)
     set scriptDir [file dirname [info script]]
-    set soPath [file join $scriptDir "xlsx_exporter_embed.so"]
     set binPath [file join $scriptDir "xlsx_exporter"]
+    set dbPath $::dbPath
     set exportFile [file join $scriptDir "board_${boardId}_export.xlsx"]
     
-    # Try embedded .so first
-    set soLoaded 0
-    if {[file exists $soPath]} {
-        if {![catch {load $soPath} loadErr]} {
-            set soLoaded 1
+    # Try the kanbango package first (kanbango/ next to this script, or
+    # lib/kanbango inside a .kit)
+    foreach dir [list [file join $scriptDir kanbango] [file join $scriptDir .. kanbango]] {
+        if {[file exists [file join $dir pkgIndex.tcl]] && [lsearch -exact $::auto_path $dir] < 0} {
+            lappend ::auto_path $dir
         }
     }
-    
-    if {$soLoaded} {
-        set result [catch {ExportBoardToXLSX $boardId $exportFile} code]
-        if {$result == 0} {
-            tk_messageBox -message "Board exported to $exportFile (XLSX) via embedded Go .so." -type ok
-            return
+    if {![catch {package require kanbango}]} {
+        if {[catch {kanbango::export -db $dbPath $boardId $exportFile} err]} {
+            tk_messageBox -title "Export Error" -message "Failed to export board to XLSX.\n\nError: $err" -type ok
+        } else {
+            tk_messageBox -message "Board exported to $exportFile (XLSX) via kanbango package." -type ok
         }
+        return
     }
     
     # Fallback to Go binary
     if {[file exists $binPath]} {
         set result ""
-        set code [catch {exec $binPath $boardId $exportFile} result]
+        set code [catch {exec $binPath -db $dbPath $boardId $exportFile} result]
         if {$code == 0} {
             tk_messageBox -message "Board exported to $exportFile (XLSX) via Go binary." -type ok
         } else {
             tk_messageBox -title "Export Error" -message "Failed to export board to XLSX via Go binary.\n\nError: $result" -type ok
         }
     } else {
-        tk_messageBox -title "Export Error" -message "No XLSX export method found. Please build xlsx_exporter_embed.so or xlsx_exporter binary." -type ok
+        tk_messageBox -title "Export Error" -message "No XLSX export method found. Please build the kanbango package or the xlsx_exporter binary." -type ok
     }
 }
 
@@ -0,0 +1,140 @@
+/* Code generated by gen_header.go from xlsx_exporter_embed.go. DO NOT EDIT. */
+
+#ifndef KANBAN_API_H
+#define KANBAN_API_H
+
+/*
+ * Go shared library for embedding the Kanban board logic into TclKit .kit
+ * files and other C programs.
+ *
+ * Build with:
+ *
+ * go build -buildmode=c-shared -o xlsx_exporter_embed.so xlsx_exporter_embed.go
+ *
+ * The documented C header kanban_api.h is generated from this file with
+ * go generate xlsx_exporter_embed.go.
+ *
+ * Every function takes the path of the SQLite database to use. Functions
+ * returning int return 0 (or a new ID) on success and -1 on failure; the
+ * error message is then available from KanbanLastError. Strings returned by
+ * the library are allocated with malloc and must be released with KanbanFree.
+ */
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/*
+ * KanbanLastError returns the message of the last failed call, or NULL if
+ * the last call succeeded. Release the string with KanbanFree.
+ */
+extern char* KanbanLastError(void);
+
+/*
+ * KanbanFree releases a string returned by the library.
+ */
+extern void KanbanFree(char* s);
+
+/*
+ * KanbanExportBoard writes a board to outputFile. The format is chosen by the
+ * extension: .xlsx, .ods, .pdf or .json. layout is "flat", "hierarchical" or
+ * "board" for spreadsheets; NULL or "" selects flat. Returns 0 or -1.
+ */
+extern int KanbanExportBoard(char* dbPath, int boardID, char* outputFile, char* layout);
+
+/*
+ * KanbanImportBoard reads a board written by KanbanExportBoard in JSON
+ * format and stores it as a new board. Returns the new board ID or -1.
+ */
+extern int KanbanImportBoard(char* dbPath, char* inputFile);
+
+/*
+ * KanbanListBoards returns a JSON array of {"id", "name", "description"}
+ * objects ordered by name, or NULL on error. Release with KanbanFree.
+ */
+extern char* KanbanListBoards(char* dbPath);
+
+/*
+ * KanbanGetBoard returns a board with its swimlanes, lists and cards as JSON,
+ * in the same format as a .json export, or NULL on error. Release with
+ * KanbanFree.
+ */
+extern char* KanbanGetBoard(char* dbPath, int boardID);
+
+/*
+ * KanbanCreateBoard creates a board and returns its ID, or -1.
+ */
+extern int KanbanCreateBoard(char* dbPath, char* name, char* description);
+
+/*
+ * KanbanUpdateBoard renames a board and replaces its description. Returns 0
+ * or -1.
+ */
+extern int KanbanUpdateBoard(char* dbPath, int boardID, char* name, char* description);
+
+/*
+ * KanbanDeleteBoard deletes a board with all of its swimlanes, lists and
+ * cards. Returns 0 or -1.
+ */
+extern int KanbanDeleteBoard(char* dbPath, int boardID);
+
+/*
+ * KanbanCreateSwimlane appends a swimlane to a board and returns its ID, or -1.
+ */
+extern int KanbanCreateSwimlane(char* dbPath, int boardID, char* name);
+
+/*
+ * KanbanUpdateSwimlane renames a swimlane. Returns 0 or -1.
+ */
+extern int KanbanUpdateSwimlane(char* dbPath, int swimlaneID, char* name);
+
+/*
+ * KanbanDeleteSwimlane deletes a swimlane with its lists and cards. Returns 0
+ * or -1.
+ */
+extern int KanbanDeleteSwimlane(char* dbPath, int swimlaneID);
+
+/*
+ * KanbanCreateList appends a list to a swimlane and returns its ID, or -1.
+ */
+extern int KanbanCreateList(char* dbPath, int swimlaneID, char* name);
+
+/*
+ * KanbanUpdateList renames a list. Returns 0 or -1.
+ */
+extern int KanbanUpdateList(char* dbPath, int listID, char* name);
+
+/*
+ * KanbanDeleteList deletes a list with its cards. Returns 0 or -1.
+ */
+extern int KanbanDeleteList(char* dbPath, int listID);
+
+/*
+ * KanbanCreateCard appends a card to a list and returns its ID, or -1.
+ */
+extern int KanbanCreateCard(char* dbPath, int listID, char* title, char* description);
+
+/*
+ * KanbanUpdateCard replaces the title and description of a card. Returns 0
+ * or -1.
+ */
+extern int KanbanUpdateCard(char* dbPath, int cardID, char* title, char* description);
+
+/*
+ * KanbanDeleteCard deletes a card. Returns 0 or -1.
+ */
+extern int KanbanDeleteCard(char* dbPath, int cardID);
+
+/*
+ * ExportBoardToXLSX exports a board from the default database (KANBAN_DB,
+ * the user's configured database or wekan.db in the data directory) using
+ * the flat layout. Kept for kanban.tcl; new code should call
+ * KanbanExportBoard. Returns 0 or -1.
+ */
+extern int ExportBoardToXLSX(int boardId, char* outputFile);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif /* KANBAN_API_H */
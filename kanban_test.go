@@ -0,0 +1,595 @@
+package main
+
+// GUI tests with Fyne's test driver, run with the files of the GUI:
+//
+//	go test -tags ci kanban.go kanban_test.go
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+
+	"tcl-tk-kanban/store"
+)
+
+// mustID returns a function that fails the test on the error of a create
+// and returns the new ID.
+func mustID(t *testing.T) func(int, error) int {
+	return func(id int, err error) int {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+}
+
+// testBoard is board 1 "Project" with swimlane "Dev" holding list "Todo"
+// with cards A, B and C and list "Done" with card D, and swimlane "Ops"
+// holding the empty list "Queue"; and board 2 "Sandbox".
+func testBoard(t *testing.T, s store.Storage) {
+	id := mustID(t)
+	project := id(s.CreateBoard("Project", ""))
+	id(s.CreateBoard("Sandbox", ""))
+	dev := id(s.CreateSwimlane(project, "Dev"))
+	ops := id(s.CreateSwimlane(project, "Ops"))
+	todo := id(s.CreateList(dev, "Todo"))
+	done := id(s.CreateList(dev, "Done"))
+	id(s.CreateList(ops, "Queue"))
+	id(s.CreateCard(todo, "A", "First"))
+	id(s.CreateCard(todo, "B", ""))
+	id(s.CreateCard(todo, "C", ""))
+	id(s.CreateCard(done, "D", ""))
+}
+
+// newTestWindow opens the main window with the test driver on boards kept
+// in memory, filled by setup, showing the first board.
+func newTestWindow(t *testing.T, setup func(*testing.T, store.Storage)) fyne.Window {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	selectedBoards = make(map[int]bool)
+	selectedSwimlanes = make(map[int]bool)
+	selectedLists = make(map[int]bool)
+	selectedCards = make(map[int]bool)
+	searchMatches = make(map[int]bool)
+	boardsCheckboxChecked = false
+	activeFilter = store.Filter{}
+	focus = focusItem{}
+	currentBoardID = 0
+
+	db, dbPath = nil, ""
+
+	a := test.NewTempApp(t)
+	s := store.NewMemory()
+	setup(t, s)
+	w := createMainWindow(a, s)
+	w.Resize(fyne.NewSize(1600, 1000))
+	t.Cleanup(w.Close)
+	return w
+}
+
+// walk calls f for obj and the objects it shows.
+func walk(obj fyne.CanvasObject, f func(fyne.CanvasObject)) {
+	if obj == nil {
+		return
+	}
+	f(obj)
+	switch o := obj.(type) {
+	case *fyne.Container:
+		for _, child := range o.Objects {
+			walk(child, f)
+		}
+	case *container.Scroll:
+		walk(o.Content, f)
+	case *widget.Card:
+		walk(o.Content, f)
+	case *widget.PopUp:
+		walk(o.Content, f)
+	case *DraggableIcon:
+		walk(o.Container, f)
+	case *DropSlot:
+		walk(o.Container, f)
+	}
+}
+
+// find returns the objects of type T shown by obj that match.
+func find[T fyne.CanvasObject](obj fyne.CanvasObject, match func(T) bool) []T {
+	var found []T
+	walk(obj, func(o fyne.CanvasObject) {
+		if t, ok := o.(T); ok && (match == nil || match(t)) {
+			found = append(found, t)
+		}
+	})
+	return found
+}
+
+// button returns the button labeled text shown by obj.
+func button(t *testing.T, obj fyne.CanvasObject, text string) *widget.Button {
+	t.Helper()
+	buttons := find(obj, func(b *widget.Button) bool { return b.Text == text })
+	if len(buttons) == 0 {
+		t.Fatalf("no %q button", text)
+	}
+	return buttons[0]
+}
+
+// renderedCards returns the titles of the cards shown in a list, in order.
+func renderedCards(t *testing.T, listID int) []string {
+	t.Helper()
+	lv, ok := view.lookupList(listID)
+	if !ok {
+		t.Fatalf("list %d not shown", listID)
+	}
+	var titles []string
+	for _, card := range find[*widget.Card](lv.obj, nil) {
+		labels := find(card.Content, func(l *widget.Label) bool { return l.TextStyle.Bold })
+		if len(labels) == 0 {
+			t.Fatalf("card without title in list %d", listID)
+		}
+		titles = append(titles, labels[0].Text)
+	}
+	return titles
+}
+
+// renderedLists returns the names of the lists shown in a swimlane, in
+// order.
+func renderedLists(t *testing.T, swimlaneID int) []string {
+	t.Helper()
+	sv, ok := view.lookupSwimlane(swimlaneID)
+	if !ok {
+		t.Fatalf("swimlane %d not shown", swimlaneID)
+	}
+	var names []string
+	for _, id := range sv.listIDs {
+		lv, _ := view.lookupList(id)
+		labels := find(lv.obj, func(l *widget.Label) bool { return !l.TextStyle.Bold && l.Text != "" })
+		names = append(names, labels[0].Text)
+	}
+	return names
+}
+
+// storedCards returns the titles and positions of the cards of a list in
+// the storage, in order.
+func storedCards(t *testing.T, listID int) ([]string, []int) {
+	t.Helper()
+	l, err := storage.LoadList(listID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	var positions []int
+	for _, c := range l.Cards {
+		titles = append(titles, c.Title)
+		positions = append(positions, c.Position)
+	}
+	return titles, positions
+}
+
+// storedLists returns the names of the lists of a swimlane in the storage,
+// in order.
+func storedLists(t *testing.T, swimlaneID int) []string {
+	t.Helper()
+	s, err := storage.LoadSwimlane(swimlaneID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, l := range s.Lists {
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+// storedSwimlanes returns the names and positions of the swimlanes of a
+// board in the storage, in order.
+func storedSwimlanes(t *testing.T, boardID int) ([]string, []int) {
+	t.Helper()
+	tree, err := storage.LoadBoard(boardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var positions []int
+	for _, s := range tree {
+		names = append(names, s.Name)
+		positions = append(positions, s.Position)
+	}
+	return names, positions
+}
+
+// handle returns the drag handle of a card, list or swimlane.
+func handle(t *testing.T, match func(*DraggableIcon) bool) *DraggableIcon {
+	t.Helper()
+	handles := find(view.root, match)
+	if len(handles) != 1 {
+		t.Fatalf("%d matching drag handles", len(handles))
+	}
+	return handles[0]
+}
+
+func cardHandle(t *testing.T, cardID int) *DraggableIcon {
+	return handle(t, func(d *DraggableIcon) bool { return d.Card != nil && d.Card.CardID == cardID })
+}
+
+// slot returns the drop slot of a kind at index in a parent.
+func slot(t *testing.T, kind string, parentID, index int) *DropSlot {
+	t.Helper()
+	slots := find(view.root, func(d *DropSlot) bool {
+		parent := map[string]int{"card": d.ListID, "list": d.SwimlaneID, "swimlane": d.BoardID}[d.Kind]
+		return d.Kind == kind && parent == parentID && d.Index == index
+	})
+	if len(slots) == 0 {
+		t.Fatalf("no %s drop slot %d in %d", kind, index, parentID)
+	}
+	return slots[0]
+}
+
+// drag drags a handle to pos in steps, with the events of the desktop
+// driver, and releases it.
+func drag(h *DraggableIcon, pos fyne.Position) {
+	d := fyne.CurrentApp().Driver()
+	start := d.AbsolutePositionForObject(h).Add(fyne.NewPos(5, 5))
+	const steps = 4
+	last := start
+	for i := 1; i <= steps; i++ {
+		f := float32(i) / steps
+		p := fyne.NewPos(start.X+(pos.X-start.X)*f, start.Y+(pos.Y-start.Y)*f)
+		h.Dragged(&fyne.DragEvent{
+			PointEvent: fyne.PointEvent{AbsolutePosition: p, Position: p.Subtract(d.AbsolutePositionForObject(h))},
+			Dragged:    fyne.NewDelta(p.X-last.X, p.Y-last.Y),
+		})
+		last = p
+	}
+	h.DragEnd()
+}
+
+// dragOnto drags a handle onto the middle of a drop slot.
+func dragOnto(h *DraggableIcon, s *DropSlot) {
+	p := fyne.CurrentApp().Driver().AbsolutePositionForObject(s)
+	drag(h, p.Add(fyne.NewPos(s.Size().Width/2, s.Size().Height/2)))
+}
+
+func TestMainWindow(t *testing.T) {
+	w := newTestWindow(t, testBoard)
+	if w.Title() != "Go Kanban Board - 1: Project" {
+		t.Errorf("title %q", w.Title())
+	}
+	for _, name := range []string{"1: Project", "2: Sandbox"} {
+		button(t, w.Content(), name)
+	}
+	if got := renderedCards(t, 1); !reflect.DeepEqual(got, []string{"A", "B", "C"}) {
+		t.Errorf("cards of Todo %q", got)
+	}
+	if got := renderedLists(t, 1); !reflect.DeepEqual(got, []string{"Todo", "Done"}) {
+		t.Errorf("lists of Dev %q", got)
+	}
+	// The empty list offers to add a card
+	lv, _ := view.lookupList(3)
+	button(t, lv.obj, "Add Card")
+
+	test.Tap(button(t, w.Content(), "2: Sandbox"))
+	if currentBoardID != 2 || len(view.lists) != 0 {
+		t.Errorf("board %d with %d lists", currentBoardID, len(view.lists))
+	}
+	button(t, w.Content(), "➕ Add Swimlane")
+}
+
+func TestDragCards(t *testing.T) {
+	cases := []struct {
+		name   string
+		cardID int
+		index  int
+		want   []string
+	}{
+		{"to first", 3, 0, []string{"C", "A", "B"}},
+		{"to last", 1, 3, []string{"B", "C", "A"}},
+		{"down one", 1, 2, []string{"B", "A", "C"}},
+		{"up one", 3, 1, []string{"A", "C", "B"}},
+		{"before itself", 2, 1, []string{"A", "B", "C"}},
+		{"after itself", 2, 2, []string{"A", "B", "C"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			newTestWindow(t, testBoard)
+			dragOnto(cardHandle(t, c.cardID), slot(t, "card", 1, c.index))
+			got, positions := storedCards(t, 1)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("stored %q, want %q", got, c.want)
+			}
+			if !reflect.DeepEqual(positions, []int{0, 1, 2}) {
+				t.Errorf("positions %v", positions)
+			}
+			if got := renderedCards(t, 1); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("rendered %q, want %q", got, c.want)
+			}
+			if draggedCard != nil || currentDropSlot != nil {
+				t.Errorf("drag state left: %v, %v", draggedCard, currentDropSlot)
+			}
+		})
+	}
+}
+
+// archivedBoard has an archived card, X (2), between A and B in Todo.
+func archivedBoard(t *testing.T, s store.Storage) {
+	id := mustID(t)
+	dev := id(s.CreateSwimlane(id(s.CreateBoard("Project", "")), "Dev"))
+	todo := id(s.CreateList(dev, "Todo"))
+	done := id(s.CreateList(dev, "Done"))
+	id(s.CreateCard(todo, "A", ""))
+	x := id(s.CreateCard(todo, "X", ""))
+	id(s.CreateCard(todo, "B", ""))
+	id(s.CreateCard(todo, "C", ""))
+	id(s.CreateCard(done, "D", ""))
+	if err := s.ArchiveCard(x); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Archived cards keep their positions among the shown ones, as with
+// store.MoveCards, so that positions stay 0..n-1 over all cards
+func TestMoveCardsAroundArchived(t *testing.T) {
+	newTestWindow(t, archivedBoard)
+	steps := []struct {
+		name string
+		move func()
+		want []string
+	}{
+		{"drag in front of B", func() { dragOnto(cardHandle(t, 4), slot(t, "card", 1, 1)) }, []string{"A", "X", "C", "B"}},
+		{"drag to the end", func() { dragOnto(cardHandle(t, 1), slot(t, "card", 1, 3)) }, []string{"X", "C", "B", "A"}},
+		{"up", func() { moveCardUp(3) }, []string{"X", "B", "C", "A"}},
+		{"down", func() { moveCardDown(3) }, []string{"X", "C", "B", "A"}},
+		{"to the next list", func() { moveCardToRightList(4) }, []string{"X", "B", "A"}},
+	}
+	for _, step := range steps {
+		step.move()
+		// The stored cards are the shown ones, at their positions
+		// around X
+		var want []string
+		var wantPositions []int
+		xAt := -1
+		for i, title := range step.want {
+			if title == "X" {
+				xAt = i
+			} else {
+				want = append(want, title)
+				wantPositions = append(wantPositions, i)
+			}
+		}
+		got, positions := storedCards(t, 1)
+		if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(positions, wantPositions) {
+			t.Errorf("%s: stored %q at %v, want %q", step.name, got, positions, step.want)
+		}
+		if x, err := storage.LoadCard(2); err != nil || x.Position != xAt {
+			t.Errorf("%s: X %+v, %v", step.name, x, err)
+		}
+	}
+	if got, _ := storedCards(t, 2); !reflect.DeepEqual(got, []string{"D", "C"}) {
+		t.Errorf("Done: %q", got)
+	}
+}
+
+func TestDragHighlightsSlot(t *testing.T) {
+	newTestWindow(t, testBoard)
+	h, s := cardHandle(t, 1), slot(t, "card", 1, 3)
+	p := fyne.CurrentApp().Driver().AbsolutePositionForObject(s).Add(fyne.NewPos(s.Size().Width/2, s.Size().Height/2))
+	h.Dragged(&fyne.DragEvent{PointEvent: fyne.PointEvent{AbsolutePosition: p}})
+	if currentDropSlot != s || draggedCard == nil || draggedItemName != "A" {
+		t.Errorf("dragging over slot: slot %v, card %v, name %q", currentDropSlot, draggedCard, draggedItemName)
+	}
+	// Moving on to a slot of another kind unhighlights it
+	lists := slot(t, "list", 1, 0)
+	h.Dragged(&fyne.DragEvent{PointEvent: fyne.PointEvent{AbsolutePosition: fyne.CurrentApp().Driver().AbsolutePositionForObject(lists)}})
+	if currentDropSlot != nil || s.isActive {
+		t.Errorf("slot still highlighted: %v", currentDropSlot)
+	}
+	h.DragEnd()
+	if got, _ := storedCards(t, 1); !reflect.DeepEqual(got, []string{"A", "B", "C"}) {
+		t.Errorf("dropped outside a slot: %q", got)
+	}
+}
+
+func TestDragCardToOtherListsSlot(t *testing.T) {
+	newTestWindow(t, testBoard)
+	// Card slots reorder within their list only
+	dragOnto(cardHandle(t, 4), slot(t, "card", 1, 0))
+	if got, _ := storedCards(t, 1); !reflect.DeepEqual(got, []string{"A", "B", "C"}) {
+		t.Errorf("Todo %q", got)
+	}
+	if got, _ := storedCards(t, 2); !reflect.DeepEqual(got, []string{"D"}) {
+		t.Errorf("Done %q", got)
+	}
+}
+
+func TestDragLists(t *testing.T) {
+	newTestWindow(t, testBoard)
+	listHandle := func(id int) *DraggableIcon {
+		return handle(t, func(d *DraggableIcon) bool { return d.List != nil && d.List.ListID == id })
+	}
+	dragOnto(listHandle(2), slot(t, "list", 1, 0))
+	want := []string{"Done", "Todo"}
+	if got := storedLists(t, 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored %q", got)
+	}
+	if got := renderedLists(t, 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("rendered %q", got)
+	}
+	// and back, to the end
+	dragOnto(listHandle(2), slot(t, "list", 1, 2))
+	if got := renderedLists(t, 1); !reflect.DeepEqual(got, []string{"Todo", "Done"}) {
+		t.Errorf("rendered %q after dragging back", got)
+	}
+}
+
+func TestDragSwimlanes(t *testing.T) {
+	newTestWindow(t, testBoard)
+	h := handle(t, func(d *DraggableIcon) bool { return d.Card == nil && d.List == nil && d.SwimlaneID == 2 })
+	dragOnto(h, slot(t, "swimlane", 1, 0))
+	if got, _ := storedSwimlanes(t, 1); !reflect.DeepEqual(got, []string{"Ops", "Dev"}) {
+		t.Errorf("stored %q", got)
+	}
+	ops, _ := view.lookupSwimlane(2)
+	if ops.index != 0 || view.root.Objects[0] != ops.obj {
+		t.Errorf("Ops rendered at %d", ops.index)
+	}
+}
+
+func TestSelectAndDelete(t *testing.T) {
+	w := newTestWindow(t, testBoard)
+	cv, _ := view.lookupCard(2)
+	test.Tap(cv.check)
+	if !selectedCards[2] || selectionInfo.Text != "Selected: 0 boards, 0 swimlanes, 0 lists, 1 cards" {
+		t.Errorf("selection %v, %q", selectedCards, selectionInfo.Text)
+	}
+
+	test.Tap(button(t, toolbar, "Delete"))
+	popup := w.Canvas().Overlays().Top()
+	if popup == nil {
+		t.Fatal("no confirmation")
+	}
+	labels := find(popup, func(l *widget.Label) bool { return strings.Contains(l.Text, "- 1 card(s)") })
+	if len(labels) != 1 {
+		t.Errorf("confirmation does not list the card")
+	}
+	// Without a database there is no backup to promise
+	if backups := find(popup, func(l *widget.Label) bool { return strings.Contains(l.Text, "backup") }); len(backups) != 0 {
+		t.Errorf("confirmation mentions a backup: %q", backups[0].Text)
+	}
+	test.Tap(button(t, popup, "Delete"))
+
+	if got, _ := storedCards(t, 1); !reflect.DeepEqual(got, []string{"A", "C"}) {
+		t.Errorf("stored %q", got)
+	}
+	if got := renderedCards(t, 1); !reflect.DeepEqual(got, []string{"A", "C"}) {
+		t.Errorf("rendered %q", got)
+	}
+	if len(selectedCards) != 0 || w.Canvas().Overlays().Top() != nil {
+		t.Errorf("selection %v and dialog left", selectedCards)
+	}
+}
+
+func TestCancelDelete(t *testing.T) {
+	w := newTestWindow(t, testBoard)
+	lv, _ := view.lookupList(2)
+	test.Tap(lv.check)
+	test.Tap(button(t, toolbar, "Delete"))
+	test.Tap(button(t, w.Canvas().Overlays().Top(), "Cancel"))
+	if got := storedLists(t, 1); !reflect.DeepEqual(got, []string{"Todo", "Done"}) {
+		t.Errorf("stored %q", got)
+	}
+	if !selectedLists[2] {
+		t.Error("selection cleared by cancel")
+	}
+}
+
+func TestToolbarMoves(t *testing.T) {
+	newTestWindow(t, testBoard)
+	cv, _ := view.lookupCard(1)
+	test.Tap(cv.check)
+
+	test.Tap(button(t, toolbar, "▼"))
+	if got, _ := storedCards(t, 1); !reflect.DeepEqual(got, []string{"B", "A", "C"}) {
+		t.Errorf("down: %q", got)
+	}
+	test.Tap(button(t, toolbar, "▶"))
+	if got, _ := storedCards(t, 2); !reflect.DeepEqual(got, []string{"A", "D"}) && !reflect.DeepEqual(got, []string{"D", "A"}) {
+		t.Errorf("right: %q", got)
+	}
+	if got := renderedCards(t, 1); !reflect.DeepEqual(got, []string{"B", "C"}) {
+		t.Errorf("rendered Todo %q", got)
+	}
+	if got := renderedCards(t, 2); len(got) != 2 {
+		t.Errorf("rendered Done %q", got)
+	}
+
+	// The moved card stays selected and its new checkbox shows it
+	cv, _ = view.lookupCard(1)
+	if !cv.check.Checked {
+		t.Error("moved card not checked")
+	}
+	test.Tap(button(t, toolbar, "Clear Selection"))
+	if len(selectedCards) != 0 || cv.check.Checked {
+		t.Errorf("selection %v after clearing", selectedCards)
+	}
+	test.Tap(cv.check)
+	if !selectedCards[1] {
+		t.Errorf("selection %v after checking again", selectedCards)
+	}
+}
+
+func TestToolbarMovesListsAndSwimlanes(t *testing.T) {
+	newTestWindow(t, testBoard)
+	lv, _ := view.lookupList(1)
+	test.Tap(lv.check)
+
+	test.Tap(button(t, toolbar, "▶"))
+	if got := renderedLists(t, 1); !reflect.DeepEqual(got, []string{"Done", "Todo"}) {
+		t.Errorf("right: %q", got)
+	}
+	test.Tap(button(t, toolbar, "▶"))
+	if got := renderedLists(t, 1); !reflect.DeepEqual(got, []string{"Done", "Todo"}) {
+		t.Errorf("right of the last: %q", got)
+	}
+	test.Tap(button(t, toolbar, "▼"))
+	if got := renderedLists(t, 1); !reflect.DeepEqual(got, []string{"Done"}) {
+		t.Errorf("Dev after down: %q", got)
+	}
+	if got := renderedLists(t, 2); !reflect.DeepEqual(got, []string{"Queue", "Todo"}) {
+		t.Errorf("Ops after down: %q", got)
+	}
+	if got := storedLists(t, 2); !reflect.DeepEqual(got, []string{"Queue", "Todo"}) {
+		t.Errorf("stored Ops %q", got)
+	}
+
+	lv, _ = view.lookupList(1)
+	test.Tap(lv.check)
+	sv, _ := view.lookupSwimlane(2)
+	test.Tap(sv.check)
+	test.Tap(button(t, toolbar, "▲"))
+	if got, _ := storedSwimlanes(t, 1); !reflect.DeepEqual(got, []string{"Ops", "Dev"}) {
+		t.Errorf("stored swimlanes %q", got)
+	}
+	test.Tap(button(t, toolbar, "▲"))
+	if got, positions := storedSwimlanes(t, 1); !reflect.DeepEqual(got, []string{"Ops", "Dev"}) || !reflect.DeepEqual(positions, []int{0, 1}) {
+		t.Errorf("swimlanes after moving up the first %q at %v", got, positions)
+	}
+}
+
+// Items made from a template are not templates, down to their cards
+func TestInstantiateTemplate(t *testing.T) {
+	newTestWindow(t, testBoard)
+	for _, m := range []store.Template{{Kind: store.TemplateSwimlane, ID: 1}, {Kind: store.TemplateList, ID: 1}, {Kind: store.TemplateCard, ID: 1}} {
+		if err := storage.SetTemplate(m.Kind, m.ID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fill := func(s string) string { return strings.ReplaceAll(s, "First", "Filled") }
+	instantiateTemplate(store.Template{Kind: store.TemplateSwimlane, ID: 1}, 1, "Sprint", fill)
+	instantiateTemplate(store.Template{Kind: store.TemplateCard, ID: 1}, 2, "A again", fill)
+
+	if names, _ := storedSwimlanes(t, 1); !reflect.DeepEqual(names, []string{"Dev", "Ops", "Sprint"}) {
+		t.Fatalf("swimlanes %q", names)
+	}
+	tree, err := storage.LoadBoard(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sprint, todo := tree[2], tree[2].Lists[0]; sprint.IsTemplate || todo.IsTemplate || todo.Cards[0].IsTemplate || todo.Cards[0].Description != "Filled" {
+		t.Errorf("swimlane from the template %+v", sprint)
+	}
+	if got, _ := storedCards(t, 2); !reflect.DeepEqual(got, []string{"D", "A again"}) {
+		t.Errorf("Done: %q", got)
+	}
+	// The originals are the only templates
+	for _, kind := range []store.TemplateKind{store.TemplateSwimlane, store.TemplateList, store.TemplateCard} {
+		if templates, err := storage.Templates(kind); err != nil || len(templates) != 1 || templates[0].ID != 1 {
+			t.Errorf("%s templates %+v, %v", kind, templates, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * kanbango.c
+ * Tcl entry point of the kanbango package. Kanbango_Init registers the
+ * kanbango:: commands, which are implemented in Go in kanbango.go.
+ *
+ * The package is built with Tcl stubs, so the same kanbango.so loads into
+ * any Tcl 8.6 interpreter, including tclkit.
+ */
+
+#include <tcl.h>
+#include "_cgo_export.h"
+
+#define KANBANGO_VERSION "1.0"
+
+/* Helpers for kanbango.go: cgo cannot call the Tcl API directly because
+ * with stubs every Tcl function is a macro. */
+
+const char *kanbango_arg(Tcl_Obj *const objv[], int i)
+{
+    return Tcl_GetString(objv[i]);
+}
+
+Tcl_Obj *kanbango_new_string(const char *s)
+{
+    return Tcl_NewStringObj(s, -1);
+}
+
+Tcl_Obj *kanbango_new_int(int n)
+{
+    return Tcl_NewIntObj(n);
+}
+
+Tcl_Obj *kanbango_new_list(void)
+{
+    return Tcl_NewListObj(0, NULL);
+}
+
+void kanbango_append(Tcl_Obj *list, Tcl_Obj *elem)
+{
+    Tcl_ListObjAppendElement(NULL, list, elem);
+}
+
+void kanbango_set_result(Tcl_Interp *interp, Tcl_Obj *result)
+{
+    Tcl_SetObjResult(interp, result);
+}
+
+int kanbango_error(Tcl_Interp *interp, const char *msg)
+{
+    Tcl_SetObjResult(interp, Tcl_NewStringObj(msg, -1));
+    Tcl_SetErrorCode(interp, "KANBANGO", NULL);
+    return TCL_ERROR;
+}
+
+static int ExportObjCmd(ClientData cd, Tcl_Interp *interp, int objc, Tcl_Obj *const objv[])
+{
+    return kanbangoExport(interp, objc, (Tcl_Obj **)objv);
+}
+
+static int ImportObjCmd(ClientData cd, Tcl_Interp *interp, int objc, Tcl_Obj *const objv[])
+{
+    return kanbangoImport(interp, objc, (Tcl_Obj **)objv);
+}
+
+static int SearchObjCmd(ClientData cd, Tcl_Interp *interp, int objc, Tcl_Obj *const objv[])
+{
+    return kanbangoSearch(interp, objc, (Tcl_Obj **)objv);
+}
+
+int Kanbango_Init(Tcl_Interp *interp)
+{
+    if (Tcl_InitStubs(interp, "8.6", 0) == NULL) {
+        return TCL_ERROR;
+    }
+    if (Tcl_CreateNamespace(interp, "::kanbango", NULL, NULL) == NULL) {
+        return TCL_ERROR;
+    }
+    Tcl_CreateObjCommand(interp, "::kanbango::export", ExportObjCmd, NULL, NULL);
+    Tcl_CreateObjCommand(interp, "::kanbango::import", ImportObjCmd, NULL, NULL);
+    Tcl_CreateObjCommand(interp, "::kanbango::search", SearchObjCmd, NULL, NULL);
+    return Tcl_PkgProvide(interp, "kanbango", KANBANGO_VERSION);
+}
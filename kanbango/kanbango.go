@@ -0,0 +1,263 @@
+// kanbango.go
+// Tcl package "kanbango": Go implementations of the kanbango:: commands
+// registered by Kanbango_Init in kanbango.c.
+//
+// Build with:
+//
+//	go build -buildmode=c-shared -o kanbango.so ./kanbango
+//
+// and load from Tcl with the pkgIndex.tcl next to it:
+//
+//	lappend auto_path /path/to/kanbango
+//	package require kanbango
+//	kanbango::export -db wekan.db -layout board 1 board_1.xlsx
+//	set newBoard [kanbango::import -db wekan.db board_1.json]
+//	foreach hit [kanbango::search -db wekan.db -board 1 "exporter"] {
+//	    puts [dict get $hit title]
+//	}
+
+package main
+
+/*
+#cgo CFLAGS: -DUSE_TCL_STUBS
+#cgo linux CFLAGS: -I/usr/include/tcl8.6 -I/usr/include/tcl
+#cgo darwin CFLAGS: -I/opt/homebrew/opt/tcl-tk/include -I/usr/local/opt/tcl-tk/include
+#cgo darwin LDFLAGS: -L/opt/homebrew/opt/tcl-tk/lib -L/usr/local/opt/tcl-tk/lib
+#cgo LDFLAGS: -ltclstub8.6
+
+#include <stdlib.h>
+#include <tcl.h>
+
+const char *kanbango_arg(Tcl_Obj *const objv[], int i);
+Tcl_Obj *kanbango_new_string(const char *s);
+Tcl_Obj *kanbango_new_int(int n);
+Tcl_Obj *kanbango_new_list(void);
+void kanbango_append(Tcl_Obj *list, Tcl_Obj *elem);
+void kanbango_set_result(Tcl_Interp *interp, Tcl_Obj *result);
+int kanbango_error(Tcl_Interp *interp, const char *msg);
+*/
+import "C"
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"tcl-tk-kanban/export"
+	"tcl-tk-kanban/store"
+	"tcl-tk-kanban/workspace"
+)
+
+// command holds the parsed options and positional arguments of a call.
+type command struct {
+	name    string
+	db      string
+	layout  string
+	boardID int
+	limit   int
+	args    []string
+}
+
+// parseArgs reads "?-option value ...? arg ..." as used by all commands.
+// allowed lists the options the command accepts besides -db.
+func parseArgs(objc C.int, objv **C.Tcl_Obj, allowed ...string) (*command, error) {
+	words := make([]string, int(objc))
+	for i := range words {
+		words[i] = C.GoString(C.kanbango_arg(objv, C.int(i)))
+	}
+	cmd := &command{name: words[0], limit: 100}
+	words = words[1:]
+	for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+		opt := words[0]
+		if opt == "--" {
+			words = words[1:]
+			break
+		}
+		if opt != "-db" && !contains(allowed, opt) {
+			return nil, fmt.Errorf("bad option %q: must be %s", opt, strings.Join(append([]string{"-db"}, allowed...), ", "))
+		}
+		if len(words) < 2 {
+			return nil, fmt.Errorf("missing value for %s", opt)
+		}
+		value := words[1]
+		words = words[2:]
+		switch opt {
+		case "-db":
+			cmd.db = value
+		case "-layout":
+			cmd.layout = value
+		case "-board", "-limit":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("expected integer for %s but got %q", opt, value)
+			}
+			if opt == "-board" {
+				cmd.boardID = n
+			} else {
+				cmd.limit = n
+			}
+		}
+	}
+	cmd.args = words
+	// Without -db, the database of KANBAN_DB or the user's configuration
+	db, err := workspace.Resolve(cmd.db)
+	if err != nil {
+		return nil, err
+	}
+	cmd.db = db
+	return cmd, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func tclError(interp *C.Tcl_Interp, err error) C.int {
+	msg := C.CString(err.Error())
+	defer C.free(unsafe.Pointer(msg))
+	return C.kanbango_error(interp, msg)
+}
+
+func tclString(s string) *C.Tcl_Obj {
+	cs := C.CString(s)
+	defer C.free(unsafe.Pointer(cs))
+	return C.kanbango_new_string(cs)
+}
+
+// kanbangoExport implements
+//
+//	kanbango::export ?-db path? ?-layout name? boardId outputFile
+//
+// The format is chosen by the extension of outputFile. Returns outputFile.
+//
+//export kanbangoExport
+func kanbangoExport(interp *C.Tcl_Interp, objc C.int, objv **C.Tcl_Obj) C.int {
+	cmd, err := parseArgs(objc, objv, "-layout")
+	if err == nil && len(cmd.args) != 2 {
+		err = fmt.Errorf(`wrong # args: should be "%s ?-db path? ?-layout name? boardId outputFile"`, cmd.name)
+	}
+	if err != nil {
+		return tclError(interp, err)
+	}
+	boardID, err := strconv.Atoi(cmd.args[0])
+	if err != nil {
+		return tclError(interp, fmt.Errorf("expected integer board ID but got %q", cmd.args[0]))
+	}
+	db, err := openDB(cmd.db)
+	if err != nil {
+		return tclError(interp, err)
+	}
+	defer db.Close()
+
+	opts := export.Options{Layout: export.Layout(cmd.layout), PDF: export.PDFOptions{PageSize: "A4"}}
+	if err := export.ExportBoard(db, boardID, cmd.args[1], opts); err != nil {
+		return tclError(interp, err)
+	}
+	C.kanbango_set_result(interp, tclString(cmd.args[1]))
+	return C.TCL_OK
+}
+
+// kanbangoImport implements
+//
+//	kanbango::import ?-db path? inputFile
+//
+// inputFile is a .json export. Returns the ID of the new board.
+//
+//export kanbangoImport
+func kanbangoImport(interp *C.Tcl_Interp, objc C.int, objv **C.Tcl_Obj) C.int {
+	cmd, err := parseArgs(objc, objv)
+	if err == nil && len(cmd.args) != 1 {
+		err = fmt.Errorf(`wrong # args: should be "%s ?-db path? inputFile"`, cmd.name)
+	}
+	if err != nil {
+		return tclError(interp, err)
+	}
+	db, err := openDB(cmd.db)
+	if err != nil {
+		return tclError(interp, err)
+	}
+	defer db.Close()
+
+	boardID, err := export.ImportBoardFromJSON(db, cmd.args[0])
+	if err != nil {
+		return tclError(interp, err)
+	}
+	C.kanbango_set_result(interp, C.kanbango_new_int(C.int(boardID)))
+	return C.TCL_OK
+}
+
+// kanbangoSearch implements
+//
+//	kanbango::search ?-db path? ?-board id? ?-limit n? text
+//
+// It returns a list of dicts with the keys id, boardId, board, swimlane,
+// list, title, description and snippet for the cards matching every word
+// of text, best first, as found by store.Search.
+//
+//export kanbangoSearch
+func kanbangoSearch(interp *C.Tcl_Interp, objc C.int, objv **C.Tcl_Obj) C.int {
+	cmd, err := parseArgs(objc, objv, "-board", "-limit")
+	if err == nil && len(cmd.args) != 1 {
+		err = fmt.Errorf(`wrong # args: should be "%s ?-db path? ?-board id? ?-limit n? text"`, cmd.name)
+	}
+	if err != nil {
+		return tclError(interp, err)
+	}
+	db, err := openDB(cmd.db)
+	if err != nil {
+		return tclError(interp, err)
+	}
+	defer db.Close()
+
+	hits, err := store.Search(db, cmd.args[0], store.SearchOptions{BoardID: cmd.boardID, Limit: cmd.limit})
+	if err != nil {
+		return tclError(interp, err)
+	}
+	result := C.kanbango_new_list()
+	for _, h := range hits {
+		hit := C.kanbango_new_list()
+		for _, kv := range []struct {
+			key   string
+			value *C.Tcl_Obj
+		}{
+			{"id", C.kanbango_new_int(C.int(h.CardID))},
+			{"boardId", C.kanbango_new_int(C.int(h.BoardID))},
+			{"board", tclString(h.Board)},
+			{"swimlane", tclString(h.Swimlane)},
+			{"list", tclString(h.List)},
+			{"title", tclString(h.Title)},
+			{"description", tclString(h.Description)},
+			{"snippet", tclString(h.Snippet)},
+		} {
+			C.kanbango_append(hit, tclString(kv.key))
+			C.kanbango_append(hit, kv.value)
+		}
+		C.kanbango_append(result, hit)
+	}
+	C.kanbango_set_result(interp, result)
+	return C.TCL_OK
+}
+
+// openDB opens the database at path with store.Open and creates or
+// migrates its tables with store.Init.
+func openDB(path string) (*sql.DB, error) {
+	db, err := store.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Init(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func main() {}
@@ -0,0 +1,116 @@
+#!/usr/bin/env tclsh
+# kanbango_test.tcl
+# Tests for the kanbango Tcl package. Build kanbango.so first:
+#
+#   go build -tags sqlite_fts5 -buildmode=c-shared -o kanbango/kanbango.so ./kanbango
+#   tclsh kanbango/kanbango_test.tcl
+
+package require tcltest
+namespace import ::tcltest::*
+package require sqlite3
+
+set here [file dirname [file normalize [info script]]]
+lappend auto_path $here
+package require kanbango
+
+set tmpDir [makeDirectory kanbango_test]
+set dbPath [file join $tmpDir wekan.db]
+file delete $dbPath
+
+sqlite3 testdb $dbPath
+testdb eval {
+    CREATE TABLE boards (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, description TEXT,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
+    CREATE TABLE swimlanes (id INTEGER PRIMARY KEY AUTOINCREMENT, board_id INTEGER NOT NULL, name TEXT NOT NULL,
+        position INTEGER DEFAULT 0, text_color TEXT DEFAULT '', background_color TEXT DEFAULT '', background_image TEXT DEFAULT '');
+    CREATE TABLE lists (id INTEGER PRIMARY KEY AUTOINCREMENT, swimlane_id INTEGER NOT NULL, name TEXT NOT NULL,
+        position INTEGER DEFAULT 0, text_color TEXT DEFAULT '', background_color TEXT DEFAULT '', background_image TEXT DEFAULT '');
+    CREATE TABLE cards (id INTEGER PRIMARY KEY AUTOINCREMENT, list_id INTEGER NOT NULL, title TEXT NOT NULL, description TEXT,
+        position INTEGER DEFAULT 0, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, attachment BLOB,
+        text_color TEXT DEFAULT '', background_color TEXT DEFAULT '');
+    INSERT INTO boards (id, name, description) VALUES (1, 'Weekly Board', 'Team work');
+    INSERT INTO swimlanes (id, board_id, name, position) VALUES (1, 1, 'Development', 0);
+    INSERT INTO lists (id, swimlane_id, name, position) VALUES (1, 1, 'Todo', 0), (2, 1, 'Done', 1);
+    INSERT INTO cards (list_id, title, description, position) VALUES
+        (1, 'Write exporter', 'XLSX and ODS', 0),
+        (1, 'Fix 100% CPU', 'busy loop', 1),
+        (2, 'Release', 'Tag the exporter release', 0);
+}
+testdb close
+
+test commands-1.0 {package registers its commands} -body {
+    lsort [info commands ::kanbango::*]
+} -result {::kanbango::export ::kanbango::import ::kanbango::search}
+
+test export-1.0 {export writes every format} -body {
+    set files {}
+    foreach ext {xlsx ods pdf json} {
+        set out [file join $tmpDir board.$ext]
+        kanbango::export -db $dbPath -layout board 1 $out
+        lappend files [expr {[file size $out] > 0}]
+    }
+    set files
+} -result {1 1 1 1}
+
+test export-1.1 {export returns the output file} -body {
+    kanbango::export -db $dbPath 1 [file join $tmpDir flat.xlsx]
+} -result [file join $tmpDir flat.xlsx]
+
+test export-2.0 {export reports unknown formats} -body {
+    kanbango::export -db $dbPath 1 [file join $tmpDir board.csv]
+} -returnCodes error -match glob -result {unsupported export format ".csv"*}
+
+test export-2.1 {export reports missing boards} -body {
+    kanbango::export -db $dbPath 42 [file join $tmpDir missing.xlsx]
+} -returnCodes error -match glob -result {load board 42:*}
+
+test export-2.2 {export checks its arguments} -body {
+    kanbango::export -db $dbPath 1
+} -returnCodes error -match glob -result {wrong # args: should be "kanbango::export *"}
+
+test export-2.3 {export rejects unknown options} -body {
+    kanbango::export -board 1 1 x.xlsx
+} -returnCodes error -result {bad option "-board": must be -db, -layout}
+
+test import-1.0 {import copies a JSON export into a new board} -body {
+    set id [kanbango::import -db $dbPath [file join $tmpDir board.json]]
+    sqlite3 testdb $dbPath
+    set cards [testdb eval {
+        SELECT c.title FROM cards c JOIN lists l ON l.id = c.list_id JOIN swimlanes s ON s.id = l.swimlane_id
+        WHERE s.board_id = $id ORDER BY l.position, c.position
+    }]
+    testdb close
+    list [expr {$id > 1}] $cards
+} -result {1 {{Write exporter} {Fix 100% CPU} Release}}
+
+test import-2.0 {import reports bad files} -body {
+    kanbango::import -db $dbPath [file join $tmpDir board.xlsx]
+} -returnCodes error -match glob -result {decode board:*}
+
+test search-1.0 {search matches titles and descriptions} -body {
+    lmap hit [kanbango::search -db $dbPath -board 1 exporter] {dict get $hit title}
+} -result {{Write exporter} Release}
+
+test search-1.1 {search returns dicts} -body {
+    set hit [lindex [kanbango::search -db $dbPath -board 1 -limit 1 "write"] 0]
+    list [dict get $hit boardId] [dict get $hit board] [dict get $hit swimlane] [dict get $hit list] [dict get $hit description]
+} -result {1 {Weekly Board} Development Todo {XLSX and ODS}}
+
+test search-1.2 {search ignores punctuation} -body {
+    lmap hit [kanbango::search -db $dbPath -board 1 "100%"] {dict get $hit title}
+} -result {{Fix 100% CPU}}
+
+test search-1.3 {search over all boards} -body {
+    llength [kanbango::search -db $dbPath exporter]
+} -result 4
+
+test search-1.4 {search marks the matched words in the snippet} -body {
+    dict get [lindex [kanbango::search -db $dbPath -board 1 "write exp"] 0] snippet
+} -match regexp -result {^\[Write\] \[exp}
+
+test search-2.0 {search checks integer options} -body {
+    kanbango::search -db $dbPath -limit many x
+} -returnCodes error -result {expected integer for -limit but got "many"}
+
+removeDirectory kanbango_test
+cleanupTests
@@ -0,0 +1,2 @@
+# Tcl package index for kanbango, the Go extension built from kanbango/.
+package ifneeded kanbango 1.0 [list load [file join $dir kanbango[info sharedlibextension]] Kanbango]
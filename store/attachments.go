@@ -0,0 +1,86 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultAttachmentCacheSize is the number of attachments kept in memory by
+// the board view.
+const DefaultAttachmentCacheSize = 32
+
+// Attachments loads card attachments on demand and keeps the most recently
+// used ones in memory. It is safe for concurrent use.
+type Attachments struct {
+	s     Storage
+	size  int
+	mu    sync.Mutex
+	order *list.List // front is most recently used
+	items map[int]*list.Element
+}
+
+type attachmentEntry struct {
+	cardID int
+	data   []byte
+}
+
+// NewAttachments returns a cache of the attachments of s holding up to size
+// attachments.
+func NewAttachments(s Storage, size int) *Attachments {
+	if size < 1 {
+		size = 1
+	}
+	return &Attachments{s: s, size: size, order: list.New(), items: make(map[int]*list.Element)}
+}
+
+// Get returns the attachment of a card, or nil if it has none. The returned
+// slice is shared with the cache and must not be modified.
+func (a *Attachments) Get(cardID int) ([]byte, error) {
+	a.mu.Lock()
+	if e, ok := a.items[cardID]; ok {
+		a.order.MoveToFront(e)
+		data := e.Value.(*attachmentEntry).data
+		a.mu.Unlock()
+		return data, nil
+	}
+	a.mu.Unlock()
+
+	data, err := a.s.Attachment(cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if e, ok := a.items[cardID]; ok {
+		// Loaded concurrently
+		e.Value.(*attachmentEntry).data = data
+		a.order.MoveToFront(e)
+		return data, nil
+	}
+	a.items[cardID] = a.order.PushFront(&attachmentEntry{cardID: cardID, data: data})
+	for a.order.Len() > a.size {
+		oldest := a.order.Back()
+		a.order.Remove(oldest)
+		delete(a.items, oldest.Value.(*attachmentEntry).cardID)
+	}
+	return data, nil
+}
+
+// Invalidate drops the cached attachment of a card after it was changed or
+// the card was deleted.
+func (a *Attachments) Invalidate(cardID int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if e, ok := a.items[cardID]; ok {
+		a.order.Remove(e)
+		delete(a.items, cardID)
+	}
+}
+
+// Len returns the number of cached attachments.
+func (a *Attachments) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.order.Len()
+}
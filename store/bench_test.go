@@ -0,0 +1,239 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Shape of the generated benchmark board: 4 swimlanes x 10 lists x 250
+// cards = 10,000 cards. Every tenth card has a 32 KiB attachment.
+const (
+	benchSwimlanes      = 4
+	benchLists          = 10
+	benchCards          = 250
+	benchAttachmentSize = 32 << 10
+)
+
+var (
+	benchOnce sync.Once
+	benchDir  string
+	benchPath string
+	benchErr  error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if benchDir != "" {
+		os.RemoveAll(benchDir)
+	}
+	os.Exit(code)
+}
+
+// openBenchDB opens the generated 10k-card database, creating it on first use.
+func openBenchDB(b *testing.B) *sql.DB {
+	b.Helper()
+	benchOnce.Do(func() {
+		benchDir, benchErr = os.MkdirTemp("", "kanban-bench")
+		if benchErr == nil {
+			benchPath = filepath.Join(benchDir, "wekan.db")
+			benchErr = generateBenchDB(benchPath)
+		}
+	})
+	if benchErr != nil {
+		b.Fatal(benchErr)
+	}
+	db, err := sql.Open("sqlite3", benchPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	return db
+}
+
+func generateBenchDB(path string) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := Init(db); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("INSERT INTO boards (id, name) VALUES (1, 'Benchmark')"); err != nil {
+		return err
+	}
+	card, err := tx.Prepare("INSERT INTO cards (list_id, title, description, position, attachment) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer card.Close()
+	attachment := make([]byte, benchAttachmentSize)
+	listID := 0
+	for s := 0; s < benchSwimlanes; s++ {
+		res, err := tx.Exec("INSERT INTO swimlanes (board_id, name, position) VALUES (1, ?, ?)", fmt.Sprintf("Swimlane %d", s), s)
+		if err != nil {
+			return err
+		}
+		swimlaneID, _ := res.LastInsertId()
+		for l := 0; l < benchLists; l++ {
+			if _, err := tx.Exec("INSERT INTO lists (swimlane_id, name, position) VALUES (?, ?, ?)", swimlaneID, fmt.Sprintf("List %d", l), l); err != nil {
+				return err
+			}
+			listID++
+			for c := 0; c < benchCards; c++ {
+				var blob []byte
+				if c%10 == 0 {
+					blob = attachment
+				}
+				if _, err := card.Exec(listID, fmt.Sprintf("Card %d", c), "Some description of the card", c, blob); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func BenchmarkLoadBoard(b *testing.B) {
+	db := openBenchDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree, err := LoadBoard(db, 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if n := countCards(tree); n != benchSwimlanes*benchLists*benchCards {
+			b.Fatalf("loaded %d cards", n)
+		}
+	}
+}
+
+// BenchmarkLoadBoardPerList is the baseline: one query per swimlane and per
+// list, with attachment blobs, as the board view used to load boards.
+func BenchmarkLoadBoardPerList(b *testing.B) {
+	db := openBenchDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n, err := loadBoardPerList(db, 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if n != benchSwimlanes*benchLists*benchCards {
+			b.Fatalf("loaded %d cards", n)
+		}
+	}
+}
+
+func BenchmarkAttachmentsGet(b *testing.B) {
+	db := openBenchDB(b)
+	ids, err := attachmentCardIDs(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("cached", func(b *testing.B) {
+		a := NewAttachments(NewSQLite(db), DefaultAttachmentCacheSize)
+		for i := 0; i < b.N; i++ {
+			if _, err := a.Get(ids[i%DefaultAttachmentCacheSize]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		a := NewAttachments(NewSQLite(db), DefaultAttachmentCacheSize)
+		for i := 0; i < b.N; i++ {
+			if _, err := a.Get(ids[i%len(ids)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func countCards(tree []Swimlane) int {
+	n := 0
+	for _, s := range tree {
+		for _, l := range s.Lists {
+			n += len(l.Cards)
+		}
+	}
+	return n
+}
+
+func attachmentCardIDs(db *sql.DB) ([]int, error) {
+	rows, err := db.Query("SELECT id FROM cards WHERE attachment IS NOT NULL ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// loadBoardPerList loads a board the old way and returns the number of cards.
+func loadBoardPerList(db *sql.DB, boardID int) (int, error) {
+	var swimlanes []Swimlane
+	rows, err := db.Query("SELECT id, board_id, name, position, COALESCE(text_color, ''), COALESCE(background_color, ''), COALESCE(background_image, '') FROM swimlanes WHERE board_id = ? ORDER BY position", boardID)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var s Swimlane
+		if err := rows.Scan(&s.ID, &s.BoardID, &s.Name, &s.Position, &s.TextColor, &s.BackgroundColor, &s.BackgroundImage); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		swimlanes = append(swimlanes, s)
+	}
+	rows.Close()
+
+	n := 0
+	for _, s := range swimlanes {
+		rows, err := db.Query("SELECT id, swimlane_id, name, position, COALESCE(text_color, ''), COALESCE(background_color, ''), COALESCE(background_image, '') FROM lists WHERE swimlane_id = ? ORDER BY position", s.ID)
+		if err != nil {
+			return 0, err
+		}
+		var lists []List
+		for rows.Next() {
+			var l List
+			if err := rows.Scan(&l.ID, &l.SwimlaneID, &l.Name, &l.Position, &l.TextColor, &l.BackgroundColor, &l.BackgroundImage); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			lists = append(lists, l)
+		}
+		rows.Close()
+
+		for _, l := range lists {
+			rows, err := db.Query("SELECT id, list_id, title, description, position, created_at, attachment, COALESCE(text_color, ''), COALESCE(background_color, '') FROM cards WHERE list_id = ? ORDER BY position", l.ID)
+			if err != nil {
+				return 0, err
+			}
+			for rows.Next() {
+				var c Card
+				var attachment []byte
+				if err := rows.Scan(&c.ID, &c.ListID, &c.Title, &c.Description, &c.Position, &c.CreatedAt, &attachment, &c.TextColor, &c.BackgroundColor); err != nil {
+					rows.Close()
+					return 0, err
+				}
+				n++
+			}
+			rows.Close()
+		}
+	}
+	return n, nil
+}
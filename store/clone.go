@@ -0,0 +1,401 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CloneOptions controls a deep copy. The zero value copies an item with
+// all its children but the archived cards next to the original, named
+// "<name> (Copy)". The copied rows are new: they get the current creation
+// time, and none of them is a template or archived.
+type CloneOptions struct {
+	// ParentID is the board, swimlane or list to copy a swimlane, list or
+	// card into, 0 for the parent of the original.
+	ParentID int
+	// WithoutCards copies boards, swimlanes and lists without their cards.
+	WithoutCards bool
+	// WithArchived copies the archived cards of boards, swimlanes and lists
+	// too, as cards that are not archived.
+	WithArchived bool
+	// Name is the name or title of the copy. Empty means the original name,
+	// with " (Copy)" appended if the copy stays in the same parent.
+	Name string
+	// Text rewrites the names, titles and descriptions of all copied rows,
+	// e.g. to fill in template placeholders. nil keeps them.
+	Text func(string) string
+}
+
+// knownRelations are the parent-child relations of the board tables,
+// used in addition to the foreign keys because databases created by older
+// versions may lack them.
+var knownRelations = map[string][]relation{
+	"boards":    {{"swimlanes", "board_id"}},
+	"swimlanes": {{"lists", "swimlane_id"}},
+	"lists":     {{"cards", "list_id"}},
+}
+
+// resetColumns are the bookkeeping columns that copied rows get anew
+// instead of copying them, with the SQL of their new values.
+var resetColumns = map[string]string{
+	"created_at":  "CURRENT_TIMESTAMP",
+	"is_template": "0",
+	"archived":    "0",
+	"archive_at":  "NULL",
+}
+
+// textColumns are rewritten by CloneOptions.Text.
+var textColumns = []string{"name", "title", "description"}
+
+// relation is a child table and its column referencing the parent id.
+type relation struct {
+	table, column string
+}
+
+// cloner copies rows and their children within a transaction. Columns and
+// relations are read from the schema, so that new columns and child
+// tables are copied without changes here.
+type cloner struct {
+	tx        *sql.Tx
+	opts      CloneOptions
+	columns   map[string][]string
+	relations map[string][]relation
+	// ids maps the IDs of the copied rows to the IDs of their copies, by
+	// table.
+	ids map[string]map[int]int
+}
+
+// CloneBoard copies a board with all its rows and returns the ID of the
+// copy. ParentID is ignored.
+func CloneBoard(db *sql.DB, boardID int, opts CloneOptions) (int, error) {
+	return clone(db, "boards", "", boardID, opts)
+}
+
+// CloneSwimlane copies a swimlane with its lists and cards to the end of a
+// board and returns the ID of the copy.
+func CloneSwimlane(db *sql.DB, swimlaneID int, opts CloneOptions) (int, error) {
+	return clone(db, "swimlanes", "board_id", swimlaneID, opts)
+}
+
+// CloneList copies a list with its cards to the end of a swimlane and
+// returns the ID of the copy.
+func CloneList(db *sql.DB, listID int, opts CloneOptions) (int, error) {
+	return clone(db, "lists", "swimlane_id", listID, opts)
+}
+
+// CloneCard copies a card with its attachment to the end of a list and
+// returns the ID of the copy.
+func CloneCard(db *sql.DB, cardID int, opts CloneOptions) (int, error) {
+	return clone(db, "cards", "list_id", cardID, opts)
+}
+
+func clone(db *sql.DB, table, parentColumn string, id int, opts CloneOptions) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("clone %s %d: %w", table, id, err)
+	}
+	defer tx.Rollback()
+	newID, err := cloneTx(tx, table, parentColumn, id, opts)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("clone %s %d: %w", table, id, err)
+	}
+	return newID, nil
+}
+
+// cloneTx is clone within a transaction.
+func cloneTx(tx *sql.Tx, table, parentColumn string, id int, opts CloneOptions) (int, error) {
+	c := &cloner{tx: tx, opts: opts, columns: make(map[string][]string), ids: make(map[string]map[int]int)}
+	if err := c.loadRelations(); err != nil {
+		return 0, fmt.Errorf("clone %s %d: %w", table, id, err)
+	}
+
+	// The root row gets its parent, position and name here; its children
+	// keep their positions.
+	set := make(map[string]any)
+	nameColumn := "name"
+	if table == "cards" {
+		nameColumn = "title"
+	}
+	var name string
+	if err := tx.QueryRow("SELECT "+nameColumn+" FROM "+table+" WHERE id = ?", id).Scan(&name); err != nil {
+		return 0, fmt.Errorf("clone %s %d: %w", table, id, err)
+	}
+	sameParent := true
+	if parentColumn != "" {
+		var parentID int
+		if err := tx.QueryRow("SELECT "+parentColumn+" FROM "+table+" WHERE id = ?", id).Scan(&parentID); err != nil {
+			return 0, fmt.Errorf("clone %s %d: %w", table, id, err)
+		}
+		if opts.ParentID != 0 && opts.ParentID != parentID {
+			parentID, sameParent = opts.ParentID, false
+		}
+		var pos int
+		err := tx.QueryRow("SELECT COALESCE(MAX(position), -1) + 1 FROM "+table+" WHERE "+parentColumn+" = ?", parentID).Scan(&pos)
+		if err != nil {
+			return 0, fmt.Errorf("clone %s %d: %w", table, id, err)
+		}
+		set[parentColumn] = parentID
+		set["position"] = pos
+	}
+	if opts.Text != nil {
+		name = opts.Text(name)
+	}
+	switch {
+	case opts.Name != "":
+		set[nameColumn] = opts.Name
+	case sameParent:
+		set[nameColumn] = name + " (Copy)"
+	}
+
+	newID, err := c.copyTree(table, id, set)
+	if err != nil {
+		return 0, fmt.Errorf("clone %s %d: %w", table, id, err)
+	}
+	if err := c.remapRules(); err != nil {
+		return 0, fmt.Errorf("clone %s %d: %w", table, id, err)
+	}
+	return newID, nil
+}
+
+// loadRelations reads the foreign keys referencing the id of each table.
+func (c *cloner) loadRelations() error {
+	relations, err := readRelations(c.tx, false)
+	if err != nil {
+		return err
+	}
+	c.relations = relations
+	return nil
+}
+
+// readRelations returns the child tables of each table: knownRelations and
+// the foreign keys referencing its id, only those with ON DELETE CASCADE if
+// cascadeOnly is set.
+func readRelations(tx *sql.Tx, cascadeOnly bool) (map[string][]relation, error) {
+	relations := make(map[string][]relation)
+	for parent, rels := range knownRelations {
+		relations[parent] = append(relations[parent], rels...)
+	}
+	rows, err := tx.Query(`SELECT m.name, f."from", f."table" FROM sqlite_master m
+		JOIN pragma_foreign_key_list(m.name) f
+		WHERE m.type = 'table' AND (f."to" IS NULL OR f."to" = 'id')
+			AND (NOT ? OR f.on_delete = 'CASCADE')
+		ORDER BY m.name`, cascadeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("read foreign keys: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rel relation
+		var parent string
+		if err := rows.Scan(&rel.table, &rel.column, &parent); err != nil {
+			return nil, fmt.Errorf("read foreign keys: %w", err)
+		}
+		if rel.table == parent || hasRelation(relations[parent], rel) {
+			continue
+		}
+		relations[parent] = append(relations[parent], rel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read foreign keys: %w", err)
+	}
+	return relations, nil
+}
+
+func hasRelation(rels []relation, rel relation) bool {
+	for _, r := range rels {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// tableColumns returns the columns of a table except id, which the copy
+// gets anew.
+func (c *cloner) tableColumns(table string) ([]string, error) {
+	if cols, ok := c.columns[table]; ok {
+		return cols, nil
+	}
+	rows, err := c.tx.Query("SELECT name FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		return nil, fmt.Errorf("read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+	var cols []string
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			return nil, fmt.Errorf("read columns of %s: %w", table, err)
+		}
+		if col != "id" {
+			cols = append(cols, col)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	c.columns[table] = cols
+	return cols, nil
+}
+
+// copyTree copies a row with the values in set replacing its own, then its
+// children, and returns the ID of the copy. Rows are identified by rowid,
+// which is the id column of the tables that have one.
+func (c *cloner) copyTree(table string, id int, set map[string]any) (int, error) {
+	cols, err := c.tableColumns(table)
+	if err != nil {
+		return 0, err
+	}
+	quoted := make([]string, len(cols))
+	values := make([]string, len(cols))
+	var args []any
+	for i, col := range cols {
+		quoted[i] = quoteIdent(col)
+		values[i] = quoted[i]
+		if v, ok := set[col]; ok {
+			values[i] = "?"
+			args = append(args, v)
+		} else if expr, ok := resetColumns[col]; ok {
+			values[i] = expr
+		}
+	}
+	args = append(args, id)
+	res, err := c.tx.Exec("INSERT INTO "+quoteIdent(table)+" ("+strings.Join(quoted, ", ")+") SELECT "+
+		strings.Join(values, ", ")+" FROM "+quoteIdent(table)+" WHERE rowid = ?", args...)
+	if err != nil {
+		return 0, fmt.Errorf("copy %s %d: %w", table, id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, fmt.Errorf("copy %s %d: %w", table, id, sql.ErrNoRows)
+	}
+	id64, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("copy %s %d: %w", table, id, err)
+	}
+	newID := int(id64)
+	if c.ids[table] == nil {
+		c.ids[table] = make(map[int]int)
+	}
+	c.ids[table][id] = newID
+
+	if c.opts.Text != nil {
+		if err := c.rewriteText(table, cols, newID, set); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, rel := range c.relations[table] {
+		if c.opts.WithoutCards && rel.table == "cards" {
+			continue
+		}
+		children, err := c.childIDs(rel, id)
+		if err != nil {
+			return 0, err
+		}
+		for _, child := range children {
+			if _, err := c.copyTree(rel.table, child, map[string]any{rel.column: newID}); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return newID, nil
+}
+
+// childIDs returns the rows of rel referencing parentID, leaving out the
+// archived cards unless CloneOptions.WithArchived is set.
+func (c *cloner) childIDs(rel relation, parentID int) ([]int, error) {
+	where := quoteIdent(rel.column) + " = ?"
+	if rel.table == "cards" && !c.opts.WithArchived {
+		cols, err := c.tableColumns(rel.table)
+		if err != nil {
+			return nil, err
+		}
+		if hasColumn(cols, "archived") {
+			where += " AND COALESCE(archived, 0) = 0"
+		}
+	}
+	rows, err := c.tx.Query("SELECT rowid FROM "+quoteIdent(rel.table)+" WHERE "+where+" ORDER BY rowid", parentID)
+	if err != nil {
+		return nil, fmt.Errorf("read %s of %d: %w", rel.table, parentID, err)
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("read %s of %d: %w", rel.table, parentID, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// rewriteText applies CloneOptions.Text to the text columns of a copied
+// row that were not set explicitly.
+func (c *cloner) rewriteText(table string, cols []string, id int, set map[string]any) error {
+	for _, col := range textColumns {
+		if _, ok := set[col]; ok || !hasColumn(cols, col) {
+			continue
+		}
+		var s sql.NullString
+		if err := c.tx.QueryRow("SELECT "+col+" FROM "+quoteIdent(table)+" WHERE rowid = ?", id).Scan(&s); err != nil {
+			return fmt.Errorf("rewrite %s.%s: %w", table, col, err)
+		}
+		if !s.Valid {
+			continue
+		}
+		if t := c.opts.Text(s.String); t != s.String {
+			if _, err := c.tx.Exec("UPDATE "+quoteIdent(table)+" SET "+col+" = ? WHERE rowid = ?", t, id); err != nil {
+				return fmt.Errorf("rewrite %s.%s: %w", table, col, err)
+			}
+		}
+	}
+	return nil
+}
+
+func hasColumn(cols []string, col string) bool {
+	for _, c := range cols {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// remapRules points the triggers of copied rules to the copied swimlanes
+// and lists. Rules refer to them inside their JSON, not by foreign keys.
+func (c *cloner) remapRules() error {
+	for _, newID := range c.ids["rules"] {
+		var data string
+		if err := c.tx.QueryRow("SELECT rule FROM rules WHERE id = ?", newID).Scan(&data); err != nil {
+			return fmt.Errorf("remap rule %d: %w", newID, err)
+		}
+		var r Rule
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			return fmt.Errorf("remap rule %d: %w", newID, err)
+		}
+		if id, ok := c.ids["swimlanes"][r.Trigger.SwimlaneID]; ok {
+			r.Trigger.SwimlaneID = id
+		}
+		if id, ok := c.ids["lists"][r.Trigger.ListID]; ok {
+			r.Trigger.ListID = id
+		}
+		remapped, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("remap rule %d: %w", newID, err)
+		}
+		if _, err := c.tx.Exec("UPDATE rules SET rule = ? WHERE id = ?", string(remapped), newID); err != nil {
+			return fmt.Errorf("remap rule %d: %w", newID, err)
+		}
+	}
+	return nil
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
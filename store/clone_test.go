@@ -0,0 +1,259 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withoutIDs returns a copy of a tree with the IDs of its rows cleared, so
+// that a tree and its clone compare equal.
+func withoutIDs(tree []Swimlane) []Swimlane {
+	out := make([]Swimlane, len(tree))
+	for i, s := range tree {
+		s.ID, s.BoardID = 0, 0
+		lists := make([]List, len(s.Lists))
+		for j, l := range s.Lists {
+			l.ID, l.SwimlaneID = 0, 0
+			cards := make([]Card, len(l.Cards))
+			for k, c := range l.Cards {
+				c.ID, c.ListID = 0, 0
+				cards[k] = c
+			}
+			l.Cards = cards
+			lists[j] = l
+		}
+		s.Lists = lists
+		out[i] = s
+	}
+	return out
+}
+
+func openCloneDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := openTestDB(t)
+	stmts := []string{
+		"UPDATE swimlanes SET background_color = '#DDEEFF', background_image = 'lane.png' WHERE id = 1",
+		"UPDATE lists SET text_color = '#333333', wip_limit = 3, wip_hard = 1, is_template = 1 WHERE id = 1",
+		"UPDATE cards SET text_color = '#FFFFFF', background_color = '#FF0000', created_at = '2025-03-10 12:00:00' WHERE id = 2",
+		"UPDATE cards SET archived = 1 WHERE id = 1",
+		"INSERT INTO cards (list_id, title, position) VALUES (2, 'Shipped', 0)",
+	}
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	return db
+}
+
+func TestCloneBoardRoundTrip(t *testing.T) {
+	db := openCloneDB(t)
+	rule := Rule{BoardID: 1, Name: "Done is green", Enabled: true,
+		Trigger: Trigger{Event: EventMoved, SwimlaneID: 1, ListID: 2},
+		Action:  Action{Kind: ActionBackgroundColor, Color: "#00FF00"}}
+	if err := SaveRule(db, &rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveFilter(db, 1, "Red", Filter{BackgroundColor: "#FF0000"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE cards SET archive_at = '2030-01-01 00:00:00' WHERE id = 2"); err != nil {
+		t.Fatal(err)
+	}
+
+	newID, err := CloneBoard(db, 1, CloneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	db.QueryRow("SELECT name FROM boards WHERE id = ?", newID).Scan(&name)
+	if name != "Board (Copy)" {
+		t.Errorf("board name %q", name)
+	}
+
+	orig, err := LoadBoard(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := LoadBoard(db, newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The copy is the same tree of new rows: created now, and neither
+	// templates nor archived
+	want := withoutIDs(orig)
+	want[1].Lists[0].IsTemplate = false
+	got := withoutIDs(copied)
+	for _, tree := range [][]Swimlane{want, got} {
+		for _, l := range tree[1].Lists {
+			for k := range l.Cards {
+				l.Cards[k].CreatedAt = ""
+			}
+		}
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("cloned tree\n%+v\nwant\n%+v", copied, orig)
+	}
+	if c := copied[1].Lists[0].Cards[0]; c.CreatedAt == "" || c.CreatedAt == "2025-03-10 12:00:00" {
+		t.Errorf("copied card created at %q, want now", c.CreatedAt)
+	}
+	var scheduled, templates int
+	db.QueryRow("SELECT COUNT(*) FROM cards WHERE archive_at IS NOT NULL").Scan(&scheduled)
+	db.QueryRow("SELECT COUNT(*) FROM lists WHERE is_template = 1").Scan(&templates)
+	if scheduled != 1 || templates != 1 {
+		t.Errorf("%d scheduled archives and %d template lists, want only the originals", scheduled, templates)
+	}
+	if copied[1].ID == orig[1].ID || copied[1].BoardID != newID {
+		t.Errorf("cloned swimlane %+v not on the new board", copied[1])
+	}
+
+	// Attachments are copied, archived cards are not
+	var origData, copyData []byte
+	db.QueryRow("SELECT attachment FROM cards WHERE id = 2").Scan(&origData)
+	db.QueryRow("SELECT attachment FROM cards WHERE id = ?", copied[1].Lists[0].Cards[0].ID).Scan(&copyData)
+	if len(copyData) == 0 || !bytes.Equal(origData, copyData) {
+		t.Errorf("attachment %q, want %q", copyData, origData)
+	}
+	var archived, todo int
+	db.QueryRow("SELECT COUNT(*) FROM cards WHERE archived = 1").Scan(&archived)
+	db.QueryRow("SELECT COUNT(*) FROM cards WHERE list_id = ?", copied[1].Lists[0].ID).Scan(&todo)
+	if archived != 1 || todo != 1 {
+		t.Errorf("%d archived cards and %d cards in the copied Todo, want the archived card left out", archived, todo)
+	}
+
+	// WithArchived copies them as cards that are not archived
+	withID, err := CloneBoard(db, 1, CloneOptions{WithArchived: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	with, err := LoadBoard(db, withID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cards := with[1].Lists[0].Cards; len(cards) != 2 || cards[1].Title != "Second" {
+		t.Errorf("Todo copied with archived cards %+v", cards)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM cards WHERE archived = 1").Scan(&archived)
+	if archived != 1 {
+		t.Errorf("%d archived cards after copying with archived cards, want 1", archived)
+	}
+
+	// Rules and saved filters reference boards by foreign key; copied
+	// rules point to the copied swimlanes and lists
+	rules, err := Rules(db, newID)
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("copied rules %+v, %v", rules, err)
+	}
+	if tr := rules[0].Trigger; tr.SwimlaneID != copied[1].ID || tr.ListID != copied[1].Lists[1].ID {
+		t.Errorf("copied rule trigger %+v, want swimlane %d and list %d", tr, copied[1].ID, copied[1].Lists[1].ID)
+	}
+	if filters, _ := SavedFilters(db, newID); len(filters) != 1 || filters[0].Name != "Red" {
+		t.Errorf("copied filters %+v", filters)
+	}
+}
+
+// futureSchema adds a column and a child table of cards, as a future
+// version could.
+const futureSchema = `
+	CREATE TABLE comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		card_id INTEGER NOT NULL REFERENCES cards(id),
+		body TEXT
+	);
+	ALTER TABLE cards ADD COLUMN priority INTEGER DEFAULT 0;
+`
+
+func TestCloneFutureSchema(t *testing.T) {
+	db := openCloneDB(t)
+	if _, err := db.Exec(futureSchema); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"UPDATE cards SET priority = 3 WHERE id = 2",
+		"INSERT INTO comments (card_id, body) VALUES (2, 'Looks good'), (2, 'Ship it')",
+	} {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A card copied to another list keeps its title, new columns and
+	// children, and goes to the end of the list
+	newCardID, err := CloneCard(db, 2, CloneOptions{ParentID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadCard(db, newCardID)
+	if err != nil || c.Title != "First" || c.ListID != 2 || c.Position != 1 {
+		t.Errorf("cloned card %+v, %v", c, err)
+	}
+	var priority int
+	db.QueryRow("SELECT priority FROM cards WHERE id = ?", newCardID).Scan(&priority)
+	if priority != 3 {
+		t.Errorf("priority %d, want 3", priority)
+	}
+	var bodies string
+	db.QueryRow("SELECT group_concat(body, '|') FROM (SELECT body FROM comments WHERE card_id = ? ORDER BY id)", newCardID).Scan(&bodies)
+	if bodies != "Looks good|Ship it" {
+		t.Errorf("copied comments %q", bodies)
+	}
+}
+
+func TestCloneOptions(t *testing.T) {
+	db := openCloneDB(t)
+
+	// Same parent: "(Copy)" at the end of the swimlane
+	listID, err := CloneList(db, 1, CloneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, _ := LoadList(db, listID)
+	if l.Name != "Todo (Copy)" || l.Position != 2 || l.SwimlaneID != 1 || len(l.Cards) != 1 || l.IsTemplate || l.WIPLimit != 3 {
+		t.Errorf("cloned list %+v", l)
+	}
+
+	// Into another board without cards
+	swimlaneID, err := CloneSwimlane(db, 1, CloneOptions{ParentID: 2, WithoutCards: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := LoadSwimlane(db, swimlaneID)
+	if s.Name != "Development" || s.BoardID != 2 || s.Position != 1 || len(s.Lists) != 3 || s.BackgroundImage != "lane.png" {
+		t.Errorf("cloned swimlane %+v", s)
+	}
+	for _, l := range s.Lists {
+		if len(l.Cards) != 0 {
+			t.Errorf("list %q copied with cards", l.Name)
+		}
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM cards c JOIN lists l ON l.id = c.list_id WHERE l.swimlane_id = ?", swimlaneID).Scan(&n)
+	if n != 0 {
+		t.Errorf("%d cards copied, archived included", n)
+	}
+
+	// Name and text rewriting
+	fill := func(s string) string { return strings.ReplaceAll(s, "First", "Erste") }
+	cardID, err := CloneCard(db, 2, CloneOptions{Text: fill})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := LoadCard(db, cardID); c.Title != "Erste (Copy)" || c.Description != "With file" {
+		t.Errorf("rewritten card %+v", c)
+	}
+	listID, err = CloneList(db, 1, CloneOptions{Name: "Sprint 12", Text: fill})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := LoadList(db, listID); l.Name != "Sprint 12" || len(l.Cards) != 2 || l.Cards[0].Title != "Erste" {
+		t.Errorf("named list %+v", l)
+	}
+
+	if _, err := CloneCard(db, 99, CloneOptions{}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("CloneCard(99) error %v, want sql.ErrNoRows", err)
+	}
+}
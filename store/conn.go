@@ -0,0 +1,66 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// BusyTimeout is how long a statement waits for a lock held by another
+// connection, such as a second GUI, a backup or an export, before failing
+// with "database is locked".
+const BusyTimeout = 5 * time.Second
+
+// Open opens the database at path, creating it if needed, with the
+// settings every program must use:
+//
+//   - foreign keys are enforced on every connection of the pool, so that
+//     deleting a board deletes its swimlanes, lists, cards, rules and saved
+//     filters
+//   - the database is in WAL mode, so that readers do not block the writer
+//   - statements wait BusyTimeout for locks
+//
+// path is a file name, not a "file:" URI; it may contain "?" and "#".
+func Open(path string) (*sql.DB, error) {
+	query := fmt.Sprintf("_foreign_keys=on&_journal_mode=WAL&_busy_timeout=%d", BusyTimeout.Milliseconds())
+	return open(path, uri(path, query))
+}
+
+// OpenReadOnly opens an existing database at path for reading, with the
+// busy timeout of Open. It does not change the journal mode.
+func OpenReadOnly(path string) (*sql.DB, error) {
+	query := fmt.Sprintf("mode=ro&_foreign_keys=on&_busy_timeout=%d", BusyTimeout.Milliseconds())
+	return open(path, uri(path, query))
+}
+
+// uri returns the "file:" URI of the database at path with the given
+// query, escaping the characters of path that would end the file name.
+func uri(path, query string) string {
+	if path == ":memory:" {
+		return "file::memory:?" + query
+	}
+	path = filepath.ToSlash(path)
+	// SQLite wants "file:///C:/..." for a path with a drive letter
+	if filepath.VolumeName(path) != "" {
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Path: path, RawQuery: query}
+	return u.String()
+}
+
+func open(path, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	// sql.Open does not connect; report a file that cannot be opened here
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return db, nil
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fkData are two boards with swimlanes, lists, cards and saved filters.
+const fkData = `
+	INSERT INTO boards (id, name) VALUES (1, 'Board'), (2, 'Other');
+	INSERT INTO swimlanes (id, board_id, name) VALUES (1, 1, 'Lane'), (2, 2, 'Other lane');
+	INSERT INTO lists (id, swimlane_id, name) VALUES (1, 1, 'Todo'), (2, 1, 'Done'), (3, 2, 'Other list');
+	INSERT INTO cards (id, list_id, title) VALUES (1, 1, 'One'), (2, 1, 'Two'), (3, 2, 'Three'), (4, 3, 'Other card');
+	INSERT INTO saved_filters (board_id, name, filter) VALUES (1, 'Mine', '{}'), (2, 'Theirs', '{}');
+	INSERT INTO rules (board_id, name, rule) VALUES (1, 'Rule', '{}');
+`
+
+// openFKDB creates a database with the schema of Init, whose tables have
+// foreign keys, and fkData.
+func openFKDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "wekan.db")
+	db := openEmptyDBAt(t, path)
+	if _, err := db.Exec(fkData); err != nil {
+		t.Fatal(err)
+	}
+	return db, path
+}
+
+func count(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestOpenSettings(t *testing.T) {
+	db, path := openFKDB(t)
+
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode %q, %v", mode, err)
+	}
+	// Every connection of the pool, not only the first
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		var fk, timeout int
+		conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&fk)
+		conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&timeout)
+		if fk != 1 || timeout != int(BusyTimeout.Milliseconds()) {
+			t.Errorf("connection %d: foreign_keys %d, busy_timeout %d", i, fk, timeout)
+		}
+	}
+
+	ro, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if n := count(t, ro, "cards"); n != 4 {
+		t.Errorf("read-only: %d cards", n)
+	}
+	if _, err := ro.Exec("DELETE FROM cards"); err == nil {
+		t.Error("wrote through a read-only connection")
+	}
+	if _, err := OpenReadOnly(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("opened a missing database read-only")
+	}
+}
+
+func TestDeleteCascades(t *testing.T) {
+	db, _ := openFKDB(t)
+
+	if _, err := db.Exec("DELETE FROM lists WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, db, "cards"); n != 2 {
+		t.Errorf("after deleting a list: %d cards, want 2", n)
+	}
+	if _, err := db.Exec("DELETE FROM boards WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	for table, want := range map[string]int{"swimlanes": 1, "lists": 1, "cards": 1, "saved_filters": 1, "rules": 0} {
+		if n := count(t, db, table); n != want {
+			t.Errorf("after deleting a board: %d %s, want %d", n, table, want)
+		}
+	}
+
+	if _, err := db.Exec("INSERT INTO cards (list_id, title) VALUES (99, 'Nowhere')"); err == nil {
+		t.Error("inserted a card into a missing list")
+	}
+}
+
+func TestBusyTimeout(t *testing.T) {
+	db, path := openFKDB(t)
+
+	// Another program holds the write lock for a moment
+	other, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	tx, err := other.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("UPDATE boards SET name = 'Locked' WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		tx.Commit()
+	}()
+	if _, err := db.Exec("UPDATE boards SET name = 'Waited' WHERE id = 2"); err != nil {
+		t.Errorf("write while locked: %v", err)
+	}
+}
+
+func TestRepairOrphans(t *testing.T) {
+	db, path := openFKDB(t)
+
+	// Deletes of a program without foreign keys, as before they were
+	// enforced
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	old.SetMaxOpenConns(1)
+	if _, err := old.Exec("PRAGMA foreign_keys = OFF; DELETE FROM boards WHERE id = 1; DELETE FROM lists WHERE id = 3"); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := FindOrphans(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFound := []Orphan{
+		{"swimlanes", 1, "boards", 1}, {"rules", 1, "boards", 1}, {"saved_filters", 1, "boards", 1},
+		{"cards", 4, "lists", 3},
+	}
+	if !reflect.DeepEqual(found, wantFound) {
+		t.Errorf("found %v, want %v", found, wantFound)
+	}
+
+	orphans, err := RepairOrphans(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Orphans{"swimlanes": 1, "lists": 2, "cards": 4, "saved_filters": 1, "rules": 1}
+	if orphans.String() != want.String() || orphans.Total() != 9 {
+		t.Errorf("repaired %v, want %v", orphans, want)
+	}
+	for table, want := range map[string]int{"boards": 1, "swimlanes": 1, "lists": 0, "cards": 0, "saved_filters": 1, "rules": 0} {
+		if n := count(t, db, table); n != want {
+			t.Errorf("%d %s, want %d", n, table, want)
+		}
+	}
+
+	if orphans, err := RepairOrphans(db); err != nil || orphans.Total() != 0 {
+		t.Errorf("second repair: %v, %v", orphans, err)
+	}
+}
+
+func TestOpenSpecialPath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"what?.db", "#1.db", "50% done.db"} {
+		path := filepath.Join(dir, name)
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := db.Exec("CREATE TABLE boards (id INTEGER PRIMARY KEY)"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		db.Close()
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		ro, err := OpenReadOnly(path)
+		if err != nil {
+			t.Fatalf("%s read-only: %v", name, err)
+		}
+		if n := count(t, ro, "boards"); n != 0 {
+			t.Errorf("%s: %d boards", name, n)
+		}
+		ro.Close()
+	}
+	// An unescaped path opens "what" or "" instead
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if n := strings.TrimSuffix(strings.TrimSuffix(e.Name(), "-wal"), "-shm"); n != "what?.db" && n != "#1.db" && n != "50% done.db" {
+			t.Errorf("created %q", e.Name())
+		}
+	}
+}
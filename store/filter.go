@@ -0,0 +1,239 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Filter selects cards on a board by text, color, creation date and
+// attachment presence. Empty fields are ignored; the others are combined
+// with AND or OR depending on Match.
+type Filter struct {
+	Match MatchMode `json:"match,omitempty"`
+	// Text matches a case-insensitive substring of the title or description.
+	Text            string `json:"text,omitempty"`
+	TextColor       string `json:"textColor,omitempty"`
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	// CreatedFrom and CreatedTo are inclusive dates in YYYY-MM-DD format.
+	CreatedFrom string           `json:"createdFrom,omitempty"`
+	CreatedTo   string           `json:"createdTo,omitempty"`
+	Attachment  AttachmentFilter `json:"attachment,omitempty"`
+	// Display says what happens to the cards that do not match.
+	Display DisplayMode `json:"display,omitempty"`
+}
+
+// MatchMode combines the conditions of a Filter.
+type MatchMode string
+
+const (
+	MatchAll MatchMode = "all" // AND, the default
+	MatchAny MatchMode = "any" // OR
+)
+
+// AttachmentFilter matches cards with or without an attachment.
+type AttachmentFilter string
+
+const (
+	AttachmentAny     AttachmentFilter = ""
+	AttachmentWith    AttachmentFilter = "with"
+	AttachmentWithout AttachmentFilter = "without"
+)
+
+// DisplayMode says how the board shows cards that do not match a filter.
+type DisplayMode string
+
+const (
+	DisplayHide DisplayMode = "hide" // the default
+	DisplayDim  DisplayMode = "dim"
+)
+
+// Active reports whether the filter has any condition.
+func (f Filter) Active() bool {
+	return len(f.conditions()) > 0
+}
+
+// Matches reports whether a card passes the filter. Every card passes a
+// filter without conditions.
+func (f Filter) Matches(c Card) bool {
+	conds := f.conditions()
+	if len(conds) == 0 {
+		return true
+	}
+	for _, cond := range conds {
+		ok := cond(c)
+		if f.Match == MatchAny && ok {
+			return true
+		}
+		if f.Match != MatchAny && !ok {
+			return false
+		}
+	}
+	return f.Match != MatchAny
+}
+
+func (f Filter) conditions() []func(Card) bool {
+	var conds []func(Card) bool
+	if text := strings.ToLower(strings.TrimSpace(f.Text)); text != "" {
+		conds = append(conds, func(c Card) bool {
+			return strings.Contains(strings.ToLower(c.Title), text) ||
+				strings.Contains(strings.ToLower(c.Description), text)
+		})
+	}
+	if color := normalizeColor(f.TextColor); color != "" {
+		conds = append(conds, func(c Card) bool { return normalizeColor(c.TextColor) == color })
+	}
+	if color := normalizeColor(f.BackgroundColor); color != "" {
+		conds = append(conds, func(c Card) bool { return normalizeColor(c.BackgroundColor) == color })
+	}
+	from, to := strings.TrimSpace(f.CreatedFrom), strings.TrimSpace(f.CreatedTo)
+	if from != "" || to != "" {
+		conds = append(conds, func(c Card) bool {
+			date := createdDate(c.CreatedAt)
+			return date != "" && (from == "" || date >= from) && (to == "" || date <= to)
+		})
+	}
+	switch f.Attachment {
+	case AttachmentWith:
+		conds = append(conds, func(c Card) bool { return c.AttachmentSize > 0 })
+	case AttachmentWithout:
+		conds = append(conds, func(c Card) bool { return c.AttachmentSize == 0 })
+	}
+	return conds
+}
+
+// Validate checks the dates and enumerations of a filter.
+func (f Filter) Validate() error {
+	for _, d := range []string{f.CreatedFrom, f.CreatedTo} {
+		d = strings.TrimSpace(d)
+		if d != "" && !isDate(d) {
+			return fmt.Errorf("invalid date %q, use YYYY-MM-DD", d)
+		}
+	}
+	switch f.Match {
+	case "", MatchAll, MatchAny:
+	default:
+		return fmt.Errorf("invalid match mode %q", f.Match)
+	}
+	switch f.Attachment {
+	case AttachmentAny, AttachmentWith, AttachmentWithout:
+	default:
+		return fmt.Errorf("invalid attachment filter %q", f.Attachment)
+	}
+	switch f.Display {
+	case "", DisplayHide, DisplayDim:
+	default:
+		return fmt.Errorf("invalid display mode %q", f.Display)
+	}
+	return nil
+}
+
+func normalizeColor(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s != "" && !strings.HasPrefix(s, "#") {
+		s = "#" + s
+	}
+	return s
+}
+
+// createdDate returns the YYYY-MM-DD part of a created_at value, which is
+// "2006-01-02 15:04:05" or RFC 3339 depending on the writer.
+func createdDate(s string) string {
+	if len(s) < 10 || !isDate(s[:10]) {
+		return ""
+	}
+	return s[:10]
+}
+
+func isDate(s string) bool {
+	if len(s) != 10 || s[4] != '-' || s[7] != '-' {
+		return false
+	}
+	for i, r := range s {
+		if i != 4 && i != 7 && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// SavedFilter is a named filter stored for a board.
+type SavedFilter struct {
+	ID      int
+	BoardID int
+	Name    string
+	Filter  Filter
+}
+
+const savedFiltersTable = `
+	CREATE TABLE IF NOT EXISTS saved_filters (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		board_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		filter TEXT NOT NULL,
+		UNIQUE (board_id, name),
+		FOREIGN KEY (board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);
+`
+
+// EnsureSavedFilters creates the saved_filters table if it is missing.
+func EnsureSavedFilters(db *sql.DB) error {
+	if _, err := db.Exec(savedFiltersTable); err != nil {
+		return fmt.Errorf("create saved_filters: %w", err)
+	}
+	return nil
+}
+
+// SaveFilter stores a filter under a name for a board, replacing a filter
+// with the same name.
+func SaveFilter(db *sql.DB, boardID int, name string, f Filter) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("save filter: empty name")
+	}
+	if err := f.Validate(); err != nil {
+		return fmt.Errorf("save filter %q: %w", name, err)
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("save filter %q: %w", name, err)
+	}
+	_, err = db.Exec(`INSERT INTO saved_filters (board_id, name, filter) VALUES (?, ?, ?)
+		ON CONFLICT (board_id, name) DO UPDATE SET filter = excluded.filter`, boardID, name, string(data))
+	if err != nil {
+		return fmt.Errorf("save filter %q: %w", name, err)
+	}
+	return nil
+}
+
+// SavedFilters returns the filters saved for a board, ordered by name.
+func SavedFilters(db *sql.DB, boardID int) ([]SavedFilter, error) {
+	rows, err := db.Query("SELECT id, board_id, name, filter FROM saved_filters WHERE board_id = ? ORDER BY name", boardID)
+	if err != nil {
+		return nil, fmt.Errorf("load saved filters: %w", err)
+	}
+	defer rows.Close()
+
+	var filters []SavedFilter
+	for rows.Next() {
+		var sf SavedFilter
+		var data string
+		if err := rows.Scan(&sf.ID, &sf.BoardID, &sf.Name, &data); err != nil {
+			return nil, fmt.Errorf("scan saved filter: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &sf.Filter); err != nil {
+			return nil, fmt.Errorf("saved filter %q: %w", sf.Name, err)
+		}
+		filters = append(filters, sf)
+	}
+	return filters, rows.Err()
+}
+
+// DeleteFilter removes a saved filter of a board.
+func DeleteFilter(db *sql.DB, boardID int, name string) error {
+	if _, err := db.Exec("DELETE FROM saved_filters WHERE board_id = ? AND name = ?", boardID, name); err != nil {
+		return fmt.Errorf("delete filter %q: %w", name, err)
+	}
+	return nil
+}
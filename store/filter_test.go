@@ -0,0 +1,102 @@
+package store
+
+import "testing"
+
+func TestFilterMatches(t *testing.T) {
+	plain := Card{Title: "Write exporter", Description: "PDF output", CreatedAt: "2025-03-10 12:00:00"}
+	colored := Card{Title: "Fix crash", TextColor: "#ffffff", BackgroundColor: "#FF0000", CreatedAt: "2025-04-01T09:30:00Z", AttachmentSize: 10}
+	undated := Card{Title: "Undated"}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []bool // plain, colored, undated
+	}{
+		{"empty", Filter{}, []bool{true, true, true}},
+		{"text in description", Filter{Text: " pdf "}, []bool{true, false, false}},
+		{"text color without #", Filter{TextColor: "FFFFFF"}, []bool{false, true, false}},
+		{"background color", Filter{BackgroundColor: "#ff0000"}, []bool{false, true, false}},
+		{"created from", Filter{CreatedFrom: "2025-04-01"}, []bool{false, true, false}},
+		{"created to", Filter{CreatedTo: "2025-03-10"}, []bool{true, false, false}},
+		{"created range", Filter{CreatedFrom: "2025-03-01", CreatedTo: "2025-03-31"}, []bool{true, false, false}},
+		{"with attachment", Filter{Attachment: AttachmentWith}, []bool{false, true, false}},
+		{"without attachment", Filter{Attachment: AttachmentWithout}, []bool{true, false, true}},
+		{"all", Filter{Text: "crash", Attachment: AttachmentWithout}, []bool{false, false, false}},
+		{"any", Filter{Match: MatchAny, Text: "crash", Attachment: AttachmentWithout}, []bool{true, true, true}},
+		{"any of text and color", Filter{Match: MatchAny, Text: "exporter", BackgroundColor: "#FF0000"}, []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		for i, c := range []Card{plain, colored, undated} {
+			if got := tt.filter.Matches(c); got != tt.want[i] {
+				t.Errorf("%s: Matches(%q) = %v, want %v", tt.name, c.Title, got, tt.want[i])
+			}
+		}
+	}
+	if (Filter{Display: DisplayDim, Match: MatchAny}).Active() {
+		t.Error("filter without conditions is active")
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	for _, f := range []Filter{
+		{CreatedFrom: "2025-3-1"},
+		{CreatedTo: "yesterday"},
+		{Match: "some"},
+		{Attachment: "maybe"},
+		{Display: "blink"},
+	} {
+		if err := f.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", f)
+		}
+	}
+	if err := (Filter{CreatedFrom: "2025-03-01", Match: MatchAny, Attachment: AttachmentWith, Display: DisplayDim}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSavedFilters(t *testing.T) {
+	db := openTestDB(t)
+	urgent := Filter{BackgroundColor: "#FF0000", Display: DisplayDim}
+	if err := SaveFilter(db, 1, "Urgent", urgent); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveFilter(db, 1, "Files", Filter{Attachment: AttachmentWith}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveFilter(db, 2, "Urgent", Filter{Text: "other board"}); err != nil {
+		t.Fatal(err)
+	}
+	// Saving under an existing name replaces the filter
+	urgent.Match = MatchAny
+	if err := SaveFilter(db, 1, "Urgent", urgent); err != nil {
+		t.Fatal(err)
+	}
+
+	filters, err := SavedFilters(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filters) != 2 || filters[0].Name != "Files" || filters[1].Name != "Urgent" {
+		t.Fatalf("saved filters %+v, want Files and Urgent", filters)
+	}
+	if filters[1].Filter != urgent || filters[1].BoardID != 1 {
+		t.Errorf("Urgent filter %+v, want %+v", filters[1].Filter, urgent)
+	}
+
+	if err := DeleteFilter(db, 1, "Urgent"); err != nil {
+		t.Fatal(err)
+	}
+	if filters, _ := SavedFilters(db, 1); len(filters) != 1 {
+		t.Errorf("after delete: %+v", filters)
+	}
+	if filters, _ := SavedFilters(db, 2); len(filters) != 1 || filters[0].Filter.Text != "other board" {
+		t.Errorf("board 2 filters %+v", filters)
+	}
+
+	if err := SaveFilter(db, 1, "  ", urgent); err == nil {
+		t.Error("saved a filter without a name")
+	}
+	if err := SaveFilter(db, 1, "Bad", Filter{CreatedTo: "tomorrow"}); err == nil {
+		t.Error("saved an invalid filter")
+	}
+}
@@ -0,0 +1,756 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Storage keeping the boards in memory, for tests. It is safe
+// for concurrent use.
+type Memory struct {
+	mu sync.Mutex
+	// items are the boards, swimlanes, lists and cards by ID, per depth
+	items [4]map[int]*memItem
+	// lastIDs are the last IDs given per depth; like AUTOINCREMENT, IDs
+	// are not reused
+	lastIDs [4]int
+	// rules are the rules of all boards in the order of their IDs
+	rules      []Rule
+	lastRuleID int
+}
+
+var _ Storage = (*Memory)(nil)
+
+// memItem is a board (depth 0), swimlane, list or card (depth 3).
+type memItem struct {
+	id, parent, position int
+	// name is the title of a card
+	name, description string
+	createdAt         string
+	// textColor, backgroundColor and backgroundImage are set on swimlanes,
+	// lists and cards; cards have no background image
+	textColor, backgroundColor, backgroundImage string
+	wipLimit                                    int
+	wipHard                                     bool
+	// attachment, archived and archiveAt are set on cards; archiveAt is
+	// zero when no archive is scheduled
+	attachment []byte
+	archived   bool
+	archiveAt  time.Time
+	isTemplate bool
+}
+
+// memTables name the depths in errors.
+var memTables = [4]string{"boards", "swimlanes", "lists", "cards"}
+
+// NewMemory returns an empty Storage.
+func NewMemory() *Memory {
+	m := &Memory{}
+	for depth := range m.items {
+		m.items[depth] = make(map[int]*memItem)
+	}
+	return m
+}
+
+func (m *Memory) Boards() ([]Board, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var boards []Board
+	for _, it := range m.items[0] {
+		boards = append(boards, board(it))
+	}
+	sort.Slice(boards, func(i, j int) bool {
+		if boards[i].Name != boards[j].Name {
+			return boards[i].Name < boards[j].Name
+		}
+		return boards[i].ID < boards[j].ID
+	})
+	return boards, nil
+}
+
+func (m *Memory) Board(id int) (*Board, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[0][id]
+	if !ok {
+		return nil, fmt.Errorf("load board %d: %w", id, sql.ErrNoRows)
+	}
+	b := board(it)
+	return &b, nil
+}
+
+func (m *Memory) CreateBoard(name, description string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.add(0, &memItem{name: name, description: description, createdAt: now()}), nil
+}
+
+func (m *Memory) UpdateBoard(id int, name, description string) error {
+	return m.update(0, id, func(it *memItem) { it.name, it.description = name, description })
+}
+
+func (m *Memory) DeleteBoard(id int) error {
+	return m.delete(0, id)
+}
+
+func (m *Memory) CloneBoard(id int, opts CloneOptions) (int, error) {
+	opts.ParentID = 0
+	return m.clone(0, id, opts)
+}
+
+func (m *Memory) LoadBoard(id int) ([]Swimlane, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tree []Swimlane
+	for _, s := range m.children(1, id) {
+		tree = append(tree, m.swimlane(s))
+	}
+	return tree, nil
+}
+
+func (m *Memory) CreateSwimlane(boardID int, name string) (int, error) {
+	return m.create(1, boardID, &memItem{name: name})
+}
+
+func (m *Memory) RenameSwimlane(id int, name string) error {
+	return m.update(1, id, func(it *memItem) { it.name = name })
+}
+
+func (m *Memory) DeleteSwimlane(id int) error {
+	return m.delete(1, id)
+}
+
+func (m *Memory) CloneSwimlane(id int, opts CloneOptions) (int, error) {
+	return m.clone(1, id, opts)
+}
+
+func (m *Memory) MoveSwimlanes(ids []int, t Target) error {
+	_, err := m.place(1, ids, t, false)
+	return err
+}
+
+func (m *Memory) CopySwimlanes(ids []int, t Target) ([]int, error) {
+	return m.place(1, ids, t, true)
+}
+
+func (m *Memory) LoadSwimlane(id int) (*Swimlane, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[1][id]
+	if !ok {
+		return nil, fmt.Errorf("load swimlane %d: %w", id, sql.ErrNoRows)
+	}
+	s := m.swimlane(it)
+	return &s, nil
+}
+
+func (m *Memory) SetSwimlaneColors(id int, text, background, image string) error {
+	return m.update(1, id, func(it *memItem) { it.textColor, it.backgroundColor, it.backgroundImage = text, background, image })
+}
+
+func (m *Memory) CreateList(swimlaneID int, name string) (int, error) {
+	return m.create(2, swimlaneID, &memItem{name: name})
+}
+
+func (m *Memory) RenameList(id int, name string) error {
+	return m.update(2, id, func(it *memItem) { it.name = name })
+}
+
+func (m *Memory) DeleteList(id int) error {
+	return m.delete(2, id)
+}
+
+func (m *Memory) CloneList(id int, opts CloneOptions) (int, error) {
+	return m.clone(2, id, opts)
+}
+
+func (m *Memory) MoveLists(ids []int, t Target) error {
+	_, err := m.place(2, ids, t, false)
+	return err
+}
+
+func (m *Memory) CopyLists(ids []int, t Target) ([]int, error) {
+	return m.place(2, ids, t, true)
+}
+
+func (m *Memory) LoadList(id int) (*List, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[2][id]
+	if !ok {
+		return nil, fmt.Errorf("load list %d: %w", id, sql.ErrNoRows)
+	}
+	l := m.list(it)
+	return &l, nil
+}
+
+func (m *Memory) SetListColors(id int, text, background, image string) error {
+	return m.update(2, id, func(it *memItem) { it.textColor, it.backgroundColor, it.backgroundImage = text, background, image })
+}
+
+func (m *Memory) SetWIPLimit(listID, limit int, hard bool) error {
+	if limit < 0 {
+		return fmt.Errorf("set WIP limit of list %d: negative limit %d", listID, limit)
+	}
+	return m.update(2, listID, func(it *memItem) { it.wipLimit, it.wipHard = limit, hard })
+}
+
+func (m *Memory) CreateCard(listID int, title, description string) (int, error) {
+	id, err := m.create(3, listID, &memItem{name: title, description: description, createdAt: now()})
+	if err != nil {
+		return 0, err
+	}
+	m.runRules(EventCreated, []int{id}, nil)
+	return id, nil
+}
+
+func (m *Memory) UpdateCard(id int, title, description string) error {
+	return m.update(3, id, func(it *memItem) { it.name, it.description = title, description })
+}
+
+func (m *Memory) DeleteCard(id int) error {
+	return m.delete(3, id)
+}
+
+func (m *Memory) CloneCard(id int, opts CloneOptions) (int, error) {
+	newID, err := m.clone(3, id, opts)
+	if err != nil {
+		return 0, err
+	}
+	m.runRules(EventCreated, []int{newID}, nil)
+	return newID, nil
+}
+
+func (m *Memory) MoveCards(ids []int, t Target) error {
+	from := make(map[int]int)
+	m.mu.Lock()
+	for _, id := range ids {
+		if it, ok := m.items[3][id]; ok {
+			from[id] = it.parent
+		}
+	}
+	m.mu.Unlock()
+	if _, err := m.place(3, ids, t, false); err != nil {
+		return err
+	}
+	m.runRules(EventMoved, ids, from)
+	return nil
+}
+
+func (m *Memory) CopyCards(ids []int, t Target) ([]int, error) {
+	newIDs, err := m.place(3, ids, t, true)
+	if err != nil {
+		return nil, err
+	}
+	m.runRules(EventCreated, newIDs, nil)
+	return newIDs, nil
+}
+
+func (m *Memory) LoadCard(id int) (*Card, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[3][id]
+	if !ok {
+		return nil, fmt.Errorf("load card %d: %w", id, sql.ErrNoRows)
+	}
+	c := card(it)
+	return &c, nil
+}
+
+func (m *Memory) SetCardColors(id int, text, background string) error {
+	return m.update(3, id, func(it *memItem) { it.textColor, it.backgroundColor = text, background })
+}
+
+func (m *Memory) Attachment(cardID int) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[3][cardID]
+	if !ok {
+		return nil, fmt.Errorf("load attachment of card %d: %w", cardID, sql.ErrNoRows)
+	}
+	return it.attachment, nil
+}
+
+func (m *Memory) SetAttachment(cardID int, data []byte) error {
+	if data != nil {
+		data = slices.Clone(data)
+	}
+	return m.update(3, cardID, func(it *memItem) { it.attachment = data })
+}
+
+func (m *Memory) ArchiveCard(id int) error {
+	return m.update(3, id, func(it *memItem) { it.archived, it.archiveAt = true, time.Time{} })
+}
+
+func (m *Memory) ScheduleArchive(id int, at time.Time) error {
+	// Stored to the second, as in the database
+	return m.update(3, id, func(it *memItem) { it.archiveAt = at.Truncate(time.Second) })
+}
+
+func (m *Memory) ArchiveDue(now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now = now.Truncate(time.Second)
+	n := 0
+	for _, it := range m.items[3] {
+		if !it.archiveAt.IsZero() && !it.archiveAt.After(now) {
+			it.archived, it.archiveAt = true, time.Time{}
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) IsTemplate(kind TemplateKind, id int) (bool, error) {
+	depth, ok := templateDepth(kind)
+	if !ok {
+		return false, fmt.Errorf("check template: invalid kind %q", kind)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[depth][id]
+	if !ok {
+		return false, fmt.Errorf("check template %s %d: %w", kind, id, sql.ErrNoRows)
+	}
+	return it.isTemplate, nil
+}
+
+func (m *Memory) SetTemplate(kind TemplateKind, id int, on bool) error {
+	depth, ok := templateDepth(kind)
+	if !ok {
+		return fmt.Errorf("set template: invalid kind %q", kind)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[depth][id]
+	if !ok {
+		return fmt.Errorf("set template %s %d: %w", kind, id, sql.ErrNoRows)
+	}
+	it.isTemplate = on
+	return nil
+}
+
+func (m *Memory) Templates(kind TemplateKind) ([]Template, error) {
+	depth, ok := templateDepth(kind)
+	if !ok {
+		return nil, fmt.Errorf("load templates: invalid kind %q", kind)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var templates []Template
+	for _, it := range m.items[depth] {
+		if !it.isTemplate {
+			continue
+		}
+		b := it
+		for d := depth; d > 0; d-- {
+			b = m.items[d-1][b.parent]
+		}
+		templates = append(templates, Template{Kind: kind, ID: it.id, Name: it.name, Board: b.name})
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		a, b := templates[i], templates[j]
+		if a.Board != b.Board {
+			return a.Board < b.Board
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	return templates, nil
+}
+
+func (m *Memory) Rules(boardID int) ([]Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var rules []Rule
+	for _, r := range m.rules {
+		if r.BoardID == boardID {
+			r.Action.Items = slices.Clone(r.Action.Items)
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+func (m *Memory) SaveRule(r *Rule) error {
+	if err := r.prepare(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *r
+	saved.Action.Items = slices.Clone(r.Action.Items)
+	if r.ID == 0 {
+		if _, ok := m.items[0][r.BoardID]; !ok {
+			return fmt.Errorf("save rule %q: %w", r.Name, sql.ErrNoRows)
+		}
+		m.lastRuleID++
+		r.ID, saved.ID = m.lastRuleID, m.lastRuleID
+		m.rules = append(m.rules, saved)
+		return nil
+	}
+	for i := range m.rules {
+		if m.rules[i].ID == r.ID {
+			// The board of a rule does not change, as with UPDATE
+			saved.BoardID = m.rules[i].BoardID
+			m.rules[i] = saved
+			return nil
+		}
+	}
+	return fmt.Errorf("save rule %q: %w", r.Name, sql.ErrNoRows)
+}
+
+func (m *Memory) DeleteRule(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = slices.DeleteFunc(m.rules, func(r Rule) bool { return r.ID == id })
+	return nil
+}
+
+// runRules runs the rules of an event on cards as RunRules does; from are
+// the lists that moved cards came from.
+func (m *Memory) runRules(event Event, ids []int, from map[int]int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, id := range ids {
+		c, ok := m.items[3][id]
+		if !ok {
+			continue
+		}
+		l := m.items[2][c.parent]
+		s := m.items[1][l.parent]
+		p := cardPlace{listID: l.id, swimlaneID: s.id, boardID: s.parent}
+		e := CardEvent{Event: event, CardID: id, FromListID: from[id]}
+		if event == EventMoved {
+			if e.FromListID == p.listID {
+				continue
+			}
+			c.archiveAt = time.Time{}
+		}
+		for _, r := range m.rules {
+			if r.BoardID == p.boardID && r.Enabled && r.Trigger.matches(e, p) {
+				applyMemAction(c, r.Action, now)
+			}
+		}
+	}
+}
+
+// applyMemAction applies an action to a card as applyAction does.
+func applyMemAction(c *memItem, a Action, now time.Time) {
+	switch a.Kind {
+	case ActionBackgroundColor:
+		c.backgroundColor = normalizeColor(a.Color)
+	case ActionTextColor:
+		c.textColor = normalizeColor(a.Color)
+	case ActionChecklist:
+		c.description = addChecklist(c.description, a.Items)
+	case ActionArchive:
+		if a.Days == 0 {
+			c.archived, c.archiveAt = true, time.Time{}
+		} else {
+			c.archiveAt = now.AddDate(0, 0, a.Days).Truncate(time.Second)
+		}
+	}
+}
+
+// templateDepth returns the depth of the items of a template kind.
+func templateDepth(kind TemplateKind) (int, bool) {
+	for depth, table := range memTables {
+		if templateTables[kind] == table {
+			return depth, true
+		}
+	}
+	return 0, false
+}
+
+// now returns the time as CURRENT_TIMESTAMP does.
+func now() string {
+	return time.Now().UTC().Format("2006-01-02 15:04:05")
+}
+
+func board(it *memItem) Board {
+	return Board{ID: it.id, Name: it.name, Description: it.description, CreatedAt: it.createdAt, IsTemplate: it.isTemplate}
+}
+
+func (m *Memory) swimlane(it *memItem) Swimlane {
+	s := Swimlane{ID: it.id, BoardID: it.parent, Name: it.name, Position: it.position, TextColor: it.textColor,
+		BackgroundColor: it.backgroundColor, BackgroundImage: it.backgroundImage, IsTemplate: it.isTemplate}
+	for _, l := range m.children(2, it.id) {
+		s.Lists = append(s.Lists, m.list(l))
+	}
+	return s
+}
+
+// list returns a list with its unarchived cards.
+func (m *Memory) list(it *memItem) List {
+	l := List{ID: it.id, SwimlaneID: it.parent, Name: it.name, Position: it.position, TextColor: it.textColor,
+		BackgroundColor: it.backgroundColor, BackgroundImage: it.backgroundImage, WIPLimit: it.wipLimit,
+		WIPHard: it.wipHard, IsTemplate: it.isTemplate}
+	for _, c := range m.children(3, it.id) {
+		if !c.archived {
+			l.Cards = append(l.Cards, card(c))
+		}
+	}
+	return l
+}
+
+func card(it *memItem) Card {
+	return Card{ID: it.id, ListID: it.parent, Title: it.name, Description: it.description, Position: it.position,
+		CreatedAt: it.createdAt, TextColor: it.textColor, BackgroundColor: it.backgroundColor,
+		AttachmentSize: len(it.attachment), IsTemplate: it.isTemplate}
+}
+
+// reset gives a copy the creation time of a new item, and makes it neither
+// a template nor archived, as resetColumns does.
+func (it *memItem) reset() {
+	if it.createdAt != "" {
+		it.createdAt = now()
+	}
+	it.isTemplate, it.archived, it.archiveAt = false, false, time.Time{}
+}
+
+// add stores an item under a new ID and returns the ID.
+func (m *Memory) add(depth int, it *memItem) int {
+	m.lastIDs[depth]++
+	it.id = m.lastIDs[depth]
+	m.items[depth][it.id] = it
+	return it.id
+}
+
+// children returns the items of a parent in position order.
+func (m *Memory) children(depth, parentID int) []*memItem {
+	var out []*memItem
+	for _, it := range m.items[depth] {
+		if it.parent == parentID {
+			out = append(out, it)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].position != out[j].position {
+			return out[i].position < out[j].position
+		}
+		return out[i].id < out[j].id
+	})
+	return out
+}
+
+// end returns the position after the items of a parent.
+func (m *Memory) end(depth, parentID int) int {
+	pos := 0
+	for _, it := range m.items[depth] {
+		if it.parent == parentID && it.position >= pos {
+			pos = it.position + 1
+		}
+	}
+	return pos
+}
+
+// renumber closes up the positions of the items of a parent.
+func (m *Memory) renumber(depth, parentID int) {
+	for pos, it := range m.children(depth, parentID) {
+		it.position = pos
+	}
+}
+
+func (m *Memory) create(depth, parentID int, it *memItem) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[depth-1][parentID]; !ok {
+		return 0, fmt.Errorf("create in %s %d: %w", memTables[depth-1], parentID, sql.ErrNoRows)
+	}
+	it.parent, it.position = parentID, m.end(depth, parentID)
+	return m.add(depth, it), nil
+}
+
+func (m *Memory) update(depth, id int, f func(*memItem)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[depth][id]
+	if !ok {
+		return fmt.Errorf("update %s %d: %w", memTables[depth], id, sql.ErrNoRows)
+	}
+	f(it)
+	return nil
+}
+
+func (m *Memory) delete(depth, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[depth][id]
+	if !ok {
+		return fmt.Errorf("delete %s %d: %w", memTables[depth], id, sql.ErrNoRows)
+	}
+	m.deleteTree(depth, id)
+	if depth > 0 {
+		m.renumber(depth, it.parent)
+	} else {
+		m.rules = slices.DeleteFunc(m.rules, func(r Rule) bool { return r.BoardID == id })
+	}
+	return nil
+}
+
+func (m *Memory) deleteTree(depth, id int) {
+	if depth+1 < len(m.items) {
+		for _, child := range m.children(depth+1, id) {
+			m.deleteTree(depth+1, child.id)
+		}
+	}
+	delete(m.items[depth], id)
+}
+
+func (m *Memory) clone(depth, id int, opts CloneOptions) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cloneLocked(depth, id, opts)
+}
+
+// cloneLocked copies an item as cloneTx does: the copy goes to the end of
+// its parent, and its children keep their positions.
+func (m *Memory) cloneLocked(depth, id int, opts CloneOptions) (int, error) {
+	it, ok := m.items[depth][id]
+	if !ok {
+		return 0, fmt.Errorf("clone %s %d: %w", memTables[depth], id, sql.ErrNoRows)
+	}
+	root := *it
+	root.reset()
+	sameParent := true
+	if depth > 0 && opts.ParentID != 0 && opts.ParentID != it.parent {
+		if _, ok := m.items[depth-1][opts.ParentID]; !ok {
+			return 0, fmt.Errorf("clone %s %d to %s %d: %w", memTables[depth], id, memTables[depth-1], opts.ParentID, sql.ErrNoRows)
+		}
+		root.parent, sameParent = opts.ParentID, false
+	}
+	if depth > 0 {
+		root.position = m.end(depth, root.parent)
+	}
+	if opts.Text != nil {
+		root.name, root.description = opts.Text(root.name), opts.Text(root.description)
+	}
+	switch {
+	case opts.Name != "":
+		root.name = opts.Name
+	case sameParent:
+		root.name += " (Copy)"
+	}
+	newID := m.add(depth, &root)
+	m.copyChildren(depth, id, newID, opts)
+	return newID, nil
+}
+
+// copyChildren copies the children of an item to its copy, in the order
+// of their IDs and skipping archived cards as cloneTx does.
+func (m *Memory) copyChildren(depth, id, newID int, opts CloneOptions) {
+	if depth+1 == len(m.items) || (opts.WithoutCards && depth+1 == 3) {
+		return
+	}
+	children := m.children(depth+1, id)
+	sort.Slice(children, func(i, j int) bool { return children[i].id < children[j].id })
+	for _, child := range children {
+		if child.archived && !opts.WithArchived {
+			continue
+		}
+		copied := *child
+		copied.reset()
+		copied.parent = newID
+		if opts.Text != nil {
+			copied.name, copied.description = opts.Text(copied.name), opts.Text(copied.description)
+		}
+		childID := m.add(depth+1, &copied)
+		m.copyChildren(depth+1, child.id, childID, opts)
+	}
+}
+
+// boardOrder returns the key ordering an item as place does: its board,
+// then the positions from its swimlane down, then its ID.
+func (m *Memory) boardOrder(depth int, it *memItem) []int {
+	var key []int
+	for d := depth; d > 0; d-- {
+		key = append([]int{it.position}, key...)
+		if d == 1 {
+			key = append([]int{it.parent}, key...)
+		} else {
+			it = m.items[d-1][it.parent]
+		}
+	}
+	return append(key, it.id)
+}
+
+// place moves or copies items to a target as the function of the same
+// name does.
+func (m *Memory) place(depth int, ids []int, t Target, copyItems bool) ([]int, error) {
+	verb := "move"
+	if copyItems {
+		verb = "copy"
+	}
+	table := memTables[depth]
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[depth-1][t.ParentID]; !ok {
+		return nil, fmt.Errorf("%s %s to %s %d: %w", verb, table, memTables[depth-1], t.ParentID, sql.ErrNoRows)
+	}
+	var items []*memItem
+	for _, id := range ids {
+		if it, ok := m.items[depth][id]; ok && !slices.Contains(items, it) {
+			items = append(items, it)
+		}
+	}
+	if len(items) != len(ids) {
+		return nil, fmt.Errorf("%s %s %v: %w", verb, table, ids, sql.ErrNoRows)
+	}
+	keys := make(map[*memItem][]int, len(items))
+	for _, it := range items {
+		keys[it] = m.boardOrder(depth, it)
+	}
+	sort.Slice(items, func(i, j int) bool { return slices.Compare(keys[items[i]], keys[items[j]]) < 0 })
+
+	var siblings []int
+	for _, it := range m.children(depth, t.ParentID) {
+		siblings = append(siblings, it.id)
+	}
+	ordered := make([]int, len(items))
+	oldParents := make(map[int]bool)
+	for i, it := range items {
+		ordered[i] = it.id
+		oldParents[it.parent] = true
+	}
+	order, err := insertBefore(siblings, ordered, t.BeforeID)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", verb, table, err)
+	}
+	if copyItems {
+		copies := make(map[int]int, len(ordered))
+		for i, id := range ordered {
+			newID, err := m.cloneLocked(depth, id, CloneOptions{ParentID: t.ParentID})
+			if err != nil {
+				return nil, err
+			}
+			copies[id], ordered[i] = newID, newID
+		}
+		// The copies go where the originals would have been moved
+		if order, err = insertBefore(siblings, ordered, t.BeforeID); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", verb, table, err)
+		}
+	}
+	for pos, id := range order {
+		it := m.items[depth][id]
+		it.parent, it.position = t.ParentID, pos
+	}
+	if !copyItems {
+		delete(oldParents, t.ParentID)
+		for parentID := range oldParents {
+			m.renumber(depth, parentID)
+		}
+		return nil, nil
+	}
+	return ordered, nil
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Target is where moved or copied items go: a board for swimlanes, a
+// swimlane for lists or a list for cards, on any board.
+type Target struct {
+	ParentID int
+	// BeforeID is the sibling to put the items in front of, 0 for the end
+	// of the parent.
+	BeforeID int
+}
+
+// level describes the table of a kind of item and of its parent.
+type level struct {
+	table, parentTable, parentColumn string
+	// order selects the IDs in %s in board order.
+	order string
+}
+
+var (
+	swimlaneLevel = level{"swimlanes", "boards", "board_id",
+		`SELECT s.id FROM swimlanes s WHERE s.id IN (%s)
+		ORDER BY s.board_id, s.position, s.id`}
+	listLevel = level{"lists", "swimlanes", "swimlane_id",
+		`SELECT l.id FROM lists l JOIN swimlanes s ON s.id = l.swimlane_id WHERE l.id IN (%s)
+		ORDER BY s.board_id, s.position, l.position, l.id`}
+	cardLevel = level{"cards", "lists", "list_id",
+		`SELECT c.id FROM cards c JOIN lists l ON l.id = c.list_id JOIN swimlanes s ON s.id = l.swimlane_id
+		WHERE c.id IN (%s) ORDER BY s.board_id, s.position, l.position, c.position, c.id`}
+)
+
+// MoveSwimlanes moves swimlanes with their lists and cards to a board.
+// The swimlanes keep their board order and are numbered in front of
+// t.BeforeID; the positions of the boards they leave are closed up.
+func MoveSwimlanes(db *sql.DB, ids []int, t Target) error {
+	_, err := place(db, swimlaneLevel, ids, t, false)
+	return err
+}
+
+// MoveLists moves lists with their cards to a swimlane, like MoveSwimlanes.
+func MoveLists(db *sql.DB, ids []int, t Target) error {
+	_, err := place(db, listLevel, ids, t, false)
+	return err
+}
+
+// MoveCards moves cards to a list, like MoveSwimlanes.
+func MoveCards(db *sql.DB, ids []int, t Target) error {
+	_, err := place(db, cardLevel, ids, t, false)
+	return err
+}
+
+// CopySwimlanes deep-copies swimlanes to a board, as CloneSwimlane does,
+// and puts the copies in front of t.BeforeID. It returns the IDs of the
+// copies in board order.
+func CopySwimlanes(db *sql.DB, ids []int, t Target) ([]int, error) {
+	return place(db, swimlaneLevel, ids, t, true)
+}
+
+// CopyLists deep-copies lists to a swimlane, like CopySwimlanes.
+func CopyLists(db *sql.DB, ids []int, t Target) ([]int, error) {
+	return place(db, listLevel, ids, t, true)
+}
+
+// CopyCards deep-copies cards to a list, like CopySwimlanes.
+func CopyCards(db *sql.DB, ids []int, t Target) ([]int, error) {
+	return place(db, cardLevel, ids, t, true)
+}
+
+// place moves or copies items to a target in one transaction and returns
+// the IDs of the copies.
+func place(db *sql.DB, lv level, ids []int, t Target, copyItems bool) ([]int, error) {
+	verb := "move"
+	if copyItems {
+		verb = "copy"
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", verb, lv.table, err)
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM "+lv.parentTable+" WHERE id = ?", t.ParentID).Scan(&n); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", verb, lv.table, err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%s %s to %s %d: %w", verb, lv.table, lv.parentTable, t.ParentID, sql.ErrNoRows)
+	}
+	items, err := orderIDs(tx, lv, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", verb, lv.table, err)
+	}
+	if len(items) != len(ids) {
+		return nil, fmt.Errorf("%s %s %v: %w", verb, lv.table, ids, sql.ErrNoRows)
+	}
+
+	siblings, err := childIDs(tx, lv, t.ParentID)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", verb, lv.table, err)
+	}
+	oldParents := make(map[int]bool)
+	if copyItems {
+		for i, id := range items {
+			if items[i], err = cloneTx(tx, lv.table, lv.parentColumn, id, CloneOptions{ParentID: t.ParentID}); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		for _, id := range items {
+			var parentID int
+			if err := tx.QueryRow("SELECT "+lv.parentColumn+" FROM "+lv.table+" WHERE id = ?", id).Scan(&parentID); err != nil {
+				return nil, fmt.Errorf("%s %s %d: %w", verb, lv.table, id, err)
+			}
+			oldParents[parentID] = true
+		}
+	}
+
+	order, err := insertBefore(siblings, items, t.BeforeID)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", verb, lv.table, err)
+	}
+	for pos, id := range order {
+		_, err := tx.Exec("UPDATE "+lv.table+" SET "+lv.parentColumn+" = ?, position = ? WHERE id = ?", t.ParentID, pos, id)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s %d: %w", verb, lv.table, id, err)
+		}
+	}
+	delete(oldParents, t.ParentID)
+	for parentID := range oldParents {
+		if err := renumber(tx, lv, parentID); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", verb, lv.table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", verb, lv.table, err)
+	}
+	if !copyItems {
+		return nil, nil
+	}
+	return items, nil
+}
+
+// insertBefore returns siblings without items, with items inserted where
+// beforeID is, or at the end for 0. beforeID may be one of the items.
+func insertBefore(siblings, items []int, beforeID int) ([]int, error) {
+	moved := make(map[int]bool, len(items))
+	for _, id := range items {
+		moved[id] = true
+	}
+	order := make([]int, 0, len(siblings)+len(items))
+	inserted := false
+	for _, id := range siblings {
+		if id == beforeID {
+			order = append(order, items...)
+			inserted = true
+		}
+		if !moved[id] {
+			order = append(order, id)
+		}
+	}
+	if !inserted {
+		if beforeID != 0 {
+			return nil, fmt.Errorf("%d is not in the target: %w", beforeID, sql.ErrNoRows)
+		}
+		order = append(order, items...)
+	}
+	return order, nil
+}
+
+// orderIDs returns the existing ones of ids in board order.
+func orderIDs(tx *sql.Tx, lv level, ids []int) ([]int, error) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	return queryIDs(tx, fmt.Sprintf(lv.order, marks), args...)
+}
+
+// childIDs returns the items of a parent in position order.
+func childIDs(tx *sql.Tx, lv level, parentID int) ([]int, error) {
+	return queryIDs(tx, "SELECT id FROM "+lv.table+" WHERE "+lv.parentColumn+" = ? ORDER BY position, id", parentID)
+}
+
+// renumber closes up the positions of the items of a parent.
+func renumber(tx *sql.Tx, lv level, parentID int) error {
+	ids, err := childIDs(tx, lv, parentID)
+	if err != nil {
+		return err
+	}
+	for pos, id := range ids {
+		if _, err := tx.Exec("UPDATE "+lv.table+" SET position = ? WHERE id = ?", pos, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queryIDs(tx *sql.Tx, query string, args ...any) ([]int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
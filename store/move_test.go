@@ -0,0 +1,139 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// positions returns the IDs of the items of a parent in position order and
+// checks that the positions are numbered from 0.
+func positions(t *testing.T, db *sql.DB, table, parentColumn string, parentID int) []int {
+	t.Helper()
+	rows, err := db.Query("SELECT id, position FROM "+table+" WHERE "+parentColumn+" = ? ORDER BY position", parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id, pos int
+		if err := rows.Scan(&id, &pos); err != nil {
+			t.Fatal(err)
+		}
+		if pos != len(ids) {
+			t.Errorf("%s %d at position %d, want %d", table, id, pos, len(ids))
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestMove(t *testing.T) {
+	db := openTestDB(t)
+
+	// Cards keep their board order, whatever the order of the selection
+	if err := MoveCards(db, []int{1, 2}, Target{ParentID: 3, BeforeID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(t, db, "cards", "list_id", 3); !reflect.DeepEqual(got, []int{2, 1, 3}) {
+		t.Errorf("cards of list 3: %v", got)
+	}
+	if got := positions(t, db, "cards", "list_id", 1); len(got) != 0 {
+		t.Errorf("cards left in list 1: %v", got)
+	}
+
+	// Within a parent, before one of the moved items
+	if err := MoveCards(db, []int{3, 1}, Target{ParentID: 3, BeforeID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(t, db, "cards", "list_id", 3); !reflect.DeepEqual(got, []int{2, 1, 3}) {
+		t.Errorf("reordered cards of list 3: %v", got)
+	}
+
+	if err := MoveLists(db, []int{1}, Target{ParentID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(t, db, "lists", "swimlane_id", 3); !reflect.DeepEqual(got, []int{3, 1}) {
+		t.Errorf("lists of swimlane 3: %v", got)
+	}
+	if got := positions(t, db, "lists", "swimlane_id", 1); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("lists of swimlane 1: %v", got)
+	}
+
+	if err := MoveSwimlanes(db, []int{1}, Target{ParentID: 2, BeforeID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(t, db, "swimlanes", "board_id", 2); !reflect.DeepEqual(got, []int{1, 3}) {
+		t.Errorf("swimlanes of board 2: %v", got)
+	}
+	if got := positions(t, db, "swimlanes", "board_id", 1); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("swimlanes of board 1: %v", got)
+	}
+	if s, _ := LoadSwimlane(db, 1); len(s.Lists) != 1 || s.Lists[0].ID != 2 {
+		t.Errorf("moved swimlane %+v", s)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	db := openTestDB(t)
+
+	ids, err := CopyCards(db, []int{1, 2}, Target{ParentID: 3, BeforeID: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(t, db, "cards", "list_id", 3); !reflect.DeepEqual(got, append(ids, 3)) {
+		t.Errorf("cards of list 3: %v, copies %v", got, ids)
+	}
+	if c, _ := LoadCard(db, ids[0]); c.Title != "First" || c.Description != "With file" {
+		t.Errorf("copy of card 2: %+v", c)
+	}
+	var data []byte
+	db.QueryRow("SELECT attachment FROM cards WHERE id = ?", ids[0]).Scan(&data)
+	if string(data) != "attachment bytes" {
+		t.Errorf("copied attachment %q", data)
+	}
+	if got := positions(t, db, "cards", "list_id", 1); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("originals in list 1: %v", got)
+	}
+
+	// Copies in the same parent are named like clones
+	ids, err = CopyLists(db, []int{1}, Target{ParentID: 1, BeforeID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := LoadList(db, ids[0]); l.Name != "Todo (Copy)" || l.Position != 0 || len(l.Cards) != 2 {
+		t.Errorf("copied list %+v", l)
+	}
+
+	ids, err = CopySwimlanes(db, []int{1}, Target{ParentID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := LoadSwimlane(db, ids[0]); s.Name != "Development" || s.Position != 1 || len(s.Lists) != 3 {
+		t.Errorf("copied swimlane %+v", s)
+	}
+}
+
+func TestMoveErrors(t *testing.T) {
+	db := openTestDB(t)
+	for _, tt := range []struct {
+		name string
+		err  error
+	}{
+		{"missing list", MoveCards(db, []int{1}, Target{ParentID: 99})},
+		{"missing card", MoveCards(db, []int{1, 99}, Target{ParentID: 2})},
+		{"sibling elsewhere", MoveCards(db, []int{1}, Target{ParentID: 2, BeforeID: 3})},
+	} {
+		if !errors.Is(tt.err, sql.ErrNoRows) {
+			t.Errorf("%s: %v, want sql.ErrNoRows", tt.name, tt.err)
+		}
+	}
+	if got := positions(t, db, "cards", "list_id", 1); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("cards of list 1 after failed moves: %v", got)
+	}
+	if err := MoveCards(db, nil, Target{ParentID: 99}); err != nil {
+		t.Errorf("moving nothing: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Orphans counts the rows deleted by RepairOrphans, by table.
+type Orphans map[string]int
+
+// Total returns the number of deleted rows.
+func (o Orphans) Total() int {
+	n := 0
+	for _, count := range o {
+		n += count
+	}
+	return n
+}
+
+// String lists the counts by table, e.g. "3 cards, 1 lists".
+func (o Orphans) String() string {
+	tables := make([]string, 0, len(o))
+	for table := range o {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	parts := make([]string, len(tables))
+	for i, table := range tables {
+		parts[i] = fmt.Sprintf("%d %s", o[table], table)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Orphan is a row whose parent row is missing.
+type Orphan struct {
+	Table    string // e.g. "cards"
+	ID       int
+	Parent   string // the parent table, e.g. "lists"
+	ParentID int
+}
+
+// FindOrphans returns the rows whose parent row is missing, by parent
+// table, table and ID. The rows below them are not included. The relations
+// are those deleted by cascade, read from the schema as for CloneBoard.
+func FindOrphans(db *sql.DB) ([]Orphan, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("find orphans: %w", err)
+	}
+	defer tx.Rollback()
+	relations, err := readRelations(tx, true)
+	if err != nil {
+		return nil, fmt.Errorf("find orphans: %w", err)
+	}
+	orphans, err := findOrphans(tx, relations)
+	if err != nil {
+		return nil, fmt.Errorf("find orphans: %w", err)
+	}
+	return orphans, nil
+}
+
+func findOrphans(tx *sql.Tx, relations map[string][]relation) ([]Orphan, error) {
+	parents := make([]string, 0, len(relations))
+	for parent := range relations {
+		parents = append(parents, parent)
+	}
+	sort.Strings(parents)
+
+	var orphans []Orphan
+	for _, parent := range parents {
+		for _, rel := range relations[parent] {
+			rows, err := tx.Query(fmt.Sprintf("SELECT id, %s FROM %s WHERE %s NOT IN (SELECT id FROM %s) ORDER BY id",
+				rel.column, rel.table, rel.column, parent))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rel.table, err)
+			}
+			for rows.Next() {
+				o := Orphan{Table: rel.table, Parent: parent}
+				if err := rows.Scan(&o.ID, &o.ParentID); err != nil {
+					rows.Close()
+					return nil, fmt.Errorf("%s: %w", rel.table, err)
+				}
+				orphans = append(orphans, o)
+			}
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rel.table, err)
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// RepairOrphans deletes the rows left behind by deletes that did not
+// cascade, as they did not before foreign keys were enforced: swimlanes of
+// missing boards, lists of missing swimlanes, cards of missing lists, and
+// the rows of other tables whose parent is missing and would have been
+// deleted with it. Rows below an orphan are deleted too.
+func RepairOrphans(db *sql.DB) (Orphans, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("repair orphans: %w", err)
+	}
+	defer tx.Rollback()
+
+	relations, err := readRelations(tx, true)
+	if err != nil {
+		return nil, fmt.Errorf("repair orphans: %w", err)
+	}
+	found, err := findOrphans(tx, relations)
+	if err != nil {
+		return nil, fmt.Errorf("repair orphans: %w", err)
+	}
+
+	// The orphans, then their children down the tree
+	orphaned := make(map[string]map[int]bool)
+	var queue []Orphan
+	add := func(table string, ids ...int) {
+		for _, id := range ids {
+			if orphaned[table] == nil {
+				orphaned[table] = make(map[int]bool)
+			}
+			if !orphaned[table][id] {
+				orphaned[table][id] = true
+				queue = append(queue, Orphan{Table: table, ID: id})
+			}
+		}
+	}
+	for _, o := range found {
+		add(o.Table, o.ID)
+	}
+	for len(queue) > 0 {
+		o := queue[0]
+		queue = queue[1:]
+		for _, rel := range relations[o.Table] {
+			ids, err := queryIDs(tx, fmt.Sprintf("SELECT id FROM %s WHERE %s = ?", rel.table, rel.column), o.ID)
+			if err != nil {
+				return nil, fmt.Errorf("repair orphans: %s: %w", rel.table, err)
+			}
+			add(rel.table, ids...)
+		}
+	}
+
+	// Children may already be gone with their parent, by cascade
+	deleted := make(Orphans)
+	for table, ids := range orphaned {
+		for id := range ids {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE id = ?", id); err != nil {
+				return nil, fmt.Errorf("repair orphans: %s %d: %w", table, id, err)
+			}
+		}
+		deleted[table] = len(ids)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("repair orphans: %w", err)
+	}
+	return deleted, nil
+}
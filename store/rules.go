@@ -0,0 +1,337 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rule is a board automation: when a card event matches Trigger, Action is
+// applied to the card.
+type Rule struct {
+	ID      int     `json:"-"`
+	BoardID int     `json:"-"`
+	Name    string  `json:"-"`
+	Enabled bool    `json:"-"`
+	Trigger Trigger `json:"trigger"`
+	Action  Action  `json:"action"`
+}
+
+// Event is a change to a card that rules react to.
+type Event string
+
+const (
+	EventCreated Event = "created" // a card was added to a list
+	EventMoved   Event = "moved"   // a card was moved into another list
+)
+
+// Trigger selects the events of a rule. A zero ListID or SwimlaneID matches
+// any list or swimlane of the board.
+type Trigger struct {
+	Event      Event `json:"event"`
+	SwimlaneID int   `json:"swimlaneId,omitempty"`
+	ListID     int   `json:"listId,omitempty"`
+}
+
+// ActionKind is what a rule does to the card.
+type ActionKind string
+
+const (
+	ActionBackgroundColor ActionKind = "backgroundColor"
+	ActionTextColor       ActionKind = "textColor"
+	ActionChecklist       ActionKind = "checklist"
+	ActionArchive         ActionKind = "archive"
+)
+
+// Action is applied to the card of a matching event. Color is used by the
+// color actions, Items by ActionChecklist and Days by ActionArchive.
+type Action struct {
+	Kind  ActionKind `json:"kind"`
+	Color string     `json:"color,omitempty"`
+	// Items are appended to the card description as "- [ ] item" lines,
+	// skipping the ones already there.
+	Items []string `json:"items,omitempty"`
+	// Days until the card is archived by ArchiveDue, 0 for right away.
+	Days int `json:"days,omitempty"`
+}
+
+// Validate checks the event, action and action arguments of a rule.
+func (r Rule) Validate() error {
+	switch r.Trigger.Event {
+	case EventCreated, EventMoved:
+	default:
+		return fmt.Errorf("invalid event %q", r.Trigger.Event)
+	}
+	a := r.Action
+	switch a.Kind {
+	case ActionBackgroundColor, ActionTextColor:
+		if !isColor(normalizeColor(a.Color)) {
+			return fmt.Errorf("invalid color %q, use #RRGGBB", a.Color)
+		}
+	case ActionChecklist:
+		if len(checklistItems(a.Items)) == 0 {
+			return fmt.Errorf("checklist without items")
+		}
+	case ActionArchive:
+		if a.Days < 0 {
+			return fmt.Errorf("negative archive delay %d", a.Days)
+		}
+	default:
+		return fmt.Errorf("invalid action %q", a.Kind)
+	}
+	return nil
+}
+
+func isColor(s string) bool {
+	if len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	for _, r := range s[1:] {
+		if !strings.ContainsRune("0123456789ABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func checklistItems(items []string) []string {
+	var out []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// rulesTable stores the rules of the boards, with the trigger and action of
+// each rule as JSON.
+const rulesTable = `
+	CREATE TABLE IF NOT EXISTS rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		board_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		enabled INTEGER DEFAULT 1,
+		rule TEXT NOT NULL,
+		FOREIGN KEY (board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_rules_board ON rules (board_id);
+`
+
+// EnsureRules creates the rules table and adds the archived and archive_at
+// columns to cards if they are missing. Archived cards stay in the database
+// but are not loaded.
+func EnsureRules(db *sql.DB) error {
+	if _, err := db.Exec(rulesTable); err != nil {
+		return fmt.Errorf("create rules: %w", err)
+	}
+	if err := addColumn(db, "cards", "archived", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	return addColumn(db, "cards", "archive_at", "TIMESTAMP")
+}
+
+// prepare trims the name of a rule to be saved and validates the rule.
+func (r *Rule) prepare() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("save rule: empty name")
+	}
+	if err := r.Validate(); err != nil {
+		return fmt.Errorf("save rule %q: %w", r.Name, err)
+	}
+	return nil
+}
+
+// SaveRule inserts a rule, or updates it if it has an ID, and sets its ID.
+func SaveRule(db *sql.DB, r *Rule) error {
+	if err := r.prepare(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("save rule %q: %w", r.Name, err)
+	}
+	if r.ID == 0 {
+		res, err := db.Exec("INSERT INTO rules (board_id, name, enabled, rule) VALUES (?, ?, ?, ?)",
+			r.BoardID, r.Name, r.Enabled, string(data))
+		if err != nil {
+			return fmt.Errorf("save rule %q: %w", r.Name, err)
+		}
+		id, _ := res.LastInsertId()
+		r.ID = int(id)
+		return nil
+	}
+	res, err := db.Exec("UPDATE rules SET name = ?, enabled = ?, rule = ? WHERE id = ?",
+		r.Name, r.Enabled, string(data), r.ID)
+	if err != nil {
+		return fmt.Errorf("save rule %q: %w", r.Name, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("save rule %q: %w", r.Name, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// Rules returns the rules of a board in the order they were created, which
+// is the order they are applied in.
+func Rules(db *sql.DB, boardID int) ([]Rule, error) {
+	rows, err := db.Query("SELECT id, board_id, name, COALESCE(enabled, 1), rule FROM rules WHERE board_id = ? ORDER BY id", boardID)
+	if err != nil {
+		return nil, fmt.Errorf("load rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []Rule
+	for rows.Next() {
+		var r Rule
+		var data string
+		if err := rows.Scan(&r.ID, &r.BoardID, &r.Name, &r.Enabled, &data); err != nil {
+			return nil, fmt.Errorf("scan rule: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteRule removes a rule.
+func DeleteRule(db *sql.DB, ruleID int) error {
+	if _, err := db.Exec("DELETE FROM rules WHERE id = ?", ruleID); err != nil {
+		return fmt.Errorf("delete rule %d: %w", ruleID, err)
+	}
+	return nil
+}
+
+// CardEvent is a change to a card, reported after the change is stored.
+// FromListID is the list a moved card came from. Reordering cards within a
+// list is not an event.
+type CardEvent struct {
+	Event      Event
+	CardID     int
+	FromListID int
+}
+
+// cardPlace is where a card is on its board.
+type cardPlace struct {
+	listID, swimlaneID, boardID int
+}
+
+// matches reports whether a trigger fires for an event on a card at place.
+func (t Trigger) matches(e CardEvent, p cardPlace) bool {
+	return t.Event == e.Event &&
+		(t.ListID == 0 || t.ListID == p.listID) &&
+		(t.SwimlaneID == 0 || t.SwimlaneID == p.swimlaneID)
+}
+
+// RunRules applies the enabled rules of the card's board that match the
+// event, in order, and returns them. now dates the archive actions. Moving
+// a card to another list cancels an archive scheduled in the old list.
+func RunRules(db *sql.DB, e CardEvent, now time.Time) ([]Rule, error) {
+	var p cardPlace
+	err := db.QueryRow(`SELECT l.id, l.swimlane_id, s.board_id FROM cards c
+		JOIN lists l ON l.id = c.list_id
+		JOIN swimlanes s ON s.id = l.swimlane_id
+		WHERE c.id = ?`, e.CardID).Scan(&p.listID, &p.swimlaneID, &p.boardID)
+	if err != nil {
+		return nil, fmt.Errorf("run rules for card %d: %w", e.CardID, err)
+	}
+	if e.Event == EventMoved {
+		if e.FromListID == p.listID {
+			return nil, nil
+		}
+		if _, err := db.Exec("UPDATE cards SET archive_at = NULL WHERE id = ?", e.CardID); err != nil {
+			return nil, fmt.Errorf("run rules for card %d: %w", e.CardID, err)
+		}
+	}
+
+	rules, err := Rules(db, p.boardID)
+	if err != nil {
+		return nil, err
+	}
+	var applied []Rule
+	for _, r := range rules {
+		if !r.Enabled || !r.Trigger.matches(e, p) {
+			continue
+		}
+		if err := applyAction(db, e.CardID, r.Action, now); err != nil {
+			return applied, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		applied = append(applied, r)
+	}
+	return applied, nil
+}
+
+func applyAction(db *sql.DB, cardID int, a Action, now time.Time) error {
+	var err error
+	switch a.Kind {
+	case ActionBackgroundColor:
+		_, err = db.Exec("UPDATE cards SET background_color = ? WHERE id = ?", normalizeColor(a.Color), cardID)
+	case ActionTextColor:
+		_, err = db.Exec("UPDATE cards SET text_color = ? WHERE id = ?", normalizeColor(a.Color), cardID)
+	case ActionChecklist:
+		var desc string
+		if err := db.QueryRow("SELECT COALESCE(description, '') FROM cards WHERE id = ?", cardID).Scan(&desc); err != nil {
+			return err
+		}
+		_, err = db.Exec("UPDATE cards SET description = ? WHERE id = ?", addChecklist(desc, a.Items), cardID)
+	case ActionArchive:
+		if a.Days == 0 {
+			_, err = db.Exec("UPDATE cards SET archived = 1, archive_at = NULL WHERE id = ?", cardID)
+		} else {
+			at := now.UTC().AddDate(0, 0, a.Days).Format(timestampLayout)
+			_, err = db.Exec("UPDATE cards SET archive_at = ? WHERE id = ?", at, cardID)
+		}
+	default:
+		err = fmt.Errorf("invalid action %q", a.Kind)
+	}
+	return err
+}
+
+// timestampLayout is the format of CURRENT_TIMESTAMP, so that stored times
+// compare as strings.
+const timestampLayout = "2006-01-02 15:04:05"
+
+// addChecklist appends "- [ ] item" lines for the items that the
+// description does not list yet, checked or not.
+func addChecklist(desc string, items []string) string {
+	lines := strings.Split(desc, "\n")
+	has := make(map[string]bool)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		for _, box := range []string{"- [ ] ", "- [x] ", "- [X] "} {
+			if strings.HasPrefix(line, box) {
+				has[strings.TrimSpace(line[len(box):])] = true
+			}
+		}
+	}
+	var b strings.Builder
+	b.WriteString(desc)
+	for _, item := range checklistItems(items) {
+		if has[item] {
+			continue
+		}
+		has[item] = true
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+		b.WriteString("- [ ] " + item)
+	}
+	return b.String()
+}
+
+// ArchiveDue archives the cards whose scheduled archive time has passed and
+// returns their number.
+func ArchiveDue(db *sql.DB, now time.Time) (int, error) {
+	res, err := db.Exec("UPDATE cards SET archived = 1, archive_at = NULL WHERE archive_at IS NOT NULL AND archive_at <= ?",
+		now.UTC().Format(timestampLayout))
+	if err != nil {
+		return 0, fmt.Errorf("archive due cards: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
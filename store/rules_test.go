@@ -0,0 +1,185 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRuleValidate(t *testing.T) {
+	for _, r := range []Rule{
+		{Trigger: Trigger{Event: "deleted"}, Action: Action{Kind: ActionArchive}},
+		{Trigger: Trigger{Event: EventMoved}, Action: Action{Kind: "notify"}},
+		{Trigger: Trigger{Event: EventMoved}, Action: Action{Kind: ActionBackgroundColor, Color: "red"}},
+		{Trigger: Trigger{Event: EventMoved}, Action: Action{Kind: ActionTextColor}},
+		{Trigger: Trigger{Event: EventCreated}, Action: Action{Kind: ActionChecklist, Items: []string{" "}}},
+		{Trigger: Trigger{Event: EventMoved}, Action: Action{Kind: ActionArchive, Days: -1}},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", r)
+		}
+	}
+	r := Rule{Trigger: Trigger{Event: EventMoved, ListID: 2}, Action: Action{Kind: ActionBackgroundColor, Color: "c8e6c9"}}
+	if err := r.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAddChecklist(t *testing.T) {
+	tests := []struct {
+		desc  string
+		items []string
+		want  string
+	}{
+		{"", []string{"Review", " Test "}, "- [ ] Review\n- [ ] Test"},
+		{"Notes", []string{"Review"}, "Notes\n- [ ] Review"},
+		{"Notes\n- [x] Review\n", []string{"Review", "Deploy", "Deploy"}, "Notes\n- [x] Review\n- [ ] Deploy"},
+	}
+	for _, tt := range tests {
+		if got := addChecklist(tt.desc, tt.items); got != tt.want {
+			t.Errorf("addChecklist(%q, %q) = %q, want %q", tt.desc, tt.items, got, tt.want)
+		}
+	}
+}
+
+func TestSaveRules(t *testing.T) {
+	db := openTestDB(t)
+	r := Rule{BoardID: 1, Name: " Done is green ", Enabled: true,
+		Trigger: Trigger{Event: EventMoved, ListID: 2},
+		Action:  Action{Kind: ActionBackgroundColor, Color: "#C8E6C9"}}
+	if err := SaveRule(db, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.ID == 0 || r.Name != "Done is green" {
+		t.Fatalf("saved rule %+v", r)
+	}
+	r.Enabled = false
+	r.Action.Color = "#FFFFFF"
+	if err := SaveRule(db, &r); err != nil {
+		t.Fatal(err)
+	}
+	other := Rule{BoardID: 2, Name: "Other", Enabled: true,
+		Trigger: Trigger{Event: EventCreated}, Action: Action{Kind: ActionArchive}}
+	if err := SaveRule(db, &other); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := Rules(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].ID != r.ID || rules[0].Enabled || rules[0].Trigger != r.Trigger || rules[0].Action.Color != "#FFFFFF" {
+		t.Fatalf("rules %+v, want %+v", rules, r)
+	}
+
+	if err := DeleteRule(db, r.ID); err != nil {
+		t.Fatal(err)
+	}
+	if rules, _ := Rules(db, 1); len(rules) != 0 {
+		t.Errorf("after delete: %+v", rules)
+	}
+	if err := SaveRule(db, &r); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("updating a deleted rule: %v, want sql.ErrNoRows", err)
+	}
+	if err := SaveRule(db, &Rule{BoardID: 1, Name: "Bad", Trigger: Trigger{Event: EventMoved}}); err == nil {
+		t.Error("saved a rule without action")
+	}
+}
+
+func TestRunRules(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, r := range []Rule{
+		{Name: "Checklist", Enabled: true,
+			Trigger: Trigger{Event: EventCreated, SwimlaneID: 1},
+			Action:  Action{Kind: ActionChecklist, Items: []string{"Write tests"}}},
+		{Name: "Other swimlane", Enabled: true,
+			Trigger: Trigger{Event: EventCreated, SwimlaneID: 2},
+			Action:  Action{Kind: ActionTextColor, Color: "#0000FF"}},
+		{Name: "Done is green", Enabled: true,
+			Trigger: Trigger{Event: EventMoved, ListID: 2},
+			Action:  Action{Kind: ActionBackgroundColor, Color: "#c8e6c9"}},
+		{Name: "Archive done", Enabled: true,
+			Trigger: Trigger{Event: EventMoved, ListID: 2},
+			Action:  Action{Kind: ActionArchive, Days: 3}},
+		{Name: "Disabled", Enabled: false,
+			Trigger: Trigger{Event: EventMoved},
+			Action:  Action{Kind: ActionTextColor, Color: "#FF0000"}},
+	} {
+		r.BoardID = 1
+		if err := SaveRule(db, &r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Created in swimlane 1
+	applied, err := RunRules(db, CardEvent{Event: EventCreated, CardID: 1}, now)
+	if err != nil || len(applied) != 1 || applied[0].Name != "Checklist" {
+		t.Fatalf("created: %+v, %v", applied, err)
+	}
+	if c, _ := LoadCard(db, 1); c.Description != "- [ ] Write tests" || c.TextColor != "" {
+		t.Errorf("created card %+v", c)
+	}
+
+	// Reordered within its list
+	if applied, err := RunRules(db, CardEvent{Event: EventMoved, CardID: 1, FromListID: 1}, now); err != nil || len(applied) != 0 {
+		t.Errorf("reordered: %+v, %v", applied, err)
+	}
+
+	// Moved to Done
+	if _, err := db.Exec("UPDATE cards SET list_id = 2 WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	applied, err = RunRules(db, CardEvent{Event: EventMoved, CardID: 1, FromListID: 1}, now)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("moved to Done: %+v, %v", applied, err)
+	}
+	if c, _ := LoadCard(db, 1); c.BackgroundColor != "#C8E6C9" || c.TextColor != "" {
+		t.Errorf("moved card %+v", c)
+	}
+	var archiveAt time.Time
+	db.QueryRow("SELECT archive_at FROM cards WHERE id = 1").Scan(&archiveAt)
+	if !archiveAt.Equal(now.AddDate(0, 0, 3)) {
+		t.Errorf("archive_at = %v", archiveAt)
+	}
+	if n, err := ArchiveDue(db, now.AddDate(0, 0, 2)); err != nil || n != 0 {
+		t.Errorf("ArchiveDue after 2 days = %d, %v", n, err)
+	}
+	if n, err := ArchiveDue(db, now.AddDate(0, 0, 3)); err != nil || n != 1 {
+		t.Errorf("ArchiveDue after 3 days = %d, %v", n, err)
+	}
+	if l, _ := LoadList(db, 2); len(l.Cards) != 0 {
+		t.Errorf("archived card loaded: %+v", l.Cards)
+	}
+	if w, _ := LoadWIP(db, 2); w.Count != 0 {
+		t.Errorf("archived card counted: %+v", w)
+	}
+
+	// Moving out of Done cancels the archive
+	db.Exec("UPDATE cards SET list_id = 2 WHERE id = 2")
+	RunRules(db, CardEvent{Event: EventMoved, CardID: 2, FromListID: 1}, now)
+	db.Exec("UPDATE cards SET list_id = 1 WHERE id = 2")
+	if _, err := RunRules(db, CardEvent{Event: EventMoved, CardID: 2, FromListID: 2}, now); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := ArchiveDue(db, now.AddDate(1, 0, 0)); n != 0 {
+		t.Errorf("archived %d cards after moving out of Done", n)
+	}
+
+	if _, err := RunRules(db, CardEvent{Event: EventCreated, CardID: 99}, now); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("missing card: %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestEnsureRulesMigrates(t *testing.T) {
+	db := openOldDB(t)
+	for i := 0; i < 2; i++ {
+		if err := EnsureRules(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ArchiveDue(db, time.Now()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// schema creates the tables of a new database. Columns added since the
+// first version are added to older databases by Init.
+const schema = `
+	CREATE TABLE IF NOT EXISTS boards (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		description TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS swimlanes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		board_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		position INTEGER DEFAULT 0,
+		text_color TEXT DEFAULT '',
+		background_color TEXT DEFAULT '',
+		background_image TEXT DEFAULT '',
+		FOREIGN KEY (board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS lists (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		swimlane_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		position INTEGER DEFAULT 0,
+		text_color TEXT DEFAULT '',
+		background_color TEXT DEFAULT '',
+		background_image TEXT DEFAULT '',
+		wip_limit INTEGER DEFAULT 0,
+		wip_hard INTEGER DEFAULT 0,
+		FOREIGN KEY (swimlane_id) REFERENCES swimlanes(id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS cards (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		list_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT,
+		position INTEGER DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		attachment BLOB,
+		text_color TEXT DEFAULT '',
+		background_color TEXT DEFAULT '',
+		FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE
+	);
+`
+
+// addedColumns are the columns of schema missing from the databases of
+// older versions, which created the tables without them.
+var addedColumns = []struct{ table, name, def string }{
+	{"swimlanes", "text_color", "TEXT DEFAULT ''"},
+	{"swimlanes", "background_color", "TEXT DEFAULT ''"},
+	{"swimlanes", "background_image", "TEXT DEFAULT ''"},
+	{"lists", "text_color", "TEXT DEFAULT ''"},
+	{"lists", "background_color", "TEXT DEFAULT ''"},
+	{"lists", "background_image", "TEXT DEFAULT ''"},
+	{"lists", "wip_limit", "INTEGER DEFAULT 0"},
+	{"lists", "wip_hard", "INTEGER DEFAULT 0"},
+	{"cards", "text_color", "TEXT DEFAULT ''"},
+	{"cards", "background_color", "TEXT DEFAULT ''"},
+}
+
+// Init creates the tables of a new database, or adds what is missing to
+// the database of an older version: the color and WIP limit columns, the
+// indexes, the saved filters, the rules and archive columns, the template
+// columns and the search index. Every program that writes to the database
+// calls it after Open. Without FTS5 in the build the search index is left
+// out and Search matches substrings.
+//
+// Init does not set the schema version, so that the caller can first deal
+// with what the version tells, see SchemaVersion.
+func Init(db *sql.DB) error {
+	if _, err := db.Exec(schema); err != nil {
+		return fmt.Errorf("create tables: %w", err)
+	}
+	for _, col := range addedColumns {
+		if err := addColumn(db, col.table, col.name, col.def); err != nil {
+			return err
+		}
+	}
+	for _, ensure := range []func(*sql.DB) error{EnsureIndexes, EnsureSavedFilters, EnsureRules, EnsureTemplates} {
+		if err := ensure(db); err != nil {
+			return err
+		}
+	}
+	if err := EnsureSearchIndex(db); err != nil && !strings.Contains(err.Error(), "no such module") {
+		return err
+	}
+	return nil
+}
+
+// addColumn adds a column to table if it is missing.
+func addColumn(db *sql.DB, table, name, def string) error {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, name).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("check %s.%s: %w", table, name, err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := db.Exec("ALTER TABLE " + table + " ADD COLUMN " + name + " " + def); err != nil {
+		return fmt.Errorf("add %s.%s: %w", table, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// oldSchema is the schema of the first version, as kanban.tcl creates it.
+const oldSchema = `
+	CREATE TABLE boards (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, description TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
+	CREATE TABLE swimlanes (id INTEGER PRIMARY KEY AUTOINCREMENT, board_id INTEGER NOT NULL, name TEXT NOT NULL,
+		position INTEGER DEFAULT 0);
+	CREATE TABLE lists (id INTEGER PRIMARY KEY AUTOINCREMENT, swimlane_id INTEGER NOT NULL, name TEXT NOT NULL,
+		position INTEGER DEFAULT 0);
+	CREATE TABLE cards (id INTEGER PRIMARY KEY AUTOINCREMENT, list_id INTEGER NOT NULL, title TEXT NOT NULL,
+		description TEXT, position INTEGER DEFAULT 0, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, attachment BLOB);
+	INSERT INTO boards (id, name) VALUES (1, 'Board');
+	INSERT INTO swimlanes (id, board_id, name) VALUES (1, 1, 'Lane');
+	INSERT INTO lists (id, swimlane_id, name) VALUES (1, 1, 'Todo');
+	INSERT INTO cards (id, list_id, title) VALUES (1, 1, 'Old card');
+`
+
+// openOldDB creates a database of the first version, with oldSchema.
+func openOldDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "wekan.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(oldSchema); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestInit(t *testing.T) {
+	for name, setup := range map[string]string{"new": "", "old": oldSchema} {
+		db, err := Open(filepath.Join(t.TempDir(), "wekan.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if setup != "" {
+			if _, err := db.Exec(setup); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Again on the migrated database
+		for i := 0; i < 2; i++ {
+			if err := Init(db); err != nil {
+				t.Fatalf("%s, Init %d: %v", name, i+1, err)
+			}
+		}
+
+		for table, columns := range map[string][]string{
+			"boards":    {"is_template"},
+			"swimlanes": {"text_color", "background_color", "background_image", "is_template"},
+			"lists":     {"text_color", "background_color", "background_image", "wip_limit", "wip_hard", "is_template"},
+			"cards":     {"text_color", "background_color", "archived", "archive_at", "is_template"},
+		} {
+			for _, column := range columns {
+				var n int
+				db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n)
+				if n != 1 {
+					t.Errorf("%s: %s.%s missing", name, table, column)
+				}
+			}
+		}
+		for _, table := range []string{"rules", "saved_filters"} {
+			count(t, db, table)
+		}
+
+		if setup != "" {
+			tree, err := LoadBoard(db, 1)
+			if err != nil || len(tree) != 1 || len(tree[0].Lists[0].Cards) != 1 {
+				t.Errorf("%s: loaded %+v, %v", name, tree, err)
+			}
+		}
+	}
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	db := openOldDB(t)
+	if err := CheckSchemaVersion(db); err == nil {
+		t.Error("old database passed")
+	}
+	if err := SetSchemaVersion(db, SchemaVersion); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSchemaVersion(db); err != nil {
+		t.Errorf("current database: %v", err)
+	}
+}
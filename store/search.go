@@ -0,0 +1,268 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// searchIndex is an FTS5 index over card titles and descriptions, kept in
+// sync with the cards table by triggers. It stores no copy of the text
+// (content='cards'); snippets are read back from the cards table. Comments
+// and checklist items are to be added as further columns once they exist.
+const searchIndex = `
+	CREATE VIRTUAL TABLE cards_fts USING fts5(
+		title, description,
+		content='cards', content_rowid='id',
+		tokenize='unicode61 remove_diacritics 2'
+	);
+	CREATE TRIGGER cards_fts_insert AFTER INSERT ON cards BEGIN
+		INSERT INTO cards_fts (rowid, title, description)
+		VALUES (new.id, new.title, new.description);
+	END;
+	CREATE TRIGGER cards_fts_delete AFTER DELETE ON cards BEGIN
+		INSERT INTO cards_fts (cards_fts, rowid, title, description)
+		VALUES ('delete', old.id, old.title, old.description);
+	END;
+	CREATE TRIGGER cards_fts_update AFTER UPDATE OF title, description ON cards BEGIN
+		INSERT INTO cards_fts (cards_fts, rowid, title, description)
+		VALUES ('delete', old.id, old.title, old.description);
+		INSERT INTO cards_fts (rowid, title, description)
+		VALUES (new.id, new.title, new.description);
+	END;
+	INSERT INTO cards_fts (cards_fts) VALUES ('rebuild');
+`
+
+// EnsureSearchIndex creates the full-text index and its triggers if they are
+// missing and indexes the existing cards. It fails if SQLite was built
+// without FTS5; Search then falls back to substring matching.
+func EnsureSearchIndex(db *sql.DB) error {
+	if ok, err := hasSearchIndex(db); err != nil || ok {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("create search index: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(searchIndex); err != nil {
+		return fmt.Errorf("create search index: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("create search index: %w", err)
+	}
+	return nil
+}
+
+func hasSearchIndex(db *sql.DB) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'cards_fts'").Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("check search index: %w", err)
+	}
+	return n > 0, nil
+}
+
+// SearchOptions restricts a search. The zero value searches all boards and
+// returns up to DefaultSearchLimit results.
+type SearchOptions struct {
+	BoardID int // 0 for all boards
+	Limit   int
+}
+
+const DefaultSearchLimit = 50
+
+// Markers around matched terms in SearchResult.Snippet.
+const (
+	SnippetStart = "["
+	SnippetEnd   = "]"
+)
+
+// SearchResult is a card matching a search, with its location on the board.
+type SearchResult struct {
+	CardID      int
+	ListID      int
+	SwimlaneID  int
+	BoardID     int
+	Board       string
+	Swimlane    string
+	List        string
+	Title       string
+	Description string
+	// Snippet is the best matching part of the title or description with
+	// the matched terms between SnippetStart and SnippetEnd.
+	Snippet string
+	// Rank orders the results, lower is better. It is the BM25 score with
+	// FTS5 and 0 with substring matching.
+	Rank float64
+}
+
+// Search finds the unarchived cards matching every word of query, best
+// matches first.
+// Words match as prefixes ("expo" finds "exporter"), title matches rank above
+// description matches. Without the full-text index, words match as
+// substrings in the order of the cards on their boards.
+func Search(db *sql.DB, query string, opts SearchOptions) ([]SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultSearchLimit
+	}
+	ok, err := hasSearchIndex(db)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		results, err := searchFTS(db, terms, opts)
+		if err == nil || !strings.Contains(err.Error(), "no such module") {
+			return results, err
+		}
+		// Index created by a build with FTS5, searched by one without
+	}
+	return searchLike(db, terms, opts)
+}
+
+// searchTerms splits a query into words, dropping FTS5 operators and
+// punctuation so that any user input is a valid query.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+const searchColumns = `c.id, l.id, s.id, b.id, b.name, s.name, l.name, c.title, COALESCE(c.description, '')`
+
+const searchJoins = `JOIN lists l ON l.id = c.list_id
+		JOIN swimlanes s ON s.id = l.swimlane_id
+		JOIN boards b ON b.id = s.board_id`
+
+func searchFTS(db *sql.DB, terms []string, opts SearchOptions) ([]SearchResult, error) {
+	match := make([]string, len(terms))
+	for i, t := range terms {
+		match[i] = `"` + t + `"*`
+	}
+	query := `SELECT ` + searchColumns + `,
+			snippet(cards_fts, -1, ?, ?, '…', 12), bm25(cards_fts, 10.0, 1.0) AS rank
+		FROM cards_fts
+		JOIN cards c ON c.id = cards_fts.rowid
+		` + searchJoins + `
+		WHERE cards_fts MATCH ? AND COALESCE(c.archived, 0) = 0`
+	args := []any{SnippetStart, SnippetEnd, strings.Join(match, " ")}
+	if opts.BoardID != 0 {
+		query += " AND b.id = ?"
+		args = append(args, opts.BoardID)
+	}
+	query += " ORDER BY rank, c.id LIMIT ?"
+	args = append(args, opts.Limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	defer rows.Close()
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		err := rows.Scan(&r.CardID, &r.ListID, &r.SwimlaneID, &r.BoardID, &r.Board, &r.Swimlane, &r.List,
+			&r.Title, &r.Description, &r.Snippet, &r.Rank)
+		if err != nil {
+			return nil, fmt.Errorf("scan search result: %w", err)
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func searchLike(db *sql.DB, terms []string, opts SearchOptions) ([]SearchResult, error) {
+	query := `SELECT ` + searchColumns + `
+		FROM cards c
+		` + searchJoins + `
+		WHERE COALESCE(c.archived, 0) = 0`
+	var args []any
+	for _, t := range terms {
+		pattern := "%" + t + "%"
+		query += ` AND (c.title LIKE ? OR c.description LIKE ?)`
+		args = append(args, pattern, pattern)
+	}
+	if opts.BoardID != 0 {
+		query += " AND b.id = ?"
+		args = append(args, opts.BoardID)
+	}
+	query += " ORDER BY b.name, b.id, s.position, l.position, c.position, c.id LIMIT ?"
+	args = append(args, opts.Limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	defer rows.Close()
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		err := rows.Scan(&r.CardID, &r.ListID, &r.SwimlaneID, &r.BoardID, &r.Board, &r.Swimlane, &r.List,
+			&r.Title, &r.Description)
+		if err != nil {
+			return nil, fmt.Errorf("scan search result: %w", err)
+		}
+		r.Snippet = likeSnippet(r.Title, r.Description, terms)
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// likeSnippet marks the terms in the title, or in the description if the
+// title does not contain any of them.
+func likeSnippet(title, description string, terms []string) string {
+	if s, ok := markTerms(title, terms); ok {
+		return s
+	}
+	s, _ := markTerms(description, terms)
+	return excerpt(s, snippetRunes)
+}
+
+// Maximum length of a description snippet with substring matching.
+const snippetRunes = 80
+
+// excerpt cuts text to about n runes around its first marked term.
+func excerpt(text string, n int) string {
+	r := []rune(text)
+	if len(r) <= n {
+		return text
+	}
+	start := strings.Index(text, SnippetStart)
+	start = max(0, len([]rune(text[:max(start, 0)]))-n/4)
+	end := min(len(r), start+n)
+	s := string(r[start:end])
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(r) {
+		s += "…"
+	}
+	return s
+}
+
+func markTerms(text string, terms []string) (string, bool) {
+	var b strings.Builder
+	found := false
+	for i := 0; i < len(text); {
+		matched := 0
+		for _, t := range terms {
+			if len(t) > matched && i+len(t) <= len(text) && strings.EqualFold(text[i:i+len(t)], t) {
+				matched = len(t)
+			}
+		}
+		if matched == 0 {
+			b.WriteByte(text[i])
+			i++
+			continue
+		}
+		found = true
+		b.WriteString(SnippetStart + text[i:i+matched] + SnippetEnd)
+		i += matched
+	}
+	return b.String(), found
+}
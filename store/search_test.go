@@ -0,0 +1,131 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	db := openTestDB(t)
+	if err := EnsureSearchIndex(db); err != nil {
+		t.Logf("no full-text index, testing substring search: %v", err)
+	}
+	fts, err := hasSearchIndex(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cards written after the index exists are kept in sync by triggers
+	stmts := []string{
+		"INSERT INTO cards (id, list_id, title, description, position) VALUES (10, 2, 'Release notes', 'Mention the exporter changes', 0)",
+		"INSERT INTO cards (id, list_id, title, description, position) VALUES (11, 2, 'Exporter crash', 'Crashes on empty boards', 1)",
+		"INSERT INTO cards (id, list_id, title, description, position) VALUES (12, 2, 'Obsolete exporter', '', 2)",
+		"UPDATE cards SET title = 'Exporter crash fixed' WHERE id = 11",
+		"DELETE FROM cards WHERE id = 12",
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	results, err := Search(db, "exporter", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := cardIDs(results)
+	if len(got) != 2 || !containsID(got, 10) || !containsID(got, 11) {
+		t.Fatalf("Search(exporter) = %v, want cards 10 and 11", got)
+	}
+	r := results[0]
+	if r.Board != "Board" || r.Swimlane != "Development" || r.List != "Done" || r.BoardID != 1 || r.ListID != 2 {
+		t.Errorf("result location %+v", r)
+	}
+	for _, r := range results {
+		if !strings.Contains(strings.ToLower(r.Snippet), SnippetStart+"exporter"+SnippetEnd) {
+			t.Errorf("snippet %q does not mark the match", r.Snippet)
+		}
+	}
+	if fts && got[0] != 11 {
+		t.Errorf("ranking %v, want the title match first", got)
+	}
+
+	// All words must match; quotes and other FTS5 syntax are ignored
+	if results, err := Search(db, `"crash" -fixed*`, SearchOptions{}); err != nil || len(results) != 1 || results[0].CardID != 11 {
+		t.Errorf("Search(crash fixed) = %v, %v", cardIDs(results), err)
+	}
+	if results, err := Search(db, "crash notes", SearchOptions{}); err != nil || len(results) != 0 {
+		t.Errorf("Search(crash notes) = %v, %v, want none", cardIDs(results), err)
+	}
+	if results, err := Search(db, "  ()  ", SearchOptions{}); err != nil || len(results) != 0 {
+		t.Errorf("Search of punctuation = %v, %v, want none", cardIDs(results), err)
+	}
+	if fts {
+		if results, err := Search(db, "expo", SearchOptions{}); err != nil || len(results) != 2 {
+			t.Errorf("prefix Search(expo) = %v, %v, want 2 cards", cardIDs(results), err)
+		}
+	}
+
+	// Board filter and limit
+	if results, err := Search(db, "not board", SearchOptions{}); err != nil || len(results) != 1 || results[0].BoardID != 2 {
+		t.Errorf("Search over all boards = %+v, %v", results, err)
+	}
+	if results, err := Search(db, "not board", SearchOptions{BoardID: 1}); err != nil || len(results) != 0 {
+		t.Errorf("Search on board 1 = %v, %v, want none", cardIDs(results), err)
+	}
+	if results, err := Search(db, "exporter", SearchOptions{Limit: 1}); err != nil || len(results) != 1 {
+		t.Errorf("Search with limit 1 = %v, %v", cardIDs(results), err)
+	}
+
+	// Archived cards are not on the board to jump to
+	if _, err := db.Exec("UPDATE cards SET archived = 1 WHERE id = 10"); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := Search(db, "exporter", SearchOptions{}); err != nil || len(results) != 1 || results[0].CardID != 11 {
+		t.Errorf("Search(exporter) with card 10 archived = %v, %v", cardIDs(results), err)
+	}
+}
+
+func TestSearchWithoutIndex(t *testing.T) {
+	db := openTestDB(t)
+	// As a database of a build without FTS5
+	if _, err := db.Exec(`DROP TRIGGER IF EXISTS cards_fts_insert; DROP TRIGGER IF EXISTS cards_fts_delete;
+		DROP TRIGGER IF EXISTS cards_fts_update; DROP TABLE IF EXISTS cards_fts`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE cards SET description = ? WHERE id = 1", strings.Repeat("filler ", 40)+"needle "+strings.Repeat("filler ", 40)); err != nil {
+		t.Fatal(err)
+	}
+	results, err := Search(db, "NEEDLE", SearchOptions{})
+	if err != nil || len(results) != 1 || results[0].CardID != 1 {
+		t.Fatalf("Search(NEEDLE) = %+v, %v", results, err)
+	}
+	s := results[0].Snippet
+	if !strings.Contains(s, SnippetStart+"needle"+SnippetEnd) || !strings.HasPrefix(s, "…") || !strings.HasSuffix(s, "…") || len([]rune(s)) > snippetRunes+2 {
+		t.Errorf("snippet %q, want an excerpt around the match", s)
+	}
+
+	if _, err := db.Exec("UPDATE cards SET archived = 1 WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := Search(db, "NEEDLE", SearchOptions{}); err != nil || len(results) != 0 {
+		t.Errorf("Search(NEEDLE) of an archived card = %v, %v, want none", cardIDs(results), err)
+	}
+}
+
+func cardIDs(results []SearchResult) []int {
+	var ids []int
+	for _, r := range results {
+		ids = append(ids, r.CardID)
+	}
+	return ids
+}
+
+func containsID(ids []int, id int) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,468 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Board is a board without its swimlanes.
+type Board struct {
+	ID          int
+	Name        string
+	Description string
+	CreatedAt   string
+	IsTemplate  bool
+}
+
+// Storage holds boards and their swimlanes, lists and cards, in a kanban
+// database (SQLite) or in memory (Memory), which behave the same.
+//
+// Items are created at the end of their parent. Deleting an item deletes
+// its children and closes up the positions of its siblings, so that the
+// positions of the items of a parent are numbered from 0. Archived cards
+// keep their positions but are left out of the loaded trees. Moves, copies
+// and clones are as the functions of the same names, such as MoveCards and
+// CloneList. Creating, copying and cloning a card, and moving it into
+// another list, run the rules of its board as RunRules, after the change.
+// Missing items and parents are reported as errors wrapping sql.ErrNoRows.
+type Storage interface {
+	// Boards returns the boards ordered by name.
+	Boards() ([]Board, error)
+	Board(id int) (*Board, error)
+	CreateBoard(name, description string) (int, error)
+	UpdateBoard(id int, name, description string) error
+	DeleteBoard(id int) error
+	CloneBoard(id int, opts CloneOptions) (int, error)
+	// LoadBoard returns the swimlanes of a board with their lists and
+	// cards, as LoadBoard.
+	LoadBoard(id int) ([]Swimlane, error)
+
+	CreateSwimlane(boardID int, name string) (int, error)
+	RenameSwimlane(id int, name string) error
+	DeleteSwimlane(id int) error
+	CloneSwimlane(id int, opts CloneOptions) (int, error)
+	MoveSwimlanes(ids []int, t Target) error
+	CopySwimlanes(ids []int, t Target) ([]int, error)
+	LoadSwimlane(id int) (*Swimlane, error)
+	// SetSwimlaneColors sets the colors and background image of a
+	// swimlane; empty strings remove them.
+	SetSwimlaneColors(id int, text, background, image string) error
+
+	CreateList(swimlaneID int, name string) (int, error)
+	RenameList(id int, name string) error
+	DeleteList(id int) error
+	CloneList(id int, opts CloneOptions) (int, error)
+	MoveLists(ids []int, t Target) error
+	CopyLists(ids []int, t Target) ([]int, error)
+	LoadList(id int) (*List, error)
+	SetListColors(id int, text, background, image string) error
+	// SetWIPLimit sets the WIP limit of a list, 0 for none.
+	SetWIPLimit(listID, limit int, hard bool) error
+
+	CreateCard(listID int, title, description string) (int, error)
+	UpdateCard(id int, title, description string) error
+	DeleteCard(id int) error
+	CloneCard(id int, opts CloneOptions) (int, error)
+	MoveCards(ids []int, t Target) error
+	CopyCards(ids []int, t Target) ([]int, error)
+	LoadCard(id int) (*Card, error)
+	SetCardColors(id int, text, background string) error
+	// Attachment returns the attachment of a card, nil if it has none.
+	Attachment(cardID int) ([]byte, error)
+	// SetAttachment replaces the attachment of a card; nil removes it.
+	SetAttachment(cardID int, data []byte) error
+	// ArchiveCard archives a card right away.
+	ArchiveCard(id int) error
+	// ScheduleArchive sets when ArchiveDue archives a card; the zero time
+	// cancels it.
+	ScheduleArchive(id int, at time.Time) error
+	// ArchiveDue archives the cards whose scheduled time has passed and
+	// returns their number.
+	ArchiveDue(now time.Time) (int, error)
+
+	IsTemplate(kind TemplateKind, id int) (bool, error)
+	SetTemplate(kind TemplateKind, id int, on bool) error
+	// Templates returns the templates of a kind on all boards, ordered by
+	// board and name.
+	Templates(kind TemplateKind) ([]Template, error)
+
+	// Rules returns the rules of a board in the order they are applied.
+	Rules(boardID int) ([]Rule, error)
+	// SaveRule inserts a rule, or updates it if it has an ID, and sets its
+	// ID.
+	SaveRule(r *Rule) error
+	DeleteRule(id int) error
+}
+
+// SQLite is the Storage of a kanban database.
+type SQLite struct {
+	db *sql.DB
+}
+
+var _ Storage = (*SQLite)(nil)
+
+// NewSQLite returns the Storage of db, which has the kanban schema.
+func NewSQLite(db *sql.DB) *SQLite {
+	return &SQLite{db: db}
+}
+
+func (s *SQLite) Boards() ([]Board, error) {
+	rows, err := s.db.Query(`SELECT id, name, COALESCE(description, ''), COALESCE(created_at, ''), COALESCE(is_template, 0)
+		FROM boards ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("load boards: %w", err)
+	}
+	defer rows.Close()
+	var boards []Board
+	for rows.Next() {
+		var b Board
+		if err := rows.Scan(&b.ID, &b.Name, &b.Description, &b.CreatedAt, &b.IsTemplate); err != nil {
+			return nil, fmt.Errorf("load boards: %w", err)
+		}
+		boards = append(boards, b)
+	}
+	return boards, rows.Err()
+}
+
+func (s *SQLite) Board(id int) (*Board, error) {
+	var b Board
+	err := s.db.QueryRow(`SELECT id, name, COALESCE(description, ''), COALESCE(created_at, ''), COALESCE(is_template, 0)
+		FROM boards WHERE id = ?`, id).Scan(&b.ID, &b.Name, &b.Description, &b.CreatedAt, &b.IsTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("load board %d: %w", id, err)
+	}
+	return &b, nil
+}
+
+func (s *SQLite) CreateBoard(name, description string) (int, error) {
+	res, err := s.db.Exec("INSERT INTO boards (name, description) VALUES (?, ?)", name, description)
+	if err != nil {
+		return 0, fmt.Errorf("create board: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("create board: %w", err)
+	}
+	return int(id), nil
+}
+
+func (s *SQLite) UpdateBoard(id int, name, description string) error {
+	return s.update("boards", id, "name = ?, description = ?", name, description)
+}
+
+func (s *SQLite) DeleteBoard(id int) error {
+	return s.delete(level{table: "boards"}, id)
+}
+
+func (s *SQLite) CloneBoard(id int, opts CloneOptions) (int, error) {
+	return CloneBoard(s.db, id, opts)
+}
+
+func (s *SQLite) LoadBoard(id int) ([]Swimlane, error) {
+	return LoadBoard(s.db, id)
+}
+
+func (s *SQLite) CreateSwimlane(boardID int, name string) (int, error) {
+	return s.create(swimlaneLevel, boardID, "name", name)
+}
+
+func (s *SQLite) RenameSwimlane(id int, name string) error {
+	return s.update("swimlanes", id, "name = ?", name)
+}
+
+func (s *SQLite) DeleteSwimlane(id int) error {
+	return s.delete(swimlaneLevel, id)
+}
+
+func (s *SQLite) CloneSwimlane(id int, opts CloneOptions) (int, error) {
+	return s.clone(swimlaneLevel, id, opts, CloneSwimlane)
+}
+
+func (s *SQLite) MoveSwimlanes(ids []int, t Target) error {
+	return MoveSwimlanes(s.db, ids, t)
+}
+
+func (s *SQLite) CopySwimlanes(ids []int, t Target) ([]int, error) {
+	return CopySwimlanes(s.db, ids, t)
+}
+
+func (s *SQLite) LoadSwimlane(id int) (*Swimlane, error) {
+	return LoadSwimlane(s.db, id)
+}
+
+func (s *SQLite) SetSwimlaneColors(id int, text, background, image string) error {
+	return s.update("swimlanes", id, "text_color = ?, background_color = ?, background_image = ?", text, background, image)
+}
+
+func (s *SQLite) CreateList(swimlaneID int, name string) (int, error) {
+	return s.create(listLevel, swimlaneID, "name", name)
+}
+
+func (s *SQLite) RenameList(id int, name string) error {
+	return s.update("lists", id, "name = ?", name)
+}
+
+func (s *SQLite) DeleteList(id int) error {
+	return s.delete(listLevel, id)
+}
+
+func (s *SQLite) CloneList(id int, opts CloneOptions) (int, error) {
+	return s.clone(listLevel, id, opts, CloneList)
+}
+
+func (s *SQLite) MoveLists(ids []int, t Target) error {
+	return MoveLists(s.db, ids, t)
+}
+
+func (s *SQLite) CopyLists(ids []int, t Target) ([]int, error) {
+	return CopyLists(s.db, ids, t)
+}
+
+func (s *SQLite) LoadList(id int) (*List, error) {
+	return LoadList(s.db, id)
+}
+
+func (s *SQLite) SetListColors(id int, text, background, image string) error {
+	return s.update("lists", id, "text_color = ?, background_color = ?, background_image = ?", text, background, image)
+}
+
+func (s *SQLite) SetWIPLimit(listID, limit int, hard bool) error {
+	return SetWIPLimit(s.db, listID, limit, hard)
+}
+
+func (s *SQLite) CreateCard(listID int, title, description string) (int, error) {
+	id, err := s.create(cardLevel, listID, "title, description", title, description)
+	if err != nil {
+		return 0, err
+	}
+	return id, s.runRules(EventCreated, []int{id}, nil)
+}
+
+func (s *SQLite) UpdateCard(id int, title, description string) error {
+	return s.update("cards", id, "title = ?, description = ?", title, description)
+}
+
+func (s *SQLite) DeleteCard(id int) error {
+	return s.delete(cardLevel, id)
+}
+
+func (s *SQLite) CloneCard(id int, opts CloneOptions) (int, error) {
+	newID, err := s.clone(cardLevel, id, opts, CloneCard)
+	if err != nil {
+		return 0, err
+	}
+	return newID, s.runRules(EventCreated, []int{newID}, nil)
+}
+
+func (s *SQLite) MoveCards(ids []int, t Target) error {
+	from := make(map[int]int)
+	for _, id := range ids {
+		var listID int
+		if err := s.db.QueryRow("SELECT list_id FROM cards WHERE id = ?", id).Scan(&listID); err == nil {
+			from[id] = listID
+		}
+	}
+	if err := MoveCards(s.db, ids, t); err != nil {
+		return err
+	}
+	return s.runRules(EventMoved, ids, from)
+}
+
+func (s *SQLite) CopyCards(ids []int, t Target) ([]int, error) {
+	newIDs, err := CopyCards(s.db, ids, t)
+	if err != nil {
+		return nil, err
+	}
+	return newIDs, s.runRules(EventCreated, newIDs, nil)
+}
+
+func (s *SQLite) LoadCard(id int) (*Card, error) {
+	return LoadCard(s.db, id)
+}
+
+func (s *SQLite) SetCardColors(id int, text, background string) error {
+	return s.update("cards", id, "text_color = ?, background_color = ?", text, background)
+}
+
+func (s *SQLite) Attachment(cardID int) ([]byte, error) {
+	var data []byte
+	if err := s.db.QueryRow("SELECT attachment FROM cards WHERE id = ?", cardID).Scan(&data); err != nil {
+		return nil, fmt.Errorf("load attachment of card %d: %w", cardID, err)
+	}
+	return data, nil
+}
+
+func (s *SQLite) SetAttachment(cardID int, data []byte) error {
+	return s.update("cards", cardID, "attachment = ?", data)
+}
+
+func (s *SQLite) ArchiveCard(id int) error {
+	return s.update("cards", id, "archived = 1, archive_at = NULL")
+}
+
+func (s *SQLite) ScheduleArchive(id int, at time.Time) error {
+	var arg any
+	if !at.IsZero() {
+		arg = at.UTC().Format(timestampLayout)
+	}
+	return s.update("cards", id, "archive_at = ?", arg)
+}
+
+func (s *SQLite) ArchiveDue(now time.Time) (int, error) {
+	return ArchiveDue(s.db, now)
+}
+
+func (s *SQLite) IsTemplate(kind TemplateKind, id int) (bool, error) {
+	return IsTemplate(s.db, kind, id)
+}
+
+func (s *SQLite) SetTemplate(kind TemplateKind, id int, on bool) error {
+	return SetTemplate(s.db, kind, id, on)
+}
+
+func (s *SQLite) Templates(kind TemplateKind) ([]Template, error) {
+	return Templates(s.db, kind)
+}
+
+func (s *SQLite) Rules(boardID int) ([]Rule, error) {
+	return Rules(s.db, boardID)
+}
+
+func (s *SQLite) SaveRule(r *Rule) error {
+	return SaveRule(s.db, r)
+}
+
+func (s *SQLite) DeleteRule(id int) error {
+	return DeleteRule(s.db, id)
+}
+
+// runRules runs the rules of an event on cards; from are the lists that
+// moved cards came from.
+func (s *SQLite) runRules(event Event, ids []int, from map[int]int) error {
+	now := time.Now()
+	for _, id := range ids {
+		if _, err := RunRules(s.db, CardEvent{Event: event, CardID: id, FromListID: from[id]}, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create inserts an item at the end of its parent; columns are the names
+// of values.
+func (s *SQLite) create(lv level, parentID int, columns string, values ...any) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("create in %s %d: %w", lv.parentTable, parentID, err)
+	}
+	defer tx.Rollback()
+	var n, pos int
+	err = tx.QueryRow("SELECT COUNT(*), (SELECT COALESCE(MAX(position), -1) + 1 FROM "+lv.table+" WHERE "+lv.parentColumn+" = ?) FROM "+
+		lv.parentTable+" WHERE id = ?", parentID, parentID).Scan(&n, &pos)
+	if err != nil {
+		return 0, fmt.Errorf("create in %s %d: %w", lv.parentTable, parentID, err)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("create in %s %d: %w", lv.parentTable, parentID, sql.ErrNoRows)
+	}
+	marks := "?, ?"
+	for range values {
+		marks += ", ?"
+	}
+	args := append([]any{parentID, pos}, values...)
+	res, err := tx.Exec("INSERT INTO "+lv.table+" ("+lv.parentColumn+", position, "+columns+") VALUES ("+marks+")", args...)
+	if err != nil {
+		return 0, fmt.Errorf("create in %s %d: %w", lv.parentTable, parentID, err)
+	}
+	id, err := res.LastInsertId()
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("create in %s %d: %w", lv.parentTable, parentID, err)
+	}
+	return int(id), nil
+}
+
+// clone runs a clone function after checking that opts.ParentID exists,
+// which databases without foreign keys do not.
+func (s *SQLite) clone(lv level, id int, opts CloneOptions, f func(*sql.DB, int, CloneOptions) (int, error)) (int, error) {
+	if opts.ParentID != 0 {
+		var n int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM "+lv.parentTable+" WHERE id = ?", opts.ParentID).Scan(&n); err != nil {
+			return 0, fmt.Errorf("clone %s %d: %w", lv.table, id, err)
+		}
+		if n == 0 {
+			return 0, fmt.Errorf("clone %s %d to %s %d: %w", lv.table, id, lv.parentTable, opts.ParentID, sql.ErrNoRows)
+		}
+	}
+	return f(s.db, id, opts)
+}
+
+// update sets columns of an item, as "name = ?" with args.
+func (s *SQLite) update(table string, id int, set string, args ...any) error {
+	res, err := s.db.Exec("UPDATE "+table+" SET "+set+" WHERE id = ?", append(args, id)...)
+	if err != nil {
+		return fmt.Errorf("update %s %d: %w", table, id, err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("update %s %d: %w", table, id, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// delete deletes an item with its children and closes up the positions of
+// its siblings. Children are deleted here too, for databases created
+// without foreign keys.
+func (s *SQLite) delete(lv level, id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("delete %s %d: %w", lv.table, id, err)
+	}
+	defer tx.Rollback()
+	var parentID int
+	if lv.parentColumn != "" {
+		err := tx.QueryRow("SELECT "+lv.parentColumn+" FROM "+lv.table+" WHERE id = ?", id).Scan(&parentID)
+		if err != nil {
+			return fmt.Errorf("delete %s %d: %w", lv.table, id, err)
+		}
+	}
+	res, err := deleteTree(tx, lv.table, id)
+	if err != nil {
+		return fmt.Errorf("delete %s %d: %w", lv.table, id, err)
+	}
+	if res == 0 {
+		return fmt.Errorf("delete %s %d: %w", lv.table, id, sql.ErrNoRows)
+	}
+	if lv.parentColumn != "" {
+		if err := renumber(tx, lv, parentID); err != nil {
+			return fmt.Errorf("delete %s %d: %w", lv.table, id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete %s %d: %w", lv.table, id, err)
+	}
+	return nil
+}
+
+// deleteTree deletes a row and its children along knownRelations and
+// returns the number of rows deleted from table.
+func deleteTree(tx *sql.Tx, table string, id int) (int64, error) {
+	for _, rel := range knownRelations[table] {
+		children, err := queryIDs(tx, "SELECT id FROM "+rel.table+" WHERE "+rel.column+" = ?", id)
+		if err != nil {
+			return 0, err
+		}
+		for _, child := range children {
+			if _, err := deleteTree(tx, rel.table, child); err != nil {
+				return 0, err
+			}
+		}
+	}
+	res, err := tx.Exec("DELETE FROM "+table+" WHERE id = ?", id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
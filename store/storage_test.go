@@ -0,0 +1,872 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storages are the Storage implementations the conformance tests run
+// against, each returning an empty storage.
+var storages = []struct {
+	name string
+	open func(t *testing.T) Storage
+}{
+	{"sqlite", func(t *testing.T) Storage { return NewSQLite(openEmptyDB(t)) }},
+	{"memory", func(t *testing.T) Storage { return NewMemory() }},
+}
+
+// forEachStorage runs test against each Storage, filled by fill.
+func forEachStorage(t *testing.T, test func(t *testing.T, s Storage)) {
+	for _, st := range storages {
+		t.Run(st.name, func(t *testing.T) {
+			s := st.open(t)
+			fill(t, s)
+			test(t, s)
+		})
+	}
+}
+
+// fill creates two boards: "Project" (1) with swimlane "Dev" (1) holding
+// list "Todo" (1) with cards A, B and C (1 to 3) and the empty list "Done"
+// (2), and swimlane "Ops" (2) holding list "Queue" (3) with card D (4);
+// and "Other" (2) with swimlane "Lane" (3) holding the empty list "Inbox"
+// (4).
+func fill(t *testing.T, s Storage) {
+	t.Helper()
+	must := func(id int, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(s.CreateBoard("Project", "The project"))
+	must(s.CreateSwimlane(1, "Dev"))
+	must(s.CreateList(1, "Todo"))
+	must(s.CreateList(1, "Done"))
+	for _, title := range []string{"A", "B", "C"} {
+		must(s.CreateCard(1, title, "About "+title))
+	}
+	must(s.CreateSwimlane(1, "Ops"))
+	must(s.CreateList(2, "Queue"))
+	must(s.CreateCard(3, "D", ""))
+	must(s.CreateBoard("Other", ""))
+	must(s.CreateSwimlane(2, "Lane"))
+	must(s.CreateList(3, "Inbox"))
+}
+
+// outline describes a board as "Dev[Todo(A B C) Done()] Ops[Queue(D)]" and
+// checks that its positions are numbered from 0 and its parent IDs match.
+// Cards not at their index are shown with their position, as "C@2".
+func outline(t *testing.T, s Storage, boardID int) string {
+	t.Helper()
+	tree, err := s.LoadBoard(boardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var swimlanes []string
+	for i, sl := range tree {
+		if sl.Position != i || sl.BoardID != boardID {
+			t.Errorf("swimlane %d %q at position %d of board %d", sl.ID, sl.Name, sl.Position, sl.BoardID)
+		}
+		var lists []string
+		for j, l := range sl.Lists {
+			if l.Position != j || l.SwimlaneID != sl.ID {
+				t.Errorf("list %d %q at position %d of swimlane %d", l.ID, l.Name, l.Position, l.SwimlaneID)
+			}
+			var cards []string
+			for k, c := range l.Cards {
+				if c.ListID != l.ID {
+					t.Errorf("card %d %q in list %d", c.ID, c.Title, c.ListID)
+				}
+				// Archived cards leave gaps in the positions shown
+				if c.Position != k {
+					cards = append(cards, fmt.Sprintf("%s@%d", c.Title, c.Position))
+				} else {
+					cards = append(cards, c.Title)
+				}
+			}
+			lists = append(lists, fmt.Sprintf("%s(%s)", l.Name, strings.Join(cards, " ")))
+		}
+		swimlanes = append(swimlanes, fmt.Sprintf("%s[%s]", sl.Name, strings.Join(lists, " ")))
+	}
+	return strings.Join(swimlanes, " ")
+}
+
+// outlines describes both boards of the fixture.
+func outlines(t *testing.T, s Storage) [2]string {
+	t.Helper()
+	return [2]string{outline(t, s, 1), outline(t, s, 2)}
+}
+
+const (
+	projectOutline = "Dev[Todo(A B C) Done()] Ops[Queue(D)]"
+	otherOutline   = "Lane[Inbox()]"
+)
+
+func TestStorageCreateAndLoad(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		if got := outlines(t, s); got != [2]string{projectOutline, otherOutline} {
+			t.Errorf("outlines %q", got)
+		}
+		boards, err := s.Boards()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(boards) != 2 || boards[0].Name != "Other" || boards[1].Name != "Project" ||
+			boards[1].ID != 1 || boards[1].Description != "The project" || boards[1].CreatedAt == "" {
+			t.Errorf("boards %+v", boards)
+		}
+		c, err := s.LoadCard(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Title != "B" || c.Description != "About B" || c.ListID != 1 || c.Position != 1 || c.CreatedAt == "" {
+			t.Errorf("card %+v", c)
+		}
+		l, err := s.LoadList(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Name != "Todo" || l.SwimlaneID != 1 || len(l.Cards) != 3 || l.Cards[2].Title != "C" {
+			t.Errorf("list %+v", l)
+		}
+		b, err := s.Board(1)
+		if err != nil || b.Name != "Project" || b.Description != "The project" || b.CreatedAt == "" || b.IsTemplate {
+			t.Errorf("board %+v, %v", b, err)
+		}
+		sl, err := s.LoadSwimlane(2)
+		if err != nil || sl.Name != "Ops" || sl.BoardID != 1 || sl.Position != 1 || len(sl.Lists) != 1 ||
+			sl.Lists[0].Cards[0].Title != "D" {
+			t.Errorf("swimlane %+v, %v", sl, err)
+		}
+		if tree, err := s.LoadBoard(9); err != nil || len(tree) != 0 {
+			t.Errorf("missing board: %v, %v", tree, err)
+		}
+
+		// Missing rows and parents
+		checks := map[string]error{
+			"load board":       second(s.Board(9)),
+			"load swimlane":    second(s.LoadSwimlane(9)),
+			"load card":        second(s.LoadCard(9)),
+			"load list":        second(s.LoadList(9)),
+			"create swimlane":  second(s.CreateSwimlane(9, "X")),
+			"create list":      second(s.CreateList(9, "X")),
+			"create card":      second(s.CreateCard(9, "X", "")),
+			"create card in 0": second(s.CreateCard(0, "X", "")),
+		}
+		for name, err := range checks {
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("%s: %v, want ErrNoRows", name, err)
+			}
+		}
+		if got := outlines(t, s); got != [2]string{projectOutline, otherOutline} {
+			t.Errorf("outlines after failed creates %q", got)
+		}
+	})
+}
+
+func second[T any](_ T, err error) error {
+	return err
+}
+
+func TestStorageUpdateAndDelete(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		steps := []struct {
+			name string
+			do   func() error
+			want [2]string
+		}{
+			{"rename swimlane", func() error { return s.RenameSwimlane(2, "Operations") },
+				[2]string{"Dev[Todo(A B C) Done()] Operations[Queue(D)]", otherOutline}},
+			{"rename list", func() error { return s.RenameList(4, "In") },
+				[2]string{"Dev[Todo(A B C) Done()] Operations[Queue(D)]", "Lane[In()]"}},
+			{"update card", func() error { return s.UpdateCard(1, "A1", "New") },
+				[2]string{"Dev[Todo(A1 B C) Done()] Operations[Queue(D)]", "Lane[In()]"}},
+			{"delete middle card", func() error { return s.DeleteCard(2) },
+				[2]string{"Dev[Todo(A1 C) Done()] Operations[Queue(D)]", "Lane[In()]"}},
+			{"delete first list", func() error { return s.DeleteList(1) },
+				[2]string{"Dev[Done()] Operations[Queue(D)]", "Lane[In()]"}},
+			{"delete first swimlane", func() error { return s.DeleteSwimlane(1) },
+				[2]string{"Operations[Queue(D)]", "Lane[In()]"}},
+			{"delete last card", func() error { return s.DeleteCard(4) },
+				[2]string{"Operations[Queue()]", "Lane[In()]"}},
+		}
+		for _, step := range steps {
+			if err := step.do(); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			if got := outlines(t, s); got != step.want {
+				t.Fatalf("%s: %q, want %q", step.name, got, step.want)
+			}
+		}
+		if c, err := s.LoadCard(1); err == nil {
+			t.Errorf("card of a deleted list: %+v", c)
+		}
+		if err := s.UpdateBoard(2, "Renamed", "Text"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteBoard(1); err != nil {
+			t.Fatal(err)
+		}
+		boards, err := s.Boards()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(boards) != 1 || boards[0].Name != "Renamed" || boards[0].Description != "Text" {
+			t.Errorf("boards %+v", boards)
+		}
+		if l, err := s.LoadList(3); err == nil {
+			t.Errorf("list of a deleted board: %+v", l)
+		}
+
+		checks := map[string]error{
+			"update board":    s.UpdateBoard(1, "X", ""),
+			"delete board":    s.DeleteBoard(1),
+			"rename swimlane": s.RenameSwimlane(1, "X"),
+			"delete swimlane": s.DeleteSwimlane(1),
+			"rename list":     s.RenameList(1, "X"),
+			"delete list":     s.DeleteList(1),
+			"update card":     s.UpdateCard(1, "X", ""),
+			"delete card":     s.DeleteCard(1),
+		}
+		for name, err := range checks {
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("%s: %v, want ErrNoRows", name, err)
+			}
+		}
+	})
+}
+
+func TestStorageMove(t *testing.T) {
+	cases := []struct {
+		name string
+		move func(s Storage) error
+		want [2]string
+	}{
+		{"card to first", func(s Storage) error { return s.MoveCards([]int{3}, Target{ParentID: 1, BeforeID: 1}) },
+			[2]string{"Dev[Todo(C A B) Done()] Ops[Queue(D)]", otherOutline}},
+		{"card to last", func(s Storage) error { return s.MoveCards([]int{1}, Target{ParentID: 1}) },
+			[2]string{"Dev[Todo(B C A) Done()] Ops[Queue(D)]", otherOutline}},
+		{"last card to last", func(s Storage) error { return s.MoveCards([]int{3}, Target{ParentID: 1}) },
+			[2]string{projectOutline, otherOutline}},
+		{"card before itself", func(s Storage) error { return s.MoveCards([]int{2}, Target{ParentID: 1, BeforeID: 2}) },
+			[2]string{projectOutline, otherOutline}},
+		{"card before the next one", func(s Storage) error { return s.MoveCards([]int{1}, Target{ParentID: 1, BeforeID: 3}) },
+			[2]string{"Dev[Todo(B A C) Done()] Ops[Queue(D)]", otherOutline}},
+		{"cards into an empty list", func(s Storage) error { return s.MoveCards([]int{3, 1}, Target{ParentID: 2}) },
+			[2]string{"Dev[Todo(B) Done(A C)] Ops[Queue(D)]", otherOutline}},
+		{"all cards out of a list", func(s Storage) error { return s.MoveCards([]int{1, 2, 3}, Target{ParentID: 3, BeforeID: 4}) },
+			[2]string{"Dev[Todo() Done()] Ops[Queue(A B C D)]", otherOutline}},
+		{"cards across swimlanes in board order", func(s Storage) error {
+			return s.MoveCards([]int{4, 2}, Target{ParentID: 2})
+		}, [2]string{"Dev[Todo(A C) Done(B D)] Ops[Queue()]", otherOutline}},
+		{"cards before a moved card", func(s Storage) error {
+			return s.MoveCards([]int{4, 1}, Target{ParentID: 1, BeforeID: 1})
+		}, [2]string{"Dev[Todo(A D B C) Done()] Ops[Queue()]", otherOutline}},
+		{"card across boards", func(s Storage) error { return s.MoveCards([]int{2}, Target{ParentID: 4}) },
+			[2]string{"Dev[Todo(A C) Done()] Ops[Queue(D)]", "Lane[Inbox(B)]"}},
+		{"list to first", func(s Storage) error { return s.MoveLists([]int{2}, Target{ParentID: 1, BeforeID: 1}) },
+			[2]string{"Dev[Done() Todo(A B C)] Ops[Queue(D)]", otherOutline}},
+		{"list across swimlanes", func(s Storage) error { return s.MoveLists([]int{1}, Target{ParentID: 2}) },
+			[2]string{"Dev[Done()] Ops[Queue(D) Todo(A B C)]", otherOutline}},
+		{"lists across swimlanes in board order", func(s Storage) error {
+			return s.MoveLists([]int{3, 2}, Target{ParentID: 3, BeforeID: 4})
+		}, [2]string{"Dev[Todo(A B C)] Ops[]", "Lane[Done() Queue(D) Inbox()]"}},
+		{"swimlane to first", func(s Storage) error { return s.MoveSwimlanes([]int{2}, Target{ParentID: 1, BeforeID: 1}) },
+			[2]string{"Ops[Queue(D)] Dev[Todo(A B C) Done()]", otherOutline}},
+		{"swimlane across boards", func(s Storage) error { return s.MoveSwimlanes([]int{1}, Target{ParentID: 2}) },
+			[2]string{"Ops[Queue(D)]", "Lane[Inbox()] Dev[Todo(A B C) Done()]"}},
+		{"no items", func(s Storage) error { return s.MoveCards(nil, Target{ParentID: 9}) },
+			[2]string{projectOutline, otherOutline}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			forEachStorage(t, func(t *testing.T, s Storage) {
+				if err := c.move(s); err != nil {
+					t.Fatal(err)
+				}
+				if got := outlines(t, s); got != c.want {
+					t.Errorf("%q, want %q", got, c.want)
+				}
+			})
+		})
+	}
+}
+
+func TestStorageMoveErrors(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		checks := map[string]error{
+			"missing list":       s.MoveCards([]int{1}, Target{ParentID: 9}),
+			"missing card":       s.MoveCards([]int{1, 9}, Target{ParentID: 2}),
+			"repeated card":      s.MoveCards([]int{1, 1}, Target{ParentID: 2}),
+			"before another":     s.MoveCards([]int{1}, Target{ParentID: 2, BeforeID: 4}),
+			"missing swimlane":   s.MoveLists([]int{1}, Target{ParentID: 9}),
+			"list before a card": s.MoveLists([]int{1}, Target{ParentID: 1, BeforeID: 3}),
+			"missing board":      s.MoveSwimlanes([]int{1}, Target{ParentID: 9}),
+			"copy to nothing":    second(s.CopyCards([]int{1}, Target{ParentID: 9})),
+			"copy before other":  second(s.CopyLists([]int{1}, Target{ParentID: 2, BeforeID: 1})),
+		}
+		for name, err := range checks {
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("%s: %v, want ErrNoRows", name, err)
+			}
+		}
+		if got := outlines(t, s); got != [2]string{projectOutline, otherOutline} {
+			t.Errorf("failed moves changed the boards: %q", got)
+		}
+	})
+}
+
+func TestStorageCopy(t *testing.T) {
+	cases := []struct {
+		name string
+		copy func(s Storage) ([]int, error)
+		ids  []int
+		want [2]string
+	}{
+		{"cards to first", func(s Storage) ([]int, error) { return s.CopyCards([]int{3, 1}, Target{ParentID: 1, BeforeID: 1}) },
+			[]int{5, 6}, [2]string{"Dev[Todo(A (Copy) C (Copy) A B C) Done()] Ops[Queue(D)]", otherOutline}},
+		{"cards before a copied card", func(s Storage) ([]int, error) {
+			return s.CopyCards([]int{2}, Target{ParentID: 1, BeforeID: 2})
+		}, []int{5}, [2]string{"Dev[Todo(A B (Copy) B C) Done()] Ops[Queue(D)]", otherOutline}},
+		{"cards into an empty list", func(s Storage) ([]int, error) { return s.CopyCards([]int{4, 2}, Target{ParentID: 2}) },
+			[]int{5, 6}, [2]string{"Dev[Todo(A B C) Done(B D)] Ops[Queue(D)]", otherOutline}},
+		{"list across boards", func(s Storage) ([]int, error) { return s.CopyLists([]int{1}, Target{ParentID: 3}) },
+			[]int{5}, [2]string{projectOutline, "Lane[Inbox() Todo(A B C)]"}},
+		{"swimlane to first", func(s Storage) ([]int, error) {
+			return s.CopySwimlanes([]int{2}, Target{ParentID: 1, BeforeID: 1})
+		}, []int{4}, [2]string{"Ops (Copy)[Queue(D)] Dev[Todo(A B C) Done()] Ops[Queue(D)]", otherOutline}},
+		{"no items", func(s Storage) ([]int, error) { return s.CopyCards([]int{}, Target{ParentID: 1}) },
+			nil, [2]string{projectOutline, otherOutline}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			forEachStorage(t, func(t *testing.T, s Storage) {
+				ids, err := c.copy(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(ids, c.ids) {
+					t.Errorf("copies %v, want %v", ids, c.ids)
+				}
+				if got := outlines(t, s); got != c.want {
+					t.Errorf("%q, want %q", got, c.want)
+				}
+			})
+		})
+	}
+}
+
+func TestStorageClone(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		id, err := s.CloneCard(1, CloneOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := s.LoadCard(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != 5 || c.Title != "A (Copy)" || c.Description != "About A" || c.Position != 3 {
+			t.Errorf("cloned card %d %+v", id, c)
+		}
+		if _, err := s.CloneCard(2, CloneOptions{ParentID: 2, Name: "Named"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CloneList(1, CloneOptions{ParentID: 3, WithoutCards: true}); err != nil {
+			t.Fatal(err)
+		}
+		upper := func(text string) string { return strings.ToUpper(text) }
+		if _, err := s.CloneSwimlane(2, CloneOptions{Text: upper}); err != nil {
+			t.Fatal(err)
+		}
+		want := [2]string{
+			"Dev[Todo(A B C A (Copy)) Done(Named)] Ops[Queue(D)] OPS (Copy)[QUEUE(D)]",
+			"Lane[Inbox() Todo()]",
+		}
+		if got := outlines(t, s); got != want {
+			t.Errorf("%q, want %q", got, want)
+		}
+
+		boardID, err := s.CloneBoard(1, CloneOptions{ParentID: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if boardID != 3 || outline(t, s, 3) != want[0] {
+			t.Errorf("cloned board %d: %q", boardID, outline(t, s, 3))
+		}
+		boards, err := s.Boards()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(boards) != 3 || boards[2].Name != "Project (Copy)" || boards[2].Description != "The project" {
+			t.Errorf("boards %+v", boards)
+		}
+
+		checks := map[string]error{
+			"missing card":   second(s.CloneCard(99, CloneOptions{})),
+			"missing list":   second(s.CloneList(99, CloneOptions{})),
+			"missing board":  second(s.CloneBoard(99, CloneOptions{})),
+			"missing parent": second(s.CloneCard(1, CloneOptions{ParentID: 99})),
+		}
+		for name, err := range checks {
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("%s: %v, want ErrNoRows", name, err)
+			}
+		}
+	})
+}
+
+func TestStorageCloneBookkeeping(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		for _, err := range []error{
+			s.ArchiveCard(2),
+			s.ScheduleArchive(3, now.Add(time.Hour)),
+			s.SetTemplate(TemplateSwimlane, 1, true),
+			s.SetTemplate(TemplateList, 1, true),
+			s.SetTemplate(TemplateCard, 1, true),
+		} {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Archived cards are left out, keeping the positions of the others
+		id, err := s.CloneSwimlane(1, CloneOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := outline(t, s, 1), "Dev[Todo(A C@2) Done()] Ops[Queue(D)] Dev (Copy)[Todo(A C@2) Done()]"; got != want {
+			t.Errorf("%q, want %q", got, want)
+		}
+		sl, err := s.LoadSwimlane(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo := sl.Lists[0]; sl.IsTemplate || todo.IsTemplate || todo.Cards[0].IsTemplate {
+			t.Errorf("copied templates %+v", sl)
+		}
+		// Only the archive of the original is scheduled
+		if n, err := s.ArchiveDue(now.Add(time.Hour)); err != nil || n != 1 {
+			t.Errorf("archived %d due cards, %v", n, err)
+		}
+
+		// WithArchived copies archived cards, as cards that are not archived
+		if _, err := s.CloneSwimlane(1, CloneOptions{WithArchived: true}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CloneCard(2, CloneOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		want := "Dev[Todo(A B (Copy)@3) Done()] Ops[Queue(D)] Dev (Copy)[Todo(A C@2) Done()] Dev (Copy)[Todo(A B C) Done()]"
+		if got := outline(t, s, 1); got != want {
+			t.Errorf("%q, want %q", got, want)
+		}
+	})
+}
+
+func TestStorageColorsAndWIP(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		if err := s.SetSwimlaneColors(1, "#FFFFFF", "#000000", "lane.png"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetListColors(1, "#111111", "#222222", "list.png"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetCardColors(2, "#333333", "#444444"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetWIPLimit(1, 2, true); err != nil {
+			t.Fatal(err)
+		}
+		tree, err := s.LoadBoard(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sl, l := tree[0], tree[0].Lists[0]
+		if sl.TextColor != "#FFFFFF" || sl.BackgroundColor != "#000000" || sl.BackgroundImage != "lane.png" {
+			t.Errorf("swimlane %+v", sl)
+		}
+		if l.TextColor != "#111111" || l.BackgroundColor != "#222222" || l.BackgroundImage != "list.png" {
+			t.Errorf("list %+v", l)
+		}
+		if w := l.WIP(); w != (WIP{Count: 3, Limit: 2, Hard: true}) || !w.Exceeded() {
+			t.Errorf("WIP %+v", w)
+		}
+		if c := l.Cards[1]; c.TextColor != "#333333" || c.BackgroundColor != "#444444" {
+			t.Errorf("card %+v", c)
+		}
+		if c, err := s.LoadCard(2); err != nil || c.TextColor != "#333333" {
+			t.Errorf("loaded card %+v, %v", c, err)
+		}
+
+		// Empty colors and a zero limit remove them
+		if err := s.SetListColors(1, "", "", ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetWIPLimit(1, 0, false); err != nil {
+			t.Fatal(err)
+		}
+		if l, err := s.LoadList(1); err != nil || l.TextColor != "" || l.BackgroundImage != "" || l.WIPLimit != 0 || l.WIPHard {
+			t.Errorf("cleared list %+v, %v", l, err)
+		}
+		if err := s.SetWIPLimit(1, -1, false); err == nil {
+			t.Error("set a negative WIP limit")
+		}
+
+		checks := map[string]error{
+			"swimlane colors": s.SetSwimlaneColors(9, "", "", ""),
+			"list colors":     s.SetListColors(9, "", "", ""),
+			"card colors":     s.SetCardColors(9, "", ""),
+			"WIP limit":       s.SetWIPLimit(9, 1, false),
+		}
+		for name, err := range checks {
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("%s: %v, want ErrNoRows", name, err)
+			}
+		}
+	})
+}
+
+func TestStorageAttachments(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		if data, err := s.Attachment(1); err != nil || data != nil {
+			t.Errorf("Attachment(1) = %q, %v, want none", data, err)
+		}
+		data := []byte("attachment bytes")
+		if err := s.SetAttachment(1, data); err != nil {
+			t.Fatal(err)
+		}
+		data[0] = 'X'
+		if got, err := s.Attachment(1); err != nil || string(got) != "attachment bytes" {
+			t.Errorf("Attachment(1) = %q, %v", got, err)
+		}
+		if c, _ := s.LoadCard(1); c.AttachmentSize != 16 {
+			t.Errorf("attachment size %d", c.AttachmentSize)
+		}
+
+		// Clones keep the attachment
+		id, err := s.CloneCard(1, CloneOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.Attachment(id); err != nil || string(got) != "attachment bytes" {
+			t.Errorf("attachment of the clone %q, %v", got, err)
+		}
+
+		if err := s.SetAttachment(1, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.Attachment(1); err != nil || got != nil {
+			t.Errorf("removed attachment %q, %v", got, err)
+		}
+		if l, _ := s.LoadList(1); l.Cards[0].AttachmentSize != 0 || l.Cards[3].AttachmentSize != 16 {
+			t.Errorf("attachment sizes %+v", l.Cards)
+		}
+
+		if _, err := s.Attachment(99); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Attachment(99) error %v, want ErrNoRows", err)
+		}
+		if err := s.SetAttachment(99, data); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("SetAttachment(99) error %v, want ErrNoRows", err)
+		}
+		a := NewAttachments(s, 1)
+		if got, err := a.Get(id); err != nil || string(got) != "attachment bytes" {
+			t.Errorf("cached attachment %q, %v", got, err)
+		}
+	})
+}
+
+// Archived cards are left out of the trees but keep their positions, so
+// that moves and deletes number them with the other cards
+func TestStorageArchive(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		if err := s.ArchiveCard(2); err != nil {
+			t.Fatal(err)
+		}
+		if got := outline(t, s, 1); got != "Dev[Todo(A C@2) Done()] Ops[Queue(D)]" {
+			t.Errorf("after archiving %q", got)
+		}
+		if c, err := s.LoadCard(2); err != nil || c.Position != 1 {
+			t.Errorf("archived card %+v, %v", c, err)
+		}
+		if err := s.MoveCards([]int{1}, Target{ParentID: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if got := outline(t, s, 1); got != "Dev[Todo(C@1 A@2) Done()] Ops[Queue(D)]" {
+			t.Errorf("after a move %q", got)
+		}
+		if err := s.DeleteCard(3); err != nil {
+			t.Fatal(err)
+		}
+		if got := outline(t, s, 1); got != "Dev[Todo(A@1) Done()] Ops[Queue(D)]" {
+			t.Errorf("after a delete %q", got)
+		}
+
+		if err := s.ScheduleArchive(1, now.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ScheduleArchive(4, now.Add(2*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := s.ArchiveDue(now.Add(time.Hour - time.Second)); err != nil || n != 0 {
+			t.Errorf("archived %d early, %v", n, err)
+		}
+		if n, err := s.ArchiveDue(now.Add(time.Hour)); err != nil || n != 1 {
+			t.Errorf("archived %d when due, %v", n, err)
+		}
+		if err := s.ScheduleArchive(4, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := s.ArchiveDue(now.Add(3 * time.Hour)); err != nil || n != 0 {
+			t.Errorf("archived %d after canceling, %v", n, err)
+		}
+		if got := outline(t, s, 1); got != "Dev[Todo() Done()] Ops[Queue(D)]" {
+			t.Errorf("after archiving due cards %q", got)
+		}
+
+		if err := s.ArchiveCard(99); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("ArchiveCard(99) error %v, want ErrNoRows", err)
+		}
+		if err := s.ScheduleArchive(99, now); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("ScheduleArchive(99) error %v, want ErrNoRows", err)
+		}
+	})
+}
+
+func TestStorageTemplates(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		if err := s.UpdateCard(2, "B {{owner}}", "Due {{date}}"); err != nil {
+			t.Fatal(err)
+		}
+		marks := []struct {
+			kind TemplateKind
+			id   int
+		}{{TemplateBoard, 2}, {TemplateSwimlane, 1}, {TemplateList, 3}, {TemplateList, 4}, {TemplateCard, 2}}
+		for _, m := range marks {
+			if err := s.SetTemplate(m.kind, m.id, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.SetTemplate(TemplateList, 3, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := s.IsTemplate(TemplateSwimlane, 1); err != nil || !ok {
+			t.Errorf("IsTemplate(swimlane 1) = %v, %v", ok, err)
+		}
+		if ok, err := s.IsTemplate(TemplateList, 3); err != nil || ok {
+			t.Errorf("IsTemplate(list 3) = %v, %v", ok, err)
+		}
+		if b, _ := s.Board(2); !b.IsTemplate {
+			t.Errorf("board %+v", b)
+		}
+		tree, err := s.LoadBoard(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tree[0].IsTemplate || tree[1].IsTemplate || tree[0].Lists[0].IsTemplate || !tree[0].Lists[0].Cards[1].IsTemplate {
+			t.Errorf("template flags %+v", tree)
+		}
+
+		lists, err := s.Templates(TemplateList)
+		if want := []Template{{TemplateList, 4, "Inbox", "Other"}}; err != nil || !reflect.DeepEqual(lists, want) {
+			t.Errorf("list templates %+v, %v", lists, err)
+		}
+		if err := s.SetTemplate(TemplateCard, 4, true); err != nil {
+			t.Fatal(err)
+		}
+		cards, err := s.Templates(TemplateCard)
+		want := []Template{{TemplateCard, 2, "B {{owner}}", "Project"}, {TemplateCard, 4, "D", "Project"}}
+		if err != nil || !reflect.DeepEqual(cards, want) {
+			t.Errorf("card templates %+v, %v", cards, err)
+		}
+		names, err := TemplatePlaceholders(s, TemplateSwimlane, 1)
+		if err != nil || !reflect.DeepEqual(names, []string{"date", "owner"}) {
+			t.Errorf("placeholders %q, %v", names, err)
+		}
+
+		if err := s.SetTemplate(TemplateCard, 99, true); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("SetTemplate(card 99) error %v, want ErrNoRows", err)
+		}
+		if _, err := s.IsTemplate(TemplateBoard, 99); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("IsTemplate(board 99) error %v, want ErrNoRows", err)
+		}
+		if _, err := s.Templates("column"); err == nil {
+			t.Error("loaded templates of an invalid kind")
+		}
+	})
+}
+
+// TestStorageRandom applies the same random operations to each Storage and
+// compares the boards after each one.
+func TestStorageRules(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		rules := []*Rule{
+			{BoardID: 1, Name: "Done is green", Enabled: true, Trigger: Trigger{Event: EventMoved, ListID: 2},
+				Action: Action{Kind: ActionBackgroundColor, Color: "#c8e6c9"}},
+			{BoardID: 1, Name: "Review", Enabled: true, Trigger: Trigger{Event: EventCreated},
+				Action: Action{Kind: ActionChecklist, Items: []string{"Review"}}},
+			{BoardID: 1, Name: "Off", Trigger: Trigger{Event: EventMoved}, Action: Action{Kind: ActionArchive}},
+			{BoardID: 1, Name: "Queue expires", Enabled: true, Trigger: Trigger{Event: EventMoved, SwimlaneID: 2},
+				Action: Action{Kind: ActionArchive, Days: 7}},
+			{BoardID: 2, Name: "Elsewhere", Enabled: true, Trigger: Trigger{Event: EventCreated},
+				Action: Action{Kind: ActionTextColor, Color: "#FF0000"}},
+		}
+		for _, r := range rules {
+			if err := s.SaveRule(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, err := s.Rules(1); err != nil || len(got) != 4 || got[0].Name != "Done is green" || got[3].ID != rules[3].ID {
+			t.Errorf("Rules(1) = %+v, %v", got, err)
+		}
+		card := func(id int) *Card {
+			t.Helper()
+			c, err := s.LoadCard(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return c
+		}
+		must := func(err error) {
+			t.Helper()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Moved into Done, and reordered there without firing again
+		must(s.MoveCards([]int{1}, Target{ParentID: 2}))
+		if c := card(1); c.BackgroundColor != "#C8E6C9" || c.ListID != 2 {
+			t.Errorf("moved card %+v", c)
+		}
+		must(s.SetCardColors(1, "", ""))
+		must(s.MoveCards([]int{1}, Target{ParentID: 2}))
+		if c := card(1); c.BackgroundColor != "" {
+			t.Errorf("reordered card %+v", c)
+		}
+
+		// Created, copied and cloned cards get the checklist, but not
+		// the rule of the other board
+		id, err := s.CreateCard(1, "E", "Notes")
+		must(err)
+		if c := card(id); c.Description != "Notes\n- [ ] Review" || c.TextColor != "" {
+			t.Errorf("created card %+v", c)
+		}
+		copies, err := s.CopyCards([]int{4}, Target{ParentID: 1})
+		must(err)
+		if c := card(copies[0]); c.Description != "- [ ] Review" {
+			t.Errorf("copied card %+v", c)
+		}
+		clone, err := s.CloneCard(3, CloneOptions{})
+		must(err)
+		if c := card(clone); c.Description != "About C\n- [ ] Review" {
+			t.Errorf("cloned card %+v", c)
+		}
+
+		// Moving into Ops schedules the archive; moving out cancels it
+		now := time.Now()
+		must(s.MoveCards([]int{2}, Target{ParentID: 3}))
+		must(s.MoveCards([]int{3}, Target{ParentID: 3}))
+		must(s.MoveCards([]int{3}, Target{ParentID: 1}))
+		if n, err := s.ArchiveDue(now.AddDate(0, 0, 6)); err != nil || n != 0 {
+			t.Errorf("archived %d early, %v", n, err)
+		}
+		if n, err := s.ArchiveDue(now.AddDate(0, 0, 8)); err != nil || n != 1 {
+			t.Errorf("archived %d after a week, %v", n, err)
+		}
+
+		// Disabled and deleted rules do not fire
+		rules[0].Enabled = false
+		must(s.SaveRule(rules[0]))
+		must(s.DeleteRule(rules[1].ID))
+		must(s.MoveCards([]int{3}, Target{ParentID: 2}))
+		if c := card(3); c.BackgroundColor != "" {
+			t.Errorf("moved by a disabled rule %+v", c)
+		}
+		id, err = s.CreateCard(2, "F", "")
+		must(err)
+		if c := card(id); c.Description != "" {
+			t.Errorf("created after deleting the rule %+v", c)
+		}
+
+		if err := s.SaveRule(&Rule{BoardID: 1, Name: " ", Trigger: rules[0].Trigger, Action: rules[0].Action}); err == nil {
+			t.Error("saved a rule without a name")
+		}
+		if err := s.SaveRule(&Rule{BoardID: 1, Name: "Bad", Trigger: Trigger{Event: "deleted"}, Action: rules[0].Action}); err == nil {
+			t.Error("saved an invalid rule")
+		}
+		if err := s.SaveRule(&Rule{ID: 99, BoardID: 1, Name: "Gone", Trigger: rules[0].Trigger, Action: rules[0].Action}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("SaveRule of rule 99 error %v, want ErrNoRows", err)
+		}
+		must(s.DeleteBoard(1))
+		if got, err := s.Rules(1); err != nil || len(got) != 0 {
+			t.Errorf("rules of the deleted board %+v, %v", got, err)
+		}
+		if got, err := s.Rules(2); err != nil || len(got) != 1 {
+			t.Errorf("rules of the other board %+v, %v", got, err)
+		}
+	})
+}
+
+func TestStorageRandom(t *testing.T) {
+	var all []Storage
+	for _, st := range storages {
+		s := st.open(t)
+		fill(t, s)
+		all = append(all, s)
+	}
+	rng := rand.New(rand.NewSource(1))
+	ids := func(n int) []int {
+		picked := rng.Perm(n + 1)[:1+rng.Intn(2)]
+		for i := range picked {
+			picked[i]++
+		}
+		return picked
+	}
+	ops := []func(s Storage, a, b, c int, sel []int) error{
+		func(s Storage, a, b, c int, sel []int) error {
+			return s.MoveCards(sel, Target{ParentID: a, BeforeID: b})
+		},
+		func(s Storage, a, b, c int, sel []int) error {
+			return s.MoveLists(sel, Target{ParentID: a, BeforeID: b})
+		},
+		func(s Storage, a, b, c int, sel []int) error { return s.MoveSwimlanes(sel, Target{ParentID: a % 3}) },
+		func(s Storage, a, b, c int, sel []int) error {
+			return second(s.CopyCards(sel, Target{ParentID: a, BeforeID: b}))
+		},
+		func(s Storage, a, b, c int, sel []int) error { return second(s.CopyLists(sel, Target{ParentID: a})) },
+		func(s Storage, a, b, c int, sel []int) error {
+			return second(s.CloneCard(c, CloneOptions{ParentID: a}))
+		},
+		func(s Storage, a, b, c int, sel []int) error { return second(s.CreateCard(a, fmt.Sprint("N", c), "")) },
+		func(s Storage, a, b, c int, sel []int) error { return s.DeleteCard(c) },
+		func(s Storage, a, b, c int, sel []int) error { return s.DeleteList(c) },
+		func(s Storage, a, b, c int, sel []int) error { return s.ArchiveCard(c) },
+	}
+	for step := 0; step < 300; step++ {
+		op := rng.Intn(len(ops))
+		a, b, c := 1+rng.Intn(6), rng.Intn(12), 1+rng.Intn(12)
+		sel := ids(10)
+		var results []string
+		for _, s := range all {
+			err := ops[op](s, a, b, c, sel)
+			results = append(results, fmt.Sprint(errors.Is(err, sql.ErrNoRows), err == nil))
+		}
+		if results[0] != results[1] {
+			t.Fatalf("step %d: op %d(%d, %d, %d, %v) results %v", step, op, a, b, c, sel, results)
+		}
+		if got, want := outlines(t, all[1]), outlines(t, all[0]); got != want {
+			t.Fatalf("step %d: op %d(%d, %d, %d, %v): memory %q, sqlite %q", step, op, a, b, c, sel, got, want)
+		}
+	}
+}
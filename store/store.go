@@ -0,0 +1,224 @@
+// Package store loads board trees from wekan.db for display. Trees are read
+// with two queries and without attachment blobs; attachments are fetched on
+// demand through an Attachments cache.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Swimlane is a swimlane with its lists and cards, ordered by position.
+type Swimlane struct {
+	ID              int
+	BoardID         int
+	Name            string
+	Position        int
+	TextColor       string
+	BackgroundColor string
+	BackgroundImage string
+	IsTemplate      bool
+	Lists           []List
+}
+
+type List struct {
+	ID              int
+	SwimlaneID      int
+	Name            string
+	Position        int
+	TextColor       string
+	BackgroundColor string
+	BackgroundImage string
+	// WIPLimit is the maximum number of cards in the list, 0 for none. With
+	// WIPHard set, moves and new cards beyond the limit need confirmation.
+	WIPLimit   int
+	WIPHard    bool
+	IsTemplate bool
+	Cards      []Card
+}
+
+// WIP returns the work-in-progress state of a loaded list.
+func (l List) WIP() WIP {
+	return WIP{Count: len(l.Cards), Limit: l.WIPLimit, Hard: l.WIPHard}
+}
+
+// Card holds the displayed fields of a card. AttachmentSize is the size of
+// the attachment in bytes, 0 if the card has none; the bytes themselves are
+// loaded with Attachments.Get.
+type Card struct {
+	ID              int
+	ListID          int
+	Title           string
+	Description     string
+	Position        int
+	CreatedAt       string
+	TextColor       string
+	BackgroundColor string
+	AttachmentSize  int
+	IsTemplate      bool
+}
+
+// indexes back the child lookups of the tree query. Without them SQLite
+// builds a temporary index per query that copies the attachment blobs.
+const indexes = `
+	CREATE INDEX IF NOT EXISTS idx_swimlanes_board ON swimlanes (board_id, position);
+	CREATE INDEX IF NOT EXISTS idx_lists_swimlane ON lists (swimlane_id, position);
+	CREATE INDEX IF NOT EXISTS idx_cards_list ON cards (list_id, position);
+`
+
+// EnsureIndexes creates the indexes used by the loaders if they are missing.
+func EnsureIndexes(db *sql.DB) error {
+	if _, err := db.Exec(indexes); err != nil {
+		return fmt.Errorf("create indexes: %w", err)
+	}
+	return nil
+}
+
+// LoadBoard loads the swimlanes of a board with their lists and cards.
+func LoadBoard(db *sql.DB, boardID int) ([]Swimlane, error) {
+	tree, err := loadTree(db, "s.board_id = ?", boardID)
+	if err != nil {
+		return nil, fmt.Errorf("load board %d: %w", boardID, err)
+	}
+	return tree, nil
+}
+
+// LoadSwimlane loads one swimlane with its lists and cards.
+func LoadSwimlane(db *sql.DB, swimlaneID int) (*Swimlane, error) {
+	tree, err := loadTree(db, "s.id = ?", swimlaneID)
+	if err != nil {
+		return nil, fmt.Errorf("load swimlane %d: %w", swimlaneID, err)
+	}
+	if len(tree) == 0 {
+		return nil, fmt.Errorf("load swimlane %d: %w", swimlaneID, sql.ErrNoRows)
+	}
+	return &tree[0], nil
+}
+
+// LoadList loads one list with its cards.
+func LoadList(db *sql.DB, listID int) (*List, error) {
+	tree, err := loadTree(db, "l.id = ?", listID)
+	if err != nil {
+		return nil, fmt.Errorf("load list %d: %w", listID, err)
+	}
+	if len(tree) == 0 || len(tree[0].Lists) == 0 {
+		return nil, fmt.Errorf("load list %d: %w", listID, sql.ErrNoRows)
+	}
+	return &tree[0].Lists[0], nil
+}
+
+// LoadCard loads one card.
+func LoadCard(db *sql.DB, cardID int) (*Card, error) {
+	var c Card
+	err := db.QueryRow(`SELECT id, list_id, title, COALESCE(description, ''), position, COALESCE(created_at, ''),
+		COALESCE(text_color, ''), COALESCE(background_color, ''), COALESCE(LENGTH(attachment), 0),
+		COALESCE(is_template, 0)
+		FROM cards WHERE id = ?`, cardID).
+		Scan(&c.ID, &c.ListID, &c.Title, &c.Description, &c.Position, &c.CreatedAt,
+			&c.TextColor, &c.BackgroundColor, &c.AttachmentSize, &c.IsTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("load card %d: %w", cardID, err)
+	}
+	return &c, nil
+}
+
+// CardIDs returns the IDs of the unarchived cards in a list, ordered by
+// position.
+func CardIDs(db *sql.DB, listID int) ([]int, error) {
+	rows, err := db.Query("SELECT id FROM cards WHERE list_id = ? AND COALESCE(archived, 0) = 0 ORDER BY position, id", listID)
+	if err != nil {
+		return nil, fmt.Errorf("load cards of list %d: %w", listID, err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan card: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// loadTree loads the swimlanes, lists and cards matching where, e.g.
+// "s.id = ?" for one swimlane or "l.id = ?" for one list with its swimlane.
+// It uses two queries: one for the swimlanes and lists, and one for the
+// cards, so that the swimlane and list columns are not repeated per card.
+func loadTree(db *sql.DB, where string, arg int) ([]Swimlane, error) {
+	rows, err := db.Query(`SELECT s.id, s.board_id, s.name, s.position,
+			COALESCE(s.text_color, ''), COALESCE(s.background_color, ''), COALESCE(s.background_image, ''),
+			COALESCE(s.is_template, 0),
+			l.id, COALESCE(l.name, ''), COALESCE(l.position, 0),
+			COALESCE(l.text_color, ''), COALESCE(l.background_color, ''), COALESCE(l.background_image, ''),
+			COALESCE(l.wip_limit, 0), COALESCE(l.wip_hard, 0), COALESCE(l.is_template, 0)
+		FROM swimlanes s
+		LEFT JOIN lists l ON l.swimlane_id = s.id
+		WHERE `+where+`
+		ORDER BY s.position, s.id, l.position, l.id`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tree []Swimlane
+	type listRef struct{ swimlane, list int }
+	listIndex := make(map[int]listRef)
+	for rows.Next() {
+		var s Swimlane
+		var l List
+		var listID sql.NullInt64
+		err := rows.Scan(&s.ID, &s.BoardID, &s.Name, &s.Position, &s.TextColor, &s.BackgroundColor, &s.BackgroundImage,
+			&s.IsTemplate,
+			&listID, &l.Name, &l.Position, &l.TextColor, &l.BackgroundColor, &l.BackgroundImage,
+			&l.WIPLimit, &l.WIPHard, &l.IsTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("scan board tree: %w", err)
+		}
+		if len(tree) == 0 || tree[len(tree)-1].ID != s.ID {
+			tree = append(tree, s)
+		}
+		if listID.Valid {
+			st := &tree[len(tree)-1]
+			l.ID, l.SwimlaneID = int(listID.Int64), s.ID
+			listIndex[l.ID] = listRef{len(tree) - 1, len(st.Lists)}
+			st.Lists = append(st.Lists, l)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if len(listIndex) == 0 {
+		return tree, nil
+	}
+
+	rows, err = db.Query(`SELECT c.id, c.list_id, c.title, COALESCE(c.description, ''), c.position, COALESCE(c.created_at, ''),
+			COALESCE(c.text_color, ''), COALESCE(c.background_color, ''), COALESCE(LENGTH(c.attachment), 0),
+			COALESCE(c.is_template, 0)
+		FROM swimlanes s
+		JOIN lists l ON l.swimlane_id = s.id
+		JOIN cards c ON c.list_id = l.id
+		WHERE `+where+` AND COALESCE(c.archived, 0) = 0
+		ORDER BY c.list_id, c.position, c.id`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c Card
+		err := rows.Scan(&c.ID, &c.ListID, &c.Title, &c.Description, &c.Position, &c.CreatedAt,
+			&c.TextColor, &c.BackgroundColor, &c.AttachmentSize, &c.IsTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("scan card: %w", err)
+		}
+		ref, ok := listIndex[c.ListID]
+		if !ok {
+			continue
+		}
+		l := &tree[ref.swimlane].Lists[ref.list]
+		l.Cards = append(l.Cards, c)
+	}
+	return tree, rows.Err()
+}
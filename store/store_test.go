@@ -0,0 +1,173 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openEmptyDB creates a database with the schema of Init and no boards.
+func openEmptyDB(t testing.TB) *sql.DB {
+	t.Helper()
+	return openEmptyDBAt(t, filepath.Join(t.TempDir(), "wekan.db"))
+}
+
+// openEmptyDBAt is openEmptyDB at path.
+func openEmptyDBAt(t testing.TB, path string) *sql.DB {
+	t.Helper()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := Init(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// openTestDB creates a database with one board: an empty swimlane listed
+// first by position, a swimlane with an empty list, and cards stored out of
+// position order, one of them with an attachment.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := openEmptyDB(t)
+	stmts := []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO boards (id, name) VALUES (1, 'Board'), (2, 'Other')", nil},
+		{"INSERT INTO swimlanes (id, board_id, name, position, text_color) VALUES (1, 1, 'Development', 1, '#FFFFFF')", nil},
+		{"INSERT INTO swimlanes (id, board_id, name, position) VALUES (2, 1, 'Empty', 0)", nil},
+		{"INSERT INTO swimlanes (id, board_id, name, position) VALUES (3, 2, 'Elsewhere', 0)", nil},
+		{"INSERT INTO lists (id, swimlane_id, name, position, background_color) VALUES (1, 1, 'Todo', 0, '#EEEEEE')", nil},
+		{"INSERT INTO lists (id, swimlane_id, name, position) VALUES (2, 1, 'Done', 1)", nil},
+		{"INSERT INTO lists (id, swimlane_id, name, position) VALUES (3, 3, 'Other list', 0)", nil},
+		{"INSERT INTO cards (id, list_id, title, description, position) VALUES (1, 1, 'Second', NULL, 1)", nil},
+		{"INSERT INTO cards (id, list_id, title, description, position, attachment) VALUES (2, 1, 'First', 'With file', 0, ?)", []any{[]byte("attachment bytes")}},
+		{"INSERT INTO cards (id, list_id, title, position) VALUES (3, 3, 'Not on board', 0)", nil},
+	}
+	for _, s := range stmts {
+		if _, err := db.Exec(s.query, s.args...); err != nil {
+			t.Fatalf("%s: %v", s.query, err)
+		}
+	}
+	return db
+}
+
+func TestLoadBoard(t *testing.T) {
+	db := openTestDB(t)
+	tree, err := LoadBoard(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != 2 || tree[0].Name != "Empty" || tree[1].Name != "Development" {
+		t.Fatalf("swimlanes %+v, want Empty and Development", tree)
+	}
+	if len(tree[0].Lists) != 0 {
+		t.Errorf("empty swimlane has lists %+v", tree[0].Lists)
+	}
+	dev := tree[1]
+	if dev.TextColor != "#FFFFFF" || dev.BoardID != 1 {
+		t.Errorf("swimlane %+v, want text color and board ID", dev)
+	}
+	if len(dev.Lists) != 2 || dev.Lists[0].Name != "Todo" || dev.Lists[1].Name != "Done" || len(dev.Lists[1].Cards) != 0 {
+		t.Fatalf("lists %+v, want Todo with cards and empty Done", dev.Lists)
+	}
+	todo := dev.Lists[0]
+	if todo.SwimlaneID != 1 || todo.BackgroundColor != "#EEEEEE" {
+		t.Errorf("list %+v, want swimlane ID and background color", todo)
+	}
+	if len(todo.Cards) != 2 || todo.Cards[0].Title != "First" || todo.Cards[1].Title != "Second" {
+		t.Fatalf("cards %+v, want First and Second", todo.Cards)
+	}
+	if c := todo.Cards[0]; c.ListID != 1 || c.Description != "With file" || c.AttachmentSize != len("attachment bytes") || c.CreatedAt == "" {
+		t.Errorf("card %+v", c)
+	}
+	if c := todo.Cards[1]; c.AttachmentSize != 0 || c.Description != "" {
+		t.Errorf("card without attachment %+v", c)
+	}
+
+	if tree, err := LoadBoard(db, 99); err != nil || len(tree) != 0 {
+		t.Errorf("missing board: %+v, %v", tree, err)
+	}
+}
+
+func TestLoadParts(t *testing.T) {
+	db := openTestDB(t)
+	s, err := LoadSwimlane(db, 1)
+	if err != nil || s.Name != "Development" || len(s.Lists) != 2 {
+		t.Errorf("LoadSwimlane: %+v, %v", s, err)
+	}
+	l, err := LoadList(db, 1)
+	if err != nil || l.Name != "Todo" || l.SwimlaneID != 1 || len(l.Cards) != 2 {
+		t.Errorf("LoadList: %+v, %v", l, err)
+	}
+	if l, err := LoadList(db, 2); err != nil || len(l.Cards) != 0 {
+		t.Errorf("LoadList of empty list: %+v, %v", l, err)
+	}
+	c, err := LoadCard(db, 2)
+	if err != nil || c.Title != "First" || c.ListID != 1 || c.AttachmentSize == 0 {
+		t.Errorf("LoadCard: %+v, %v", c, err)
+	}
+	ids, err := CardIDs(db, 1)
+	if err != nil || len(ids) != 2 || ids[0] != 2 || ids[1] != 1 {
+		t.Errorf("CardIDs: %v, %v", ids, err)
+	}
+
+	if _, err := LoadSwimlane(db, 99); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("LoadSwimlane(99) error %v, want sql.ErrNoRows", err)
+	}
+	if _, err := LoadList(db, 99); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("LoadList(99) error %v, want sql.ErrNoRows", err)
+	}
+	if _, err := LoadCard(db, 99); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("LoadCard(99) error %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	db := openTestDB(t)
+	a := NewAttachments(NewSQLite(db), 2)
+
+	data, err := a.Get(2)
+	if err != nil || !bytes.Equal(data, []byte("attachment bytes")) {
+		t.Fatalf("Get(2) = %q, %v", data, err)
+	}
+	if data, err := a.Get(1); err != nil || data != nil {
+		t.Errorf("Get(1) = %q, %v, want no attachment", data, err)
+	}
+	if _, err := a.Get(99); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Get(99) error %v, want sql.ErrNoRows", err)
+	}
+
+	// Served from the cache until invalidated
+	if _, err := db.Exec("UPDATE cards SET attachment = ? WHERE id = 2", []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := a.Get(2); string(data) != "attachment bytes" {
+		t.Errorf("cached Get(2) = %q", data)
+	}
+	a.Invalidate(2)
+	if data, _ := a.Get(2); string(data) != "changed" {
+		t.Errorf("Get(2) after Invalidate = %q", data)
+	}
+
+	// Card 1 is least recently used and evicted by card 3
+	if _, err := a.Get(3); err != nil {
+		t.Fatal(err)
+	}
+	if a.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", a.Len())
+	}
+	if _, ok := a.items[1]; ok {
+		t.Error("least recently used attachment not evicted")
+	}
+	if _, ok := a.items[2]; !ok {
+		t.Error("recently used attachment evicted")
+	}
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// TemplateKind is the kind of item marked as a template.
+type TemplateKind string
+
+const (
+	TemplateBoard    TemplateKind = "board"
+	TemplateSwimlane TemplateKind = "swimlane"
+	TemplateList     TemplateKind = "list"
+	TemplateCard     TemplateKind = "card"
+)
+
+// templateTables maps the template kinds to their tables.
+var templateTables = map[TemplateKind]string{
+	TemplateBoard:    "boards",
+	TemplateSwimlane: "swimlanes",
+	TemplateList:     "lists",
+	TemplateCard:     "cards",
+}
+
+// Template is an item marked as a template, with the name of its board.
+type Template struct {
+	Kind  TemplateKind
+	ID    int
+	Name  string
+	Board string
+}
+
+// EnsureTemplates adds the is_template column to the boards, swimlanes,
+// lists and cards tables if it is missing.
+func EnsureTemplates(db *sql.DB) error {
+	for _, table := range []string{"boards", "swimlanes", "lists", "cards"} {
+		if err := addColumn(db, table, "is_template", "INTEGER DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetTemplate marks an item as a template or unmarks it.
+func SetTemplate(db *sql.DB, kind TemplateKind, id int, on bool) error {
+	table, ok := templateTables[kind]
+	if !ok {
+		return fmt.Errorf("set template: invalid kind %q", kind)
+	}
+	res, err := db.Exec("UPDATE "+table+" SET is_template = ? WHERE id = ?", on, id)
+	if err != nil {
+		return fmt.Errorf("set template %s %d: %w", kind, id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("set template %s %d: %w", kind, id, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// IsTemplate reports whether an item is marked as a template.
+func IsTemplate(db *sql.DB, kind TemplateKind, id int) (bool, error) {
+	table, ok := templateTables[kind]
+	if !ok {
+		return false, fmt.Errorf("check template: invalid kind %q", kind)
+	}
+	var on bool
+	err := db.QueryRow("SELECT COALESCE(is_template, 0) FROM "+table+" WHERE id = ?", id).Scan(&on)
+	if err != nil {
+		return false, fmt.Errorf("check template %s %d: %w", kind, id, err)
+	}
+	return on, nil
+}
+
+// templateQueries select the templates of each kind with their board name.
+var templateQueries = map[TemplateKind]string{
+	TemplateBoard: `SELECT b.id, b.name, b.name FROM boards b
+		WHERE b.is_template = 1`,
+	TemplateSwimlane: `SELECT s.id, s.name, b.name FROM swimlanes s
+		JOIN boards b ON b.id = s.board_id
+		WHERE s.is_template = 1`,
+	TemplateList: `SELECT l.id, l.name, b.name FROM lists l
+		JOIN swimlanes s ON s.id = l.swimlane_id
+		JOIN boards b ON b.id = s.board_id
+		WHERE l.is_template = 1`,
+	TemplateCard: `SELECT c.id, c.title, b.name FROM cards c
+		JOIN lists l ON l.id = c.list_id
+		JOIN swimlanes s ON s.id = l.swimlane_id
+		JOIN boards b ON b.id = s.board_id
+		WHERE c.is_template = 1`,
+}
+
+// Templates returns the templates of a kind on all boards, ordered by board
+// and name.
+func Templates(db *sql.DB, kind TemplateKind) ([]Template, error) {
+	query, ok := templateQueries[kind]
+	if !ok {
+		return nil, fmt.Errorf("load templates: invalid kind %q", kind)
+	}
+	rows, err := db.Query(query + " ORDER BY 3, 2, 1")
+	if err != nil {
+		return nil, fmt.Errorf("load %s templates: %w", kind, err)
+	}
+	defer rows.Close()
+
+	var templates []Template
+	for rows.Next() {
+		t := Template{Kind: kind}
+		if err := rows.Scan(&t.ID, &t.Name, &t.Board); err != nil {
+			return nil, fmt.Errorf("scan template: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// placeholder matches "{{name}}" with optional spaces around the name.
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// Placeholders returns the sorted names of the placeholders in texts.
+func Placeholders(texts ...string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, s := range texts {
+		for _, m := range placeholder.FindAllStringSubmatch(s, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				names = append(names, m[1])
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FillPlaceholders replaces the placeholders in s that have a value.
+// Others are kept so that a missing value shows.
+func FillPlaceholders(s string, values map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		if v, ok := values[placeholder.FindStringSubmatch(m)[1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// PlaceholderDefaults returns the values of the built-in placeholders:
+// date (YYYY-MM-DD), year and week (ISO week number).
+func PlaceholderDefaults(now time.Time) map[string]string {
+	year, week := now.ISOWeek()
+	return map[string]string{
+		"date": now.Format("2006-01-02"),
+		"year": fmt.Sprint(now.Year()),
+		"week": fmt.Sprintf("%d-W%02d", year, week),
+	}
+}
+
+// TemplatePlaceholders returns the placeholder names used by a template and
+// the items it contains.
+func TemplatePlaceholders(s Storage, kind TemplateKind, id int) ([]string, error) {
+	var texts []string
+	addLists := func(lists []List) {
+		for _, l := range lists {
+			texts = append(texts, l.Name)
+			for _, c := range l.Cards {
+				texts = append(texts, c.Title, c.Description)
+			}
+		}
+	}
+	addSwimlanes := func(swimlanes []Swimlane) {
+		for _, sl := range swimlanes {
+			texts = append(texts, sl.Name)
+			addLists(sl.Lists)
+		}
+	}
+	switch kind {
+	case TemplateBoard:
+		b, err := s.Board(id)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := s.LoadBoard(id)
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, b.Name, b.Description)
+		addSwimlanes(tree)
+	case TemplateSwimlane:
+		sl, err := s.LoadSwimlane(id)
+		if err != nil {
+			return nil, err
+		}
+		addSwimlanes([]Swimlane{*sl})
+	case TemplateList:
+		l, err := s.LoadList(id)
+		if err != nil {
+			return nil, err
+		}
+		addLists([]List{*l})
+	case TemplateCard:
+		c, err := s.LoadCard(id)
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, c.Title, c.Description)
+	default:
+		return nil, fmt.Errorf("template placeholders: invalid kind %q", kind)
+	}
+	return Placeholders(texts...), nil
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlaceholders(t *testing.T) {
+	got := Placeholders("Sprint {{sprint}} ({{ date }})", "", "Review {{sprint}}, {{team_1}} {{not a name}}")
+	if want := []string{"date", "sprint", "team_1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Placeholders = %q, want %q", got, want)
+	}
+
+	values := map[string]string{"sprint": "12", "date": "2025-03-10"}
+	tests := []struct{ in, want string }{
+		{"Sprint {{sprint}}", "Sprint 12"},
+		{"{{ date }}: {{sprint}}/{{sprint}}", "2025-03-10: 12/12"},
+		{"Owner {{owner}}", "Owner {{owner}}"},
+		{"No placeholders", "No placeholders"},
+	}
+	for _, tt := range tests {
+		if got := FillPlaceholders(tt.in, values); got != tt.want {
+			t.Errorf("FillPlaceholders(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	defaults := PlaceholderDefaults(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC))
+	if defaults["date"] != "2025-01-01" || defaults["year"] != "2025" || defaults["week"] != "2025-W01" {
+		t.Errorf("PlaceholderDefaults = %v", defaults)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	db := openTestDB(t)
+	stmts := []string{
+		"UPDATE boards SET name = 'Sprint {{sprint}}', description = 'Starts {{date}}' WHERE id = 1",
+		"UPDATE lists SET name = 'Todo {{sprint}}' WHERE id = 1",
+		"UPDATE cards SET description = 'Review by {{owner}}' WHERE id = 1",
+	}
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range []struct {
+		kind TemplateKind
+		id   int
+	}{{TemplateBoard, 1}, {TemplateList, 1}, {TemplateCard, 1}, {TemplateCard, 3}, {TemplateSwimlane, 3}} {
+		if err := SetTemplate(db, m.kind, m.id, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SetTemplate(db, TemplateCard, 3, false); err != nil {
+		t.Fatal(err)
+	}
+
+	cards, err := Templates(db, TemplateCard)
+	if err != nil || len(cards) != 1 || cards[0].ID != 1 || cards[0].Board != "Sprint {{sprint}}" {
+		t.Errorf("card templates %+v, %v", cards, err)
+	}
+	swimlanes, err := Templates(db, TemplateSwimlane)
+	if err != nil || len(swimlanes) != 1 || swimlanes[0].Name != "Elsewhere" || swimlanes[0].Board != "Other" {
+		t.Errorf("swimlane templates %+v, %v", swimlanes, err)
+	}
+	if ok, err := IsTemplate(db, TemplateList, 1); err != nil || !ok {
+		t.Errorf("IsTemplate(list 1) = %v, %v", ok, err)
+	}
+	if l, _ := LoadList(db, 1); !l.IsTemplate || !l.Cards[1].IsTemplate || l.Cards[0].IsTemplate {
+		t.Errorf("loaded template flags %+v", l)
+	}
+
+	names, err := TemplatePlaceholders(NewSQLite(db), TemplateBoard, 1)
+	if want := []string{"date", "owner", "sprint"}; err != nil || !reflect.DeepEqual(names, want) {
+		t.Errorf("board placeholders %q, %v, want %q", names, err, want)
+	}
+	names, err = TemplatePlaceholders(NewSQLite(db), TemplateCard, 2)
+	if err != nil || len(names) != 0 {
+		t.Errorf("card placeholders %q, %v", names, err)
+	}
+
+	if err := SetTemplate(db, TemplateList, 99, true); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("SetTemplate(list 99) error %v, want sql.ErrNoRows", err)
+	}
+	if _, err := Templates(db, "column"); err == nil {
+		t.Error("loaded templates of an invalid kind")
+	}
+}
+
+func TestEnsureTemplates(t *testing.T) {
+	db := openOldDB(t)
+	for i := 0; i < 2; i++ {
+		if err := EnsureTemplates(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SetTemplate(db, TemplateList, 1, true); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SchemaVersion is the version of the schema created and migrated by this
+// version of the programs. It is stored in PRAGMA user_version once all
+// migrations have run; databases of older versions have 0.
+//
+//	1: the schema version is recorded
+//	2: foreign keys are enforced; orphaned rows left by older versions are
+//	   reported, and doctor recovers them
+const SchemaVersion = 2
+
+// LoadSchemaVersion returns the schema version of a database.
+func LoadSchemaVersion(db *sql.DB) (int, error) {
+	var v int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&v); err != nil {
+		return 0, fmt.Errorf("load schema version: %w", err)
+	}
+	return v, nil
+}
+
+// SetSchemaVersion records the schema version of a database after its
+// migrations.
+func SetSchemaVersion(db *sql.DB, v int) error {
+	// PRAGMA does not take parameters
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", v)); err != nil {
+		return fmt.Errorf("set schema version: %w", err)
+	}
+	return nil
+}
+
+// CheckSchemaVersion returns an error if a database has an older schema
+// version than SchemaVersion, for programs that read databases without
+// migrating them.
+func CheckSchemaVersion(db *sql.DB) error {
+	v, err := LoadSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if v < SchemaVersion {
+		return fmt.Errorf("schema version %d is older than %d: open the database with kanban_go once to migrate it", v, SchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Watcher detects changes committed to a database by other programs, such
+// as the Tcl/Tk GUI, the exporters or create_sample_data.tcl.
+//
+// It polls PRAGMA data_version, which changes when a connection other than
+// the one reading it commits. db must therefore be limited to a single
+// connection with db.SetMaxOpenConns(1), so that the changes made through
+// db itself are not reported.
+type Watcher struct {
+	db      *sql.DB
+	version int64
+}
+
+// NewWatcher returns a watcher reporting the changes from now on.
+func NewWatcher(db *sql.DB) (*Watcher, error) {
+	w := &Watcher{db: db}
+	if err := w.read(&w.version); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Changed reports whether another program committed changes since the
+// previous call.
+func (w *Watcher) Changed() (bool, error) {
+	var version int64
+	if err := w.read(&version); err != nil {
+		return false, err
+	}
+	changed := version != w.version
+	w.version = version
+	return changed, nil
+}
+
+func (w *Watcher) read(version *int64) error {
+	if err := w.db.QueryRow("PRAGMA data_version").Scan(version); err != nil {
+		return fmt.Errorf("read data version: %w", err)
+	}
+	return nil
+}
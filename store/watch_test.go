@@ -0,0 +1,49 @@
+package store
+
+import "testing"
+
+func TestWatcher(t *testing.T) {
+	db, path := openFKDB(t)
+	db.SetMaxOpenConns(1)
+	w, err := NewWatcher(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := func() bool {
+		t.Helper()
+		changed, err := w.Changed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changed
+	}
+
+	if _, err := db.Exec("UPDATE boards SET name = 'Own change' WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if changed() {
+		t.Error("reported a change made through db")
+	}
+
+	other, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.Exec("UPDATE boards SET name = 'Other program' WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if !changed() {
+		t.Error("missed a change of another program")
+	}
+	if changed() {
+		t.Error("reported the change twice")
+	}
+
+	// Reads of other programs are not changes
+	var n int
+	other.QueryRow("SELECT COUNT(*) FROM cards").Scan(&n)
+	if changed() {
+		t.Error("reported a read")
+	}
+}
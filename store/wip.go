@@ -0,0 +1,61 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// WIP is the work-in-progress state of a list: its number of cards and
+// its limit.
+type WIP struct {
+	Count int
+	Limit int // 0 for no limit
+	Hard  bool
+}
+
+// Exceeded reports whether the list holds more cards than its limit.
+func (w WIP) Exceeded() bool {
+	return w.Limit > 0 && w.Count > w.Limit
+}
+
+// Allows reports whether n more cards may be added without overriding a
+// hard limit. Soft limits allow everything.
+func (w WIP) Allows(n int) bool {
+	return !w.Hard || w.Limit <= 0 || w.Count+n <= w.Limit
+}
+
+// String formats the state as "count/limit", or just the count without a
+// limit.
+func (w WIP) String() string {
+	if w.Limit <= 0 {
+		return fmt.Sprint(w.Count)
+	}
+	return fmt.Sprintf("%d/%d", w.Count, w.Limit)
+}
+
+// LoadWIP returns the number of cards and the limit of a list.
+func LoadWIP(db *sql.DB, listID int) (WIP, error) {
+	var w WIP
+	err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM cards WHERE list_id = lists.id AND COALESCE(archived, 0) = 0),
+		COALESCE(wip_limit, 0), COALESCE(wip_hard, 0)
+		FROM lists WHERE id = ?`, listID).Scan(&w.Count, &w.Limit, &w.Hard)
+	if err != nil {
+		return WIP{}, fmt.Errorf("load WIP limit of list %d: %w", listID, err)
+	}
+	return w, nil
+}
+
+// SetWIPLimit sets the limit of a list; 0 removes it.
+func SetWIPLimit(db *sql.DB, listID, limit int, hard bool) error {
+	if limit < 0 {
+		return fmt.Errorf("set WIP limit of list %d: negative limit %d", listID, limit)
+	}
+	res, err := db.Exec("UPDATE lists SET wip_limit = ?, wip_hard = ? WHERE id = ?", limit, hard, listID)
+	if err != nil {
+		return fmt.Errorf("set WIP limit of list %d: %w", listID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("set WIP limit of list %d: %w", listID, sql.ErrNoRows)
+	}
+	return nil
+}
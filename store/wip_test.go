@@ -0,0 +1,63 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestWIP(t *testing.T) {
+	tests := []struct {
+		wip      WIP
+		exceeded bool
+		allows   bool // one more card
+		text     string
+	}{
+		{WIP{Count: 3}, false, true, "3"},
+		{WIP{Count: 2, Limit: 3}, false, true, "2/3"},
+		{WIP{Count: 3, Limit: 3}, false, true, "3/3"},
+		{WIP{Count: 3, Limit: 3, Hard: true}, false, false, "3/3"},
+		{WIP{Count: 2, Limit: 3, Hard: true}, false, true, "2/3"},
+		{WIP{Count: 4, Limit: 3}, true, true, "4/3"},
+		{WIP{Count: 4, Hard: true}, false, true, "4"},
+	}
+	for _, tt := range tests {
+		if got := tt.wip.Exceeded(); got != tt.exceeded {
+			t.Errorf("%+v.Exceeded() = %v", tt.wip, got)
+		}
+		if got := tt.wip.Allows(1); got != tt.allows {
+			t.Errorf("%+v.Allows(1) = %v", tt.wip, got)
+		}
+		if got := tt.wip.String(); got != tt.text {
+			t.Errorf("%+v.String() = %q, want %q", tt.wip, got, tt.text)
+		}
+	}
+}
+
+func TestWIPLimit(t *testing.T) {
+	db := openTestDB(t)
+	if err := SetWIPLimit(db, 1, 2, true); err != nil {
+		t.Fatal(err)
+	}
+	w, err := LoadWIP(db, 1)
+	if err != nil || w != (WIP{Count: 2, Limit: 2, Hard: true}) {
+		t.Errorf("LoadWIP(1) = %+v, %v", w, err)
+	}
+	l, err := LoadList(db, 1)
+	if err != nil || l.WIP() != w {
+		t.Errorf("LoadList(1).WIP() = %+v, %v, want %+v", l.WIP(), err, w)
+	}
+	if w, err := LoadWIP(db, 2); err != nil || w != (WIP{}) {
+		t.Errorf("LoadWIP(2) = %+v, %v, want no cards and no limit", w, err)
+	}
+
+	if err := SetWIPLimit(db, 1, -1, false); err == nil {
+		t.Error("set a negative limit")
+	}
+	if err := SetWIPLimit(db, 99, 1, false); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("SetWIPLimit(99) error %v, want sql.ErrNoRows", err)
+	}
+	if _, err := LoadWIP(db, 99); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("LoadWIP(99) error %v, want sql.ErrNoRows", err)
+	}
+}
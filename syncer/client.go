@@ -0,0 +1,762 @@
+package syncer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// table is a synced table and the column referencing its parent.
+type table struct {
+	name, parentColumn, parentTable string
+}
+
+// tables are the synced tables, parents first so that a push sends a new
+// board before its swimlanes.
+var tables = []table{
+	{"boards", "", ""},
+	{"swimlanes", "board_id", "boards"},
+	{"lists", "swimlane_id", "swimlanes"},
+	{"cards", "list_id", "lists"},
+}
+
+func findTable(name string) (table, bool) {
+	for _, t := range tables {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return table{}, false
+}
+
+// timeFormat is the format of Op.Time and sync_changes.changed_at.
+const timeFormat = "2006-01-02 15:04:05.000"
+
+// clientSchema holds the client's ID and position in the server's log
+// (sync_state), the global IDs of the synced rows with the Seq and the
+// fields of their last operation (sync_entities), and the rows changed
+// since the last push (sync_changes). Rows of deleted rows are kept in
+// sync_entities so that their deletion can be pushed.
+const clientSchema = `
+	CREATE TABLE IF NOT EXISTS sync_state (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		client TEXT NOT NULL,
+		cursor INTEGER NOT NULL DEFAULT 0,
+		applying INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS sync_entities (
+		tbl TEXT NOT NULL,
+		row_id INTEGER NOT NULL,
+		entity TEXT NOT NULL UNIQUE,
+		version INTEGER NOT NULL DEFAULT 0,
+		fields TEXT,
+		PRIMARY KEY (tbl, row_id)
+	);
+	CREATE TABLE IF NOT EXISTS sync_changes (
+		tbl TEXT NOT NULL,
+		row_id INTEGER NOT NULL,
+		changed_at TEXT NOT NULL,
+		PRIMARY KEY (tbl, row_id)
+	);
+`
+
+// triggerSQL records the changes of a table in sync_changes, except those
+// made while applying pulled operations.
+const triggerSQL = `
+	CREATE TRIGGER IF NOT EXISTS sync_%[1]s_%[2]s AFTER %[2]s ON %[1]s
+	WHEN (SELECT applying FROM sync_state) = 0
+	BEGIN
+		INSERT OR REPLACE INTO sync_changes (tbl, row_id, changed_at)
+		VALUES ('%[1]s', %[3]s.id, strftime('%%Y-%%m-%%d %%H:%%M:%%f', 'now'));
+	END;
+`
+
+// Enable prepares db for syncing: it creates the sync tables, the CRDT
+// columns and the triggers recording changes. The first time, it gives
+// the database a client ID and marks every existing row as changed, so
+// that the first sync pushes them.
+func Enable(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("enable sync: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(clientSchema); err != nil {
+		return fmt.Errorf("enable sync: %w", err)
+	}
+	// Added after the first version of the sync
+	columns := map[string]string{"sync_entities": "fields"}
+	for table, column := range crdtColumns {
+		columns[table] = column
+	}
+	for table, column := range columns {
+		var n int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n); err != nil {
+			return fmt.Errorf("enable sync: %w", err)
+		}
+		if n == 0 {
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s TEXT", table, column)); err != nil {
+				return fmt.Errorf("enable sync: %w", err)
+			}
+		}
+	}
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM sync_state").Scan(&n); err != nil {
+		return fmt.Errorf("enable sync: %w", err)
+	}
+	if n == 0 {
+		if _, err := tx.Exec("INSERT INTO sync_state (id, client) VALUES (1, ?)", newID()); err != nil {
+			return fmt.Errorf("enable sync: %w", err)
+		}
+		now := time.Now().UTC().Format(timeFormat)
+		for _, t := range tables {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO sync_changes (tbl, row_id, changed_at) SELECT ?, id, ? FROM "+t.name, t.name, now); err != nil {
+				return fmt.Errorf("enable sync: %w", err)
+			}
+		}
+	}
+	for _, t := range tables {
+		for _, event := range []string{"INSERT", "UPDATE", "DELETE"} {
+			row := "NEW"
+			if event == "DELETE" {
+				row = "OLD"
+			}
+			if _, err := tx.Exec(fmt.Sprintf(triggerSQL, t.name, event, row)); err != nil {
+				return fmt.Errorf("enable sync: %w", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("enable sync: %w", err)
+	}
+	return nil
+}
+
+// newID returns a random global ID.
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Result is the outcome of a sync.
+type Result struct {
+	// Pulled is the number of operations of other clients applied.
+	Pulled int
+	// Pushed is the number of local changes logged by the server.
+	Pushed int
+	// Conflicts are the concurrent changes decided by the sync.
+	Conflicts []Conflict
+}
+
+// addConflict reports a conflict, unless both changes left the row the
+// same, apart from its merged columns.
+func (res *Result) addConflict(kept, lost *Op) {
+	if kept.Deleted == lost.Deleted && kept.Parent == lost.Parent && sameFields(kept.Fields, lost.Fields) {
+		return
+	}
+	res.Conflicts = append(res.Conflicts, Conflict{Kept: *kept, Lost: *lost})
+}
+
+// Client syncs a database with a server.
+type Client struct {
+	db     *sql.DB
+	server string
+	token  string
+	id     string
+	http   *http.Client
+}
+
+// NewClient enables syncing db with the server at serverURL, such as
+// "http://host:8547". token is sent if the server requires one.
+func NewClient(db *sql.DB, serverURL, token string) (*Client, error) {
+	if err := Enable(db); err != nil {
+		return nil, err
+	}
+	c := &Client{db: db, server: strings.TrimSuffix(serverURL, "/"), token: token, http: &http.Client{Timeout: time.Minute}}
+	if err := db.QueryRow("SELECT client FROM sync_state").Scan(&c.id); err != nil {
+		return nil, fmt.Errorf("read client ID: %w", err)
+	}
+	return c, nil
+}
+
+// ID returns the client ID of the database.
+func (c *Client) ID() string {
+	return c.id
+}
+
+// Sync applies the operations of the other clients, then pushes the local
+// changes. Concurrent changes of a row are decided by their times: pulled
+// operations older than a local change are not applied, and the server
+// rejects pushed changes older than what it has. Descriptions and card
+// order are merged instead.
+func (c *Client) Sync() (*Result, error) {
+	res := &Result{}
+	if err := c.pull(res); err != nil {
+		return res, err
+	}
+	if err := c.push(res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+func (c *Client) pull(res *Result) error {
+	for {
+		var cursor int64
+		if err := c.db.QueryRow("SELECT cursor FROM sync_state").Scan(&cursor); err != nil {
+			return fmt.Errorf("pull: %w", err)
+		}
+		var resp pullResponse
+		if err := c.call(http.MethodGet, "/pull?since="+url.QueryEscape(fmt.Sprint(cursor)), nil, &resp); err != nil {
+			return fmt.Errorf("pull: %w", err)
+		}
+		if len(resp.Ops) == 0 {
+			return nil
+		}
+		err := c.applying(func(b *batch) error {
+			for i := range resp.Ops {
+				if err := b.applyPulled(&resp.Ops[i], res); err != nil {
+					return err
+				}
+			}
+			_, err := b.tx.Exec("UPDATE sync_state SET cursor = ?", resp.Ops[len(resp.Ops)-1].Seq)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("pull: %w", err)
+		}
+		if !resp.More {
+			return nil
+		}
+	}
+}
+
+// batch is a transaction applying pulled operations or reading the local
+// changes, in which the triggers do not record changes.
+type batch struct {
+	*Client
+	tx *sql.Tx
+	// touched are the lists whose card positions are set from their
+	// order at the end of the batch
+	touched map[int64]bool
+}
+
+// applying runs f in a batch. Local changes to descriptions and card
+// order are added to their CRDTs first, so that merging keeps them.
+func (c *Client) applying(f func(b *batch) error) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	b := &batch{Client: c, tx: tx, touched: make(map[int64]bool)}
+	if _, err := tx.Exec("UPDATE sync_state SET applying = 1"); err != nil {
+		return err
+	}
+	if err := b.absorb(); err != nil {
+		return err
+	}
+	if err := f(b); err != nil {
+		return err
+	}
+	for listID := range b.touched {
+		if err := b.renumber(listID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE sync_state SET applying = 0"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// applyPulled applies an operation of the log, unless it is our own or it
+// loses against a local change not pushed yet.
+func (b *batch) applyPulled(op *Op, res *Result) error {
+	if op.Client == b.id {
+		if op.Merge {
+			return nil
+		}
+		// Our push succeeded but its response was lost
+		_, err := b.tx.Exec("UPDATE sync_entities SET version = ? WHERE entity = ? AND version < ?", op.Seq, op.Entity, op.Seq)
+		return err
+	}
+	if op.Merge {
+		if err := b.applyMerge(op); err != nil {
+			return err
+		}
+		res.Pulled++
+		return nil
+	}
+	var tbl string
+	var rowID, version int64
+	err := b.tx.QueryRow("SELECT tbl, row_id, version FROM sync_entities WHERE entity = ?", op.Entity).Scan(&tbl, &rowID, &version)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && version >= op.Seq {
+		// Our push won over op
+		return nil
+	}
+	if err == nil {
+		var changedAt string
+		err := b.tx.QueryRow("SELECT changed_at FROM sync_changes WHERE tbl = ? AND row_id = ?", tbl, rowID).Scan(&changedAt)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil {
+			mine, err := b.localOp(tbl, rowID, changedAt)
+			if err != nil {
+				return err
+			}
+			switch {
+			case mine == nil, mine.Deleted && op.Deleted:
+			case mine.after(op):
+				// Pushed next, on top of op
+				res.addConflict(mine, op)
+				_, err := b.tx.Exec("UPDATE sync_entities SET version = ? WHERE entity = ?", op.Seq, op.Entity)
+				return err
+			default:
+				res.addConflict(op, mine)
+			}
+		}
+	}
+	if err := b.apply(op); err != nil {
+		return err
+	}
+	res.Pulled++
+	return nil
+}
+
+// apply writes a row operation to the database: it deletes, updates or
+// inserts the row. Operations of rows whose parent is missing, because it
+// was deleted, are ignored.
+func (b *batch) apply(op *Op) error {
+	t, ok := findTable(op.Table)
+	if !ok {
+		return nil
+	}
+	rowID, exists, err := b.lookup(t, op.Entity)
+	if err != nil {
+		return err
+	}
+	if t.name == "cards" && exists {
+		// The card may leave its list
+		var listID int64
+		if err := b.tx.QueryRow("SELECT list_id FROM cards WHERE id = ?", rowID).Scan(&listID); err != nil {
+			return err
+		}
+		b.touched[listID] = true
+	}
+
+	if op.Deleted {
+		if exists {
+			if _, err := b.tx.Exec("DELETE FROM "+t.name+" WHERE id = ?", rowID); err != nil {
+				return err
+			}
+		}
+		_, err := b.tx.Exec("UPDATE sync_entities SET version = ? WHERE entity = ?", op.Seq, op.Entity)
+		return err
+	}
+
+	var columns []string
+	var values []any
+	if t.parentColumn != "" {
+		var parentID int64
+		err := b.tx.QueryRow(`SELECT e.row_id FROM sync_entities e JOIN `+t.parentTable+` p ON p.id = e.row_id
+			WHERE e.entity = ? AND e.tbl = ?`, op.Parent, t.parentTable).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		columns = append(columns, t.parentColumn)
+		values = append(values, parentID)
+		if t.name == "cards" {
+			b.touched[parentID] = true
+		}
+	}
+	rows, err := b.tx.Query("SELECT name, UPPER(type) FROM pragma_table_info(?)", t.name)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			rows.Close()
+			return err
+		}
+		value, ok := op.Fields[name]
+		if !ok || name == "id" || name == t.parentColumn || merged(t.name, name) {
+			continue
+		}
+		if s, ok := value.(string); ok && strings.Contains(typ, "BLOB") {
+			if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+				value = b
+			}
+		}
+		columns = append(columns, name)
+		values = append(values, value)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	if exists {
+		set := make([]string, len(columns))
+		for i, column := range columns {
+			set[i] = fmt.Sprintf("%q = ?", column)
+		}
+		if _, err := b.tx.Exec("UPDATE "+t.name+" SET "+strings.Join(set, ", ")+" WHERE id = ?", append(values, rowID)...); err != nil {
+			return err
+		}
+	} else {
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = fmt.Sprintf("%q", column)
+		}
+		r, err := b.tx.Exec("INSERT INTO "+t.name+" ("+strings.Join(quoted, ", ")+") VALUES (?"+strings.Repeat(", ?", len(columns)-1)+")", values...)
+		if err != nil {
+			return err
+		}
+		if rowID, err = r.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	synced, err := syncedFields(op)
+	if err != nil {
+		return err
+	}
+	_, err = b.tx.Exec(`INSERT INTO sync_entities (tbl, row_id, entity, version, fields) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (entity) DO UPDATE SET row_id = excluded.row_id, version = excluded.version, fields = excluded.fields`,
+		t.name, rowID, op.Entity, op.Seq, synced)
+	return err
+}
+
+// lookup returns the local row of an entity and whether it exists.
+func (b *batch) lookup(t table, entity string) (rowID int64, exists bool, err error) {
+	err = b.tx.QueryRow("SELECT row_id FROM sync_entities WHERE entity = ? AND tbl = ?", entity, t.name).Scan(&rowID)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	err = b.tx.QueryRow("SELECT EXISTS (SELECT 1 FROM "+t.name+" WHERE id = ?)", rowID).Scan(&exists)
+	return rowID, exists, err
+}
+
+// syncedFields encodes the parent and fields of a row operation, to tell
+// whether the row changed since.
+func syncedFields(op *Op) (string, error) {
+	data, err := json.Marshal(struct {
+		Parent string         `json:"parent,omitempty"`
+		Fields map[string]any `json:"fields"`
+	}{op.Parent, op.Fields})
+	return string(data), err
+}
+
+// sameFields reports whether two rows have the same fields.
+func sameFields(a, b map[string]any) bool {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	return err == nil && bytes.Equal(ja, jb)
+}
+
+// pushed is a change sent to the server and its operations.
+type pushed struct {
+	table     string
+	rowID     int64
+	changedAt string
+	ops       []int
+}
+
+// push sends the recorded changes and applies the operations that won
+// over them.
+func (c *Client) push(res *Result) error {
+	// An idle sync writes nothing, so that programs watching the
+	// database do not reload
+	var pending bool
+	if err := c.db.QueryRow("SELECT EXISTS (SELECT 1 FROM sync_changes)").Scan(&pending); err != nil {
+		return fmt.Errorf("push: %w", err)
+	}
+	if !pending {
+		return nil
+	}
+	var changes []pushed
+	var ops []Op
+	err := c.applying(func(b *batch) error {
+		for _, t := range tables {
+			rows, err := b.tx.Query("SELECT row_id, changed_at FROM sync_changes WHERE tbl = ? ORDER BY changed_at, row_id", t.name)
+			if err != nil {
+				return err
+			}
+			var list []pushed
+			for rows.Next() {
+				p := pushed{table: t.name}
+				if err := rows.Scan(&p.rowID, &p.changedAt); err != nil {
+					rows.Close()
+					return err
+				}
+				list = append(list, p)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			for _, p := range list {
+				op, err := b.localOp(t.name, p.rowID, p.changedAt)
+				if err != nil {
+					return err
+				}
+				if op != nil {
+					p.ops = append(p.ops, len(ops))
+					ops = append(ops, *op)
+				}
+				mergeOp, err := b.mergeOp(t.name, p.rowID, p.changedAt)
+				if err != nil {
+					return err
+				}
+				if mergeOp != nil {
+					p.ops = append(p.ops, len(ops))
+					ops = append(ops, *mergeOp)
+				}
+				changes = append(changes, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("push: %w", err)
+	}
+
+	var resp pushResponse
+	if len(ops) > 0 {
+		if err := c.call(http.MethodPost, "/push", pushRequest{Ops: ops}, &resp); err != nil {
+			return fmt.Errorf("push: %w", err)
+		}
+		if len(resp.Results) != len(ops) {
+			return fmt.Errorf("push: %d results for %d operations", len(resp.Results), len(ops))
+		}
+	}
+	err = c.applying(func(b *batch) error {
+		for _, p := range changes {
+			// Changed again since read: keep the change for the next push
+			var changedAt string
+			err := b.tx.QueryRow("SELECT changed_at FROM sync_changes WHERE tbl = ? AND row_id = ?", p.table, p.rowID).Scan(&changedAt)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			changedAgain := err == nil && changedAt != p.changedAt
+			if err == nil && !changedAgain {
+				if _, err := b.tx.Exec("DELETE FROM sync_changes WHERE tbl = ? AND row_id = ?", p.table, p.rowID); err != nil {
+					return err
+				}
+			}
+			for _, i := range p.ops {
+				if err := b.pushed(&ops[i], resp.Results[i], changedAgain, res); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("push: %w", err)
+	}
+	return nil
+}
+
+// pushed records the outcome of a pushed operation.
+func (b *batch) pushed(op *Op, result pushResult, changedAgain bool, res *Result) error {
+	switch {
+	case op.Merge:
+		if result.Seq > 0 {
+			res.Pushed++
+		}
+		return nil
+	case result.Winner != nil:
+		res.addConflict(result.Winner, op)
+		if changedAgain {
+			// The newer change is pushed next, on top of the winner
+			_, err := b.tx.Exec("UPDATE sync_entities SET version = ? WHERE entity = ?", result.Winner.Seq, op.Entity)
+			return err
+		}
+		return b.apply(result.Winner)
+	case result.Seq > 0:
+		res.Pushed++
+		if result.Lost != nil {
+			res.addConflict(op, result.Lost)
+		}
+		synced, err := syncedFields(op)
+		if err != nil {
+			return err
+		}
+		if op.Deleted {
+			synced = ""
+		}
+		_, err = b.tx.Exec("UPDATE sync_entities SET version = ?, fields = ? WHERE entity = ?", result.Seq, synced, op.Entity)
+		return err
+	}
+	return nil
+}
+
+// localOp returns the operation of a changed row: its deletion, or its
+// content if it changed since the last sync. It gives rows without one a
+// global ID, and returns nil for unchanged rows and rows deleted before
+// they were ever pushed.
+func (b *batch) localOp(tableName string, rowID int64, changedAt string) (*Op, error) {
+	t, ok := findTable(tableName)
+	if !ok {
+		return nil, fmt.Errorf("unknown table %s", tableName)
+	}
+	op := &Op{Client: b.id, Table: t.name, Time: changedAt}
+	var synced sql.NullString
+	err := b.tx.QueryRow("SELECT entity, version, fields FROM sync_entities WHERE tbl = ? AND row_id = ?", t.name, rowID).Scan(&op.Entity, &op.Base, &synced)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	known := err == nil
+
+	values, err := b.row(t.name, rowID)
+	if err == sql.ErrNoRows {
+		if !known {
+			return nil, nil
+		}
+		op.Deleted = true
+		return op, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !known {
+		op.Entity = newID()
+		if _, err := b.tx.Exec("INSERT INTO sync_entities (tbl, row_id, entity) VALUES (?, ?, ?)", t.name, rowID, op.Entity); err != nil {
+			return nil, err
+		}
+	}
+	op.Fields = make(map[string]any)
+	for column, value := range values {
+		switch {
+		case column == "id", merged(t.name, column):
+		case column == t.parentColumn:
+			parentID, _ := value.(int64)
+			if op.Parent, err = b.entityOf(t.parentTable, parentID); err != nil {
+				return nil, err
+			}
+		default:
+			op.Fields[column] = value
+		}
+	}
+	current, err := syncedFields(op)
+	if err != nil {
+		return nil, err
+	}
+	if synced.Valid && synced.String == current {
+		return nil, nil
+	}
+	return op, nil
+}
+
+// row returns the columns of a row, or sql.ErrNoRows.
+func (b *batch) row(tableName string, rowID int64) (map[string]any, error) {
+	rows, err := b.tx.Query("SELECT name FROM pragma_table_info(?) ORDER BY cid", tableName)
+	if err != nil {
+		return nil, err
+	}
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// +column drops the declared type, so that the driver returns
+	// timestamps as they are stored
+	exprs := make([]string, len(columns))
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i, column := range columns {
+		exprs[i] = fmt.Sprintf("+%q", column)
+		dest[i] = &values[i]
+	}
+	if err := b.tx.QueryRow("SELECT "+strings.Join(exprs, ", ")+" FROM "+tableName+" WHERE id = ?", rowID).Scan(dest...); err != nil {
+		return nil, err
+	}
+	row := make(map[string]any, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	return row, nil
+}
+
+// entityOf returns the global ID of a row, giving it one if needed.
+func (b *batch) entityOf(tableName string, rowID int64) (string, error) {
+	var entity string
+	err := b.tx.QueryRow("SELECT entity FROM sync_entities WHERE tbl = ? AND row_id = ?", tableName, rowID).Scan(&entity)
+	if err == sql.ErrNoRows {
+		entity = newID()
+		_, err = b.tx.Exec("INSERT INTO sync_entities (tbl, row_id, entity) VALUES (?, ?, ?)", tableName, rowID, entity)
+	}
+	return entity, err
+}
+
+// call sends a request to the server and decodes its JSON response.
+func (c *Client) call(method, path string, body, resp any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.server+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	r, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(r.Body, 1024))
+		return fmt.Errorf("%s: %s", r.Status, strings.TrimSpace(string(msg)))
+	}
+	dec := json.NewDecoder(r.Body)
+	// Numbers stay exact and are stored as the column's type
+	dec.UseNumber()
+	return dec.Decode(resp)
+}
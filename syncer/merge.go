@@ -0,0 +1,318 @@
+package syncer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"tcl-tk-kanban/crdt"
+)
+
+// crdtColumns are the columns holding the CRDT state of a row: the text of
+// a card's description (crdt.Text) and the order of a list's cards
+// (crdt.List of card entities). They are synced by merge operations.
+var crdtColumns = map[string]string{
+	"cards": "description_crdt",
+	"lists": "card_order",
+}
+
+// derivedColumns follow from the CRDT states and are not synced by row
+// operations: the description of a card from its text, and its position
+// from the order of its list.
+var derivedColumns = map[string][]string{
+	"cards": {"description", "position"},
+}
+
+// merged reports whether a column is synced by merge operations.
+func merged(table, column string) bool {
+	return crdtColumns[table] == column || slices.Contains(derivedColumns[table], column)
+}
+
+// absorb adds the edits of descriptions and the card moves made since the
+// last sync, by any program, to the CRDTs.
+func (b *batch) absorb() error {
+	var cards []int64
+	lists := make(map[int64]bool)
+	rows, err := b.tx.Query(`SELECT c.id, c.list_id FROM sync_changes s JOIN cards c ON c.id = s.row_id
+		WHERE s.tbl = 'cards'`)
+	if err != nil {
+		return fmt.Errorf("absorb: %w", err)
+	}
+	for rows.Next() {
+		var id, listID int64
+		if err := rows.Scan(&id, &listID); err != nil {
+			rows.Close()
+			return fmt.Errorf("absorb: %w", err)
+		}
+		cards = append(cards, id)
+		lists[listID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("absorb: %w", err)
+	}
+	rows, err = b.tx.Query("SELECT l.id FROM sync_changes s JOIN lists l ON l.id = s.row_id WHERE s.tbl = 'lists'")
+	if err != nil {
+		return fmt.Errorf("absorb: %w", err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("absorb: %w", err)
+		}
+		lists[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("absorb: %w", err)
+	}
+
+	for _, id := range cards {
+		if err := b.absorbText(id); err != nil {
+			return fmt.Errorf("absorb card %d: %w", id, err)
+		}
+	}
+	for id := range lists {
+		if err := b.absorbOrder(id); err != nil {
+			return fmt.Errorf("absorb list %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// absorbText edits the text of a card to its description.
+func (b *batch) absorbText(cardID int64) error {
+	var description, state sql.NullString
+	if err := b.tx.QueryRow("SELECT description, description_crdt FROM cards WHERE id = ?", cardID).Scan(&description, &state); err != nil {
+		return err
+	}
+	var text crdt.Text
+	if state.String != "" {
+		if err := json.Unmarshal([]byte(state.String), &text); err != nil {
+			return err
+		}
+	}
+	if ops := text.Edit(b.id, description.String); len(ops) == 0 && state.Valid {
+		return nil
+	}
+	data, err := json.Marshal(&text)
+	if err != nil {
+		return err
+	}
+	_, err = b.tx.Exec("UPDATE cards SET description_crdt = ? WHERE id = ?", string(data), cardID)
+	return err
+}
+
+// absorbOrder moves the cards in the order of a list to their positions,
+// and marks the list changed if they moved.
+func (b *batch) absorbOrder(listID int64) error {
+	var ids []int64
+	rows, err := b.tx.Query("SELECT id FROM cards WHERE list_id = ? ORDER BY position, id", listID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	entities := make([]string, len(ids))
+	for i, id := range ids {
+		if entities[i], err = b.entityOf("cards", id); err != nil {
+			return err
+		}
+	}
+
+	order, valid, err := b.loadOrder(listID)
+	if err != nil {
+		return err
+	}
+	if valid && slices.Equal(order.Items(), entities) {
+		return nil
+	}
+	order.SetOrder(b.id, entities)
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	if _, err := b.tx.Exec("UPDATE lists SET card_order = ? WHERE id = ?", string(data), listID); err != nil {
+		return err
+	}
+	return b.markChanged("lists", listID)
+}
+
+// loadOrder returns the order of a list's cards and whether it has one.
+func (b *batch) loadOrder(listID int64) (*crdt.List, bool, error) {
+	var state sql.NullString
+	if err := b.tx.QueryRow("SELECT card_order FROM lists WHERE id = ?", listID).Scan(&state); err != nil {
+		return nil, false, err
+	}
+	order := &crdt.List{}
+	if state.String == "" {
+		return order, false, nil
+	}
+	if err := json.Unmarshal([]byte(state.String), order); err != nil {
+		return nil, false, err
+	}
+	return order, true, nil
+}
+
+// markChanged records a change made while applying, so that it is pushed.
+func (b *batch) markChanged(table string, rowID int64) error {
+	_, err := b.tx.Exec("INSERT OR IGNORE INTO sync_changes (tbl, row_id, changed_at) VALUES (?, ?, ?)",
+		table, rowID, time.Now().UTC().Format(timeFormat))
+	return err
+}
+
+// mergeOp returns the merge operation of a changed row with a CRDT
+// column, or nil.
+func (b *batch) mergeOp(table string, rowID int64, changedAt string) (*Op, error) {
+	column, ok := crdtColumns[table]
+	if !ok {
+		return nil, nil
+	}
+	var state sql.NullString
+	var entity string
+	err := b.tx.QueryRow(`SELECT t.`+column+`, e.entity FROM `+table+` t
+		JOIN sync_entities e ON e.tbl = ? AND e.row_id = t.id WHERE t.id = ?`, table, rowID).Scan(&state, &entity)
+	if err == sql.ErrNoRows || (err == nil && !state.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Op{
+		Client: b.id, Entity: entity, Table: table, Time: changedAt, Merge: true,
+		Fields: map[string]any{column: state.String},
+	}, nil
+}
+
+// applyMerge merges a pulled CRDT state into its row. If the local state
+// had edits the pulled one lacks, the row is pushed again.
+func (b *batch) applyMerge(op *Op) error {
+	t, ok := findTable(op.Table)
+	column := crdtColumns[op.Table]
+	remote, isString := op.Fields[column].(string)
+	if !ok || column == "" || !isString {
+		return nil
+	}
+	rowID, exists, err := b.lookup(t, op.Entity)
+	if err != nil || !exists {
+		return err
+	}
+
+	var data []byte
+	switch t.name {
+	case "cards":
+		var state sql.NullString
+		if err := b.tx.QueryRow("SELECT description_crdt FROM cards WHERE id = ?", rowID).Scan(&state); err != nil {
+			return err
+		}
+		var text, other crdt.Text
+		if state.String != "" {
+			if err := json.Unmarshal([]byte(state.String), &text); err != nil {
+				return err
+			}
+		}
+		if err := json.Unmarshal([]byte(remote), &other); err != nil {
+			return fmt.Errorf("card %s: %w", op.Entity, err)
+		}
+		text.Merge(&other)
+		if data, err = json.Marshal(&text); err != nil {
+			return err
+		}
+		if _, err := b.tx.Exec("UPDATE cards SET description_crdt = ?, description = ? WHERE id = ?", string(data), text.String(), rowID); err != nil {
+			return err
+		}
+	case "lists":
+		order, _, err := b.loadOrder(rowID)
+		if err != nil {
+			return err
+		}
+		var other crdt.List
+		if err := json.Unmarshal([]byte(remote), &other); err != nil {
+			return fmt.Errorf("list %s: %w", op.Entity, err)
+		}
+		order.Merge(&other)
+		if data, err = json.Marshal(order); err != nil {
+			return err
+		}
+		if _, err := b.tx.Exec("UPDATE lists SET card_order = ? WHERE id = ?", string(data), rowID); err != nil {
+			return err
+		}
+		b.touched[rowID] = true
+	}
+	if string(data) != remote {
+		return b.markChanged(t.name, rowID)
+	}
+	return nil
+}
+
+// renumber sets the positions of a list's cards from its order. Cards
+// missing from the order, such as cards added by a program while the
+// order was merged, follow in their current order.
+func (b *batch) renumber(listID int64) error {
+	order, valid, err := b.loadOrder(listID)
+	if err != nil || !valid {
+		return err
+	}
+	rank := make(map[string]int)
+	for i, item := range order.Items() {
+		rank[item] = i
+	}
+	type card struct {
+		id       int64
+		position sql.NullInt64
+		entity   string
+	}
+	var cards []card
+	rows, err := b.tx.Query(`SELECT c.id, c.position, COALESCE(e.entity, '') FROM cards c
+		LEFT JOIN sync_entities e ON e.tbl = 'cards' AND e.row_id = c.id WHERE c.list_id = ?`, listID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var c card
+		if err := rows.Scan(&c.id, &c.position, &c.entity); err != nil {
+			rows.Close()
+			return err
+		}
+		cards = append(cards, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	sort.Slice(cards, func(i, j int) bool {
+		ri, inI := rank[cards[i].entity]
+		rj, inJ := rank[cards[j].entity]
+		switch {
+		case inI && inJ:
+			return ri < rj
+		case inI != inJ:
+			return inI
+		case cards[i].position.Int64 != cards[j].position.Int64:
+			return cards[i].position.Int64 < cards[j].position.Int64
+		}
+		return cards[i].entity < cards[j].entity
+	})
+	for i, c := range cards {
+		if !c.position.Valid || c.position.Int64 != int64(i) {
+			if _, err := b.tx.Exec("UPDATE cards SET position = ? WHERE id = ?", i, c.id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Package syncer keeps the boards of several kanban databases, for example
+// on two desktops, in sync through a small server.
+//
+// The server stores an operation log: every change of a board, swimlane,
+// list or card pushed by a client, numbered in the order the server
+// received it. Clients pull the operations of the other clients since the
+// last number they saw, then push their own changes.
+//
+// Changes are recorded by triggers in the database, so the changes of
+// every program are synced, including the Tcl/Tk GUI. Each synced row has
+// a global ID, as the row IDs of two databases collide. An operation
+// carries all the columns of its row; when two clients change the same
+// row concurrently, the later change wins (last writer wins) and the
+// other is reported as a Conflict. Rules and saved filters are not
+// synced.
+//
+// Card descriptions and the order of the cards in a list are CRDTs (see
+// package crdt) instead, kept in the description_crdt and card_order
+// columns: concurrent edits are merged, so text typed offline on two
+// desktops and cards moved on both are all kept. Edits of the plain
+// description and position columns, by any program, are added to the
+// CRDTs when syncing.
+package syncer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is a change of a row: its new content, or its deletion.
+type Op struct {
+	// Seq numbers the operation in the server's log; 0 until it is
+	// pushed.
+	Seq int64 `json:"seq,omitempty"`
+	// Client is the ID of the client that made the change.
+	Client string `json:"client"`
+	// Entity is the global ID of the row.
+	Entity string `json:"entity"`
+	// Table is boards, swimlanes, lists or cards.
+	Table string `json:"table"`
+	// Parent is the global ID of the board of a swimlane, the swimlane
+	// of a list or the list of a card.
+	Parent string `json:"parent,omitempty"`
+	// Fields are the columns of the row, except its ID, its parent and
+	// the columns synced by merge operations; blobs are base64 encoded.
+	Fields  map[string]any `json:"fields,omitempty"`
+	Deleted bool           `json:"deleted,omitempty"`
+	// Merge marks an operation carrying the CRDT state of a description
+	// or of the card order of a list, which is merged into the row
+	// instead of replacing it and never conflicts.
+	Merge bool `json:"merge,omitempty"`
+	// Time is when the change was made, in UTC, as
+	// "2006-01-02 15:04:05.000".
+	Time string `json:"time"`
+	// Base is the Seq of the last operation of the entity the client had
+	// applied when it made the change; 0 for new rows.
+	Base int64 `json:"base"`
+}
+
+// after reports whether op wins over other: the later change wins, and
+// the client IDs break ties so that every replica picks the same winner.
+func (op *Op) after(other *Op) bool {
+	if op.Time != other.Time {
+		return op.Time > other.Time
+	}
+	return op.Client > other.Client
+}
+
+// describe names the row and the change for conflict messages.
+func (op *Op) describe() string {
+	name := op.Entity
+	for _, column := range []string{"title", "name"} {
+		if s, ok := op.Fields[column].(string); ok {
+			name = fmt.Sprintf("%q", s)
+			break
+		}
+	}
+	if op.Deleted {
+		return fmt.Sprintf("%s deleted", name)
+	}
+	return name
+}
+
+// Conflict is a pair of concurrent changes of the same row; Kept won and
+// Lost was discarded.
+type Conflict struct {
+	Kept, Lost Op
+}
+
+func (c Conflict) String() string {
+	singular := strings.TrimSuffix(c.Kept.Table, "s")
+	return fmt.Sprintf("%s %s: kept the change of %s at %s (%s), discarded the change of %s at %s (%s)",
+		singular, c.Kept.Entity, c.Kept.Client, c.Kept.Time, c.Kept.describe(),
+		c.Lost.Client, c.Lost.Time, c.Lost.describe())
+}
+
+// pushRequest is the body of POST /push.
+type pushRequest struct {
+	Ops []Op `json:"ops"`
+}
+
+// pushResult is the outcome of one pushed operation: its Seq if the
+// server logged it, or 0 and the operation that won over it.
+type pushResult struct {
+	Seq    int64 `json:"seq"`
+	Winner *Op   `json:"winner,omitempty"`
+	// Lost is the logged operation the pushed one won over.
+	Lost *Op `json:"lost,omitempty"`
+}
+
+// pushResponse is the body of the response to POST /push.
+type pushResponse struct {
+	Results []pushResult `json:"results"`
+}
+
+// pullResponse is the body of the response to GET /pull.
+type pullResponse struct {
+	Ops []Op `json:"ops"`
+	// More is set if the response was limited and more operations
+	// follow.
+	More bool `json:"more,omitempty"`
+}
@@ -0,0 +1,212 @@
+package syncer
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// pullLimit is the maximum number of operations of a pull response.
+const pullLimit = 500
+
+// MaxPushSize is the maximum size in bytes of a push request. It is large
+// because the first push of a desktop carries all its cards, attachments
+// included.
+const MaxPushSize = 256 << 20
+
+const serverSchema = `
+	CREATE TABLE IF NOT EXISTS sync_log (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		entity TEXT NOT NULL,
+		op TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_sync_log_entity ON sync_log(entity, seq);
+`
+
+// Server is the HTTP handler of the sync server. It serves
+//
+//	POST /push         log the operations of a client
+//	GET  /pull?since=N the operations after Seq N
+//
+// and keeps the operation log in a database of its own.
+type Server struct {
+	db    *sql.DB
+	token string
+	// maxPush is the maximum size of a push request, MaxPushSize
+	maxPush int64
+	// mu makes checking for conflicts and logging a push atomic
+	mu sync.Mutex
+}
+
+// NewServer returns a server keeping its log in db. If token is not
+// empty, requests must carry it as "Authorization: Bearer <token>".
+func NewServer(db *sql.DB, token string) (*Server, error) {
+	if _, err := db.Exec(serverSchema); err != nil {
+		return nil, fmt.Errorf("create sync log: %w", err)
+	}
+	return &Server{db: db, token: token, maxPush: MaxPushSize}, nil
+}
+
+// Loopback reports whether addr, a host:port to listen on, only accepts
+// connections from the same machine. An empty host listens on every
+// interface.
+func Loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	var resp any
+	var err error
+	switch {
+	case r.URL.Path == "/push" && r.Method == http.MethodPost:
+		var req pushRequest
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxPush))
+		dec.UseNumber()
+		if err := dec.Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = s.push(req.Ops)
+	case r.URL.Path == "/pull" && r.Method == http.MethodGet:
+		since, perr := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+		if perr != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		resp, err = s.pull(since)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// push logs the operations, except those that lose a conflict: a row
+// operation conflicts with the last logged operation of its entity if
+// that one is from another client and newer than the operation's Base.
+func (s *Server) push(ops []Op) (*pushResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("push: %w", err)
+	}
+	defer tx.Rollback()
+
+	resp := &pushResponse{Results: make([]pushResult, len(ops))}
+	for i := range ops {
+		op := ops[i]
+		result := &resp.Results[i]
+		// Merged states are logged apart from the row operations
+		key := op.Entity
+		if op.Merge {
+			key += "/merge"
+		}
+		latest, err := lastOp(tx, key)
+		if err != nil {
+			return nil, fmt.Errorf("push: %w", err)
+		}
+		if !op.Merge && latest != nil && latest.Seq > op.Base && latest.Client != op.Client {
+			if !op.after(latest) {
+				result.Winner = latest
+				continue
+			}
+			result.Lost = latest
+		}
+		op.Seq = 0
+		data, err := json.Marshal(op)
+		if err != nil {
+			return nil, fmt.Errorf("push: %w", err)
+		}
+		res, err := tx.Exec("INSERT INTO sync_log (entity, op) VALUES (?, ?)", key, data)
+		if err != nil {
+			return nil, fmt.Errorf("push: %w", err)
+		}
+		if result.Seq, err = res.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("push: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("push: %w", err)
+	}
+	return resp, nil
+}
+
+// pull returns up to pullLimit operations after since.
+func (s *Server) pull(since int64) (*pullResponse, error) {
+	rows, err := s.db.Query("SELECT seq, op FROM sync_log WHERE seq > ? ORDER BY seq LIMIT ?", since, pullLimit+1)
+	if err != nil {
+		return nil, fmt.Errorf("pull: %w", err)
+	}
+	defer rows.Close()
+	resp := &pullResponse{Ops: []Op{}}
+	for rows.Next() {
+		if len(resp.Ops) == pullLimit {
+			resp.More = true
+			break
+		}
+		op, err := scanOp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("pull: %w", err)
+		}
+		resp.Ops = append(resp.Ops, *op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("pull: %w", err)
+	}
+	return resp, nil
+}
+
+// lastOp returns the last logged operation of an entity, or nil.
+func lastOp(tx *sql.Tx, entity string) (*Op, error) {
+	op, err := scanOp(tx.QueryRow("SELECT seq, op FROM sync_log WHERE entity = ? ORDER BY seq DESC LIMIT 1", entity))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return op, err
+}
+
+func scanOp(row interface{ Scan(...any) error }) (*Op, error) {
+	var seq int64
+	var data []byte
+	if err := row.Scan(&seq, &data); err != nil {
+		return nil, err
+	}
+	var op Op
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&op); err != nil {
+		return nil, fmt.Errorf("operation %d: %w", seq, err)
+	}
+	op.Seq = seq
+	return &op, nil
+}
@@ -0,0 +1,414 @@
+package syncer
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"tcl-tk-kanban/store"
+)
+
+// startServer runs a sync server with its log in a temporary file.
+func startServer(t *testing.T, token string) string {
+	t.Helper()
+	return startServerWith(t, token, func(*Server) {})
+}
+
+// startServerWith starts a server after letting configure change it.
+func startServerWith(t *testing.T, token string, configure func(*Server)) string {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "sync.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	srv, err := NewServer(db, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configure(srv)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+// newReplica creates a database with the kanban schema, runs setup on it
+// and returns a client syncing it.
+func newReplica(t *testing.T, serverURL, setup string) (*sql.DB, *Client) {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "wekan.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := store.Init(db); err != nil {
+		t.Fatal(err)
+	}
+	exec(t, db, setup)
+	c, err := NewClient(db, serverURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, c
+}
+
+func exec(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func syncNow(t *testing.T, c *Client) *Result {
+	t.Helper()
+	res, err := c.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// dump lists the boards of db by their content, not their row IDs.
+func dump(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`
+		SELECT b.name || ' ' || b.created_at, '', '' FROM boards b
+		UNION ALL
+		SELECT b.name || '/' || s.name, s.position, '' FROM swimlanes s JOIN boards b ON b.id = s.board_id
+		UNION ALL
+		SELECT b.name || '/' || s.name || '/' || l.name, l.position, l.wip_limit FROM lists l
+			JOIN swimlanes s ON s.id = l.swimlane_id JOIN boards b ON b.id = s.board_id
+		UNION ALL
+		SELECT b.name || '/' || s.name || '/' || l.name || '/' || c.title || ' ' || COALESCE(c.description, '') || ' ' || c.created_at,
+			c.position, hex(c.attachment) FROM cards c
+			JOIN lists l ON l.id = c.list_id JOIN swimlanes s ON s.id = l.swimlane_id JOIN boards b ON b.id = s.board_id
+		ORDER BY 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var path, position, extra string
+		if err := rows.Scan(&path, &position, &extra); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, strings.TrimSpace(fmt.Sprintf("%s %s %s", path, position, extra)))
+	}
+	return out
+}
+
+func assertSame(t *testing.T, a, b *sql.DB) {
+	t.Helper()
+	if da, db := dump(t, a), dump(t, b); !reflect.DeepEqual(da, db) {
+		t.Errorf("replicas differ:\n%q\n%q", da, db)
+	}
+}
+
+const sampleBoard = `
+	INSERT INTO boards (name, description, created_at) VALUES ('Project', 'Shared', '2024-01-02 03:04:05');
+	INSERT INTO swimlanes (board_id, name) VALUES (1, 'Lane');
+	INSERT INTO lists (swimlane_id, name, position, wip_limit) VALUES (1, 'Todo', 0, 3), (1, 'Done', 1, 0);
+	INSERT INTO cards (list_id, title, description, position, created_at, attachment) VALUES
+		(1, 'Write', 'Text', 0, '2024-01-02 03:04:05', X'89504E47'), (1, 'Review', NULL, 1, '2024-01-03 00:00:00', NULL);
+`
+
+func TestSyncCopiesBoards(t *testing.T) {
+	url := startServer(t, "")
+	a, ca := newReplica(t, url, sampleBoard)
+	b, cb := newReplica(t, url, "")
+
+	// 6 rows, and the merge states of 2 descriptions and 2 card orders
+	if res := syncNow(t, ca); res.Pushed != 10 || res.Pulled != 0 {
+		t.Errorf("first sync of a: %+v", res)
+	}
+	if res := syncNow(t, cb); res.Pulled != 10 || res.Pushed != 0 {
+		t.Errorf("first sync of b: %+v", res)
+	}
+	assertSame(t, a, b)
+	if len(dump(t, b)) != 6 {
+		t.Errorf("b: %q", dump(t, b))
+	}
+
+	// The rows applied by the sync are not changes of b, and a sync
+	// without changes does not write
+	var seq int
+	var name, path string
+	if err := b.QueryRow("PRAGMA database_list").Scan(&seq, &name, &path); err != nil {
+		t.Fatal(err)
+	}
+	gui, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gui.Close()
+	gui.SetMaxOpenConns(1)
+	w, err := store.NewWatcher(gui)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := syncNow(t, cb); res.Pushed != 0 || res.Pulled != 0 {
+		t.Errorf("second sync of b: %+v", res)
+	}
+	if changed, err := w.Changed(); changed || err != nil {
+		t.Errorf("idle sync changed the database: %v", err)
+	}
+	if res := syncNow(t, ca); res.Pushed != 0 || res.Pulled != 0 {
+		t.Errorf("second sync of a: %+v", res)
+	}
+}
+
+func TestSyncChangesAndDeletes(t *testing.T) {
+	url := startServer(t, "")
+	a, ca := newReplica(t, url, sampleBoard)
+	b, cb := newReplica(t, url, "")
+	syncNow(t, ca)
+	syncNow(t, cb)
+
+	// b edits and moves a card and adds one; a adds a list and deletes a
+	// card, as the Tcl/Tk GUI would
+	exec(t, b, `UPDATE cards SET title = 'Written', list_id = (SELECT id FROM lists WHERE name = 'Done') WHERE title = 'Write'`)
+	exec(t, b, `INSERT INTO cards (list_id, title) SELECT id, 'New' FROM lists WHERE name = 'Todo'`)
+	exec(t, a, `INSERT INTO lists (swimlane_id, name, position) VALUES (1, 'Doing', 2)`)
+	exec(t, a, `DELETE FROM cards WHERE title = 'Review'`)
+	syncNow(t, cb)
+	res := syncNow(t, ca)
+	syncNow(t, cb)
+	if len(res.Conflicts) != 0 {
+		t.Errorf("conflicts: %v", res.Conflicts)
+	}
+	assertSame(t, a, b)
+	want := []string{
+		"Project 2024-01-02 03:04:05",
+		"Project/Lane 0",
+		"Project/Lane/Doing 2 0",
+		"Project/Lane/Done 1 0",
+		"Project/Lane/Done/Written Text 2024-01-02 03:04:05 0 89504E47",
+		"Project/Lane/Todo 0 3",
+	}
+	got := dump(t, a)
+	// The new card has its own created_at
+	for i, line := range got {
+		if strings.HasPrefix(line, "Project/Lane/Todo/New") {
+			got = append(got[:i], got[i+1:]...)
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("a:\n%q\nwant\n%q", got, want)
+	}
+
+	// Deleting a board deletes its rows on the other replica
+	exec(t, b, "DELETE FROM boards")
+	syncNow(t, cb)
+	syncNow(t, ca)
+	for _, table := range []string{"boards", "swimlanes", "lists", "cards"} {
+		var n int
+		a.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n)
+		if n != 0 {
+			t.Errorf("%d %s left", n, table)
+		}
+	}
+}
+
+// change sets a card's title and gives the change a time, so that the
+// order of concurrent changes is known.
+func change(t *testing.T, db *sql.DB, title string, at time.Time) {
+	t.Helper()
+	exec(t, db, "UPDATE cards SET title = ? WHERE position = 0", title)
+	exec(t, db, "UPDATE sync_changes SET changed_at = ? WHERE tbl = 'cards'", at.UTC().Format(timeFormat))
+}
+
+func title(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	var s string
+	if err := db.QueryRow("SELECT title FROM cards WHERE position = 0").Scan(&s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestConflictPulled(t *testing.T) {
+	url := startServer(t, "")
+	a, ca := newReplica(t, url, sampleBoard)
+	b, cb := newReplica(t, url, "")
+	syncNow(t, ca)
+	syncNow(t, cb)
+
+	now := time.Now()
+	change(t, a, "Older", now)
+	change(t, b, "Newer", now.Add(time.Second))
+	syncNow(t, ca)
+	res := syncNow(t, cb)
+	if len(res.Conflicts) != 1 {
+		t.Fatalf("conflicts: %v", res.Conflicts)
+	}
+	c := res.Conflicts[0]
+	if c.Kept.Client != cb.ID() || c.Lost.Client != ca.ID() || c.Kept.Fields["title"] != "Newer" {
+		t.Errorf("conflict: %v", c)
+	}
+	if !strings.Contains(c.String(), `("Newer"), discarded the change of `+ca.ID()) {
+		t.Errorf("message: %s", c)
+	}
+	syncNow(t, ca)
+	if title(t, a) != "Newer" || title(t, b) != "Newer" {
+		t.Errorf("titles %q, %q", title(t, a), title(t, b))
+	}
+	assertSame(t, a, b)
+}
+
+func TestConflictPushed(t *testing.T) {
+	for _, aWins := range []bool{false, true} {
+		t.Run(fmt.Sprintf("a wins %v", aWins), func(t *testing.T) {
+			url := startServer(t, "")
+			a, ca := newReplica(t, url, sampleBoard)
+			b, cb := newReplica(t, url, "")
+			syncNow(t, ca)
+			syncNow(t, cb)
+
+			now := time.Now()
+			aTime, bTime := now, now.Add(time.Second)
+			if aWins {
+				aTime, bTime = bTime, aTime
+			}
+			change(t, a, "From a", aTime)
+			change(t, b, "From b", bTime)
+			syncNow(t, cb)
+			// a pushes without pulling b's change, as if both synced at
+			// once
+			res := &Result{}
+			if err := ca.push(res); err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Conflicts) != 1 {
+				t.Fatalf("conflicts: %v", res.Conflicts)
+			}
+			winner := "From b"
+			if aWins {
+				winner = "From a"
+			}
+			if got := res.Conflicts[0].Kept.Fields["title"]; got != winner {
+				t.Errorf("kept %v, want %q", got, winner)
+			}
+			syncNow(t, ca)
+			syncNow(t, cb)
+			if title(t, a) != winner || title(t, b) != winner {
+				t.Errorf("titles %q, %q, want %q", title(t, a), title(t, b), winner)
+			}
+			assertSame(t, a, b)
+		})
+	}
+}
+
+func TestDescriptionsMerge(t *testing.T) {
+	url := startServer(t, "")
+	a, ca := newReplica(t, url, sampleBoard)
+	b, cb := newReplica(t, url, "")
+	syncNow(t, ca)
+	syncNow(t, cb)
+
+	// Both edit the description offline; b also renames the card
+	exec(t, a, "UPDATE cards SET description = 'Text and more' WHERE title = 'Write'")
+	exec(t, b, "UPDATE cards SET description = 'Some Text', title = 'Draft' WHERE title = 'Write'")
+	syncNow(t, ca)
+	res := syncNow(t, cb)
+	syncNow(t, ca)
+	if len(res.Conflicts) != 0 {
+		t.Errorf("conflicts: %v", res.Conflicts)
+	}
+	for _, db := range []*sql.DB{a, b} {
+		var title, description string
+		if err := db.QueryRow("SELECT title, description FROM cards WHERE position = 0").Scan(&title, &description); err != nil {
+			t.Fatal(err)
+		}
+		if title != "Draft" || description != "Some Text and more" {
+			t.Errorf("%q: %q", title, description)
+		}
+	}
+	assertSame(t, a, b)
+}
+
+func TestCardOrderMerges(t *testing.T) {
+	url := startServer(t, "")
+	a, ca := newReplica(t, url, sampleBoard+`
+		INSERT INTO cards (list_id, title, position) VALUES (1, 'Test', 2), (1, 'Ship', 3);`)
+	b, cb := newReplica(t, url, "")
+	syncNow(t, ca)
+	syncNow(t, cb)
+
+	// a moves Ship to the top while b moves Write to the bottom
+	exec(t, a, "UPDATE cards SET position = CASE title WHEN 'Ship' THEN 0 ELSE position + 1 END")
+	exec(t, b, "UPDATE cards SET position = CASE title WHEN 'Write' THEN 3 ELSE position - 1 END")
+	syncNow(t, ca)
+	res := syncNow(t, cb)
+	syncNow(t, ca)
+	if len(res.Conflicts) != 0 {
+		t.Errorf("conflicts: %v", res.Conflicts)
+	}
+	want := []string{"Ship", "Review", "Test", "Write"}
+	for _, db := range []*sql.DB{a, b} {
+		var got []string
+		rows, err := db.Query("SELECT title FROM cards ORDER BY position")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var title string
+			rows.Scan(&title)
+			got = append(got, title)
+		}
+		rows.Close()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q, want %q", got, want)
+		}
+	}
+	assertSame(t, a, b)
+}
+
+func TestToken(t *testing.T) {
+	url := startServer(t, "secret")
+	_, c := newReplica(t, url, sampleBoard)
+	if _, err := c.Sync(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("sync without the token: %v", err)
+	}
+	c.token = "secret"
+	if res, err := c.Sync(); err != nil || res.Pushed != 10 {
+		t.Errorf("sync with the token: %+v, %v", res, err)
+	}
+}
+
+func TestPushTooLarge(t *testing.T) {
+	url := startServerWith(t, "", func(s *Server) { s.maxPush = 1000 })
+	_, c := newReplica(t, url, sampleBoard)
+	if _, err := c.Sync(); err == nil || !strings.Contains(err.Error(), "413") {
+		t.Errorf("sync of a push over the limit: %v", err)
+	}
+
+	url = startServerWith(t, "", func(s *Server) { s.maxPush = 1 << 20 })
+	_, c = newReplica(t, url, sampleBoard)
+	if res, err := c.Sync(); err != nil || res.Pushed != 10 {
+		t.Errorf("sync of a push under the limit: %+v, %v", res, err)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8547": true,
+		"[::1]:8547":     true,
+		"localhost:8547": true,
+		":8547":          false,
+		"0.0.0.0:8547":   false,
+		"10.0.0.5:8547":  false,
+		"example.com:80": false,
+		"127.0.0.1":      false,
+	} {
+		if got := Loopback(addr); got != want {
+			t.Errorf("Loopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package workspace finds the database file of the kanban programs and
+// keeps the per-user configuration with the recently opened databases.
+//
+// The database is, in this order: the -db flag, the KANBAN_DB environment
+// variable, the database of the configuration file, or wekan.db in the
+// user's data directory ($XDG_DATA_HOME/tcl-tk-kanban, by default
+// ~/.local/share/tcl-tk-kanban).
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	// EnvVar names the environment variable holding the database path.
+	EnvVar = "KANBAN_DB"
+	// DefaultName is the file name of the default database.
+	DefaultName = "wekan.db"
+	// MaxRecent is the number of databases kept in Config.Recent.
+	MaxRecent = 10
+
+	appDir = "tcl-tk-kanban"
+)
+
+// Config is the per-user configuration, stored as JSON in
+// $XDG_CONFIG_HOME/tcl-tk-kanban/config.json.
+type Config struct {
+	// Database is the database opened without -db or KANBAN_DB.
+	Database string `json:"database,omitempty"`
+	// Recent are the recently opened databases, most recent first.
+	Recent []string `json:"recent,omitempty"`
+}
+
+// ConfigPath returns the path of the configuration file.
+func ConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("config directory: %w", err)
+	}
+	return filepath.Join(dir, appDir, "config.json"), nil
+}
+
+// DataDir returns the directory of the default database:
+// $XDG_DATA_HOME/tcl-tk-kanban, or the platform's default data directory.
+func DataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appDir), nil
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return filepath.Join(dir, appDir), nil
+		}
+		return "", errors.New("data directory: %LocalAppData% is not set")
+	case "darwin", "ios":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("data directory: %w", err)
+		}
+		return filepath.Join(home, "Library", "Application Support", appDir), nil
+	default:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("data directory: %w", err)
+		}
+		return filepath.Join(home, ".local", "share", appDir), nil
+	}
+}
+
+// LoadConfig reads the configuration file. A missing file is an empty
+// configuration.
+func LoadConfig() (*Config, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the configuration file, creating its directory.
+func (c *Config) Save() error {
+	path, err := ConfigPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
+
+// AddRecent puts a database first in the recent list, dropping an older
+// entry for the same file and the entries beyond MaxRecent.
+func (c *Config) AddRecent(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	recent := []string{path}
+	for _, p := range c.Recent {
+		if p != path && len(recent) < MaxRecent {
+			recent = append(recent, p)
+		}
+	}
+	c.Recent = recent
+}
+
+// AddFlag defines the -db flag in fs; pass its value to Resolve.
+func AddFlag(fs *flag.FlagSet) *string {
+	return fs.String("db", "", "SQLite database file (default $"+EnvVar+", the configured database or "+DefaultName+" in the data directory)")
+}
+
+// Resolve returns the absolute path of the database to open, given the
+// value of the -db flag. The default database is created in the data
+// directory, which is created if needed; a wekan.db in the current
+// directory is used instead while there is none there, so that databases
+// of older versions keep working.
+func Resolve(flagPath string) (string, error) {
+	path := flagPath
+	if path == "" {
+		path = os.Getenv(EnvVar)
+	}
+	if path == "" {
+		c, err := LoadConfig()
+		if err != nil {
+			return "", err
+		}
+		path = c.Database
+	}
+	if path == "" {
+		dir, err := DataDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(dir, DefaultName)
+		if !exists(path) && exists(DefaultName) {
+			path = DefaultName
+		} else if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", fmt.Errorf("create data directory: %w", err)
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("database path %q: %w", path, err)
+	}
+	return abs, nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
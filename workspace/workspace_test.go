@@ -0,0 +1,97 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// isolate points the configuration and data directories and the current
+// directory to temporary directories.
+func isolate(t *testing.T) (configDir, dataDir, workDir string) {
+	t.Helper()
+	configDir, dataDir, workDir = t.TempDir(), t.TempDir(), t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("XDG_DATA_HOME", dataDir)
+	t.Setenv(EnvVar, "")
+	t.Chdir(workDir)
+	return configDir, dataDir, workDir
+}
+
+func TestResolve(t *testing.T) {
+	_, dataDir, workDir := isolate(t)
+	defaultPath := filepath.Join(dataDir, appDir, DefaultName)
+
+	got, err := Resolve("")
+	if err != nil || got != defaultPath {
+		t.Errorf("default: %q, %v, want %q", got, err, defaultPath)
+	}
+	if fi, err := os.Stat(filepath.Dir(defaultPath)); err != nil || !fi.IsDir() {
+		t.Errorf("data directory not created: %v", err)
+	}
+
+	// An old database in the current directory, until the default exists
+	os.WriteFile(DefaultName, nil, 0o644)
+	if got, _ := Resolve(""); got != filepath.Join(workDir, DefaultName) {
+		t.Errorf("with ./%s: %q", DefaultName, got)
+	}
+	os.WriteFile(defaultPath, nil, 0o644)
+	if got, _ := Resolve(""); got != defaultPath {
+		t.Errorf("with both: %q", got)
+	}
+
+	c := &Config{Database: "/srv/kanban/team.db"}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Resolve(""); got != "/srv/kanban/team.db" {
+		t.Errorf("configured: %q", got)
+	}
+	t.Setenv(EnvVar, "env.db")
+	if got, _ := Resolve(""); got != filepath.Join(workDir, "env.db") {
+		t.Errorf("environment: %q", got)
+	}
+	if got, _ := Resolve("/tmp/flag.db"); got != "/tmp/flag.db" {
+		t.Errorf("flag: %q", got)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	configDir, _, workDir := isolate(t)
+
+	c, err := LoadConfig()
+	if err != nil || !reflect.DeepEqual(c, &Config{}) {
+		t.Fatalf("missing config: %+v, %v", c, err)
+	}
+	for i := 0; i < MaxRecent+2; i++ {
+		c.AddRecent(fmt.Sprintf("/db/%d.db", i))
+	}
+	c.AddRecent("/db/5.db")
+	c.AddRecent("relative.db")
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(configDir, appDir, "config.json")); err != nil {
+		t.Error(err)
+	}
+
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(workDir, "relative.db"), "/db/5.db", "/db/11.db", "/db/10.db", "/db/9.db",
+		"/db/8.db", "/db/7.db", "/db/6.db", "/db/4.db", "/db/3.db"}
+	if !reflect.DeepEqual(loaded.Recent, want) {
+		t.Errorf("recent %q, want %q", loaded.Recent, want)
+	}
+
+	os.WriteFile(filepath.Join(configDir, appDir, "config.json"), []byte("{"), 0o644)
+	if _, err := LoadConfig(); err == nil {
+		t.Error("loaded a broken config")
+	}
+	if _, err := Resolve(""); err == nil {
+		t.Error("resolved with a broken config")
+	}
+}
@@ -2,22 +2,43 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
-	"github.com/xuri/excelize/v2"
 	_ "github.com/mattn/go-sqlite3"
+
+	"tcl-tk-kanban/export"
+	"tcl-tk-kanban/store"
+	"tcl-tk-kanban/workspace"
 )
 
 func main() {
-	if len(os.Args) != 3 {
-		fmt.Println("Usage: xlsx_exporter <boardId> <outputFile>")
+	if len(os.Args) > 1 && os.Args[1] == "search" {
+		search(os.Args[2:])
+		return
+	}
+
+	dbPath := workspace.AddFlag(flag.CommandLine)
+	layout := flag.String("layout", "flat", "spreadsheet layout: flat, hierarchical or board")
+	pageSize := flag.String("page-size", "A4", "PDF page size: "+strings.Join(export.PDFPageSizes, ", "))
+	landscape := flag.Bool("landscape", false, "PDF landscape orientation")
+	flag.Usage = func() {
+		fmt.Println("Usage: xlsx_exporter [-db file] [-layout flat] [-page-size A4] [-landscape] <boardId> <outputFile>")
+		fmt.Println("       xlsx_exporter search [-db file] [-board id] [-limit n] <text>")
+		fmt.Println("The output format is chosen by the file extension: " + strings.Join(export.Formats, ", "))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	boardIdStr := os.Args[1]
-	outputFile := os.Args[2]
+	boardIdStr := flag.Arg(0)
+	outputFile := flag.Arg(1)
 
 	boardId, err := strconv.Atoi(boardIdStr)
 	if err != nil {
@@ -26,86 +47,86 @@ func main() {
 	}
 
 	// Open database
-	db, err := sql.Open("sqlite3", "wekan.db")
+	db, err := openDatabase(*dbPath)
 	if err != nil {
 		fmt.Printf("Failed to open database: %v\n", err)
 		os.Exit(1)
 	}
 	defer db.Close()
 
-	// Create Excel file
-	f := excelize.NewFile()
-	defer f.Close()
-
-	streamWriter, err := f.NewStreamWriter("Sheet1")
-	if err != nil {
-		fmt.Printf("Failed to create stream writer: %v\n", err)
+	opts := export.Options{
+		Layout: export.Layout(*layout),
+		PDF:    export.PDFOptions{PageSize: *pageSize, Landscape: *landscape},
+	}
+	if err := export.ExportBoard(db, boardId, outputFile, opts); err != nil {
+		fmt.Printf("Failed to export board: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Write header
-	header := []interface{}{"Board", "Swimlane", "List", "Card Title", "Description", "Created At"}
-	if err := streamWriter.SetRow("A1", header); err != nil {
-		fmt.Printf("Failed to set header: %v\n", err)
+	fmt.Printf("Exported board %d to %s\n", boardId, outputFile)
+}
+
+// search prints the cards matching a text, best matches first, with the
+// matched words in brackets.
+func search(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	dbPath := workspace.AddFlag(fs)
+	boardID := fs.Int("board", 0, "search only this board (0 searches all boards)")
+	limit := fs.Int("limit", store.DefaultSearchLimit, "maximum number of results")
+	fs.Usage = func() {
+		fmt.Println("Usage: xlsx_exporter search [-db file] [-board id] [-limit n] <text>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
 		os.Exit(1)
 	}
 
-	// Query data
-	rows, err := db.Query(`
-		SELECT b.name AS board_name, s.name AS swimlane_name, l.name AS list_name,
-		       c.title, c.description, c.created_at, c.attachment
-		FROM boards b
-		JOIN swimlanes s ON b.id = s.board_id
-		JOIN lists l ON s.id = l.swimlane_id
-		JOIN cards c ON l.id = c.list_id
-		WHERE b.id = ?
-		ORDER BY s.position, l.position, c.position
-	`, boardId)
+	db, err := openDatabase(*dbPath)
 	if err != nil {
-		fmt.Printf("Failed to query data: %v\n", err)
+		fmt.Printf("Failed to open database: %v\n", err)
 		os.Exit(1)
 	}
-	defer rows.Close()
-
-	rowNum := 2
-	for rows.Next() {
-		var boardName, swimlaneName, listName, title, description, createdAt string
-		var attachment []byte
-		err := rows.Scan(&boardName, &swimlaneName, &listName, &title, &description, &createdAt, &attachment)
-		if err != nil {
-			fmt.Printf("Failed to scan row: %v\n", err)
-			continue
-		}
-
-		row := []interface{}{boardName, swimlaneName, listName, title, description, createdAt}
-		cell, _ := excelize.CoordinatesToCellName(1, rowNum)
-		if err := streamWriter.SetRow(cell, row); err != nil {
-			fmt.Printf("Failed to set row: %v\n", err)
-			continue
-		}
+	defer db.Close()
 
-		// If attachment exists, add as image (simplified, assuming PNG)
-		if len(attachment) > 0 {
-			imageCell, _ := excelize.CoordinatesToCellName(7, rowNum)
-			f.AddPictureFromBytes("Sheet1", imageCell, &excelize.Picture{
-				File:      attachment,
-				Extension: ".png",
-			})
+	results, err := store.Search(db, strings.Join(fs.Args(), " "), store.SearchOptions{BoardID: *boardID, Limit: *limit})
+	if err != nil {
+		fmt.Printf("Search failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(results) == 0 {
+		fmt.Println("No matching cards")
+		return
+	}
+	for i, r := range results {
+		fmt.Printf("%d. %s (card %d, rank %.3f)\n", i+1, r.Title, r.CardID, r.Rank)
+		fmt.Printf("   %s > %s > %s\n", r.Board, r.Swimlane, r.List)
+		if r.Snippet != "" {
+			fmt.Printf("   %s\n", strings.ReplaceAll(r.Snippet, "\n", " "))
 		}
-
-		rowNum++
 	}
-
-	if err := streamWriter.Flush(); err != nil {
-		fmt.Printf("Failed to flush stream: %v\n", err)
-		os.Exit(1)
+}
+
+// openDatabase opens the database named by the -db flag, see
+// workspace.Resolve. Unlike the GUI it neither creates a missing file nor
+// migrates an older database, which it refuses instead, since exports and
+// searches should not change the databases they read.
+func openDatabase(dbFlag string) (*sql.DB, error) {
+	path, err := workspace.Resolve(dbFlag)
+	if err != nil {
+		return nil, err
 	}
-
-	// Save file
-	if err := f.SaveAs(outputFile); err != nil {
-		fmt.Printf("Failed to save file: %v\n", err)
-		os.Exit(1)
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
 	}
-
-	fmt.Printf("Exported board %d to %s\n", boardId, outputFile)
-}
\ No newline at end of file
+	db, err := store.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.CheckSchemaVersion(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
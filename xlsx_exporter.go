@@ -1,26 +1,39 @@
 package main
 
 import (
-	"database/sql"
+	"flag"
 	"fmt"
 	"os"
 	"log"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/xuri/excelize/v2"
+
+	"tcl-tk-kanban/store"
+	"tcl-tk-kanban/workspace"
 )
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: xlsx_exporter <boardId> <output.xlsx>")
+	dbFlag := workspace.AddFlag(flag.CommandLine)
+	flag.Parse()
+	if flag.NArg() < 2 {
+		fmt.Println("Usage: xlsx_exporter [-db file] <boardId> <output.xlsx>")
 		os.Exit(1)
 	}
-	boardId := os.Args[1]
-	output := os.Args[2]
-	db, err := sql.Open("sqlite3", "wekan.db")
+	boardId := flag.Arg(0)
+	output := flag.Arg(1)
+	dbPath, err := workspace.Resolve(*dbFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := store.Open(dbPath)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
+	// Exports do not migrate the databases they read
+	if err := store.CheckSchemaVersion(db); err != nil {
+		log.Fatalf("%s: %v", dbPath, err)
+	}
 
 	f := excelize.NewFile()
 	streamWriter, err := f.NewStreamWriter("Sheet1")
@@ -56,7 +69,7 @@ func main() {
 			streamWriter.SetRow(fmt.Sprintf("B%d", row), []interface{}{"List:", listName})
 			row++
 			// Cards
-			cardRows, _ := db.Query("SELECT title, description, attachment FROM cards WHERE list_id = ? ORDER BY position", listId)
+			cardRows, _ := db.Query("SELECT title, description, attachment FROM cards WHERE list_id = ? AND COALESCE(archived, 0) = 0 ORDER BY position", listId)
 			defer cardRows.Close()
 			for cardRows.Next() {
 				var cardTitle, cardDesc string
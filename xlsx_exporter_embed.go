@@ -1,97 +1,359 @@
 // xlsx_exporter_embed.go
-// Go shared library for embedding XLSX export into TclKit .kit file
-// Exports a C function for Tcl to call
+// Go shared library for embedding the Kanban board logic into TclKit .kit
+// files and other C programs.
+//
+// Build with:
+//
+//	go build -buildmode=c-shared -o xlsx_exporter_embed.so xlsx_exporter_embed.go
+//
+// The documented C header kanban_api.h is generated from this file with
+// go generate xlsx_exporter_embed.go.
+//
+// Every function takes the path of the SQLite database to use. Functions
+// returning int return 0 (or a new ID) on success and -1 on failure; the
+// error message is then available from KanbanLastError. Strings returned by
+// the library are allocated with malloc and must be released with KanbanFree.
 
 package main
 
+//go:generate go run gen_header.go
+
+/*
+#include <stdlib.h>
+*/
 import "C"
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"unsafe"
 
-	"github.com/xuri/excelize/v2"
 	_ "github.com/mattn/go-sqlite3"
+
+	"tcl-tk-kanban/export"
+	"tcl-tk-kanban/store"
+	"tcl-tk-kanban/workspace"
 )
 
-//export ExportBoardToXLSX
-func ExportBoardToXLSX(boardId C.int, outputFile *C.char) C.int {
-	boardID := int(boardId)
-	outFile := C.GoString(outputFile)
+var (
+	lastErrorMu sync.Mutex
+	lastError   string
+)
+
+// setError records err as the last error and returns -1 so that callers can
+// write `return setError(err)`.
+func setError(err error) C.int {
+	lastErrorMu.Lock()
+	lastError = err.Error()
+	lastErrorMu.Unlock()
+	return -1
+}
+
+func clearError() {
+	lastErrorMu.Lock()
+	lastError = ""
+	lastErrorMu.Unlock()
+}
+
+// openDB opens the database at path with store.Open and creates or
+// migrates the tables with store.Init. Foreign keys are enforced so that
+// deletes cascade to swimlanes, lists and cards.
+func openDB(path *C.char) (*sql.DB, error) {
+	if path == nil {
+		return nil, fmt.Errorf("database path is NULL")
+	}
+	db, err := store.Open(C.GoString(path))
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Init(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
 
-	// Open database
-	db, err := sql.Open("sqlite3", "wekan.db")
+// withDB opens the database, runs fn and records its error. fn returns the
+// value to hand back to C on success.
+func withDB(path *C.char, fn func(db *sql.DB) (int, error)) C.int {
+	db, err := openDB(path)
 	if err != nil {
-		return -1
+		return setError(err)
 	}
 	defer db.Close()
+	n, err := fn(db)
+	if err != nil {
+		return setError(err)
+	}
+	clearError()
+	return C.int(n)
+}
 
-	// Create Excel file
-	f := excelize.NewFile()
-	defer f.Close()
-
-	streamWriter, err := f.NewStreamWriter("Sheet1")
+// insertID runs an INSERT and returns the new row ID. The child inserts
+// select from their parent table, so no row means the parent is missing.
+func insertID(db *sql.DB, parent string, parentID C.int, query string, args ...any) (int, error) {
+	res, err := db.Exec(query, args...)
 	if err != nil {
-		return -1
-	}
-
-	// Write header
-	header := []interface{}{"Board", "Swimlane", "List", "Card Title", "Description", "Created At"}
-	if err := streamWriter.SetRow("A1", header); err != nil {
-		return -1
-	}
-
-	// Query data
-	rows, err := db.Query(`
-		SELECT b.name AS board_name, s.name AS swimlane_name, l.name AS list_name,
-		       c.title, c.description, c.created_at, c.attachment
-		FROM boards b
-		JOIN swimlanes s ON b.id = s.board_id
-		JOIN lists l ON s.id = l.swimlane_id
-		JOIN cards c ON l.id = c.list_id
-		WHERE b.id = ?
-		ORDER BY s.position, l.position, c.position
-	`, boardID)
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, fmt.Errorf("%s %d not found", parent, int(parentID))
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// execOne runs an UPDATE or DELETE that must affect exactly one row.
+func execOne(db *sql.DB, what string, id C.int, query string, args ...any) (int, error) {
+	res, err := db.Exec(query, args...)
 	if err != nil {
-		return -1
+		return 0, err
 	}
-	defer rows.Close()
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, fmt.Errorf("%s %d not found", what, int(id))
+	}
+	return 0, nil
+}
 
-	rowNum := 2
-	for rows.Next() {
-		var boardName, swimlaneName, listName, title, description, createdAt string
-		var attachment []byte
-		err := rows.Scan(&boardName, &swimlaneName, &listName, &title, &description, &createdAt, &attachment)
-		if err != nil {
-			continue
+// jsonResult marshals v into a malloc'ed C string, or records the error and
+// returns NULL.
+func jsonResult(v any, err error) *C.char {
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(v); err == nil {
+			clearError()
+			return C.CString(string(data))
 		}
+	}
+	setError(err)
+	return nil
+}
 
-		row := []interface{}{boardName, swimlaneName, listName, title, description, createdAt}
-		cell, _ := excelize.CoordinatesToCellName(1, rowNum)
-		if err := streamWriter.SetRow(cell, row); err != nil {
-			continue
-		}
+// KanbanLastError returns the message of the last failed call, or NULL if
+// the last call succeeded. Release the string with KanbanFree.
+//
+//export KanbanLastError
+func KanbanLastError() *C.char {
+	lastErrorMu.Lock()
+	defer lastErrorMu.Unlock()
+	if lastError == "" {
+		return nil
+	}
+	return C.CString(lastError)
+}
 
-		// If attachment exists, add as image
-		if len(attachment) > 0 {
-			imageCell, _ := excelize.CoordinatesToCellName(7, rowNum)
-			f.AddPictureFromBytes("Sheet1", imageCell, &excelize.Picture{
-				File:      attachment,
-				Extension: ".png",
-			})
+// KanbanFree releases a string returned by the library.
+//
+//export KanbanFree
+func KanbanFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+// KanbanExportBoard writes a board to outputFile. The format is chosen by the
+// extension: .xlsx, .ods, .pdf or .json. layout is "flat", "hierarchical" or
+// "board" for spreadsheets; NULL or "" selects flat. Returns 0 or -1.
+//
+//export KanbanExportBoard
+func KanbanExportBoard(dbPath *C.char, boardID C.int, outputFile *C.char, layout *C.char) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		opts := export.Options{PDF: export.PDFOptions{PageSize: "A4"}}
+		if layout != nil {
+			opts.Layout = export.Layout(C.GoString(layout))
 		}
+		return 0, export.ExportBoard(db, int(boardID), C.GoString(outputFile), opts)
+	})
+}
 
-		rowNum++
-	}
+// KanbanImportBoard reads a board written by KanbanExportBoard in JSON
+// format and stores it as a new board. Returns the new board ID or -1.
+//
+//export KanbanImportBoard
+func KanbanImportBoard(dbPath *C.char, inputFile *C.char) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return export.ImportBoardFromJSON(db, C.GoString(inputFile))
+	})
+}
 
-	if err := streamWriter.Flush(); err != nil {
-		return -1
+// KanbanListBoards returns a JSON array of {"id", "name", "description"}
+// objects ordered by name, or NULL on error. Release with KanbanFree.
+//
+//export KanbanListBoards
+func KanbanListBoards(dbPath *C.char) *C.char {
+	type board struct {
+		ID          int    `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	db, err := openDB(dbPath)
+	if err != nil {
+		return jsonResult(nil, err)
 	}
+	defer db.Close()
+	rows, err := db.Query("SELECT id, name, COALESCE(description, '') FROM boards ORDER BY name")
+	if err != nil {
+		return jsonResult(nil, err)
+	}
+	defer rows.Close()
+	boards := []board{}
+	for rows.Next() {
+		var b board
+		if err := rows.Scan(&b.ID, &b.Name, &b.Description); err != nil {
+			return jsonResult(nil, err)
+		}
+		boards = append(boards, b)
+	}
+	return jsonResult(boards, rows.Err())
+}
 
-	// Save file
-	if err := f.SaveAs(outFile); err != nil {
-		return -1
+// KanbanGetBoard returns a board with its swimlanes, lists and cards as JSON,
+// in the same format as a .json export, or NULL on error. Release with
+// KanbanFree.
+//
+//export KanbanGetBoard
+func KanbanGetBoard(dbPath *C.char, boardID C.int) *C.char {
+	db, err := openDB(dbPath)
+	if err != nil {
+		return jsonResult(nil, err)
 	}
+	defer db.Close()
+	return jsonResult(export.LoadBoard(db, int(boardID)))
+}
+
+// KanbanCreateBoard creates a board and returns its ID, or -1.
+//
+//export KanbanCreateBoard
+func KanbanCreateBoard(dbPath *C.char, name *C.char, description *C.char) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return insertID(db, "", 0, "INSERT INTO boards (name, description) VALUES (?, ?)", C.GoString(name), C.GoString(description))
+	})
+}
+
+// KanbanUpdateBoard renames a board and replaces its description. Returns 0
+// or -1.
+//
+//export KanbanUpdateBoard
+func KanbanUpdateBoard(dbPath *C.char, boardID C.int, name *C.char, description *C.char) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return execOne(db, "board", boardID, "UPDATE boards SET name = ?, description = ? WHERE id = ?",
+			C.GoString(name), C.GoString(description), int(boardID))
+	})
+}
 
-	return 0
+// KanbanDeleteBoard deletes a board with all of its swimlanes, lists and
+// cards. Returns 0 or -1.
+//
+//export KanbanDeleteBoard
+func KanbanDeleteBoard(dbPath *C.char, boardID C.int) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return execOne(db, "board", boardID, "DELETE FROM boards WHERE id = ?", int(boardID))
+	})
+}
+
+// KanbanCreateSwimlane appends a swimlane to a board and returns its ID, or -1.
+//
+//export KanbanCreateSwimlane
+func KanbanCreateSwimlane(dbPath *C.char, boardID C.int, name *C.char) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return insertID(db, "board", boardID, `INSERT INTO swimlanes (board_id, name, position)
+			SELECT id, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM swimlanes WHERE board_id = boards.id)
+			FROM boards WHERE id = ?`, C.GoString(name), int(boardID))
+	})
+}
+
+// KanbanUpdateSwimlane renames a swimlane. Returns 0 or -1.
+//
+//export KanbanUpdateSwimlane
+func KanbanUpdateSwimlane(dbPath *C.char, swimlaneID C.int, name *C.char) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return execOne(db, "swimlane", swimlaneID, "UPDATE swimlanes SET name = ? WHERE id = ?", C.GoString(name), int(swimlaneID))
+	})
+}
+
+// KanbanDeleteSwimlane deletes a swimlane with its lists and cards. Returns 0
+// or -1.
+//
+//export KanbanDeleteSwimlane
+func KanbanDeleteSwimlane(dbPath *C.char, swimlaneID C.int) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return execOne(db, "swimlane", swimlaneID, "DELETE FROM swimlanes WHERE id = ?", int(swimlaneID))
+	})
+}
+
+// KanbanCreateList appends a list to a swimlane and returns its ID, or -1.
+//
+//export KanbanCreateList
+func KanbanCreateList(dbPath *C.char, swimlaneID C.int, name *C.char) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return insertID(db, "swimlane", swimlaneID, `INSERT INTO lists (swimlane_id, name, position)
+			SELECT id, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM lists WHERE swimlane_id = swimlanes.id)
+			FROM swimlanes WHERE id = ?`, C.GoString(name), int(swimlaneID))
+	})
+}
+
+// KanbanUpdateList renames a list. Returns 0 or -1.
+//
+//export KanbanUpdateList
+func KanbanUpdateList(dbPath *C.char, listID C.int, name *C.char) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return execOne(db, "list", listID, "UPDATE lists SET name = ? WHERE id = ?", C.GoString(name), int(listID))
+	})
+}
+
+// KanbanDeleteList deletes a list with its cards. Returns 0 or -1.
+//
+//export KanbanDeleteList
+func KanbanDeleteList(dbPath *C.char, listID C.int) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return execOne(db, "list", listID, "DELETE FROM lists WHERE id = ?", int(listID))
+	})
+}
+
+// KanbanCreateCard appends a card to a list and returns its ID, or -1.
+//
+//export KanbanCreateCard
+func KanbanCreateCard(dbPath *C.char, listID C.int, title *C.char, description *C.char) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return insertID(db, "list", listID, `INSERT INTO cards (list_id, title, description, position)
+			SELECT id, ?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM cards WHERE list_id = lists.id)
+			FROM lists WHERE id = ?`, C.GoString(title), C.GoString(description), int(listID))
+	})
+}
+
+// KanbanUpdateCard replaces the title and description of a card. Returns 0
+// or -1.
+//
+//export KanbanUpdateCard
+func KanbanUpdateCard(dbPath *C.char, cardID C.int, title *C.char, description *C.char) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return execOne(db, "card", cardID, "UPDATE cards SET title = ?, description = ? WHERE id = ?",
+			C.GoString(title), C.GoString(description), int(cardID))
+	})
+}
+
+// KanbanDeleteCard deletes a card. Returns 0 or -1.
+//
+//export KanbanDeleteCard
+func KanbanDeleteCard(dbPath *C.char, cardID C.int) C.int {
+	return withDB(dbPath, func(db *sql.DB) (int, error) {
+		return execOne(db, "card", cardID, "DELETE FROM cards WHERE id = ?", int(cardID))
+	})
+}
+
+// ExportBoardToXLSX exports a board from the default database (KANBAN_DB,
+// the user's configured database or wekan.db in the data directory) using
+// the flat layout. Kept for kanban.tcl; new code should call
+// KanbanExportBoard. Returns 0 or -1.
+//
+//export ExportBoardToXLSX
+func ExportBoardToXLSX(boardId C.int, outputFile *C.char) C.int {
+	path, err := workspace.Resolve("")
+	if err != nil {
+		return setError(err)
+	}
+	dbPath := C.CString(path)
+	defer C.free(unsafe.Pointer(dbPath))
+	return KanbanExportBoard(dbPath, boardId, outputFile, nil)
 }
 
 func main() {}